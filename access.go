@@ -0,0 +1,158 @@
+/**********************************************************************
+ * Copyright (c) 2017 Jayamine Alupotha                               *
+ * Distributed under the MIT software license, see the accompanying   *
+ * file COPYING or http://www.opensource.org/licenses/mit-license.php.*
+ **********************************************************************/
+
+package txhelper
+
+import (
+	"math"
+	"math/rand"
+)
+
+// AccessPattern chooses the inputs of random transactions. Candidates are ordered by their creation, i.e., account
+// ids in account models and unspent outputs (the oldest first) in UTXO models. Without an access pattern, inputs are
+// chosen in round-robin manner.
+type AccessPattern interface {
+	// Name returns a short name of the access pattern
+	Name() string
+	// Choose returns the index of the next input in [0, n)
+	Choose(r *rand.Rand, n int) int
+}
+
+// UniformAccess chooses every candidate with the same probability
+type UniformAccess struct{}
+
+func (UniformAccess) Name() string {
+	return "uniform"
+}
+
+func (UniformAccess) Choose(r *rand.Rand, n int) int {
+	return r.Intn(n)
+}
+
+// ZipfAccess chooses the k-th candidate with a probability proportional to (V + k)^(-S). S > 1 sets the skew and
+// V >= 1 flattens the head of the distribution.
+type ZipfAccess struct {
+	S float64
+	V float64
+}
+
+func (ZipfAccess) Name() string {
+	return "zipf"
+}
+
+func (d ZipfAccess) Choose(r *rand.Rand, n int) int {
+	zipf := rand.NewZipf(r, math.Max(d.S, 1.0001), math.Max(d.V, 1), uint64(n-1))
+	return int(zipf.Uint64())
+}
+
+// HotspotAccess chooses one of the first HotFraction of candidates with the probability HotProbability, e.g., 20% of
+// accounts receive 80% of transactions with HotFraction = 0.2 and HotProbability = 0.8
+type HotspotAccess struct {
+	HotFraction    float64
+	HotProbability float64
+}
+
+func (HotspotAccess) Name() string {
+	return "hotspot"
+}
+
+func (d HotspotAccess) Choose(r *rand.Rand, n int) int {
+	hot := int(math.Ceil(d.HotFraction * float64(n)))
+	if hot < 1 {
+		hot = 1
+	}
+	if hot >= n {
+		return r.Intn(n)
+	}
+	if r.Float64() < d.HotProbability {
+		return r.Intn(hot)
+	}
+	return hot + r.Intn(n-hot)
+}
+
+// LocalityAccess chooses one of the last Window chosen candidates again with the probability Probability, and other
+// candidates uniformly. In UTXO models, recently chosen indexes point to outputs created around the same time as the
+// recently spent ones.
+type LocalityAccess struct {
+	Probability float64
+	Window      int
+	recent      []int
+}
+
+func (*LocalityAccess) Name() string {
+	return "locality"
+}
+
+func (d *LocalityAccess) Choose(r *rand.Rand, n int) int {
+	choice := -1
+	if len(d.recent) > 0 && r.Float64() < d.Probability {
+		if recent := d.recent[r.Intn(len(d.recent))]; recent < n {
+			choice = recent
+		}
+	}
+	if choice < 0 {
+		choice = r.Intn(n)
+	}
+	if d.Window > 0 {
+		if len(d.recent) == d.Window {
+			d.recent = d.recent[1:]
+		}
+		d.recent = append(d.recent, choice)
+	}
+	return choice
+}
+
+// chooseDistinct returns min(k, n) different candidates in [0, n) chosen by the access pattern. If the pattern
+// repeatedly chooses taken candidates, the next free candidate is taken.
+func (ctx *ExeContext) chooseDistinct(n int, k int) []int {
+	if k > n {
+		k = n
+	}
+	taken := make(map[int]bool, k)
+	choices := make([]int, k)
+	for i := 0; i < k; i++ {
+		choice := ctx.access.Choose(ctx.rand, n)
+		for try := 0; taken[choice] && try < 10; try++ {
+			choice = ctx.access.Choose(ctx.rand, n)
+		}
+		for taken[choice] {
+			choice = (choice + 1) % n
+		}
+		taken[choice] = true
+		choices[i] = choice
+	}
+	return choices
+}
+
+// nextUtxoInput returns the id of the next output to spend in UTXO models
+func (ctx *ExeContext) nextUtxoInput() (int, bool) {
+	if ctx.access == nil {
+		if ctx.outputPointer <= ctx.inputPointer {
+			return -1, false
+		}
+		ctx.inputPointer++
+		return ctx.inputPointer - 1, true
+	}
+	if len(ctx.unspent) == 0 {
+		return -1, false
+	}
+	i := ctx.access.Choose(ctx.rand, len(ctx.unspent))
+	return ctx.spendUnspent(i), true
+}
+
+// accountInputs returns ids of k existing accounts to update in account models
+func (ctx *ExeContext) accountInputs(k int) []int {
+	if ctx.access != nil {
+		return ctx.chooseDistinct(ctx.CurrentUsers, k)
+	}
+	ids := make([]int, k)
+	id := ctx.rand.Intn(0xff)
+	for i := 0; i < k; i++ {
+		id += 1
+		ids[i] = id % ctx.CurrentUsers
+	}
+	return ids
+}
@@ -0,0 +1,134 @@
+package txhelper
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestAccessPatterns(tester *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	n := 100
+	draws := 20000
+	patterns := []AccessPattern{UniformAccess{}, ZipfAccess{S: 1.5, V: 1}, HotspotAccess{HotFraction: 0.2, HotProbability: 0.8},
+		&LocalityAccess{Probability: 0.9, Window: 4}}
+	counts := make([][]int, len(patterns))
+	for i, p := range patterns {
+		counts[i] = make([]int, n)
+		for j := 0; j < draws; j++ {
+			choice := p.Choose(r, n)
+			if choice < 0 || choice >= n {
+				tester.Fatal("invalid choice:", p.Name(), choice)
+			}
+			counts[i][choice]++
+		}
+	}
+	if counts[0][0] > draws/n*2 {
+		tester.Fatal("uniform access is skewed:", counts[0][0])
+	}
+	if counts[1][0] < draws/4 || counts[1][0] < counts[1][n-1]*10 {
+		tester.Fatal("zipf access is not skewed:", counts[1][0], counts[1][n-1])
+	}
+	hot := 0
+	for j := 0; j < n/5; j++ {
+		hot += counts[2][j]
+	}
+	if hot < draws*75/100 || hot > draws*85/100 {
+		tester.Fatal("hotspot access doesn't follow the hot probability:", hot)
+	}
+
+	// locality repeats recent choices
+	p := &LocalityAccess{Probability: 0.9, Window: 4}
+	repeats := 0
+	last := make([]int, 0, 4)
+	for j := 0; j < 1000; j++ {
+		choice := p.Choose(r, n)
+		for _, recent := range last {
+			if recent == choice {
+				repeats++
+				break
+			}
+		}
+		if len(last) == 4 {
+			last = last[1:]
+		}
+		last = append(last, choice)
+	}
+	if repeats < 850 {
+		tester.Fatal("locality access doesn't repeat recent choices:", repeats)
+	}
+
+	ctx := ExeContext{rand: r, access: ZipfAccess{S: 3, V: 1}}
+	choices := ctx.chooseDistinct(5, 7)
+	taken := make(map[int]bool)
+	for _, choice := range choices {
+		taken[choice] = true
+	}
+	if len(choices) != 5 || len(taken) != 5 {
+		tester.Fatal("choices are not distinct:", choices)
+	}
+}
+
+func TestAccessPatternPeers(tester *testing.T) {
+	patterns := []func() AccessPattern{
+		func() AccessPattern { return UniformAccess{} },
+		func() AccessPattern { return ZipfAccess{S: 1.2, V: 1} },
+		func() AccessPattern { return HotspotAccess{HotFraction: 0.2, HotProbability: 0.8} },
+		func() AccessPattern { return &LocalityAccess{Probability: 0.5, Window: 3} },
+	}
+	for i := 1; i <= 6; i++ {
+		for _, pattern := range patterns {
+			ctx := newTestContext(tester, 150, 1, i, 1, 32, 10, 4, 5, 1, false, 2)
+			ctx.testPeerTransactions(10, tester, WithAccessPattern(pattern()))
+		}
+	}
+}
+
+func TestAccountHistories(tester *testing.T) {
+	totalUsers := 20
+	opts := []Option{WithAccessPattern(HotspotAccess{HotFraction: 0.2, HotProbability: 0.9}), WithSeed(5)}
+	client := newTestContext(tester, 151, 1, 6, 1, 32, totalUsers, 3, 4, 1, false, 2, opts...)
+	peer := newTestContext(tester, 151, 2, 6, 1, 32, totalUsers, 3, 4, 1, false, 2, opts...)
+	for i := 0; i < 40; i++ {
+		tx, err := client.RandomTransaction()
+		if err != nil {
+			tester.Fatal("couldn't create tx:", err)
+		}
+		if err = client.UpdateAppDataClient(&tx.Data); err != nil {
+			tester.Fatal("could not update the client:", err)
+		}
+		var tx1 Transaction
+		if err = peer.FromBytes(client.ToBytes(tx), &tx1); err != nil {
+			tester.Fatal("couldn't parse tx:", err)
+		}
+		if err = peer.VerifyIncomingTransaction(&tx1); err != nil {
+			tester.Fatal("invalid transaction:", err)
+		}
+		if err = peer.CommitTransaction(i, &tx1); err != nil {
+			tester.Fatal("could not commit tx in the peer:", err)
+		}
+	}
+
+	sizes, err := peer.AccountHistorySizes()
+	if err != nil {
+		tester.Fatal(err)
+	}
+	if len(sizes) < totalUsers {
+		tester.Fatal("invalid number of accounts:", len(sizes))
+	}
+	// the first accounts were hot for most of the time
+	hot, cold := 0, 0
+	hotAccounts := len(sizes) / 5
+	for i := range sizes {
+		if i < hotAccounts {
+			hot += sizes[i]
+		} else {
+			cold += sizes[i]
+		}
+	}
+	if hot*(len(sizes)-hotAccounts) <= 2*cold*hotAccounts {
+		tester.Fatal("hot accounts don't have longer histories:", sizes)
+	}
+	if _, err = client.AccountHistorySizes(); err != ErrNotPeer {
+		tester.Fatal("client returned histories:", err)
+	}
+}
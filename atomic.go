@@ -0,0 +1,68 @@
+/**********************************************************************
+ * Copyright (c) 2017 Jayamine Alupotha                               *
+ * Distributed under the MIT software license, see the accompanying   *
+ * file COPYING or http://www.opensource.org/licenses/mit-license.php.*
+ **********************************************************************/
+
+package txhelper
+
+// peerCounters are the in-memory parts of the peer state that follow the database
+type peerCounters struct {
+	totalTx        int
+	totalBlock     int
+	currentUsers   int
+	currentOutputs int
+	deletedOutputs int
+	lastBlockHash  []byte
+}
+
+func (ctx *ExeContext) savePeerCounters() peerCounters {
+	return peerCounters{
+		totalTx:        ctx.TotalTx,
+		totalBlock:     ctx.TotalBlock,
+		currentUsers:   ctx.CurrentUsers,
+		currentOutputs: ctx.CurrentOutputs,
+		deletedOutputs: ctx.DeletedOutputs,
+		lastBlockHash:  ctx.lastBlockHash,
+	}
+}
+
+func (ctx *ExeContext) restorePeerCounters(c peerCounters) {
+	ctx.TotalTx = c.totalTx
+	ctx.TotalBlock = c.totalBlock
+	ctx.CurrentUsers = c.currentUsers
+	ctx.CurrentOutputs = c.currentOutputs
+	ctx.DeletedOutputs = c.deletedOutputs
+	ctx.lastBlockHash = c.lastBlockHash
+}
+
+// atomic runs update and saves the counters in one atomic update of the store. If update fails, both the store and the
+// peer counters are rolled back. Updates inside update join the same atomic update.
+func (ctx *ExeContext) atomic(update func() error) error {
+	if ctx.inAtomic {
+		return update()
+	}
+	saved := ctx.savePeerCounters()
+	if err := ctx.store.Begin(); err != nil {
+		return err
+	}
+	ctx.inAtomic = true
+	defer func() {
+		ctx.inAtomic = false
+	}()
+
+	err := update()
+	if err == nil {
+		err = ctx.saveMeta()
+	}
+	if err != nil {
+		ctx.store.Rollback()
+		ctx.restorePeerCounters(saved)
+		return err
+	}
+	if err = ctx.store.Commit(); err != nil {
+		ctx.restorePeerCounters(saved)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package txhelper
+
+import (
+	"errors"
+	"testing"
+)
+
+// testPeerTx creates a transaction of the client and verifies it in the peer
+func testPeerTx(tester *testing.T, client *ExeContext, peer *ExeContext) *Transaction {
+	tx, err := client.RandomTransaction()
+	if err != nil {
+		tester.Fatal("couldn't create tx:", err)
+	}
+	if err = client.VerifyIncomingTransaction(tx); err != nil {
+		tester.Fatal("invalid transaction in the client:", err)
+	}
+	if err = client.UpdateAppDataClient(&tx.Data); err != nil {
+		tester.Fatal("could not update the client:", err)
+	}
+	received := new(Transaction)
+	if err = peer.FromBytes(client.ToBytes(tx), received); err != nil {
+		tester.Fatal("couldn't parse tx:", err)
+	}
+	if err = peer.VerifyIncomingTransaction(received); err != nil {
+		tester.Fatal("invalid transaction:", err)
+	}
+	return received
+}
+
+func TestAtomicCommit(tester *testing.T) {
+	for txType := 1; txType <= 6; txType++ {
+		shape := WithShapeDistribution(FixedShape{Inputs: 1, Outputs: 3})
+		client := newTestContext(tester, 260, 1, txType, 1, 32, 50, 1, 3, 1, false, 2, shape)
+		peer := newTestContext(tester, 260, 2, txType, 1, 32, 50, 1, 3, 1, false, 2)
+
+		for i := 0; i < 3; i++ {
+			if err := peer.CommitTransaction(peer.TotalTx, testPeerTx(tester, &client, &peer)); err != nil {
+				tester.Fatal("could not commit the transaction:", err, txType)
+			}
+		}
+
+		// the third output fails after the input and the other outputs are updated
+		state := peer.peerState(tester)
+		tx := testPeerTx(tester, &client, &peer)
+		header := tx.Data.Outputs[2].header
+		tx.Data.Outputs[2].header = tx.Data.Outputs[1].header
+		if err := peer.CommitTransaction(peer.TotalTx, tx); !errors.Is(err, ErrDatabase) {
+			tester.Fatal("duplicate output was committed:", err, txType)
+		}
+		if peer.peerState(tester) != state {
+			tester.Fatal("failed commit changed the peer:", txType)
+		}
+		tx.Data.Outputs[2].header = header
+		if err := peer.CommitTransaction(peer.TotalTx, tx); err != nil {
+			tester.Fatal("could not commit the transaction:", err, txType)
+		}
+
+		if err := peer.VerifyStoredAllTransaction(); err != nil {
+			tester.Fatal("invalid chain was created:", err, txType)
+		}
+
+		// the third transaction of a block fails
+		client = newTestContext(tester, 261, 1, txType, 1, 32, 50, 1, 3, 1, false, 2, shape)
+		peer = newTestContext(tester, 261, 2, txType, 1, 32, 50, 1, 3, 1, false, 2)
+		if err := peer.CommitBlock(testBlockFromClient(tester, &client, &peer, 3)); err != nil {
+			tester.Fatal("could not commit the block:", err, txType)
+		}
+		state = peer.peerState(tester)
+		block := testBlockFromClient(tester, &client, &peer, 3)
+		header = block.Txs[2].Data.Outputs[2].header
+		block.Txs[2].Data.Outputs[2].header = block.Txs[2].Data.Outputs[1].header
+		if err := peer.CommitBlock(block); !errors.Is(err, ErrDatabase) {
+			tester.Fatal("block with a duplicate output was committed:", err, txType)
+		}
+		if peer.peerState(tester) != state {
+			tester.Fatal("failed block commit changed the peer:", txType)
+		}
+		block.Txs[2].Data.Outputs[2].header = header
+		if err := peer.VerifyBlock(block); err != nil {
+			tester.Fatal("could not verify the block:", err, txType)
+		}
+		if err := peer.CommitBlock(block); err != nil {
+			tester.Fatal("could not commit the block:", err, txType)
+		}
+		if err := peer.VerifyStoredAllBlocks(); err != nil {
+			tester.Fatal("invalid chain was created:", err, txType)
+		}
+	}
+}
@@ -0,0 +1,327 @@
+/**********************************************************************
+ * Copyright (c) 2017 Jayamine Alupotha                               *
+ * Distributed under the MIT software license, see the accompanying   *
+ * file COPYING or http://www.opensource.org/licenses/mit-license.php.*
+ **********************************************************************/
+
+package txhelper
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"golang.org/x/crypto/sha3"
+)
+
+type Block struct {
+	Height     int            `json:"h"` // block height (the first block is 0)
+	ParentHash []byte         `json:"p"` // hash of the previous block
+	Root       []byte         `json:"r"` // root of the transaction identifiers
+	Txs        []*Transaction `json:"t"` // ordered transactions
+	Sig        Signature      `json:"s"` // aggregate signature of all transactions (only with block aggregation)
+}
+
+// blockSigs collects the signers and messages of headers that are verified with a block signature
+type blockSigs struct {
+	pks  []Pubkey
+	msgs [][]byte
+}
+
+func (s *blockSigs) add(pk *Pubkey, msg []byte) {
+	s.pks = append(s.pks, *pk)
+	s.msgs = append(s.msgs, msg)
+}
+
+// verify checks the aggregate signature of all collected headers. Without headers, the signature must be empty.
+func (s *blockSigs) verify(sigContext *SignatureContext, sig Signature) bool {
+	if len(s.pks) == 0 {
+		return len(sig) == 0
+	}
+	return sigContext.batchVerifyMultipleMsg(s.pks, s.msgs, sig)
+}
+
+// Hash returns hash(height, parent hash, root, number of transactions), followed by the block signature if any
+func (b *Block) Hash() []byte {
+	buf := make([]byte, 12)
+	binary.BigEndian.PutUint64(buf, uint64(b.Height))
+	binary.BigEndian.PutUint32(buf[8:], uint32(len(b.Txs)))
+
+	hasher := sha3.New256()
+	hasher.Write(buf[:8])
+	hasher.Write(b.ParentHash)
+	hasher.Write(b.Root)
+	hasher.Write(buf[8:])
+	hasher.Write(b.Sig)
+	return hasher.Sum(nil)
+}
+
+// computeMerkleRoot returns the root of the tree of the identifiers
+func computeMerkleRoot(identifiers [][]byte) []byte {
+	return NewMerkleTree(identifiers).Root()
+}
+
+// computeBlockRoot computes the root of the transaction identifiers. Transactions must be verified before.
+func (ctx *ExeContext) computeBlockRoot(txs []*Transaction) ([]byte, error) {
+	tree, err := ctx.blockTree(txs)
+	if err != nil {
+		return nil, err
+	}
+	return tree.Root(), nil
+}
+
+// resetTemps drops all temporary outputs, so the next block is verified against the committed state
+func (ctx *ExeContext) resetTemps() {
+	ctx.TempUsers = make(map[[sha256.Size]byte]TempUser)
+	ctx.TempPKs = make(map[[128]byte]int)
+	ctx.TempTxH = make(map[int][]byte)
+	ctx.CurrentOutputsWithTemp = ctx.CurrentOutputs
+	ctx.CurrentUsersWithTemp = ctx.CurrentUsers
+}
+
+// verifyBlockTransaction verifies a transaction against the committed state and the previous transactions of the
+// block, and adds its outputs to temps
+func (ctx *ExeContext) verifyBlockTransaction(txNum int, tx *Transaction, spent map[[sha256.Size]byte]bool) error {
+	for i := 0; i < len(tx.Data.Inputs); i++ {
+		if len(tx.Data.Inputs[i].Header) != sha256.Size {
+			return newTxError("check inputs", i, ErrMalformedEncoding)
+		}
+		if spent[getHeaderMapKey(tx.Data.Inputs[i].Header)] {
+			return newTxError("check inputs", i, ErrDoubleSpend)
+		}
+	}
+	if err := ctx.VerifyIncomingTransactionWithTemp(tx); err != nil {
+		return err
+	}
+	if err := ctx.applyTemp(txNum, tx); err != nil {
+		return err
+	}
+	for i := 0; i < len(tx.Data.Inputs); i++ {
+		spent[getHeaderMapKey(tx.Data.Inputs[i].Header)] = true
+	}
+	return nil
+}
+
+// ProposeBlock creates the next block from the given transactions. Invalid transactions and transactions conflicting
+// with earlier ones are skipped. The proposer can commit the returned block without verifying it again.
+func (ctx *ExeContext) ProposeBlock(txs []*Transaction) (*Block, error) {
+	if ctx.uType != 2 {
+		return nil, ErrNotPeer
+	}
+	ctx.resetTemps()
+
+	block := &Block{
+		Height:     ctx.TotalBlock,
+		ParentHash: make([]byte, len(ctx.lastBlockHash)),
+		Txs:        make([]*Transaction, 0, len(txs)),
+	}
+	copy(block.ParentHash, ctx.lastBlockHash)
+
+	spent := make(map[[sha256.Size]byte]bool)
+	sigs := make([]Signature, 0, len(txs))
+	for i := 0; i < len(txs); i++ {
+		if ctx.verifyBlockTransaction(ctx.TotalTx+len(block.Txs), txs[i], spent) != nil {
+			continue
+		}
+		if !ctx.blockAggregation {
+			block.Txs = append(block.Txs, txs[i])
+			continue
+		}
+		// signatures are moved to the block signature
+		sigs = append(sigs, txs[i].Txh.Kyber...)
+		stripped := *txs[i]
+		stripped.Txh.Kyber = nil
+		block.Txs = append(block.Txs, &stripped)
+	}
+
+	var err error
+	if len(sigs) > 0 {
+		block.Sig, err = ctx.sigContext.combineAggregates(sigs)
+		if err != nil {
+			ctx.resetTemps()
+			return nil, err
+		}
+	}
+
+	block.Root, err = ctx.computeBlockRoot(block.Txs)
+	if err != nil {
+		ctx.resetTemps()
+		return nil, err
+	}
+	return block, nil
+}
+
+// VerifyBlock verifies that the block extends the current chain and all of its transactions are valid in order
+func (ctx *ExeContext) VerifyBlock(b *Block) error {
+	if ctx.uType != 2 {
+		return ErrNotPeer
+	}
+	if b.Height != ctx.TotalBlock {
+		return newTxError("verify block height", b.Height, ErrInvalidBlock)
+	}
+	if !bytes.Equal(b.ParentHash, ctx.lastBlockHash) {
+		return newTxError("verify parent hash", b.Height, ErrInvalidBlock)
+	}
+	if !ctx.blockAggregation && len(b.Sig) != 0 {
+		return newTxError("verify block signature", b.Height, ErrInvalidBlock)
+	}
+	ctx.resetTemps()
+
+	// headers are collected and verified with the block signature
+	if ctx.blockAggregation {
+		ctx.blockSigs = &blockSigs{}
+		defer func() { ctx.blockSigs = nil }()
+	}
+	spent := make(map[[sha256.Size]byte]bool)
+	for i := 0; i < len(b.Txs); i++ {
+		if ctx.blockAggregation && len(b.Txs[i].Txh.Kyber) != 0 {
+			ctx.resetTemps()
+			return newTxError("verify block transaction", i, fmt.Errorf("%w: transactions of aggregated blocks can't carry signatures", ErrInvalidBlock))
+		}
+		if err := ctx.verifyBlockTransaction(ctx.TotalTx+i, b.Txs[i], spent); err != nil {
+			ctx.resetTemps()
+			return newTxError("verify block transaction", i, err)
+		}
+	}
+	if ctx.blockAggregation && !ctx.blockSigs.verify(ctx.sigContext, b.Sig) {
+		ctx.resetTemps()
+		return newTxError("verify block signature", b.Height, ErrInvalidSignature)
+	}
+
+	root, err := ctx.computeBlockRoot(b.Txs)
+	if err != nil {
+		ctx.resetTemps()
+		return err
+	}
+	if !bytes.Equal(root, b.Root) {
+		ctx.resetTemps()
+		return newTxError("verify block root", b.Height, ErrInvalidBlock)
+	}
+	return nil
+}
+
+// CommitBlock adds all transactions of a block, which was proposed or verified before, and saves the block in one SQL
+// transaction. If it fails, neither the database nor the peer counters are changed.
+func (ctx *ExeContext) CommitBlock(b *Block) error {
+	if ctx.uType != 2 {
+		return ErrNotPeer
+	}
+	if b.Height != ctx.TotalBlock || !bytes.Equal(b.ParentHash, ctx.lastBlockHash) {
+		return newTxError("commit block", b.Height, ErrInvalidBlock)
+	}
+
+	defer ctx.resetTemps()
+	return ctx.atomic(func() error {
+		firstTxn := ctx.TotalTx
+		for i := 0; i < len(b.Txs); i++ {
+			if err := ctx.applyTx(firstTxn+i, b.Txs[i]); err != nil {
+				return newTxError("commit block transaction", i, err)
+			}
+			if err := ctx.insertTxHeader(firstTxn+i, b.Txs[i]); err != nil {
+				return newTxError("commit block transaction", i, err)
+			}
+		}
+
+		hash := b.Hash()
+		ok, err := ctx.insertPeerBlock(b, hash, firstTxn)
+		if !ok {
+			return newTxError("commit block", b.Height, err)
+		}
+		ctx.TotalBlock += 1
+		ctx.lastBlockHash = hash
+		return nil
+	})
+}
+
+// storedTxIdentifier recomputes the identifier of a stored transaction
+func (ctx *ExeContext) storedTxIdentifier(txn int) ([]byte, error) {
+	tx, err := ctx.model.LoadTx(ctx, txn)
+	if err != nil {
+		return nil, newTxError("get stored tx", txn, err)
+	}
+	identifier, err := ctx.GetTxHeaderIdentifier(tx, nil)
+	if err != nil {
+		return nil, newTxError("get stored tx", txn, err)
+	}
+	return identifier, nil
+}
+
+// storedTxns returns transaction numbers in the order of blocks. Without blocks, it returns [0, TotalTx).
+func (ctx *ExeContext) storedTxns() ([]int, error) {
+	txns := make([]int, 0, ctx.TotalTx)
+	if ctx.TotalBlock == 0 {
+		for i := 0; i < ctx.TotalTx; i++ {
+			txns = append(txns, i)
+		}
+		return txns, nil
+	}
+	for height := 0; height < ctx.TotalBlock; height++ {
+		ok, _, _, _, _, firstTxn, txCount, err := ctx.getPeerBlock(height)
+		if !ok {
+			return nil, newTxError("get block", height, err)
+		}
+		for i := 0; i < txCount; i++ {
+			txns = append(txns, firstTxn+i)
+		}
+	}
+	if len(txns) != ctx.TotalTx {
+		return nil, newTxError("get blocks", -1, fmt.Errorf("%w: some transactions are not included in blocks", ErrInvalidChain))
+	}
+	return txns, nil
+}
+
+// VerifyStoredAllBlocks verifies the chain of stored blocks, their roots, and then all stored transactions
+func (ctx *ExeContext) VerifyStoredAllBlocks() error {
+	parent := make([]byte, sha256.Size)
+	for height := 0; height < ctx.TotalBlock; height++ {
+		ok, hash, parentHash, root, sig, firstTxn, txCount, err := ctx.getPeerBlock(height)
+		if !ok {
+			return newTxError("get block", height, err)
+		}
+		if !bytes.Equal(parent, parentHash) {
+			return newTxError("verify parent hash", height, ErrInvalidChain)
+		}
+
+		identifiers := make([][]byte, txCount)
+		for i := 0; i < txCount; i++ {
+			identifiers[i], err = ctx.storedTxIdentifier(firstTxn + i)
+			if err != nil {
+				return err
+			}
+		}
+		block := Block{Height: height, ParentHash: parentHash, Root: computeMerkleRoot(identifiers), Txs: make([]*Transaction, txCount), Sig: sig}
+		if !bytes.Equal(block.Root, root) {
+			return newTxError("verify block root", height, ErrInvalidChain)
+		}
+		if !bytes.Equal(block.Hash(), hash) {
+			return newTxError("verify block hash", height, ErrInvalidChain)
+		}
+		parent = hash
+	}
+	return ctx.VerifyStoredAllTransaction()
+}
+
+// verifyStoredBlockSigs verifies the headers collected in blockSigs with the signatures of all stored blocks
+func (ctx *ExeContext) verifyStoredBlockSigs() error {
+	sigs := make([]Signature, 0, ctx.TotalBlock)
+	for height := 0; height < ctx.TotalBlock; height++ {
+		ok, _, _, _, sig, _, _, err := ctx.getPeerBlock(height)
+		if !ok {
+			return newTxError("get block", height, err)
+		}
+		if len(sig) > 0 {
+			sigs = append(sigs, sig)
+		}
+	}
+	var sig Signature
+	var err error
+	if len(sigs) > 0 {
+		if sig, err = ctx.sigContext.combineAggregates(sigs); err != nil {
+			return newTxError("verify block signatures", -1, err)
+		}
+	}
+	if !ctx.blockSigs.verify(ctx.sigContext, sig) {
+		return newTxError("verify block signatures", -1, ErrInvalidSignature)
+	}
+	return nil
+}
@@ -0,0 +1,169 @@
+package txhelper
+
+import (
+	"errors"
+	"testing"
+)
+
+func (ctx *ExeContext) testBlocks(blockNum int, blockSize int, tester *testing.T, opts ...Option) (*ExeContext, *ExeContext) {
+	ctxClient := newTestContext(tester, ctx.exeId+120, 1, ctx.txModel, ctx.sigContext.SigType, ctx.payloadSize, ctx.TotalUsers, ctx.AverageInputMax, ctx.AverageOutputMax, ctx.distributionType, ctx.enableIndexing, ctx.PublicKeyReuse)
+	ctxProposer := newTestContext(tester, ctx.exeId+120, 2, ctx.txModel, ctx.sigContext.SigType, ctx.payloadSize, ctx.TotalUsers, ctx.AverageInputMax, ctx.AverageOutputMax, ctx.distributionType, ctx.enableIndexing, ctx.PublicKeyReuse, opts...)
+	ctxVerifier := newTestContext(tester, ctx.exeId+121, 2, ctx.txModel, ctx.sigContext.SigType, ctx.payloadSize, ctx.TotalUsers, ctx.AverageInputMax, ctx.AverageOutputMax, ctx.distributionType, ctx.enableIndexing, ctx.PublicKeyReuse, opts...)
+
+	for i := 0; i < blockNum; i++ {
+		txs := make([]*Transaction, blockSize)
+		received := make([]*Transaction, blockSize)
+		for j := 0; j < blockSize; j++ {
+			tx, err := ctxClient.RandomTransaction()
+			if err != nil {
+				tester.Fatal("couldn't create tx:", err, ctxClient.txModel)
+			}
+			if err := ctxClient.VerifyIncomingTransaction(tx); err != nil {
+				tester.Fatal("invalid transaction in the client:", err, ctx.txModel)
+			}
+			if err := ctxClient.UpdateAppDataClient(&tx.Data); err != nil {
+				tester.Fatal("could not update the client:", err, ctx.txModel)
+			}
+
+			txBytes := ctxClient.ToBytes(tx)
+			txs[j] = new(Transaction)
+			received[j] = new(Transaction)
+			if ctxProposer.FromBytes(txBytes, txs[j]) != nil || ctxVerifier.FromBytes(txBytes, received[j]) != nil {
+				tester.Fatal("couldn't parse tx:", ctx.txModel)
+			}
+		}
+
+		block, err := ctxProposer.ProposeBlock(txs)
+		if err != nil {
+			tester.Fatal("could not propose the block:", err, ctx.txModel)
+		}
+		if len(block.Txs) != blockSize {
+			tester.Fatal("valid transactions were skipped:", ctx.txModel, len(block.Txs))
+		}
+
+		// aggregated blocks carry transactions without signatures
+		if ctxProposer.blockAggregation {
+			for j := 0; j < blockSize; j++ {
+				received[j].Txh.Kyber = nil
+			}
+		}
+		receivedBlock := Block{Height: block.Height, ParentHash: block.ParentHash, Root: block.Root, Txs: received, Sig: block.Sig}
+		if err := ctxVerifier.VerifyBlock(&receivedBlock); err != nil {
+			tester.Fatal("could not verify the block:", err, ctx.txModel)
+		}
+
+		if err := ctxProposer.CommitBlock(block); err != nil {
+			tester.Fatal("could not commit the block in the proposer:", err, ctx.txModel)
+		}
+		if err := ctxVerifier.CommitBlock(&receivedBlock); err != nil {
+			tester.Fatal("could not commit the block in the verifier:", err, ctx.txModel)
+		}
+	}
+
+	if ctxProposer.TotalBlock != blockNum || ctxProposer.TotalTx != blockNum*blockSize {
+		tester.Fatal("invalid block count:", ctxProposer.TotalBlock, ctxProposer.TotalTx)
+	}
+	if string(ctxProposer.lastBlockHash) != string(ctxVerifier.lastBlockHash) {
+		tester.Fatal("peers have different chains:", ctx.txModel)
+	}
+
+	if err := ctxProposer.VerifyStoredAllBlocks(); err != nil {
+		tester.Fatal("invalid blockchain was created:", err, ctx.txModel)
+	}
+	if err := ctxVerifier.VerifyStoredAllBlocks(); err != nil {
+		tester.Fatal("invalid blockchain was created:", err, ctx.txModel)
+	}
+	return &ctxProposer, &ctxVerifier
+}
+
+func TestBlocks(tester *testing.T) {
+	totalUsers := 10
+	for i := 1; i <= 6; i++ {
+		ctx := newTestContext(tester, 100, 1, i, 1, 32, totalUsers, 4, 5, 1, false, 2)
+		ctx.testBlocks(3, 3, tester)
+		ctx = newTestContext(tester, 100, 1, i, 2, 32, totalUsers, 4, 5, 1, false, 2)
+		ctx.testBlocks(3, 3, tester)
+	}
+}
+
+func TestBlockConflicts(tester *testing.T) {
+	for i := 1; i <= 6; i++ {
+		ctxClient := newTestContext(tester, 230, 1, i, 1, 32, 10, 2, 3, 1, false, 2)
+		ctxPeer := newTestContext(tester, 230, 2, i, 1, 32, 10, 2, 3, 1, false, 2)
+
+		tx, err := ctxClient.RandomTransaction()
+		if err != nil {
+			tester.Fatal("couldn't create tx:", err, ctxClient.txModel)
+		}
+		txBytes := ctxClient.ToBytes(tx)
+		var tx1 Transaction
+		var tx2 Transaction
+		ctxPeer.FromBytes(txBytes, &tx1)
+		ctxPeer.FromBytes(txBytes, &tx2)
+
+		// the same transaction can only be included once
+		block, err := ctxPeer.ProposeBlock([]*Transaction{&tx1, &tx2})
+		if err != nil {
+			tester.Fatal("could not propose the block:", err, i)
+		}
+		if len(block.Txs) != 1 {
+			tester.Fatal("conflicting transactions were included:", i, len(block.Txs))
+		}
+
+		block.Root[0] ^= 1
+		if ctxPeer.VerifyBlock(block) == nil {
+			tester.Fatal("invalid root was accepted:", i)
+		}
+		block.Root[0] ^= 1
+		block.Height = 1
+		if ctxPeer.VerifyBlock(block) == nil {
+			tester.Fatal("invalid height was accepted:", i)
+		}
+	}
+}
+
+func TestBlockAggregation(tester *testing.T) {
+	for _, sigType := range []int32{2, 6, 7} {
+		for i := 1; i <= 4; i++ {
+			ctx := newTestContext(tester, 110, 1, i, sigType, 32, 10, 4, 5, 1, false, 2)
+			ctxProposer, ctxVerifier := ctx.testBlocks(3, 3, tester, WithBlockAggregation())
+
+			var sigBytes int
+			err := ctxVerifier.store.ForEachTxHeader(func(txh *StoredTxHeader) error {
+				sigBytes += len(txh.Sig)
+				return nil
+			})
+			if err != nil || sigBytes != 0 {
+				tester.Fatal("signatures of aggregated blocks were stored:", err, sigBytes)
+			}
+
+			// the block signature must cover all transactions
+			client := newTestContext(tester, 112, 1, i, sigType, 32, 10, 4, 5, 1, false, 2)
+			tx, err := client.RandomTransaction()
+			if err != nil {
+				tester.Fatal("couldn't create tx:", err)
+			}
+			block, err := ctxProposer.ProposeBlock([]*Transaction{tx})
+			if err != nil || len(block.Txs) != 1 || len(block.Txs[0].Txh.Kyber) != 0 || len(tx.Txh.Kyber) != 1 {
+				tester.Fatal("could not propose the block:", err)
+			}
+			empty := *block
+			empty.Sig = nil
+			if err = ctxVerifier.VerifyBlock(&empty); !errors.Is(err, ErrInvalidSignature) {
+				tester.Fatal("block without the signature was accepted:", err)
+			}
+			signed := *block
+			signed.Txs = []*Transaction{tx}
+			if err = ctxVerifier.VerifyBlock(&signed); !errors.Is(err, ErrInvalidBlock) {
+				tester.Fatal("transaction with a signature was accepted:", err)
+			}
+		}
+	}
+
+	if _, err := NewContext(113, 2, 1, 1, 32, 10, 4, 5, 1, false, 2, WithBlockAggregation()); !errors.Is(err, ErrInvalidConfig) {
+		tester.Fatal("block aggregation was allowed with Schnorr signatures:", err)
+	}
+	if _, err := NewContext(113, 2, 6, 2, 32, 10, 4, 5, 1, false, 2, WithBlockAggregation()); !errors.Is(err, ErrInvalidConfig) {
+		tester.Fatal("block aggregation was allowed with an origami model:", err)
+	}
+}
@@ -0,0 +1,386 @@
+/**********************************************************************
+ * Copyright (c) 2017 Jayamine Alupotha                               *
+ * Distributed under the MIT software license, see the accompanying   *
+ * file COPYING or http://www.opensource.org/licenses/mit-license.php.*
+ **********************************************************************/
+
+package txhelper
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	bls12381 "github.com/kilic/bls12-381"
+	"io"
+)
+
+// domain separation tags of the message augmentation scheme (draft-irtf-cfrg-bls-signature), where pk || msg is signed
+var (
+	blsMinPkDST  = []byte("BLS_SIG_BLS12381G2_XMD:SHA-256_SSWU_RO_AUG_")
+	blsMinSigDST = []byte("BLS_SIG_BLS12381G1_XMD:SHA-256_SSWU_RO_AUG_")
+)
+
+const blsSkSize = 32
+
+// blsSecretKey is a BLS12-381 secret key with its public key, which is a *bls12381.PointG1 (min-pk) or
+// *bls12381.PointG2 (min-sig)
+type blsSecretKey struct {
+	sk *bls12381.Fr
+	pk any
+}
+
+// newBlsSecret reads a non-zero secret key
+func newBlsSecret(rand io.Reader) (*bls12381.Fr, error) {
+	for {
+		sk, err := bls12381.NewFr().Rand(rand)
+		if err != nil {
+			return nil, err
+		}
+		if !sk.IsZero() {
+			return sk, nil
+		}
+	}
+}
+
+// blsTweak returns a - b, or a if b is nil
+func blsTweak(a []byte, b []byte) *bls12381.Fr {
+	s := bls12381.NewFr().FromBytes(a)
+	if b != nil {
+		s.Sub(s, bls12381.NewFr().FromBytes(b))
+	}
+	return s
+}
+
+// blsSumSecrets returns sum(keys) - sum(negKeys)
+func blsSumSecrets(keys []*SigKeyPair, negKeys []*SigKeyPair) *bls12381.Fr {
+	sk := bls12381.NewFr().Set(keys[0].key.(*blsSecretKey).sk)
+	for i := 1; i < len(keys); i++ {
+		sk.Add(sk, keys[i].key.(*blsSecretKey).sk)
+	}
+	for i := 0; i < len(negKeys); i++ {
+		sk.Sub(sk, negKeys[i].key.(*blsSecretKey).sk)
+	}
+	return sk
+}
+
+// blsSecret returns the secret key of a key pair
+func blsSecret(keys *SigKeyPair) (*blsSecretKey, error) {
+	sk, ok := keys.key.(*blsSecretKey)
+	if !ok {
+		return nil, errors.New("bls12381: invalid secret key")
+	}
+	return sk, nil
+}
+
+// BLS12381MinPk is the BLS signature on BLS12-381 with 48-byte public keys in G1 and 96-byte signatures in G2, which is
+// the layout of Ethereum consensus-layer (sigType 6). Messages are augmented with public keys, so signatures of the
+// same message can be aggregated.
+type BLS12381MinPk struct{}
+
+func (BLS12381MinPk) Name() string {
+	return "bls12381-minpk"
+}
+
+func (BLS12381MinPk) Sizes() (int32, int32, int32) {
+	return blsSkSize, 48, 96
+}
+
+func (s BLS12381MinPk) newKeys(sk *bls12381.Fr) *blsSecretKey {
+	g1 := bls12381.NewG1()
+	return &blsSecretKey{sk: sk, pk: g1.MulScalar(g1.New(), g1.One(), sk)}
+}
+
+func (s BLS12381MinPk) Generate(rand io.Reader, keys *SigKeyPair) error {
+	sk, err := newBlsSecret(rand)
+	if err != nil {
+		return err
+	}
+	keys.key = s.newKeys(sk)
+	return nil
+}
+
+func (BLS12381MinPk) PublicKey(keys *SigKeyPair) Pubkey {
+	return Pubkey{key: keys.key.(*blsSecretKey).pk}
+}
+
+// hash hashes pk || msg to G2
+func (BLS12381MinPk) hash(pk *bls12381.PointG1, msg []byte) (*bls12381.PointG2, error) {
+	augmented := append(bls12381.NewG1().ToCompressed(pk), msg...)
+	return bls12381.NewG2().HashToCurve(augmented, blsMinPkDST)
+}
+
+func (s BLS12381MinPk) Sign(keys *SigKeyPair, msg []byte) (Signature, error) {
+	sk, err := blsSecret(keys)
+	if err != nil {
+		return nil, err
+	}
+	g2 := bls12381.NewG2()
+	HM, err := s.hash(sk.pk.(*bls12381.PointG1), msg)
+	if err != nil {
+		return nil, err
+	}
+	return g2.ToCompressed(g2.MulScalar(HM, HM, sk.sk)), nil
+}
+
+func (s BLS12381MinPk) Verify(pk *Pubkey, msg []byte, sig Signature) bool {
+	return s.VerifyAggregate([]Pubkey{*pk}, [][]byte{msg}, sig)
+}
+
+func (BLS12381MinPk) AggregateSignatures(pks []Pubkey, msgs [][]byte, sigs []Signature) (Signature, error) {
+	g2 := bls12381.NewG2()
+	sig := g2.Zero()
+	for i := 0; i < len(sigs); i++ {
+		sigToAdd, err := g2.FromCompressed(sigs[i])
+		if err != nil {
+			return nil, newTxError("aggregate signatures", i, fmt.Errorf("%w: %w", ErrMalformedEncoding, err))
+		}
+		g2.Add(sig, sig, sigToAdd)
+	}
+	return g2.ToCompressed(sig), nil
+}
+
+// VerifyAggregate checks e(g1, sig) = prod e(pk_i, H(pk_i || msg_i))
+func (s BLS12381MinPk) VerifyAggregate(pks []Pubkey, msgs [][]byte, sig Signature) bool {
+	if len(pks) == 0 || len(pks) != len(msgs) {
+		return false
+	}
+	g1 := bls12381.NewG1()
+	g2 := bls12381.NewG2()
+	sigPoint, err := g2.FromCompressed(sig)
+	if err != nil || !g2.InCorrectSubgroup(sigPoint) {
+		return false
+	}
+	engine := bls12381.NewEngine()
+	for i := range pks {
+		pk, ok := pks[i].key.(*bls12381.PointG1)
+		if !ok {
+			return false
+		}
+		HM, err := s.hash(pk, msgs[i])
+		if err != nil {
+			return false
+		}
+		engine.AddPair(pk, HM)
+	}
+	engine.AddPairInv(g1.One(), sigPoint)
+	return engine.Check()
+}
+
+func (BLS12381MinPk) MarshalKeys(keys *SigKeyPair, buf *bytes.Buffer) {
+	sk := keys.key.(*blsSecretKey)
+	buf.Write(bls12381.NewG1().ToCompressed(sk.pk.(*bls12381.PointG1)))
+	buf.Write(sk.sk.ToBytes())
+}
+
+func (s BLS12381MinPk) UnmarshalKeys(keys *SigKeyPair, buf []byte) error {
+	sk := s.newKeys(bls12381.NewFr().FromBytes(buf[48 : 48+blsSkSize]))
+	if sk.sk.IsZero() || !bytes.Equal(bls12381.NewG1().ToCompressed(sk.pk.(*bls12381.PointG1)), buf[:48]) {
+		return errors.New("bls12381: public key doesn't match the secret key")
+	}
+	keys.key = sk
+	return nil
+}
+
+func (BLS12381MinPk) MarshalPublicKey(pk *Pubkey) ([]byte, error) {
+	key, ok := pk.key.(*bls12381.PointG1)
+	if !ok {
+		return nil, errors.New("bls12381: invalid public key")
+	}
+	return bls12381.NewG1().ToCompressed(key), nil
+}
+
+func (BLS12381MinPk) UnmarshalPublicKey(pk *Pubkey, buf []byte) error {
+	g1 := bls12381.NewG1()
+	key, err := g1.FromCompressed(buf)
+	if err != nil {
+		return err
+	}
+	if g1.IsZero(key) || !g1.InCorrectSubgroup(key) {
+		return errors.New("bls12381: invalid public key")
+	}
+	pk.key = key
+	return nil
+}
+
+func (s BLS12381MinPk) AddKeyPairs(keys []*SigKeyPair, negKeys []*SigKeyPair) SigKeyPair {
+	return SigKeyPair{key: s.newKeys(blsSumSecrets(keys, negKeys))}
+}
+
+func (BLS12381MinPk) AddPublicKeys(keys []*Pubkey, negKeys []*Pubkey) Pubkey {
+	g1 := bls12381.NewG1()
+	pk := g1.New().Set(keys[0].key.(*bls12381.PointG1))
+	for i := 1; i < len(keys); i++ {
+		g1.Add(pk, pk, keys[i].key.(*bls12381.PointG1))
+	}
+	for i := 0; i < len(negKeys); i++ {
+		g1.Sub(pk, pk, negKeys[i].key.(*bls12381.PointG1))
+	}
+	return Pubkey{key: pk}
+}
+
+func (s BLS12381MinPk) MulKeyPair(keys *SigKeyPair, a []byte, b []byte) {
+	sk := bls12381.NewFr()
+	sk.Mul(keys.key.(*blsSecretKey).sk, blsTweak(a, b))
+	keys.key = s.newKeys(sk)
+}
+
+func (BLS12381MinPk) MulPublicKey(pk *Pubkey, a []byte, b []byte) {
+	g1 := bls12381.NewG1()
+	pk.key = g1.MulScalar(g1.New(), pk.key.(*bls12381.PointG1), blsTweak(a, b))
+}
+
+// BLS12381MinSig is the BLS signature on BLS12-381 with 96-byte public keys in G2 and 48-byte signatures in G1
+// (sigType 7). Messages are augmented with public keys, so signatures of the same message can be aggregated.
+type BLS12381MinSig struct{}
+
+func (BLS12381MinSig) Name() string {
+	return "bls12381-minsig"
+}
+
+func (BLS12381MinSig) Sizes() (int32, int32, int32) {
+	return blsSkSize, 96, 48
+}
+
+func (s BLS12381MinSig) newKeys(sk *bls12381.Fr) *blsSecretKey {
+	g2 := bls12381.NewG2()
+	return &blsSecretKey{sk: sk, pk: g2.MulScalar(g2.New(), g2.One(), sk)}
+}
+
+func (s BLS12381MinSig) Generate(rand io.Reader, keys *SigKeyPair) error {
+	sk, err := newBlsSecret(rand)
+	if err != nil {
+		return err
+	}
+	keys.key = s.newKeys(sk)
+	return nil
+}
+
+func (BLS12381MinSig) PublicKey(keys *SigKeyPair) Pubkey {
+	return Pubkey{key: keys.key.(*blsSecretKey).pk}
+}
+
+// hash hashes pk || msg to G1
+func (BLS12381MinSig) hash(pk *bls12381.PointG2, msg []byte) (*bls12381.PointG1, error) {
+	augmented := append(bls12381.NewG2().ToCompressed(pk), msg...)
+	return bls12381.NewG1().HashToCurve(augmented, blsMinSigDST)
+}
+
+func (s BLS12381MinSig) Sign(keys *SigKeyPair, msg []byte) (Signature, error) {
+	sk, err := blsSecret(keys)
+	if err != nil {
+		return nil, err
+	}
+	g1 := bls12381.NewG1()
+	HM, err := s.hash(sk.pk.(*bls12381.PointG2), msg)
+	if err != nil {
+		return nil, err
+	}
+	return g1.ToCompressed(g1.MulScalar(HM, HM, sk.sk)), nil
+}
+
+func (s BLS12381MinSig) Verify(pk *Pubkey, msg []byte, sig Signature) bool {
+	return s.VerifyAggregate([]Pubkey{*pk}, [][]byte{msg}, sig)
+}
+
+func (BLS12381MinSig) AggregateSignatures(pks []Pubkey, msgs [][]byte, sigs []Signature) (Signature, error) {
+	g1 := bls12381.NewG1()
+	sig := g1.Zero()
+	for i := 0; i < len(sigs); i++ {
+		sigToAdd, err := g1.FromCompressed(sigs[i])
+		if err != nil {
+			return nil, newTxError("aggregate signatures", i, fmt.Errorf("%w: %w", ErrMalformedEncoding, err))
+		}
+		g1.Add(sig, sig, sigToAdd)
+	}
+	return g1.ToCompressed(sig), nil
+}
+
+// VerifyAggregate checks e(sig, g2) = prod e(H(pk_i || msg_i), pk_i)
+func (s BLS12381MinSig) VerifyAggregate(pks []Pubkey, msgs [][]byte, sig Signature) bool {
+	if len(pks) == 0 || len(pks) != len(msgs) {
+		return false
+	}
+	g1 := bls12381.NewG1()
+	g2 := bls12381.NewG2()
+	sigPoint, err := g1.FromCompressed(sig)
+	if err != nil || !g1.InCorrectSubgroup(sigPoint) {
+		return false
+	}
+	engine := bls12381.NewEngine()
+	for i := range pks {
+		pk, ok := pks[i].key.(*bls12381.PointG2)
+		if !ok {
+			return false
+		}
+		HM, err := s.hash(pk, msgs[i])
+		if err != nil {
+			return false
+		}
+		engine.AddPair(HM, pk)
+	}
+	engine.AddPairInv(sigPoint, g2.One())
+	return engine.Check()
+}
+
+func (BLS12381MinSig) MarshalKeys(keys *SigKeyPair, buf *bytes.Buffer) {
+	sk := keys.key.(*blsSecretKey)
+	buf.Write(bls12381.NewG2().ToCompressed(sk.pk.(*bls12381.PointG2)))
+	buf.Write(sk.sk.ToBytes())
+}
+
+func (s BLS12381MinSig) UnmarshalKeys(keys *SigKeyPair, buf []byte) error {
+	sk := s.newKeys(bls12381.NewFr().FromBytes(buf[96 : 96+blsSkSize]))
+	if sk.sk.IsZero() || !bytes.Equal(bls12381.NewG2().ToCompressed(sk.pk.(*bls12381.PointG2)), buf[:96]) {
+		return errors.New("bls12381: public key doesn't match the secret key")
+	}
+	keys.key = sk
+	return nil
+}
+
+func (BLS12381MinSig) MarshalPublicKey(pk *Pubkey) ([]byte, error) {
+	key, ok := pk.key.(*bls12381.PointG2)
+	if !ok {
+		return nil, errors.New("bls12381: invalid public key")
+	}
+	return bls12381.NewG2().ToCompressed(key), nil
+}
+
+func (BLS12381MinSig) UnmarshalPublicKey(pk *Pubkey, buf []byte) error {
+	g2 := bls12381.NewG2()
+	key, err := g2.FromCompressed(buf)
+	if err != nil {
+		return err
+	}
+	if g2.IsZero(key) || !g2.InCorrectSubgroup(key) {
+		return errors.New("bls12381: invalid public key")
+	}
+	pk.key = key
+	return nil
+}
+
+func (s BLS12381MinSig) AddKeyPairs(keys []*SigKeyPair, negKeys []*SigKeyPair) SigKeyPair {
+	return SigKeyPair{key: s.newKeys(blsSumSecrets(keys, negKeys))}
+}
+
+func (BLS12381MinSig) AddPublicKeys(keys []*Pubkey, negKeys []*Pubkey) Pubkey {
+	g2 := bls12381.NewG2()
+	pk := g2.New().Set(keys[0].key.(*bls12381.PointG2))
+	for i := 1; i < len(keys); i++ {
+		g2.Add(pk, pk, keys[i].key.(*bls12381.PointG2))
+	}
+	for i := 0; i < len(negKeys); i++ {
+		g2.Sub(pk, pk, negKeys[i].key.(*bls12381.PointG2))
+	}
+	return Pubkey{key: pk}
+}
+
+func (s BLS12381MinSig) MulKeyPair(keys *SigKeyPair, a []byte, b []byte) {
+	sk := bls12381.NewFr()
+	sk.Mul(keys.key.(*blsSecretKey).sk, blsTweak(a, b))
+	keys.key = s.newKeys(sk)
+}
+
+func (BLS12381MinSig) MulPublicKey(pk *Pubkey, a []byte, b []byte) {
+	g2 := bls12381.NewG2()
+	pk.key = g2.MulScalar(g2.New(), pk.key.(*bls12381.PointG2), blsTweak(a, b))
+}
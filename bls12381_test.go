@@ -0,0 +1,90 @@
+package txhelper
+
+import (
+	"bytes"
+	"crypto/rand"
+	"testing"
+)
+
+func TestBLS12381(tester *testing.T) {
+	sizes := map[int32][2]int32{6: {48, 96}, 7: {96, 48}}
+	for sigType, size := range sizes {
+		sigCtx, err := NewSigContext(sigType)
+		if err != nil {
+			tester.Fatal(err)
+		}
+		if sigCtx.SkSize != 32 || sigCtx.PkSize != size[0] || sigCtx.SigSize != size[1] {
+			tester.Fatal("invalid sizes:", sigType, sigCtx.SkSize, sigCtx.PkSize, sigCtx.SigSize)
+		}
+		if !sigCtx.aggregates() || !sigCtx.tweakable() {
+			tester.Fatal("invalid scheme capabilities:", sigType)
+		}
+
+		msg := make([]byte, 32)
+		rand.Read(msg)
+		pks := make([]Pubkey, 5)
+		msgs := make([][]byte, 5)
+		sigs := make([]Signature, 5)
+		for j := 0; j < 5; j++ {
+			var keys SigKeyPair
+			var keys1 SigKeyPair
+			var kp bytes.Buffer
+			if err := sigCtx.generate(&keys); err != nil {
+				tester.Fatal(err)
+			}
+			sigCtx.marshelKeys(&keys, &kp)
+			if err := sigCtx.unmarshelKeys(&keys1, kp.Bytes()); err != nil {
+				tester.Fatal(err)
+			}
+			if err := sigCtx.unmarshelPublicKeysFromBytes(&pks[j], kp.Bytes()[:sigCtx.PkSize]); err != nil {
+				tester.Fatal(err)
+			}
+			msgs[j] = make([]byte, 32)
+			rand.Read(msgs[j])
+			if sigs[j], err = sigCtx.sign(&keys1, msgs[j]); err != nil {
+				tester.Fatal(err)
+			}
+			if !sigCtx.verify(&pks[j], msgs[j], sigs[j]) {
+				tester.Fatal("invalid signature:", sigType)
+			}
+			if sigCtx.verify(&pks[j], msg, sigs[j]) {
+				tester.Fatal("signature of a different message was accepted:", sigType)
+			}
+		}
+
+		sig, err := sigCtx.aggregateSignaturesMultipleMsg(pks, msgs, sigs)
+		if err != nil {
+			tester.Fatal(err)
+		}
+		if !sigCtx.batchVerifyMultipleMsg(pks, msgs, sig) {
+			tester.Fatal("invalid aggregated signature:", sigType)
+		}
+		scheme := sigCtx.scheme.(AggregateScheme)
+		if scheme.VerifyAggregate(pks, msgs[1:], sig) {
+			tester.Fatal("aggregated signature with missing messages was accepted:", sigType)
+		}
+		empty, err := scheme.AggregateSignatures(nil, nil, nil)
+		if err == nil && scheme.VerifyAggregate(nil, nil, empty) {
+			tester.Fatal("empty aggregated signature was accepted:", sigType)
+		}
+		msgs[0], msgs[1] = msgs[1], msgs[0]
+		if sigCtx.batchVerifyMultipleMsg(pks, msgs, sig) {
+			tester.Fatal("aggregated signature of swapped messages was accepted:", sigType)
+		}
+	}
+}
+
+func TestBLS12381Peers(tester *testing.T) {
+	for sigType := int32(6); sigType <= 7; sigType++ {
+		for i := 1; i <= 4; i++ {
+			ctx := newTestContext(tester, 390, 1, i, sigType, 32, 10, 4, 5, 1, false, 2)
+			ctx.testPeerTransactions(10, tester)
+			ctx.testPeerBatchTransactions(10, tester)
+		}
+		// both layouts support difference signatures of Origami models
+		for i := 5; i <= 6; i++ {
+			ctx := newTestContext(tester, 391, 1, i, sigType, 32, 10, 4, 5, 1, false, 2)
+			ctx.testPeerTransactions(10, tester)
+		}
+	}
+}
@@ -0,0 +1,208 @@
+/**********************************************************************
+ * Copyright (c) 2017 Jayamine Alupotha                               *
+ * Distributed under the MIT software license, see the accompanying   *
+ * file COPYING or http://www.opensource.org/licenses/mit-license.php.*
+ **********************************************************************/
+
+package txhelper
+
+import (
+	"fmt"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+// CBOR encodings use maps with the field numbers of txhelper.proto as keys. They are encoded in the canonical form
+// of RFC 8949 (core deterministic encoding), and decoders reject duplicate keys and indefinite lengths.
+
+var (
+	cborEnc cbor.EncMode
+	cborDec cbor.DecMode
+)
+
+func init() {
+	var err error
+	if cborEnc, err = cbor.CoreDetEncOptions().EncMode(); err != nil {
+		panic(err)
+	}
+	cborDec, err = cbor.DecOptions{
+		DupMapKey:        cbor.DupMapKeyEnforcedAPF,
+		IndefLength:      cbor.IndefLengthForbidden,
+		MaxArrayElements: 1 << 24,
+	}.DecMode()
+	if err != nil {
+		panic(err)
+	}
+}
+
+type cborInput struct {
+	Header []byte `cbor:"1,keyasint,omitempty"`
+}
+
+type cborOutput struct {
+	Pk   []byte `cbor:"1,keyasint,omitempty"`
+	N    uint8  `cbor:"2,keyasint,omitempty"`
+	Data []byte `cbor:"3,keyasint,omitempty"`
+}
+
+type cborAppData struct {
+	Inputs  []cborInput  `cbor:"1,keyasint,omitempty"`
+	Outputs []cborOutput `cbor:"2,keyasint,omitempty"`
+}
+
+type cborTxHeader struct {
+	Signatures    [][]byte `cbor:"1,keyasint,omitempty"`
+	ActivityProof []byte   `cbor:"2,keyasint,omitempty"`
+	ExcessPK      []byte   `cbor:"3,keyasint,omitempty"`
+}
+
+type cborTransaction struct {
+	N      int32        `cbor:"1,keyasint,omitempty"`
+	Header cborTxHeader `cbor:"2,keyasint,omitempty"`
+	Data   cborAppData  `cbor:"3,keyasint,omitempty"`
+}
+
+type cborBlock struct {
+	Height     int               `cbor:"1,keyasint,omitempty"`
+	ParentHash []byte            `cbor:"2,keyasint,omitempty"`
+	Root       []byte            `cbor:"3,keyasint,omitempty"`
+	Txs        []cborTransaction `cbor:"4,keyasint,omitempty"`
+	Sig        []byte            `cbor:"5,keyasint,omitempty"`
+}
+
+// cborError wraps CBOR decoding errors
+func cborError(err error) error {
+	return fmt.Errorf("%w: cbor: %w", ErrMalformedEncoding, err)
+}
+
+func (data *AppData) toCBOR() cborAppData {
+	c := cborAppData{Inputs: make([]cborInput, len(data.Inputs)), Outputs: make([]cborOutput, len(data.Outputs))}
+	for i := range data.Inputs {
+		c.Inputs[i].Header = data.Inputs[i].Header
+	}
+	for i := range data.Outputs {
+		c.Outputs[i] = cborOutput{Pk: data.Outputs[i].Pk, N: data.Outputs[i].N, Data: data.Outputs[i].Data}
+	}
+	return c
+}
+
+func (data *AppData) fromCBOR(c *cborAppData) {
+	*data = AppData{}
+	if len(c.Inputs) > 0 {
+		data.Inputs = make([]InputData, len(c.Inputs))
+	}
+	for i := range c.Inputs {
+		data.Inputs[i].Header = c.Inputs[i].Header
+	}
+	if len(c.Outputs) > 0 {
+		data.Outputs = make([]OutputData, len(c.Outputs))
+	}
+	for i := range c.Outputs {
+		data.Outputs[i] = OutputData{Pk: c.Outputs[i].Pk, N: c.Outputs[i].N, Data: c.Outputs[i].Data}
+	}
+}
+
+func (txh *TxHeader) toCBOR() cborTxHeader {
+	c := cborTxHeader{ActivityProof: txh.activityProof, ExcessPK: txh.excessPK}
+	if len(txh.Kyber) > 0 {
+		c.Signatures = make([][]byte, len(txh.Kyber))
+	}
+	for i := range txh.Kyber {
+		c.Signatures[i] = txh.Kyber[i]
+	}
+	return c
+}
+
+func (txh *TxHeader) fromCBOR(c *cborTxHeader) {
+	*txh = TxHeader{activityProof: c.ActivityProof, excessPK: c.ExcessPK}
+	if len(c.Signatures) > 0 {
+		txh.Kyber = make([]Signature, len(c.Signatures))
+	}
+	for i := range c.Signatures {
+		txh.Kyber[i] = c.Signatures[i]
+	}
+}
+
+func (tx *Transaction) toCBOR() cborTransaction {
+	return cborTransaction{N: tx.N, Header: tx.Txh.toCBOR(), Data: tx.Data.toCBOR()}
+}
+
+func (tx *Transaction) fromCBOR(c *cborTransaction) {
+	tx.N = c.N
+	tx.Txh.fromCBOR(&c.Header)
+	tx.Data.fromCBOR(&c.Data)
+}
+
+// MarshalCBOR encodes the application data in canonical CBOR
+func (data *AppData) MarshalCBOR() ([]byte, error) {
+	return cborEnc.Marshal(data.toCBOR())
+}
+
+// UnmarshalCBOR parses application data encoded by MarshalCBOR
+func (data *AppData) UnmarshalCBOR(b []byte) error {
+	var c cborAppData
+	if err := cborDec.Unmarshal(b, &c); err != nil {
+		return cborError(err)
+	}
+	data.fromCBOR(&c)
+	return nil
+}
+
+// MarshalCBOR encodes the header in canonical CBOR, including the Origami activity proof and excess public key
+func (txh *TxHeader) MarshalCBOR() ([]byte, error) {
+	return cborEnc.Marshal(txh.toCBOR())
+}
+
+// UnmarshalCBOR parses a header encoded by MarshalCBOR
+func (txh *TxHeader) UnmarshalCBOR(b []byte) error {
+	var c cborTxHeader
+	if err := cborDec.Unmarshal(b, &c); err != nil {
+		return cborError(err)
+	}
+	txh.fromCBOR(&c)
+	return nil
+}
+
+// MarshalCBOR encodes the transaction in canonical CBOR
+func (tx *Transaction) MarshalCBOR() ([]byte, error) {
+	return cborEnc.Marshal(tx.toCBOR())
+}
+
+// UnmarshalCBOR parses a transaction encoded by MarshalCBOR
+func (tx *Transaction) UnmarshalCBOR(b []byte) error {
+	var c cborTransaction
+	if err := cborDec.Unmarshal(b, &c); err != nil {
+		return cborError(err)
+	}
+	tx.fromCBOR(&c)
+	return nil
+}
+
+// MarshalCBOR encodes the block in canonical CBOR
+func (b *Block) MarshalCBOR() ([]byte, error) {
+	c := cborBlock{Height: b.Height, ParentHash: b.ParentHash, Root: b.Root, Sig: b.Sig}
+	if len(b.Txs) > 0 {
+		c.Txs = make([]cborTransaction, len(b.Txs))
+	}
+	for i := range b.Txs {
+		c.Txs[i] = b.Txs[i].toCBOR()
+	}
+	return cborEnc.Marshal(c)
+}
+
+// UnmarshalCBOR parses a block encoded by MarshalCBOR
+func (b *Block) UnmarshalCBOR(buf []byte) error {
+	var c cborBlock
+	if err := cborDec.Unmarshal(buf, &c); err != nil {
+		return cborError(err)
+	}
+	*b = Block{Height: c.Height, ParentHash: c.ParentHash, Root: c.Root, Sig: c.Sig}
+	if len(c.Txs) > 0 {
+		b.Txs = make([]*Transaction, len(c.Txs))
+	}
+	for i := range c.Txs {
+		b.Txs[i] = new(Transaction)
+		b.Txs[i].fromCBOR(&c.Txs[i])
+	}
+	return nil
+}
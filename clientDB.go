@@ -11,7 +11,6 @@ import (
 	"encoding/json"
 	"errors"
 	_ "github.com/mattn/go-sqlite3"
-	"strconv"
 )
 
 type User struct {
@@ -28,9 +27,15 @@ type User struct {
 func (ctx *ExeContext) initClientDB() (bool, error) {
 	var err error
 
-	ctx.db, err = sql.Open("sqlite3", "client"+strconv.FormatInt(int64(ctx.exeId), 10)+".db")
-	if err != nil {
-		return false, errors.New("TXHELPER_FAILED_CLIENTDB")
+	if err = ctx.openDB(); err != nil {
+		return false, err
+	}
+
+	if ctx.openExisting {
+		found, err := ctx.openClientMeta()
+		if err != nil || found {
+			return found, err
+		}
 	}
 
 	statement := "DROP TABLE IF EXISTS outputs; " +
@@ -39,7 +44,10 @@ func (ctx *ExeContext) initClientDB() (bool, error) {
 	_, err = ctx.db.Exec(statement)
 
 	if err != nil {
-		return false, errors.New("TXHELPER_FAILED_CLIENTDB:OUTPUT")
+		return false, dbError(err)
+	}
+	if err = ctx.initMeta(); err != nil {
+		return false, err
 	}
 	return true, nil
 }
@@ -50,7 +58,7 @@ func (ctx *ExeContext) insertClientOut(id int, out *User) (bool, error) {
 
 	stm, err := ctx.db.Prepare("INSERT INTO outputs(id, h, Data) VALUES(?, ?, ?);")
 	if err != nil {
-		return false, err
+		return false, dbError(err)
 	}
 	defer stm.Close()
 
@@ -61,7 +69,7 @@ func (ctx *ExeContext) insertClientOut(id int, out *User) (bool, error) {
 	_, err = stm.Exec(id, out.H, data)
 
 	if err != nil {
-		return false, err
+		return false, dbError(err)
 	}
 	return true, nil
 }
@@ -73,7 +81,7 @@ func (ctx *ExeContext) getClientOut(id int, out *User) (bool, error) {
 	row := ctx.db.QueryRow("SELECT data FROM outputs WHERE id = ?", id)
 	err = row.Scan(&data)
 	if errors.Is(err, sql.ErrNoRows) {
-		return false, err
+		return false, dbError(err)
 	}
 	err = json.Unmarshal(data, out)
 	if err != nil {
@@ -92,13 +100,13 @@ func (ctx *ExeContext) updateClientOut(id int, out *User) (bool, error) {
 	}
 	stm, err := ctx.db.Prepare("UPDATE outputs SET h = ?, data = ? WHERE id = ?")
 	if err != nil {
-		return false, err
+		return false, dbError(err)
 	}
 	defer stm.Close()
 
 	_, err = stm.Exec(out.H, data, id)
 	if err != nil {
-		return false, err
+		return false, dbError(err)
 	}
 	defer stm.Close()
 	return true, nil
@@ -111,7 +119,7 @@ func (ctx *ExeContext) getClientOutFromH(h []byte, out *User) (bool, error) {
 	row := ctx.db.QueryRow("SELECT data FROM outputs WHERE h = ?", h)
 	err = row.Scan(&data)
 	if errors.Is(err, sql.ErrNoRows) {
-		return false, err
+		return false, ErrUnknownInput
 	}
 	err = json.Unmarshal(data, out)
 	if err != nil {
@@ -130,13 +138,13 @@ func (ctx *ExeContext) updateClientOutFromH(h []byte, out *User) (bool, error) {
 	}
 	stm, err := ctx.db.Prepare("UPDATE outputs SET h = ?, data = ? WHERE h = ?")
 	if err != nil {
-		return false, err
+		return false, dbError(err)
 	}
 	defer stm.Close()
 
 	_, err = stm.Exec(out.H, data, h)
 	if err != nil {
-		return false, err
+		return false, dbError(err)
 	}
 	defer stm.Close()
 	return true, nil
@@ -0,0 +1,223 @@
+package txhelper
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+func TestProtoAndCBOR(tester *testing.T) {
+	for i := 1; i <= 6; i++ {
+		client := newTestContext(tester, 200, 1, i, 1, 32, 10, 2, 3, 1, false, 2)
+		peer := newTestContext(tester, 200, 2, i, 1, 32, 10, 2, 3, 1, false, 2)
+		tx, err := client.FixedTransaction(0, 3)
+		if err != nil {
+			tester.Fatal("couldn't create tx:", err)
+		}
+		tx.N = 7
+
+		// protobuf
+		txBytes := tx.MarshalProto()
+		var tx1 Transaction
+		if err = tx1.UnmarshalProto(txBytes); err != nil {
+			tester.Fatal("couldn't parse protobuf:", err)
+		}
+		if !bytes.Equal(tx1.MarshalProto(), txBytes) || !bytes.Equal(client.ToBytes(&tx1), client.ToBytes(tx)) {
+			tester.Fatal("protobuf changed the transaction:", i)
+		}
+		if tx1.N != 7 || !bytes.Equal(tx1.Txh.activityProof, tx.Txh.activityProof) || !bytes.Equal(tx1.Txh.excessPK, tx.Txh.excessPK) {
+			tester.Fatal("protobuf lost header fields:", i)
+		}
+		if err = peer.VerifyIncomingTransaction(&tx1); err != nil {
+			tester.Fatal("invalid protobuf transaction:", err)
+		}
+
+		// cbor
+		cborBytes, err := tx.MarshalCBOR()
+		if err != nil {
+			tester.Fatal("couldn't encode cbor:", err)
+		}
+		var tx2 Transaction
+		if err = tx2.UnmarshalCBOR(cborBytes); err != nil {
+			tester.Fatal("couldn't parse cbor:", err)
+		}
+		cborBytes2, _ := tx2.MarshalCBOR()
+		if !bytes.Equal(cborBytes, cborBytes2) || !bytes.Equal(client.ToBytes(&tx2), client.ToBytes(tx)) {
+			tester.Fatal("cbor changed the transaction:", i)
+		}
+		if tx2.N != 7 || !bytes.Equal(tx2.Txh.activityProof, tx.Txh.activityProof) || !bytes.Equal(tx2.Txh.excessPK, tx.Txh.excessPK) {
+			tester.Fatal("cbor lost header fields:", i)
+		}
+
+		// truncated encodings are malformed
+		if err = tx1.UnmarshalProto(txBytes[:len(txBytes)-1]); !errors.Is(err, ErrMalformedEncoding) {
+			tester.Fatal("truncated protobuf was parsed:", err)
+		}
+		if err = tx2.UnmarshalCBOR(cborBytes[:len(cborBytes)-1]); !errors.Is(err, ErrMalformedEncoding) {
+			tester.Fatal("truncated cbor was parsed:", err)
+		}
+
+		// blocks
+		block := Block{Height: 3, ParentHash: make([]byte, 32), Root: bytes.Repeat([]byte{1}, 32), Txs: []*Transaction{tx, tx},
+			Sig: []byte{1, 2, 3}}
+		var block1, block2 Block
+		if err = block1.UnmarshalProto(block.MarshalProto()); err != nil {
+			tester.Fatal("couldn't parse protobuf block:", err)
+		}
+		blockBytes, err := block.MarshalCBOR()
+		if err != nil {
+			tester.Fatal("couldn't encode cbor block:", err)
+		}
+		if err = block2.UnmarshalCBOR(blockBytes); err != nil {
+			tester.Fatal("couldn't parse cbor block:", err)
+		}
+		for _, b := range []Block{block1, block2} {
+			if !bytes.Equal(b.Hash(), block.Hash()) || len(b.Txs) != 2 || !bytes.Equal(client.ToBytes(b.Txs[1]), client.ToBytes(tx)) {
+				tester.Fatal("block changed:", i)
+			}
+		}
+	}
+}
+
+func TestProtoUnknownFields(tester *testing.T) {
+	// field 9 (varint 1) is skipped, and field 1 must be a varint
+	tx := Transaction{N: 5}
+	txBytes := append(tx.MarshalProto(), 9<<3, 1)
+	var tx1 Transaction
+	if err := tx1.UnmarshalProto(txBytes); err != nil || tx1.N != 5 {
+		tester.Fatal("unknown field wasn't skipped:", err)
+	}
+	if err := tx1.UnmarshalProto([]byte{1<<3 | 2, 0}); !errors.Is(err, ErrMalformedEncoding) {
+		tester.Fatal("invalid wire type was accepted:", err)
+	}
+	// map {1: 5, 1: 5} has a duplicate key
+	if err := tx1.UnmarshalCBOR([]byte{0xa2, 0x01, 0x05, 0x01, 0x05}); !errors.Is(err, ErrMalformedEncoding) {
+		tester.Fatal("duplicate cbor key was accepted:", err)
+	}
+}
+
+// protoTestFile returns the messages of txhelper.proto for the protobuf runtime
+func protoTestFile(tester *testing.T) protoreflect.FileDescriptor {
+	type field struct {
+		name     string
+		typ      descriptorpb.FieldDescriptorProto_Type
+		message  string
+		repeated bool
+	}
+	message := func(name string, fields ...field) *descriptorpb.DescriptorProto {
+		msg := &descriptorpb.DescriptorProto{Name: proto.String(name)}
+		for i, f := range fields {
+			label := descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL
+			if f.repeated {
+				label = descriptorpb.FieldDescriptorProto_LABEL_REPEATED
+			}
+			desc := &descriptorpb.FieldDescriptorProto{Name: proto.String(f.name), Number: proto.Int32(int32(i + 1)),
+				Type: f.typ.Enum(), Label: label.Enum()}
+			if f.message != "" {
+				desc.TypeName = proto.String(".txhelper." + f.message)
+			}
+			msg.Field = append(msg.Field, desc)
+		}
+		return msg
+	}
+	bytesType, messageType := descriptorpb.FieldDescriptorProto_TYPE_BYTES, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE
+	file := &descriptorpb.FileDescriptorProto{
+		Name:    proto.String("txhelper.proto"),
+		Package: proto.String("txhelper"),
+		Syntax:  proto.String("proto3"),
+		MessageType: []*descriptorpb.DescriptorProto{
+			message("Input", field{name: "header", typ: bytesType}),
+			message("Output", field{name: "pk", typ: bytesType},
+				field{name: "n", typ: descriptorpb.FieldDescriptorProto_TYPE_UINT32}, field{name: "data", typ: bytesType}),
+			message("AppData", field{name: "inputs", typ: messageType, message: "Input", repeated: true},
+				field{name: "outputs", typ: messageType, message: "Output", repeated: true}),
+			message("TxHeader", field{name: "signatures", typ: bytesType, repeated: true},
+				field{name: "activity_proof", typ: bytesType}, field{name: "excess_pk", typ: bytesType}),
+			message("Transaction", field{name: "n", typ: descriptorpb.FieldDescriptorProto_TYPE_INT32},
+				field{name: "header", typ: messageType, message: "TxHeader"},
+				field{name: "data", typ: messageType, message: "AppData"}),
+			message("Block", field{name: "height", typ: descriptorpb.FieldDescriptorProto_TYPE_INT64},
+				field{name: "parent_hash", typ: bytesType}, field{name: "root", typ: bytesType},
+				field{name: "txs", typ: messageType, message: "Transaction", repeated: true}, field{name: "sig", typ: bytesType}),
+		},
+	}
+	fd, err := protodesc.NewFile(file, nil)
+	if err != nil {
+		tester.Fatal("invalid descriptor:", err)
+	}
+	return fd
+}
+
+// protoRuntimeCopy parses b with the protobuf runtime and encodes it again
+func protoRuntimeCopy(tester *testing.T, fd protoreflect.FileDescriptor, name protoreflect.Name, b []byte) []byte {
+	msg := dynamicpb.NewMessage(fd.Messages().ByName(name))
+	if err := proto.Unmarshal(b, msg); err != nil {
+		tester.Fatal("protobuf runtime couldn't parse the encoding:", err)
+	}
+	if len(msg.GetUnknown()) != 0 {
+		tester.Fatal("encoding has unknown fields:", name)
+	}
+	copied, err := proto.MarshalOptions{Deterministic: true}.Marshal(msg)
+	if err != nil {
+		tester.Fatal("protobuf runtime couldn't encode the message:", err)
+	}
+	return copied
+}
+
+func TestProtoRuntime(tester *testing.T) {
+	fd := protoTestFile(tester)
+	for i := 1; i <= 6; i++ {
+		client := newTestContext(tester, 201, 1, i, 1, 32, 10, 2, 3, 1, false, 2)
+		tx, err := client.FixedTransaction(2, 3)
+		if err != nil {
+			tester.Fatal("couldn't create tx:", err)
+		}
+		tx.N = -7
+
+		// the protobuf runtime writes the same bytes
+		txBytes := tx.MarshalProto()
+		if !bytes.Equal(protoRuntimeCopy(tester, fd, "Transaction", txBytes), txBytes) {
+			tester.Fatal("protobuf runtime encodes the transaction differently:", i)
+		}
+		block := Block{Height: 3, ParentHash: make([]byte, 32), Root: bytes.Repeat([]byte{1}, 32), Txs: []*Transaction{tx, {}},
+			Sig: []byte{1, 2, 3}}
+		blockBytes := block.MarshalProto()
+		if !bytes.Equal(protoRuntimeCopy(tester, fd, "Block", blockBytes), blockBytes) {
+			tester.Fatal("protobuf runtime encodes the block differently:", i)
+		}
+
+		// repeated header and data messages are merged
+		var split []byte
+		split = protowire.AppendTag(split, 1, protowire.VarintType)
+		split = protowire.AppendVarint(split, uint64(int64(tx.N)))
+		// the activity proof of the second header replaces the one of the first
+		first := TxHeader{Kyber: tx.Txh.Kyber[:1]}
+		if len(tx.Txh.activityProof) > 0 {
+			first.activityProof = bytes.Repeat([]byte{9}, 33)
+		}
+		second := TxHeader{Kyber: tx.Txh.Kyber[1:], activityProof: tx.Txh.activityProof, excessPK: tx.Txh.excessPK}
+		for _, msg := range [][]byte{first.MarshalProto(), second.MarshalProto()} {
+			split = protowire.AppendTag(split, 2, protowire.BytesType)
+			split = protowire.AppendBytes(split, msg)
+		}
+		inputs, outputs := AppData{Inputs: tx.Data.Inputs}, AppData{Outputs: tx.Data.Outputs}
+		for _, msg := range [][]byte{inputs.MarshalProto(), outputs.MarshalProto()} {
+			split = protowire.AppendTag(split, 3, protowire.BytesType)
+			split = protowire.AppendBytes(split, msg)
+		}
+		var merged Transaction
+		if err = merged.UnmarshalProto(split); err != nil {
+			tester.Fatal("couldn't parse repeated messages:", err)
+		}
+		if !bytes.Equal(merged.MarshalProto(), txBytes) || !bytes.Equal(protoRuntimeCopy(tester, fd, "Transaction", split), txBytes) {
+			tester.Fatal("repeated messages weren't merged like the protobuf runtime:", i)
+		}
+	}
+}
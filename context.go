@@ -7,13 +7,15 @@
 package txhelper
 
 import (
+	"crypto/rand"
 	"crypto/sha256"
 	"database/sql"
+	"encoding/binary"
 	"fmt"
 	"go.dedis.ch/kyber/v3/group/edwards25519"
 	"go.dedis.ch/kyber/v3/util/key"
 	"go.dedis.ch/kyber/v3/xof/blake2xb"
-	"log"
+	rand2 "math/rand"
 	"unsafe"
 )
 
@@ -25,14 +27,15 @@ import (
 import "C"
 
 type ExeContext struct {
-	exeId            int // will be used for databases
-	uType            int // 1 - client, 0 - peer
-	txModel          int // transaction model
+	exeId            int     // will be used for databases
+	uType            int     // 1 - client, 0 - peer
+	txModel          int     // transaction model
+	model            TxModel // registered implementation of txModel
 	sigContext       *SignatureContext
-	payloadSize      uint16 // data size per output (bytes)
-	AverageInputMax  uint8  // average number of inputs will be in [0, AverageInputMax]
+	payloadSize      uint16 // average data size per output (bytes)
+	AverageInputMax  uint8  // average number of inputs will be in [0, AverageInputMax] (without WithShapeDistribution)
 	AverageOutputMax uint8  // average number of outputs  will be in [0, AverageOutputMax]
-	distributionType int    // output Data size distribution (currently only support uniform)
+	distributionType int    // output Data size distribution (1 - constant, 2 - uniform, 3 - normal, 4 - log-normal, 5 - Pareto)
 	PublicKeyReuse   int    // (UTXO models) when a new output is created whether to reuse the input public key or
 	// not will be decided from this such that probability of reuse = 1/publicKeyReuse
 	TotalUsers     int // (ACC models) total number of users represented if this is a client
@@ -40,6 +43,8 @@ type ExeContext struct {
 	TotalBlock     int // total number of blocks  if this is a peer
 	TotalTempUsers int // maximum number of temp users
 
+	lastBlockHash []byte // hash of the last committed block
+
 	TempUsers map[[sha256.Size]byte]TempUser
 	TempPKs   map[[128]byte]int
 	TempTxH   map[int][]byte // only used for origami accounts
@@ -52,6 +57,16 @@ type ExeContext struct {
 	CurrentOutputsWithTemp int // in Origami, CurrentUsers = CurrentOutputs
 	DeletedOutputs         int //
 	groupContext           key.Suite
+	rand                   *rand2.Rand         // source of all random choices, payloads and keys (with WithSeed)
+	payloads               PayloadDistribution // draws payload sizes of outputs
+	access                 AccessPattern       // chooses inputs, round-robin if nil
+	shapes                 ShapeDistribution   // draws input and output counts of random transactions
+	trace                  *TraceWriter        // (clients) records created transactions if set
+	wireFormat             WireFormat          // encoding of ToBytes
+	origamiHeaders         bool                // Origami header fields are transmitted instead of only recomputed
+	unspent                []int               // (UTXO clients with an access pattern) ids of outputs that are not spent
+	unspentAdded           []int               // (WithOpenExisting) unspent outputs added since the last saveMeta
+	unspentSpent           []int               // (WithOpenExisting) unspent outputs spent since the last saveMeta
 
 	bnQ   *C.BIGNUM
 	bnCtx *C.BN_CTX
@@ -59,12 +74,24 @@ type ExeContext struct {
 
 	enableIndexing bool
 
-	db *sql.DB // sqlite database
+	blockAggregation bool       // peers aggregate signatures of all transactions in a block
+	blockSigs        *blockSigs // signers of headers that are verified with a block signature
+
+	db           *sql.DB   // (clients) sqlite database
+	dbConn       *sql.Conn // (clients) connection that keeps an in-memory database
+	store        PeerStore // (peers) storage of the peer state
+	inAtomic     bool      // (peers) an atomic update of the store is running
+	openExisting bool      // keep the stored data and counters of an existing database
+	dataDir      string    // directory of the database file
+	dsn          string    // explicit sqlite data source
+	inMemory     bool      // use an in-memory database
+	memoryId     uint64    // unique number of the in-memory database in the process
 }
 
+// NewContext creates a client (uType = 1) or a peer (uType = 2) context
 func NewContext(exeId int, uType int, txType int, sigType int32, averageSize uint16, totalUsers int,
-	averageInputMax uint8, averageOutputMax uint8, distributionType int, enableIndexing bool, publicKeyReuse int) ExeContext {
-	log.SetFlags(log.LstdFlags | log.Lshortfile)
+	averageInputMax uint8, averageOutputMax uint8, distributionType int, enableIndexing bool, publicKeyReuse int, opts ...Option) (ExeContext, error) {
+	var err error
 
 	ctx := ExeContext{
 		exeId:                  exeId,
@@ -90,47 +117,74 @@ func NewContext(exeId int, uType int, txType int, sigType int32, averageSize uin
 		TempPKs:                make(map[[128]byte]int),
 		TempTxH:                make(map[int][]byte),
 		enableIndexing:         enableIndexing,
+		lastBlockHash:          make([]byte, sha256.Size),
+	}
+
+	ctx.model, err = GetTxModel(txType)
+	if err != nil {
+		return ExeContext{}, err
+	}
+	if uType != 1 && uType != 2 {
+		return ExeContext{}, fmt.Errorf("%w: %d", ErrUnknownUserType, uType)
+	}
+	if ctx.model.IsAccount() && averageInputMax > averageOutputMax {
+		return ExeContext{}, fmt.Errorf("%w: can't be AverageInputMax > AverageOutputMax in account models", ErrInvalidConfig)
+	}
+	if int(averageInputMax) >= totalUsers {
+		return ExeContext{}, fmt.Errorf("%w: can't be AverageInputMax >= TotalUsers", ErrInvalidConfig)
+	}
+	if averageOutputMax == 0 || publicKeyReuse <= 0 {
+		return ExeContext{}, fmt.Errorf("%w: AverageOutputMax and PublicKeyReuse must be positive", ErrInvalidConfig)
 	}
 
 	// generate group context
 	rng := blake2xb.New(nil)
 	ctx.groupContext = edwards25519.NewBlakeSHA256Ed25519WithRand(rng)
 
-	// generate signature context
-	ctx.sigContext = NewSigContext(sigType)
-	ctx.sigContext.SigType = sigType
+	// workloads are random unless a seed is given
+	var seed [8]byte
+	if _, err = rand.Read(seed[:]); err != nil {
+		return ExeContext{}, err
+	}
+	ctx.rand = rand2.New(rand2.NewSource(int64(binary.BigEndian.Uint64(seed[:]))))
 
-	if averageInputMax > averageOutputMax {
-		log.Fatal("can't be AverageInputMax > AverageOutputMax")
+	ctx.payloads, err = newPayloadDistribution(distributionType, averageSize)
+	if err != nil {
+		return ExeContext{}, err
 	}
+	ctx.shapes = UniformShape{MaxInputs: averageInputMax, MaxOutputs: averageOutputMax}
 
-	if int(averageInputMax) >= totalUsers {
-		log.Fatal("can't be AverageInputMax >= TotalUsers")
+	// generate signature context
+	ctx.sigContext, err = NewSigContext(sigType)
+	if err != nil {
+		return ExeContext{}, err
+	}
+	if ctx.model.IsOrigami() && !ctx.model.IsAccount() && !ctx.sigContext.tweakable() {
+		return ExeContext{}, fmt.Errorf("%w: %s needs a signature scheme with difference signatures", ErrInvalidConfig, ctx.model.Name())
 	}
 
-	// generate all users for clients
-	if uType == 1 {
-		if ctx.txModel == 1 || ctx.txModel == 3 || ctx.txModel == 5 {
-			ok, err := ctx.initClientDB()
-			if !ok {
-				log.Fatal("couldn't initiate the db:", err)
-			}
-		} else if ctx.txModel == 2 || ctx.txModel == 4 || ctx.txModel == 6 {
-			ok, err := ctx.initClientDB()
-			if !ok {
-				log.Fatal("couldn't initiate the db:", err)
-			}
+	for _, opt := range opts {
+		if err = opt(&ctx); err != nil {
+			return ExeContext{}, err
 		}
-	} else if ctx.uType == 2 {
-		ok, err := ctx.initPeerDB()
-		if !ok {
-			log.Fatal("couldn't initiate the db:", err)
+	}
+	if ctx.trace != nil {
+		if err = ctx.trace.writeHeader(ctx.traceHeader()); err != nil {
+			return ExeContext{}, err
 		}
+	}
+
+	// generate all users for clients
+	if uType == 1 {
+		_, err = ctx.initClientDB()
 	} else {
-		log.Fatal("unknown utype")
+		_, err = ctx.initPeerDB()
+	}
+	if err != nil {
+		return ExeContext{}, newTxError("couldn't initiate the db", -1, err)
 	}
 
-	if ctx.txModel == 5 || ctx.txModel == 6 {
+	if ctx.model.IsOrigami() {
 		// 11299664372728897582526563392681553682012299567391845763352611480686339092302161
 		qBytes := []byte{13, 4, 90, 151, 95, 128, 247, 206, 252, 192, 83, 31, 233, 88, 11, 186, 251, 63, 158, 54, 191, 232, 0, 72, 241, 158, 134, 107, 133, 75, 78, 157, 223}
 		ctx.bnQ = C.BN_new()
@@ -140,13 +194,35 @@ func NewContext(exeId int, uType int, txType int, sigType int32, averageSize uin
 		ctx.bnOne[33-1] = 1
 	}
 
-	return ctx
+	return ctx, nil
+}
+
+// Close releases the database or the peer store and the OpenSSL resources of the context. In-memory databases are
+// deleted. The context can't be used after Close.
+func (ctx *ExeContext) Close() error {
+	if ctx.bnCtx != nil {
+		C.BN_CTX_free(ctx.bnCtx)
+		ctx.bnCtx = nil
+	}
+	if ctx.bnQ != nil {
+		C.BN_free(ctx.bnQ)
+		ctx.bnQ = nil
+	}
+	if ctx.store != nil {
+		return ctx.store.Close()
+	}
+	if ctx.db == nil {
+		return nil
+	}
+	err := closeSQLite(ctx.db, ctx.dbConn)
+	ctx.db, ctx.dbConn = nil, nil
+	return err
 }
 
 func (ctx *ExeContext) PrintDetails() {
-	fmt.Println("tx model:", ctx.txModel)
+	fmt.Println("tx model:", ctx.txModel, ctx.model.Name())
 	fmt.Println("sig type:", ctx.sigContext.SigType)
-	fmt.Println("payload size:", ctx.payloadSize)
+	fmt.Println("payload size:", ctx.payloadSize, ctx.payloads.Name())
 	fmt.Println("input max:", ctx.AverageInputMax)
 	fmt.Println("output max:", ctx.AverageOutputMax)
 	fmt.Println("transactions:", ctx.TotalTx)
@@ -0,0 +1,90 @@
+/**********************************************************************
+ * Copyright (c) 2017 Jayamine Alupotha                               *
+ * Distributed under the MIT software license, see the accompanying   *
+ * file COPYING or http://www.opensource.org/licenses/mit-license.php.*
+ **********************************************************************/
+
+package txhelper
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// inMemoryDBs counts in-memory databases, so each context gets its own one
+var inMemoryDBs uint64
+
+// dbName returns the default name of the database, client<exeId> or peer<exeId>
+func (ctx *ExeContext) dbName() string {
+	if ctx.uType == 1 {
+		return "client" + strconv.FormatInt(int64(ctx.exeId), 10)
+	}
+	return "peer" + strconv.FormatInt(int64(ctx.exeId), 10)
+}
+
+// dbSource returns the sqlite data source of the context. By default, it is <dbName>.db in the working directory.
+func (ctx *ExeContext) dbSource() (string, error) {
+	switch {
+	case ctx.dsn != "" && (ctx.inMemory || ctx.dataDir != ""):
+		return "", fmt.Errorf("%w: WithDSN can't be used with WithDataDir or WithInMemoryDB", ErrInvalidConfig)
+	case ctx.dsn != "":
+		return ctx.dsn, nil
+	case ctx.inMemory:
+		// a named shared cache lets all connections of the pool use the same database, and the number keeps contexts
+		// with the same exeId apart
+		return "file:" + ctx.dbName() + "-" + strconv.FormatUint(ctx.memoryId, 10) + "?mode=memory&cache=shared", nil
+	case ctx.dataDir != "":
+		if err := os.MkdirAll(ctx.dataDir, 0o755); err != nil {
+			return "", fmt.Errorf("%w: %w", ErrInvalidConfig, err)
+		}
+		return filepath.Join(ctx.dataDir, ctx.dbName()+".db"), nil
+	}
+	return ctx.dbName() + ".db", nil
+}
+
+// openDB opens the sqlite database of a client
+func (ctx *ExeContext) openDB() error {
+	source, err := ctx.dbSource()
+	if err != nil {
+		return err
+	}
+	ctx.db, ctx.dbConn, err = openSQLite(source, ctx.inMemory)
+	return err
+}
+
+// openSQLite opens a sqlite database. An in-memory database is deleted when its last connection is closed, so one
+// connection of it is kept open until closeSQLite.
+func openSQLite(source string, inMemory bool) (*sql.DB, *sql.Conn, error) {
+	db, err := sql.Open("sqlite3", source)
+	if err != nil {
+		return nil, nil, dbError(err)
+	}
+	if !inMemory {
+		return db, nil, nil
+	}
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		db.Close()
+		return nil, nil, dbError(err)
+	}
+	return db, conn, nil
+}
+
+// closeSQLite closes the kept connection and the database
+func closeSQLite(db *sql.DB, conn *sql.Conn) error {
+	var err error
+	if conn != nil {
+		err = conn.Close()
+	}
+	if dbErr := db.Close(); err == nil {
+		err = dbErr
+	}
+	if err != nil {
+		return dbError(err)
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+package txhelper
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// testDatabase commits transactions of a client to a peer and checks the stored chain. Options select the location of
+// the databases, so contexts are created without the temporary directory of newTestContext.
+func testDatabase(tester *testing.T, txType int, clientOpts []Option, peerOpts []Option) (*ExeContext, *ExeContext) {
+	client, err := NewContext(280, 1, txType, 1, 32, 10, 3, 4, 1, false, 2, clientOpts...)
+	if err != nil {
+		tester.Fatal("couldn't create the client:", err)
+	}
+	peer, err := NewContext(280, 2, txType, 1, 32, 10, 3, 4, 1, false, 2, peerOpts...)
+	if err != nil {
+		tester.Fatal("couldn't create the peer:", err)
+	}
+	for i := 0; i < 5; i++ {
+		if err := peer.CommitTransaction(peer.TotalTx, testPeerTx(tester, &client, &peer)); err != nil {
+			tester.Fatal("could not commit the transaction:", err, txType)
+		}
+	}
+	if err := peer.VerifyStoredAllTransaction(); err != nil {
+		tester.Fatal("invalid chain was created:", err, txType)
+	}
+	return &client, &peer
+}
+
+func TestDataDir(tester *testing.T) {
+	dir := filepath.Join(tester.TempDir(), "data")
+	client, peer := testDatabase(tester, 1, []Option{WithDataDir(dir)}, []Option{WithDataDir(dir)})
+	for _, name := range []string{"client280.db", "peer280.db"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			tester.Fatal("database is not in the data directory:", err)
+		}
+	}
+	if err := client.Close(); err != nil {
+		tester.Fatal("couldn't close the client:", err)
+	}
+	if err := peer.Close(); err != nil {
+		tester.Fatal("couldn't close the peer:", err)
+	}
+	if err := peer.Close(); err != nil {
+		tester.Fatal("couldn't close the peer twice:", err)
+	}
+}
+
+func TestDSN(tester *testing.T) {
+	dir := tester.TempDir()
+	clientDSN, peerDSN := filepath.Join(dir, "client.sqlite"), "file:"+filepath.Join(dir, "peer.sqlite")
+	client, peer := testDatabase(tester, 5, []Option{WithDSN(clientDSN)}, []Option{WithDSN(peerDSN)})
+	client.Close()
+	peer.Close()
+	for _, name := range []string{"client.sqlite", "peer.sqlite"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			tester.Fatal("database is not at the DSN:", err)
+		}
+	}
+	_, err := NewContext(280, 2, 1, 1, 32, 10, 3, 4, 1, false, 2, WithDSN(peerDSN), WithInMemoryDB())
+	if !errors.Is(err, ErrInvalidConfig) {
+		tester.Fatal("DSN was used with an in-memory database:", err)
+	}
+}
+
+func TestInMemoryDB(tester *testing.T) {
+	for _, txType := range []int{1, 6} {
+		os.Remove("client281.db")
+		os.Remove("peer281.db")
+		client := newTestContext(tester, 281, 1, txType, 1, 32, 10, 3, 4, 1, false, 2, WithInMemoryDB())
+		peer := newTestContext(tester, 281, 2, txType, 1, 32, 10, 3, 4, 1, false, 2, WithInMemoryDB())
+		// the database is kept without idle connections
+		client.db.SetMaxIdleConns(0)
+		peer.store.(*SQLiteStore).db.SetMaxIdleConns(0)
+		for i := 0; i < 2; i++ {
+			if err := peer.CommitBlock(testBlockFromClient(tester, &client, &peer, 4)); err != nil {
+				tester.Fatal("could not commit the block:", err, txType)
+			}
+		}
+		if err := peer.VerifyStoredAllBlocks(); err != nil {
+			tester.Fatal("invalid chain was created:", err, txType)
+		}
+		for _, name := range []string{"client281.db", "peer281.db"} {
+			if _, err := os.Stat(name); !os.IsNotExist(err) {
+				tester.Fatal("in-memory database created a file:", name)
+			}
+		}
+
+		// contexts with the same exeId don't share the database
+		state := peer.peerState(tester)
+		other := newTestContext(tester, 281, 2, txType, 1, 32, 10, 3, 4, 1, false, 2, WithInMemoryDB())
+		if other.TotalTx != 0 {
+			tester.Fatal("in-memory database of another context was opened:", txType)
+		}
+		if peer.peerState(tester) != state {
+			tester.Fatal("another context changed the in-memory database:", txType)
+		}
+		if err := peer.VerifyStoredAllBlocks(); err != nil {
+			tester.Fatal("another context changed the chain:", err, txType)
+		}
+		other.Close()
+		client.Close()
+		peer.Close()
+	}
+}
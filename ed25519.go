@@ -0,0 +1,86 @@
+/**********************************************************************
+ * Copyright (c) 2017 Jayamine Alupotha                               *
+ * Distributed under the MIT software license, see the accompanying   *
+ * file COPYING or http://www.opensource.org/licenses/mit-license.php.*
+ **********************************************************************/
+
+package txhelper
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"errors"
+	"io"
+)
+
+// Ed25519 is the standard Ed25519 signature (RFC 8032) of crypto/ed25519 (sigType 3).
+// Keys are encoded as pk || seed. It can't create difference signatures, so it can't be used with Origami UTXO.
+type Ed25519 struct{}
+
+func (Ed25519) Name() string {
+	return "ed25519"
+}
+
+func (Ed25519) Sizes() (int32, int32, int32) {
+	return ed25519.SeedSize, ed25519.PublicKeySize, ed25519.SignatureSize
+}
+
+func (Ed25519) Generate(rand io.Reader, keys *SigKeyPair) error {
+	_, sk, err := ed25519.GenerateKey(rand)
+	if err != nil {
+		return err
+	}
+	keys.key = sk
+	return nil
+}
+
+func (Ed25519) PublicKey(keys *SigKeyPair) Pubkey {
+	return Pubkey{key: keys.key.(ed25519.PrivateKey).Public()}
+}
+
+func (Ed25519) Sign(keys *SigKeyPair, msg []byte) (Signature, error) {
+	sk, ok := keys.key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, errors.New("ed25519: invalid secret key")
+	}
+	return ed25519.Sign(sk, msg), nil
+}
+
+func (Ed25519) Verify(pk *Pubkey, msg []byte, sig Signature) bool {
+	key, ok := pk.key.(ed25519.PublicKey)
+	if !ok {
+		return false
+	}
+	return ed25519.Verify(key, msg, sig)
+}
+
+func (Ed25519) MarshalKeys(keys *SigKeyPair, buf *bytes.Buffer) {
+	sk := keys.key.(ed25519.PrivateKey)
+	buf.Write(sk[ed25519.SeedSize:])
+	buf.Write(sk.Seed())
+}
+
+func (Ed25519) UnmarshalKeys(keys *SigKeyPair, buf []byte) error {
+	sk := ed25519.NewKeyFromSeed(buf[ed25519.PublicKeySize : ed25519.PublicKeySize+ed25519.SeedSize])
+	if !bytes.Equal(sk[ed25519.SeedSize:], buf[:ed25519.PublicKeySize]) {
+		return errors.New("ed25519: public key doesn't match the seed")
+	}
+	keys.key = sk
+	return nil
+}
+
+func (Ed25519) MarshalPublicKey(pk *Pubkey) ([]byte, error) {
+	key, ok := pk.key.(ed25519.PublicKey)
+	if !ok {
+		return nil, errors.New("ed25519: invalid public key")
+	}
+	return append([]byte(nil), key...), nil
+}
+
+func (Ed25519) UnmarshalPublicKey(pk *Pubkey, buf []byte) error {
+	if len(buf) != ed25519.PublicKeySize {
+		return errors.New("ed25519: invalid public key size")
+	}
+	pk.key = ed25519.PublicKey(append([]byte(nil), buf...))
+	return nil
+}
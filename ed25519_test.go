@@ -0,0 +1,65 @@
+package txhelper
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"testing"
+)
+
+func TestEd25519(tester *testing.T) {
+	sigCtx, err := NewSigContext(3)
+	if err != nil {
+		tester.Fatal(err)
+	}
+	if sigCtx.SkSize != 32 || sigCtx.PkSize != 32 || sigCtx.SigSize != 64 {
+		tester.Fatal("invalid sizes:", sigCtx.SkSize, sigCtx.PkSize, sigCtx.SigSize)
+	}
+	for j := 0; j < 10; j++ {
+		msg := make([]byte, 32)
+		rand.Read(msg)
+		var keys SigKeyPair
+		var keys1 SigKeyPair
+		var kp bytes.Buffer
+		if err := sigCtx.generate(&keys); err != nil {
+			tester.Fatal(err)
+		}
+		sigCtx.marshelKeys(&keys, &kp)
+		if err := sigCtx.unmarshelKeys(&keys1, kp.Bytes()); err != nil {
+			tester.Fatal(err)
+		}
+		sig, err := sigCtx.sign(&keys1, msg)
+		if err != nil {
+			tester.Fatal(err)
+		}
+
+		// signatures must be verifiable by standard Ed25519 implementations
+		pk := sigCtx.getPubKey(&keys)
+		pkBytes, err := sigCtx.marshelPublicKey(&pk)
+		if err != nil {
+			tester.Fatal(err)
+		}
+		if !bytes.Equal(pkBytes, kp.Bytes()[:32]) || !ed25519.Verify(pkBytes, msg, sig) {
+			tester.Fatal("not compatible with crypto/ed25519")
+		}
+		if !sigCtx.verify(&pk, msg, sig) {
+			tester.Fatal("invalid signature")
+		}
+		msg[0] ^= 1
+		if sigCtx.verify(&pk, msg, sig) {
+			tester.Fatal("signature of a different message was accepted")
+		}
+	}
+}
+
+func TestEd25519Peers(tester *testing.T) {
+	for i := 1; i <= 4; i++ {
+		ctx := newTestContext(tester, 370, 1, i, 3, 32, 10, 4, 5, 1, false, 2)
+		ctx.testPeerTransactions(10, tester)
+		ctx.testPeerBatchTransactions(10, tester)
+	}
+	if _, err := NewContext(370, 2, 5, 3, 32, 10, 4, 5, 1, false, 2); !errors.Is(err, ErrInvalidConfig) {
+		tester.Fatal("origami utxo accepted ed25519:", err)
+	}
+}
@@ -0,0 +1,285 @@
+/**********************************************************************
+ * Copyright (c) 2017 Jayamine Alupotha                               *
+ * Distributed under the MIT software license, see the accompanying   *
+ * file COPYING or http://www.opensource.org/licenses/mit-license.php.*
+ **********************************************************************/
+
+package txhelper
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+)
+
+// WireFormat selects how ToBytes encodes transactions
+type WireFormat int
+
+const (
+	// CompactFormat is the encoding of the transaction model without any metadata. Peers must be configured like the
+	// client, and counts are single bytes.
+	CompactFormat WireFormat = iota
+	// EnvelopeFormat starts with the magic "TXHE", the version, the transaction model and the signature type, and
+	// encodes all counts as varints and all inputs, keys, payloads and signatures with their lengths
+	EnvelopeFormat
+)
+
+// envelopeMagic starts every transaction in EnvelopeFormat
+var envelopeMagic = [4]byte{'T', 'X', 'H', 'E'}
+
+const envelopeVersion = 1
+
+// DecodeError tells which field of an encoded transaction couldn't be parsed and where it starts
+type DecodeError struct {
+	Field  string // e.g., "magic", "output[2].data"
+	Offset int    // byte offset of the field
+	Err    error  // cause, wraps ErrMalformedEncoding
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("decode %s at byte %d: %s", e.Field, e.Offset, e.Err.Error())
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// envelopeReader reads the fields of an envelope and remembers the offset for errors
+type envelopeReader struct {
+	arr     []byte
+	pointer int
+}
+
+func (r *envelopeReader) fail(field string, format string, args ...any) error {
+	return &DecodeError{Field: field, Offset: r.pointer, Err: fmt.Errorf("%w: %s", ErrMalformedEncoding, fmt.Sprintf(format, args...))}
+}
+
+// uvarint reads a varint that must not be larger than max
+func (r *envelopeReader) uvarint(field string, max uint64) (uint64, error) {
+	value, n := binary.Uvarint(r.arr[r.pointer:])
+	if n == 0 {
+		return 0, r.fail(field, "truncated")
+	}
+	if n < 0 {
+		return 0, r.fail(field, "varint overflows")
+	}
+	if value > max {
+		return 0, r.fail(field, "%d is larger than %d", value, max)
+	}
+	r.pointer += n
+	return value, nil
+}
+
+// count reads the number of items that need at least itemSize bytes each, so counts are bounded by the remaining bytes
+func (r *envelopeReader) count(field string, itemSize int) (int, error) {
+	value, err := r.uvarint(field, uint64((len(r.arr)-r.pointer)/itemSize))
+	return int(value), err
+}
+
+// bytes reads n bytes
+func (r *envelopeReader) bytes(field string, n int) ([]byte, error) {
+	if len(r.arr)-r.pointer < n {
+		return nil, r.fail(field, "needs %d bytes, but %d are left", n, len(r.arr)-r.pointer)
+	}
+	out := make([]byte, n)
+	copy(out, r.arr[r.pointer:])
+	r.pointer += n
+	return out, nil
+}
+
+// sized reads a length-prefixed field
+func (r *envelopeReader) sized(field string, max int) ([]byte, error) {
+	start := r.pointer
+	length, err := r.uvarint(field, uint64(max))
+	if err != nil {
+		return nil, err
+	}
+	out, err := r.bytes(field, int(length))
+	if err != nil {
+		r.pointer = start
+		return nil, err
+	}
+	return out, nil
+}
+
+// appendSized writes data with its length
+func appendSized(buffer *bytes.Buffer, data []byte) {
+	buffer.Write(binary.AppendUvarint(nil, uint64(len(data))))
+	buffer.Write(data)
+}
+
+// encodeEnvelope outputs the transaction in EnvelopeFormat. Outputs that update accounts have empty public keys.
+// Origami header fields follow the signatures if headers is true.
+func (ctx *ExeContext) encodeEnvelope(tx *Transaction, headers bool) []byte {
+	buffer := new(bytes.Buffer)
+	buffer.Write(envelopeMagic[:])
+	buffer.WriteByte(envelopeVersion)
+	buffer.Write(binary.AppendUvarint(nil, uint64(ctx.txModel)))
+	buffer.Write(binary.AppendUvarint(nil, uint64(ctx.sigContext.SigType)))
+
+	buffer.Write(binary.AppendUvarint(nil, uint64(len(tx.Data.Inputs))))
+	for i := 0; i < len(tx.Data.Inputs); i++ {
+		appendSized(buffer, tx.Data.Inputs[i].Header)
+	}
+	buffer.Write(binary.AppendUvarint(nil, uint64(len(tx.Data.Outputs))))
+	for i := 0; i < len(tx.Data.Outputs); i++ {
+		if i >= len(tx.Data.Inputs) || !ctx.model.IsAccount() {
+			appendSized(buffer, tx.Data.Outputs[i].Pk)
+			buffer.WriteByte(tx.Data.Outputs[i].N)
+		} else {
+			buffer.WriteByte(0)
+		}
+		appendSized(buffer, tx.Data.Outputs[i].Data)
+	}
+	buffer.Write(binary.AppendUvarint(nil, uint64(len(tx.Txh.Kyber))))
+	for i := 0; i < len(tx.Txh.Kyber); i++ {
+		appendSized(buffer, tx.Txh.Kyber[i])
+	}
+	if headers {
+		appendSized(buffer, tx.Txh.activityProof)
+		if !ctx.model.IsAccount() {
+			appendSized(buffer, tx.Txh.excessPK)
+		}
+	}
+	return buffer.Bytes()
+}
+
+// decodeEnvelope parses a transaction created by encodeEnvelope. The transaction model and the signature type must be
+// the ones of the context, and keys, inputs and signatures must have their sizes.
+func (ctx *ExeContext) decodeEnvelope(arr []byte, tx *Transaction) error {
+	r := envelopeReader{arr: arr}
+	magic, err := r.bytes("magic", len(envelopeMagic))
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(magic, envelopeMagic[:]) {
+		r.pointer = 0
+		return r.fail("magic", "not an envelope")
+	}
+	version, err := r.bytes("version", 1)
+	if err != nil {
+		return err
+	}
+	if version[0] != envelopeVersion {
+		r.pointer--
+		return r.fail("version", "unsupported version %d", version[0])
+	}
+	txModel, err := r.uvarint("tx model", 0xffff)
+	if err != nil {
+		return err
+	}
+	if int(txModel) != ctx.txModel {
+		return r.fail("tx model", "tx model %d, expected %d", txModel, ctx.txModel)
+	}
+	sigType, err := r.uvarint("sig type", 0xffff)
+	if err != nil {
+		return err
+	}
+	if int32(sigType) != ctx.sigContext.SigType {
+		return r.fail("sig type", "sig type %d, expected %d", sigType, ctx.sigContext.SigType)
+	}
+
+	// inputs are length-prefixed identifiers
+	inSize, err := r.count("input count", 1+sha256.Size)
+	if err != nil {
+		return err
+	}
+	tx.Data.Inputs = make([]InputData, inSize)
+	for i := range tx.Data.Inputs {
+		field := fmt.Sprintf("input[%d]", i)
+		start := r.pointer
+		if tx.Data.Inputs[i].Header, err = r.sized(field, sha256.Size); err != nil {
+			return err
+		}
+		if len(tx.Data.Inputs[i].Header) != sha256.Size {
+			r.pointer = start
+			return r.fail(field, "input of %d bytes", len(tx.Data.Inputs[i].Header))
+		}
+	}
+
+	// outputs that update accounts have an empty public key and may have empty data
+	minOutput := 1 + int(ctx.sigContext.PkSize) + 1 + 1
+	if ctx.model.IsAccount() {
+		minOutput = 2
+	}
+	outSize, err := r.count("output count", minOutput)
+	if err != nil {
+		return err
+	}
+	tx.Data.Outputs = make([]OutputData, outSize)
+	for i := range tx.Data.Outputs {
+		field := fmt.Sprintf("output[%d].pk", i)
+		start := r.pointer
+		pk, err := r.sized(field, int(ctx.sigContext.PkSize))
+		if err != nil {
+			return err
+		}
+		// only outputs that update accounts don't carry public keys
+		updates := i < len(tx.Data.Inputs) && ctx.model.IsAccount()
+		if (updates && len(pk) != 0) || (!updates && len(pk) != int(ctx.sigContext.PkSize)) {
+			r.pointer = start
+			return r.fail(field, "public key of %d bytes", len(pk))
+		}
+		if !updates {
+			tx.Data.Outputs[i].Pk = pk
+			n, err := r.bytes(fmt.Sprintf("output[%d].n", i), 1)
+			if err != nil {
+				return err
+			}
+			tx.Data.Outputs[i].N = n[0]
+		}
+		if tx.Data.Outputs[i].Data, err = r.sized(fmt.Sprintf("output[%d].data", i), MaxPayloadSize); err != nil {
+			return err
+		}
+	}
+
+	variableSigs := ctx.sigContext.variableAggregates()
+	minSig := 1 + int(ctx.sigContext.SigSize)
+	if variableSigs {
+		minSig = 1
+	}
+	sigSize, err := r.count("signature count", minSig)
+	if err != nil {
+		return err
+	}
+	tx.Txh.Kyber = make([]Signature, sigSize)
+	for i := range tx.Txh.Kyber {
+		field := fmt.Sprintf("signature[%d]", i)
+		start := r.pointer
+		sig, err := r.sized(field, 0xffff)
+		if err != nil {
+			return err
+		}
+		if !variableSigs && len(sig) != int(ctx.sigContext.SigSize) {
+			r.pointer = start
+			return r.fail(field, "signature of %d bytes", len(sig))
+		}
+		tx.Txh.Kyber[i] = sig
+	}
+	if ctx.origamiHeaders {
+		activitySize, excessSize := ctx.origamiHeaderSizes()
+		start := r.pointer
+		if tx.Txh.activityProof, err = r.sized("activity proof", activitySize); err != nil {
+			return err
+		}
+		if len(tx.Txh.activityProof) != activitySize {
+			r.pointer = start
+			return r.fail("activity proof", "activity proof of %d bytes", len(tx.Txh.activityProof))
+		}
+		if excessSize > 0 {
+			start = r.pointer
+			if tx.Txh.excessPK, err = r.sized("excess pk", excessSize); err != nil {
+				return err
+			}
+			if len(tx.Txh.excessPK) != excessSize {
+				r.pointer = start
+				return r.fail("excess pk", "public key of %d bytes", len(tx.Txh.excessPK))
+			}
+		}
+	}
+	if r.pointer != len(arr) {
+		return r.fail("end", "%d trailing bytes", len(arr)-r.pointer)
+	}
+	return nil
+}
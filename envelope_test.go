@@ -0,0 +1,109 @@
+package txhelper
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestEnvelopePeers(tester *testing.T) {
+	for i := 1; i <= 6; i++ {
+		for _, sigType := range []int32{1, 2, 8} {
+			if sigType == 8 && i >= 5 {
+				continue
+			}
+			ctx := newTestContext(tester, 180, 1, i, sigType, 32, 10, 2, 3, 1, false, 2)
+			ctx.testPeerTransactions(5, tester, WithWireFormat(EnvelopeFormat))
+		}
+		// variable payloads
+		ctx := newTestContext(tester, 180, 1, i, 1, 32, 10, 2, 3, 4, false, 2)
+		ctx.testPeerTransactions(5, tester, WithWireFormat(EnvelopeFormat))
+	}
+}
+
+func TestEnvelopeErrors(tester *testing.T) {
+	client := newTestContext(tester, 181, 1, 1, 1, 32, 10, 2, 3, 1, false, 2, WithWireFormat(EnvelopeFormat))
+	tx, err := client.FixedTransaction(0, 2)
+	if err != nil {
+		tester.Fatal("couldn't create tx:", err)
+	}
+	txBytes := client.ToBytes(tx)
+	if !bytes.HasPrefix(txBytes, []byte("TXHE\x01\x01\x01")) {
+		tester.Fatal("invalid envelope header:", txBytes[:7])
+	}
+
+	// peers with another payload size can parse envelopes
+	peer := newTestContext(tester, 181, 2, 1, 1, 64, 10, 2, 3, 1, false, 2, WithWireFormat(EnvelopeFormat))
+	var tx1 Transaction
+	if err = peer.FromBytes(txBytes, &tx1); err != nil {
+		tester.Fatal("couldn't parse tx:", err)
+	}
+	if !bytes.Equal(tx1.Data.Outputs[1].Data, tx.Data.Outputs[1].Data) {
+		tester.Fatal("payload changed")
+	}
+
+	// other models and signature types are detected
+	var decodeErr *DecodeError
+	peer = newTestContext(tester, 182, 2, 3, 1, 32, 10, 2, 3, 1, false, 2, WithWireFormat(EnvelopeFormat))
+	if err = peer.FromBytes(txBytes, &tx1); !errors.As(err, &decodeErr) || decodeErr.Field != "tx model" {
+		tester.Fatal("another tx model was parsed:", err)
+	}
+	peer = newTestContext(tester, 182, 2, 1, 2, 32, 10, 2, 3, 1, false, 2, WithWireFormat(EnvelopeFormat))
+	if err = peer.FromBytes(txBytes, &tx1); !errors.As(err, &decodeErr) || decodeErr.Field != "sig type" {
+		tester.Fatal("another sig type was parsed:", err)
+	}
+
+	// every truncation is reported with the field
+	for n := 0; n < len(txBytes); n++ {
+		err = client.FromBytes(txBytes[:n], &tx1)
+		if !errors.Is(err, ErrMalformedEncoding) || !errors.As(err, &decodeErr) {
+			tester.Fatal("truncated tx was parsed:", n, err)
+		}
+		if decodeErr.Offset > n {
+			tester.Fatal("invalid offset:", n, decodeErr)
+		}
+	}
+	if err = client.FromBytes(append(txBytes, 0), &tx1); !errors.As(err, &decodeErr) || decodeErr.Field != "end" {
+		tester.Fatal("trailing bytes were accepted:", err)
+	}
+	// counts can't be larger than the remaining bytes allow
+	corrupted := append([]byte("TXHE\x01\x01\x01\x64"), make([]byte, 100)...)
+	if err = client.FromBytes(corrupted, &tx1); !errors.As(err, &decodeErr) || decodeErr.Field != "input count" {
+		tester.Fatal("too large input count was accepted:", err)
+	}
+	corrupted = append([]byte(nil), txBytes...)
+	corrupted[4] = 2
+	if err = client.FromBytes(corrupted, &tx1); !errors.As(err, &decodeErr) || decodeErr.Field != "version" {
+		tester.Fatal("unknown version was accepted:", err)
+	}
+
+	// compact transactions are not envelopes
+	compact := newTestContext(tester, 183, 1, 1, 1, 32, 10, 2, 3, 1, false, 2)
+	tx, err = compact.FixedTransaction(0, 2)
+	if err != nil {
+		tester.Fatal("couldn't create tx:", err)
+	}
+	if err = client.FromBytes(compact.ToBytes(tx), &tx1); !errors.As(err, &decodeErr) || decodeErr.Field != "magic" {
+		tester.Fatal("compact tx was parsed as an envelope:", err)
+	}
+	if _, err = NewContext(184, 1, 1, 1, 32, 10, 2, 3, 1, false, 2, WithWireFormat(5)); !errors.Is(err, ErrInvalidConfig) {
+		tester.Fatal("unknown wire format was accepted:", err)
+	}
+}
+
+func TestEnvelopeCounts(tester *testing.T) {
+	// envelopes keep counts of 255 and more, which single bytes of the compact format can't
+	client := newTestContext(tester, 185, 1, 1, 1, 8, 300, 2, 3, 1, false, 2, WithWireFormat(EnvelopeFormat))
+	tx, err := client.FixedTransaction(0, 255)
+	if err != nil {
+		tester.Fatal("couldn't create tx:", err)
+	}
+	tx.Data.Outputs = append(tx.Data.Outputs, tx.Data.Outputs[0])
+	var tx1 Transaction
+	if err = client.FromBytes(client.ToBytes(tx), &tx1); err != nil {
+		tester.Fatal("couldn't parse tx:", err)
+	}
+	if len(tx1.Data.Outputs) != 256 {
+		tester.Fatal("invalid output count:", len(tx1.Data.Outputs))
+	}
+}
@@ -0,0 +1,65 @@
+/**********************************************************************
+ * Copyright (c) 2017 Jayamine Alupotha                               *
+ * Distributed under the MIT software license, see the accompanying   *
+ * file COPYING or http://www.opensource.org/licenses/mit-license.php.*
+ **********************************************************************/
+
+package txhelper
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Errors returned by txhelper. Use errors.Is to check them since most of them are wrapped with more details.
+var (
+	ErrInvalidConfig     = errors.New("TXHELPER_INVALID_CONFIG")
+	ErrUnknownTxModel    = errors.New("TXHELPER_UNKNOWN_TX_MODEL")
+	ErrUnknownSigType    = errors.New("TXHELPER_UNKNOWN_SIG_TYPE")
+	ErrUnknownUserType   = errors.New("TXHELPER_UNKNOWN_USER_TYPE")
+	ErrNotPeer           = errors.New("TXHELPER_NOT_A_PEER")
+	ErrDatabase          = errors.New("TXHELPER_DATABASE")
+	ErrUnknownInput      = errors.New("TXHELPER_NOT_FOUND_OUT")
+	ErrDoubleSpend       = errors.New("TXHELPER_REUSED_IN")
+	ErrDuplicateInput    = errors.New("TXHELPER_DUPLICATE_INPUTS")
+	ErrDuplicateOutput   = errors.New("TXHELPER_DUPLICATE_OUTPUTS")
+	ErrReusedPublicKey   = errors.New("TXHELPER_DUPLICATE_PK")
+	ErrInvalidSignature  = errors.New("TXHELPER_INVALID_SIG")
+	ErrSigning           = errors.New("TXHELPER_SIGNING")
+	ErrMalformedEncoding = errors.New("TXHELPER_MALFORMED_ENCODING")
+	ErrUnverified        = errors.New("TXHELPER_UNVERIFIED_TX")
+	ErrInvalidActivity   = errors.New("TXHELPER_INVALID_ACTIVITY")
+	ErrInvalidBlock      = errors.New("TXHELPER_INVALID_BLOCK")
+	ErrInvalidChain      = errors.New("TXHELPER_INVALID_CHAIN")
+	ErrInvalidProof      = errors.New("TXHELPER_INVALID_PROOF")
+	ErrNotStored         = errors.New("TXHELPER_NOT_STORED")
+)
+
+// TxError tells which operation failed and, if it is known, the index of the input, output, signature,
+// transaction or block that caused the failure.
+type TxError struct {
+	Op    string // failed operation
+	Index int    // -1 if there is no related index
+	Err   error  // cause
+}
+
+func (e *TxError) Error() string {
+	if e.Index < 0 {
+		return e.Op + ": " + e.Err.Error()
+	}
+	return fmt.Sprintf("%s [%d]: %s", e.Op, e.Index, e.Err.Error())
+}
+
+func (e *TxError) Unwrap() error {
+	return e.Err
+}
+
+// newTxError wraps err with the failed operation and the related index
+func newTxError(op string, index int, err error) error {
+	return &TxError{Op: op, Index: index, Err: err}
+}
+
+// dbError marks errors coming from the database, so both ErrDatabase and the original error can be checked
+func dbError(err error) error {
+	return fmt.Errorf("%w: %w", ErrDatabase, err)
+}
@@ -0,0 +1,135 @@
+package txhelper
+
+import (
+	"errors"
+	"testing"
+)
+
+// committedTxWithInputs sends random transactions from ctxClient to ctxPeer until one of them spends some inputs
+func committedTxWithInputs(ctxClient *ExeContext, ctxPeer *ExeContext, tester *testing.T) []byte {
+	for i := 0; i < 100; i++ {
+		tx, err := ctxClient.RandomTransaction()
+		if err != nil {
+			tester.Fatal("couldn't create tx:", err, ctxClient.txModel)
+		}
+		if err := ctxClient.VerifyIncomingTransaction(tx); err != nil {
+			tester.Fatal("invalid transaction in the client:", err, ctxClient.txModel)
+		}
+		if err := ctxClient.UpdateAppDataClient(&tx.Data); err != nil {
+			tester.Fatal("could not update tx in the client:", err, ctxClient.txModel)
+		}
+
+		txBytes := ctxClient.ToBytes(tx)
+		var tx1 Transaction
+		if err := ctxPeer.FromBytes(txBytes, &tx1); err != nil {
+			tester.Fatal("couldn't parse tx:", err, ctxPeer.txModel)
+		}
+		if err := ctxPeer.VerifyIncomingTransaction(&tx1); err != nil {
+			tester.Fatal("invalid transaction in the peer:", err, ctxPeer.txModel)
+		}
+		if err := ctxPeer.CommitTransaction(i, &tx1); err != nil {
+			tester.Fatal("could not commit tx in the peer:", err, ctxPeer.txModel)
+		}
+		if len(tx.Data.Inputs) > 0 {
+			return txBytes
+		}
+	}
+	tester.Fatal("couldn't create a transaction with inputs:", ctxClient.txModel)
+	return nil
+}
+
+func TestConfigErrors(tester *testing.T) {
+	_, err := NewContext(300, 1, 7, 1, 32, 10, 2, 3, 1, false, 2)
+	if !errors.Is(err, ErrUnknownTxModel) {
+		tester.Fatal("unknown tx model was accepted:", err)
+	}
+	_, err = NewContext(300, 1, 1, 9, 32, 10, 2, 3, 1, false, 2)
+	if !errors.Is(err, ErrUnknownSigType) {
+		tester.Fatal("unknown signature type was accepted:", err)
+	}
+	_, err = NewContext(300, 3, 1, 1, 32, 10, 2, 3, 1, false, 2)
+	if !errors.Is(err, ErrUnknownUserType) {
+		tester.Fatal("unknown user type was accepted:", err)
+	}
+	_, err = NewContext(300, 1, 2, 1, 32, 10, 4, 3, 1, false, 2)
+	if !errors.Is(err, ErrInvalidConfig) {
+		tester.Fatal("invalid input size was accepted:", err)
+	}
+	_, err = NewContext(300, 1, 1, 1, 32, 2, 2, 3, 1, false, 2)
+	if !errors.Is(err, ErrInvalidConfig) {
+		tester.Fatal("invalid total users were accepted:", err)
+	}
+}
+
+func TestDoubleSpendErrors(tester *testing.T) {
+	for i := 1; i <= 6; i++ {
+		ctxClient := newTestContext(tester, 310, 1, i, 1, 32, 10, 2, 3, 1, false, 2)
+		ctxPeer := newTestContext(tester, 310, 2, i, 1, 32, 10, 2, 3, 1, false, 2)
+
+		txBytes := committedTxWithInputs(&ctxClient, &ctxPeer, tester)
+
+		var tx Transaction
+		if err := ctxPeer.FromBytes(txBytes, &tx); err != nil {
+			tester.Fatal("couldn't parse tx:", err, i)
+		}
+		err := ctxPeer.VerifyIncomingTransaction(&tx)
+		if !errors.Is(err, ErrDoubleSpend) && !errors.Is(err, ErrUnknownInput) {
+			tester.Fatal("spent inputs were accepted:", err, i)
+		}
+		var txErr *TxError
+		if !errors.As(err, &txErr) || txErr.Index != 0 {
+			tester.Fatal("invalid input index:", err, i)
+		}
+	}
+}
+
+func TestSignatureErrors(tester *testing.T) {
+	for i := 1; i <= 6; i++ {
+		for sigType := int32(1); sigType <= 3; sigType++ {
+			if i == 5 && sigType == 3 { // no difference signatures
+				continue
+			}
+			ctxClient := newTestContext(tester, 320, 1, i, sigType, 32, 10, 2, 3, 1, false, 2)
+			ctxPeer := newTestContext(tester, 320, 2, i, sigType, 32, 10, 2, 3, 1, false, 2)
+
+			tx, err := ctxClient.RandomTransaction()
+			if err != nil {
+				tester.Fatal("couldn't create tx:", err, i)
+			}
+			// signatures do not cover the modified payload anymore
+			tx.Data.Outputs[0].Data[0] ^= 1
+
+			var tx1 Transaction
+			if err := ctxPeer.FromBytes(ctxClient.ToBytes(tx), &tx1); err != nil {
+				tester.Fatal("couldn't parse tx:", err, i)
+			}
+			err = ctxPeer.VerifyIncomingTransaction(&tx1)
+			if !errors.Is(err, ErrInvalidSignature) {
+				tester.Fatal("tampered transaction was accepted:", err, i, sigType)
+			}
+		}
+	}
+}
+
+func TestEncodingErrors(tester *testing.T) {
+	for i := 1; i <= 6; i++ {
+		ctxClient := newTestContext(tester, 330, 1, i, 1, 32, 10, 2, 3, 1, false, 2)
+
+		tx, err := ctxClient.RandomTransaction()
+		if err != nil {
+			tester.Fatal("couldn't create tx:", err, i)
+		}
+		txBytes := ctxClient.ToBytes(tx)
+
+		for _, size := range []int{0, 1, len(txBytes) / 2, len(txBytes) - 1} {
+			var tx1 Transaction
+			if err := ctxClient.FromBytes(txBytes[:size], &tx1); !errors.Is(err, ErrMalformedEncoding) {
+				tester.Fatal("truncated tx was accepted:", err, i, size)
+			}
+		}
+		var tx1 Transaction
+		if err := ctxClient.FromBytes(append(txBytes, 0), &tx1); !errors.Is(err, ErrMalformedEncoding) {
+			tester.Fatal("tx with trailing bytes was accepted:", err, i)
+		}
+	}
+}
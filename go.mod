@@ -3,12 +3,21 @@ module github.com/zero-history/txhelper
 go 1.20
 
 require (
+	github.com/btcsuite/btcd/btcec/v2 v2.3.2
+	github.com/fxamacker/cbor/v2 v2.5.0
+	github.com/kilic/bls12-381 v0.1.0
 	github.com/mattn/go-sqlite3 v1.14.17
 	go.dedis.ch/kyber/v3 v3.1.0
+	go.etcd.io/bbolt v1.3.8
 	golang.org/x/crypto v0.0.0-20190123085648-057139ce5d2b
+	google.golang.org/protobuf v1.31.0
 )
 
 require (
+	github.com/btcsuite/btcd/chaincfg/chainhash v1.0.1 // indirect
+	github.com/decred/dcrd/crypto/blake256 v1.0.0 // indirect
+	github.com/decred/dcrd/dcrec/secp256k1/v4 v4.0.1 // indirect
+	github.com/x448/float16 v0.8.4 // indirect
 	go.dedis.ch/fixbuf v1.0.3 // indirect
-	golang.org/x/sys v0.0.0-20190124100055-b90733256f2e // indirect
+	golang.org/x/sys v0.4.0 // indirect
 )
@@ -0,0 +1,262 @@
+/**********************************************************************
+ * Copyright (c) 2017 Jayamine Alupotha                               *
+ * Distributed under the MIT software license, see the accompanying   *
+ * file COPYING or http://www.opensource.org/licenses/mit-license.php.*
+ **********************************************************************/
+
+package txhelper
+
+import (
+	"bytes"
+	"crypto/sha512"
+	"errors"
+	"fmt"
+	"go.dedis.ch/kyber/v3"
+	"go.dedis.ch/kyber/v3/group/edwards25519"
+	"go.dedis.ch/kyber/v3/pairing"
+	"go.dedis.ch/kyber/v3/sign/schnorr"
+	"go.dedis.ch/kyber/v3/util/random"
+	"go.dedis.ch/kyber/v3/xof/blake2xb"
+	"io"
+)
+
+var (
+	ed25519Suite = edwards25519.NewBlakeSHA256Ed25519()
+	bn256Suite   = pairing.NewSuiteBn256()
+)
+
+// kyberKeys implements the key encoding and the key tweaking of schemes that use kyber points as public keys and
+// kyber scalars as secret keys
+type kyberKeys struct {
+	group kyber.Group
+}
+
+func (k kyberKeys) PublicKey(keys *SigKeyPair) Pubkey {
+	return Pubkey{kyber: keys.Pk}
+}
+
+func (k kyberKeys) MarshalKeys(keys *SigKeyPair, buf *bytes.Buffer) {
+	_, _ = keys.Pk.MarshalTo(buf)
+	_, _ = keys.Sk.MarshalTo(buf)
+}
+
+func (k kyberKeys) UnmarshalKeys(keys *SigKeyPair, buf []byte) error {
+	keys.Pk = k.group.Point().Base()
+	keys.Sk = k.group.Scalar()
+	pkSize := keys.Pk.MarshalSize()
+	if err := keys.Pk.UnmarshalBinary(buf[:pkSize]); err != nil {
+		return err
+	}
+	return keys.Sk.UnmarshalBinary(buf[pkSize : pkSize+keys.Sk.MarshalSize()])
+}
+
+func (k kyberKeys) MarshalPublicKey(pk *Pubkey) ([]byte, error) {
+	return pk.kyber.MarshalBinary()
+}
+
+func (k kyberKeys) UnmarshalPublicKey(pk *Pubkey, buf []byte) error {
+	pk.kyber = k.group.Point().Base()
+	return pk.kyber.UnmarshalBinary(buf)
+}
+
+func (k kyberKeys) AddKeyPairs(keys []*SigKeyPair, negKeys []*SigKeyPair) SigKeyPair {
+	aggregate := SigKeyPair{Pk: keys[0].Pk.Clone(), Sk: keys[0].Sk.Clone()}
+	for i := 1; i < len(keys); i++ {
+		aggregate.Pk.Add(aggregate.Pk, keys[i].Pk)
+		aggregate.Sk.Add(aggregate.Sk, keys[i].Sk)
+	}
+	for i := 0; i < len(negKeys); i++ {
+		aggregate.Pk.Sub(aggregate.Pk, negKeys[i].Pk)
+		aggregate.Sk.Sub(aggregate.Sk, negKeys[i].Sk)
+	}
+	return aggregate
+}
+
+func (k kyberKeys) AddPublicKeys(keys []*Pubkey, negKeys []*Pubkey) Pubkey {
+	aggregate := keys[0].kyber.Clone()
+	for i := 1; i < len(keys); i++ {
+		aggregate.Add(aggregate, keys[i].kyber)
+	}
+	for i := 0; i < len(negKeys); i++ {
+		aggregate.Sub(aggregate, negKeys[i].kyber)
+	}
+	return Pubkey{kyber: aggregate}
+}
+
+// tweak returns a - b, or a if b is nil
+func (k kyberKeys) tweak(a []byte, b []byte) kyber.Scalar {
+	s := k.group.Scalar().SetBytes(a)
+	if b != nil {
+		s = s.Sub(s, k.group.Scalar().SetBytes(b))
+	}
+	return s
+}
+
+func (k kyberKeys) MulKeyPair(keys *SigKeyPair, a []byte, b []byte) {
+	keys.Sk = k.group.Scalar().Mul(k.tweak(a, b), keys.Sk)
+	keys.Pk = k.group.Point().Base().Mul(keys.Sk, nil)
+}
+
+func (k kyberKeys) MulPublicKey(pk *Pubkey, a []byte, b []byte) {
+	pk.kyber = k.group.Point().Mul(k.tweak(a, b), pk.kyber)
+}
+
+// KyberSchnorr is the Schnorr signature of dedis/kyber on edwards25519 (sigType 1)
+type KyberSchnorr struct {
+	kyberKeys
+}
+
+// NewKyberSchnorr returns the Schnorr scheme of sigType 1
+func NewKyberSchnorr() KyberSchnorr {
+	return KyberSchnorr{kyberKeys{group: ed25519Suite}}
+}
+
+func (KyberSchnorr) Name() string {
+	return "schnorr"
+}
+
+func (KyberSchnorr) Sizes() (int32, int32, int32) {
+	return 32, 32, 64
+}
+
+func (KyberSchnorr) Generate(rand io.Reader, keys *SigKeyPair) error {
+	keys.Sk = ed25519Suite.NewKey(random.New(rand))
+	keys.Pk = ed25519Suite.Point().Mul(keys.Sk, nil)
+	return nil
+}
+
+// schnorrNonceTag separates the nonce derivation of KyberSchnorr from other hashes of secret keys
+const schnorrNonceTag = "txhelper/schnorr/nonce"
+
+// Sign derives the nonce from the secret key and msg like EdDSA, so signatures don't depend on the system randomness
+func (KyberSchnorr) Sign(keys *SigKeyPair, msg []byte) (Signature, error) {
+	skBytes, err := keys.Sk.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	hasher := sha512.New()
+	hasher.Write([]byte(schnorrNonceTag))
+	hasher.Write([]byte{0})
+	hasher.Write(skBytes)
+	hasher.Write(msg)
+	return schnorr.Sign(edwards25519.NewBlakeSHA256Ed25519WithRand(blake2xb.New(hasher.Sum(nil))), keys.Sk, msg)
+}
+
+func (KyberSchnorr) Verify(pk *Pubkey, msg []byte, sig Signature) bool {
+	return schnorr.Verify(ed25519Suite, pk.kyber, msg, sig) == nil
+}
+
+// KyberBLS is the BLS signature of dedis/kyber on bn256 with public keys in G2 (sigType 2).
+// Messages are signed as msg || pk, so signatures of the same message can be aggregated.
+type KyberBLS struct {
+	kyberKeys
+}
+
+// NewKyberBLS returns the BLS scheme of sigType 2
+func NewKyberBLS() KyberBLS {
+	return KyberBLS{kyberKeys{group: bn256Suite.G2()}}
+}
+
+func (KyberBLS) Name() string {
+	return "bls"
+}
+
+func (KyberBLS) Sizes() (int32, int32, int32) {
+	return 32, 128, 64
+}
+
+func (KyberBLS) Generate(rand io.Reader, keys *SigKeyPair) error {
+	keys.Sk = bn256Suite.G2().Scalar().Pick(random.New(rand))
+	keys.Pk = bn256Suite.G2().Point().Mul(keys.Sk, nil)
+	return nil
+}
+
+func (KyberBLS) Sign(keys *SigKeyPair, msg []byte) (Signature, error) {
+	return signBLS(bn256Suite, keys.Sk, keys.Pk, msg)
+}
+
+func (KyberBLS) Verify(pk *Pubkey, msg []byte, sig Signature) bool {
+	return verifyBLS(bn256Suite, pk.kyber, msg, sig) == nil
+}
+
+// AggregateSignatures This is modified to remove copying signature bytes (AggregateSignatures from dedis/kyber)
+func (KyberBLS) AggregateSignatures(pks []Pubkey, msgs [][]byte, sigs []Signature) (Signature, error) {
+	sig := bn256Suite.G1().Point()
+	for i := 0; i < len(sigs); i++ {
+		sigToAdd := bn256Suite.G1().Point()
+		if err := sigToAdd.UnmarshalBinary(sigs[i]); err != nil {
+			return nil, newTxError("aggregate signatures", i, fmt.Errorf("%w: %w", ErrMalformedEncoding, err))
+		}
+		sig.Add(sig, sigToAdd)
+	}
+	return sig.MarshalBinary()
+}
+
+// VerifyAggregate This is modified to remove copying public key bytes (BatchVerify from dedis/kyber)
+func (KyberBLS) VerifyAggregate(pks []Pubkey, msgs [][]byte, sig Signature) bool {
+	if len(pks) == 0 || len(pks) != len(msgs) {
+		return false
+	}
+	s := bn256Suite.G1().Point()
+	if err := s.UnmarshalBinary(sig); err != nil {
+		return false
+	}
+
+	var aggregatedLeft kyber.Point
+	for i := range pks {
+		hashable, ok := bn256Suite.G1().Point().(hashablePoint)
+		if !ok {
+			return false
+		}
+		pkBytes, _ := pks[i].kyber.MarshalBinary()
+		HM := hashable.Hash(append(msgs[i], pkBytes...))
+		pair := bn256Suite.Pair(HM, pks[i].kyber)
+
+		if i == 0 {
+			aggregatedLeft = pair
+		} else {
+			aggregatedLeft.Add(aggregatedLeft, pair)
+		}
+	}
+
+	right := bn256Suite.Pair(s, bn256Suite.G2().Point().Base())
+	return aggregatedLeft.Equal(right)
+}
+
+// signBLS is an updated version of original dedis/kyber bls signing for pk-based message signing to avoid searching for duplicate msgs
+// because we already make sure that public keys are unique
+func signBLS(suite pairing.Suite, sk kyber.Scalar, pk kyber.Point, msg []byte) ([]byte, error) {
+	hashable, ok := suite.G1().Point().(hashablePoint)
+	if !ok {
+		return nil, errors.New("point needs to implement hashablePoint")
+	}
+	pkBytes, _ := pk.MarshalBinary()
+	HM := hashable.Hash(append(msg, pkBytes...))
+	xHM := HM.Mul(sk, HM)
+
+	s, err := xHM.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func verifyBLS(suite pairing.Suite, pk kyber.Point, msg, sig []byte) error {
+	hashable, ok := suite.G1().Point().(hashablePoint)
+	if !ok {
+		return errors.New("bls: point needs to implement hashablePoint")
+	}
+	var HM kyber.Point
+	pkBytes, _ := pk.MarshalBinary()
+	HM = hashable.Hash(append(msg, pkBytes...))
+	left := suite.Pair(HM, pk)
+	s := suite.G1().Point()
+	if err := s.UnmarshalBinary(sig); err != nil {
+		return err
+	}
+	right := suite.Pair(s, suite.G2().Point().Base())
+	if !left.Equal(right) {
+		return errors.New("bls: invalid signature")
+	}
+	return nil
+}
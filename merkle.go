@@ -0,0 +1,173 @@
+/**********************************************************************
+ * Copyright (c) 2017 Jayamine Alupotha                               *
+ * Distributed under the MIT software license, see the accompanying   *
+ * file COPYING or http://www.opensource.org/licenses/mit-license.php.*
+ **********************************************************************/
+
+package txhelper
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+)
+
+// MerkleTree is the tree behind block roots. Leaves are the hashes of the transaction identifiers, and every other node
+// is the hash of its two children. Leaves and nodes are hashed with different domain tags, so an interior node can't be
+// passed off as a leaf. A node without a sibling is moved to the next level unchanged. The root is the hash of the
+// number of leaves and the top node, so a proof also shows the position of the leaf.
+type MerkleTree struct {
+	levels [][][]byte // levels[0] are the leaves, and the last level is the root
+}
+
+// MerkleProof shows that a transaction identifier is in a tree. Siblings are ordered from the leaves to the root, and
+// levels where the node doesn't have a sibling are skipped.
+type MerkleProof struct {
+	Index    int      `json:"i"` // index of the transaction in the block
+	Count    int      `json:"c"` // number of transactions in the block
+	Siblings [][]byte `json:"s"` // sibling hashes
+}
+
+const (
+	merkleLeafTag = "txhelper/merkle/leaf"
+	merkleNodeTag = "txhelper/merkle/node"
+	merkleRootTag = "txhelper/merkle/root"
+)
+
+// merkleLeaf hashes an identifier
+func merkleLeaf(identifier []byte) []byte {
+	return taggedHash(merkleLeafTag, identifier)
+}
+
+// merkleNode hashes two children
+func merkleNode(left []byte, right []byte) []byte {
+	return taggedHash(merkleNodeTag, left, right)
+}
+
+// merkleRoot hashes the number of leaves and the top node
+func merkleRoot(count int, top []byte) []byte {
+	return taggedHash(merkleRootTag, binary.BigEndian.AppendUint64(nil, uint64(count)), top)
+}
+
+// NewMerkleTree builds the tree of the identifiers
+func NewMerkleTree(identifiers [][]byte) *MerkleTree {
+	tree := &MerkleTree{}
+	if len(identifiers) == 0 {
+		return tree
+	}
+	level := make([][]byte, len(identifiers))
+	for i := 0; i < len(identifiers); i++ {
+		level[i] = merkleLeaf(identifiers[i])
+	}
+	tree.levels = append(tree.levels, level)
+	for len(level) > 1 {
+		next := make([][]byte, 0, (len(level)+1)/2)
+		for i := 0; i < len(level); i += 2 {
+			if i+1 == len(level) {
+				next = append(next, level[i])
+				continue
+			}
+			next = append(next, merkleNode(level[i], level[i+1]))
+		}
+		tree.levels = append(tree.levels, next)
+		level = next
+	}
+	return tree
+}
+
+// Root returns the root of the tree, or zeros if the tree is empty
+func (tree *MerkleTree) Root() []byte {
+	if len(tree.levels) == 0 {
+		return make([]byte, sha256.Size)
+	}
+	return merkleRoot(len(tree.levels[0]), tree.levels[len(tree.levels)-1][0])
+}
+
+// Proof returns the inclusion proof of the identifier at index
+func (tree *MerkleTree) Proof(index int) (*MerkleProof, error) {
+	if len(tree.levels) == 0 || index < 0 || index >= len(tree.levels[0]) {
+		return nil, fmt.Errorf("%w: no transaction at %d", ErrInvalidProof, index)
+	}
+	proof := &MerkleProof{Index: index, Count: len(tree.levels[0])}
+	for _, level := range tree.levels[:len(tree.levels)-1] {
+		sibling := index ^ 1
+		if sibling < len(level) {
+			proof.Siblings = append(proof.Siblings, level[sibling])
+		}
+		index /= 2
+	}
+	return proof, nil
+}
+
+// VerifyMerkleProof checks that the identifier is in the tree of root. Identifiers must be 32 bytes.
+func VerifyMerkleProof(root []byte, identifier []byte, proof *MerkleProof) error {
+	if proof == nil || proof.Index < 0 || proof.Index >= proof.Count {
+		return fmt.Errorf("%w: index is out of range", ErrInvalidProof)
+	}
+	if len(identifier) != sha256.Size {
+		return fmt.Errorf("%w: identifier must be %d bytes", ErrInvalidProof, sha256.Size)
+	}
+	hash := merkleLeaf(identifier)
+	index, width, used := proof.Index, proof.Count, 0
+	for width > 1 {
+		if index^1 < width {
+			if used == len(proof.Siblings) {
+				return fmt.Errorf("%w: missing siblings", ErrInvalidProof)
+			}
+			if index%2 == 0 {
+				hash = merkleNode(hash, proof.Siblings[used])
+			} else {
+				hash = merkleNode(proof.Siblings[used], hash)
+			}
+			used++
+		}
+		index /= 2
+		width = (width + 1) / 2
+	}
+	if used != len(proof.Siblings) {
+		return fmt.Errorf("%w: too many siblings", ErrInvalidProof)
+	}
+	if !bytes.Equal(merkleRoot(proof.Count, hash), root) {
+		return fmt.Errorf("%w: root mismatch", ErrInvalidProof)
+	}
+	return nil
+}
+
+// blockTree builds the tree of the block's transactions. Transactions must be verified before.
+func (ctx *ExeContext) blockTree(txs []*Transaction) (*MerkleTree, error) {
+	identifiers := make([][]byte, len(txs))
+	for i := 0; i < len(txs); i++ {
+		identifier, err := ctx.GetTxHeaderIdentifier(txs[i], nil)
+		if err != nil {
+			return nil, newTxError("compute block root", i, err)
+		}
+		identifiers[i] = identifier
+	}
+	return NewMerkleTree(identifiers), nil
+}
+
+// InclusionProof returns the proof that the transaction at index is in the block. Transactions of the block must be
+// verified (or proposed) before.
+func (ctx *ExeContext) InclusionProof(b *Block, index int) (*MerkleProof, error) {
+	tree, err := ctx.blockTree(b.Txs)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(tree.Root(), b.Root) {
+		return nil, fmt.Errorf("%w: root mismatch", ErrInvalidBlock)
+	}
+	return tree.Proof(index)
+}
+
+// VerifyInclusion checks that the transaction is in the block of root without the other transactions. Origami UTXO
+// transactions need their activity proof and excess public key, so they must be verified or received with
+// WithOrigamiHeaders. Origami account transactions also need the public keys of updated accounts, which only peers
+// know after verifying them.
+func (ctx *ExeContext) VerifyInclusion(root []byte, tx *Transaction, proof *MerkleProof) error {
+	identifier, err := ctx.WTxID(tx)
+	if err != nil {
+		return err
+	}
+	return VerifyMerkleProof(root, identifier, proof)
+}
@@ -0,0 +1,154 @@
+package txhelper
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestMerkleProofs(tester *testing.T) {
+	for count := 1; count <= 9; count++ {
+		identifiers := make([][]byte, count)
+		for i := 0; i < count; i++ {
+			identifiers[i] = bytes.Repeat([]byte{byte(i + 1)}, 32)
+		}
+		tree := NewMerkleTree(identifiers)
+		for i := 0; i < count; i++ {
+			proof, err := tree.Proof(i)
+			if err != nil {
+				tester.Fatal("couldn't create the proof:", err, count, i)
+			}
+			if err = VerifyMerkleProof(tree.Root(), identifiers[i], proof); err != nil {
+				tester.Fatal("valid proof was rejected:", err, count, i)
+			}
+			if err = VerifyMerkleProof(tree.Root(), identifiers[(i+1)%count], proof); count > 1 && !errors.Is(err, ErrInvalidProof) {
+				tester.Fatal("proof of another identifier was accepted:", count, i)
+			}
+			if len(proof.Siblings) > 0 {
+				proof.Siblings = proof.Siblings[1:]
+				if err = VerifyMerkleProof(tree.Root(), identifiers[i], proof); !errors.Is(err, ErrInvalidProof) {
+					tester.Fatal("proof without a sibling was accepted:", count, i)
+				}
+			}
+		}
+		if _, err := tree.Proof(count); !errors.Is(err, ErrInvalidProof) {
+			tester.Fatal("proof of a missing transaction was created")
+		}
+	}
+	if !bytes.Equal(NewMerkleTree(nil).Root(), make([]byte, 32)) {
+		tester.Fatal("empty tree must have a zero root")
+	}
+}
+
+func TestMerkleNodeForgery(tester *testing.T) {
+	identifiers := make([][]byte, 4)
+	for i := 0; i < len(identifiers); i++ {
+		identifiers[i] = bytes.Repeat([]byte{byte(i + 1)}, 32)
+	}
+	tree := NewMerkleTree(identifiers)
+	proof, err := tree.Proof(0)
+	if err != nil {
+		tester.Fatal("couldn't create the proof:", err)
+	}
+
+	// the children of an interior node, presented as a 64-byte identifier of a two-leaf tree
+	forged := append(append([]byte{}, tree.levels[0][0]...), tree.levels[0][1]...)
+	forgedProof := &MerkleProof{Index: 0, Count: 2, Siblings: [][]byte{tree.levels[1][1]}}
+	if err = VerifyMerkleProof(tree.Root(), forged, forgedProof); !errors.Is(err, ErrInvalidProof) {
+		tester.Fatal("interior node was accepted as an identifier")
+	}
+	// an interior node presented as a 32-byte identifier
+	if err = VerifyMerkleProof(tree.Root(), tree.levels[1][0], forgedProof); !errors.Is(err, ErrInvalidProof) {
+		tester.Fatal("interior node hash was accepted as an identifier")
+	}
+	// leaves and nodes are hashed differently
+	if bytes.Equal(merkleLeaf(forged), merkleNode(tree.levels[0][0], tree.levels[0][1])) {
+		tester.Fatal("leaves and nodes have the same hash")
+	}
+	if err = VerifyMerkleProof(tree.Root(), identifiers[0], proof); err != nil {
+		tester.Fatal("valid proof was rejected:", err)
+	}
+}
+
+func TestMerklePosition(tester *testing.T) {
+	identifiers := make([][]byte, 3)
+	for i := 0; i < len(identifiers); i++ {
+		identifiers[i] = bytes.Repeat([]byte{byte(i + 1)}, 32)
+	}
+	tree := NewMerkleTree(identifiers)
+	proof, err := tree.Proof(2)
+	if err != nil {
+		tester.Fatal("couldn't create the proof:", err)
+	}
+	if err = VerifyMerkleProof(tree.Root(), identifiers[2], proof); err != nil {
+		tester.Fatal("valid proof was rejected:", err)
+	}
+
+	// the last leaf at index 1 of a two-leaf tree has the same path to the top node
+	forged := &MerkleProof{Index: 1, Count: 2, Siblings: proof.Siblings}
+	if err = VerifyMerkleProof(tree.Root(), identifiers[2], forged); !errors.Is(err, ErrInvalidProof) {
+		tester.Fatal("proof with a forged count was accepted")
+	}
+	for _, count := range []int{4, 5} {
+		forged = &MerkleProof{Index: 2, Count: count, Siblings: proof.Siblings}
+		if err = VerifyMerkleProof(tree.Root(), identifiers[2], forged); !errors.Is(err, ErrInvalidProof) {
+			tester.Fatal("proof with a forged count was accepted:", count)
+		}
+	}
+	for index := 0; index < 2; index++ {
+		forged = &MerkleProof{Index: index, Count: 3, Siblings: proof.Siblings}
+		if err = VerifyMerkleProof(tree.Root(), identifiers[2], forged); !errors.Is(err, ErrInvalidProof) {
+			tester.Fatal("proof with a forged index was accepted:", index)
+		}
+	}
+}
+
+func TestBlockInclusion(tester *testing.T) {
+	for _, txType := range []int{1, 3, 5} {
+		var opts []Option
+		if txType > 4 {
+			opts = append(opts, WithOrigamiHeaders())
+		}
+		client := newTestContext(tester, 240, 1, txType, 1, 32, 10, 2, 3, 1, false, 2, opts...)
+		peer := newTestContext(tester, 240, 2, txType, 1, 32, 10, 2, 3, 1, false, 2, opts...)
+		light := newTestContext(tester, 241, 2, txType, 1, 32, 10, 2, 3, 1, false, 2, opts...)
+
+		txs := make([]*Transaction, 5)
+		received := make([]*Transaction, 5)
+		for i := 0; i < len(txs); i++ {
+			tx, err := client.RandomTransaction()
+			if err != nil {
+				tester.Fatal("couldn't create tx:", err)
+			}
+			if err = client.VerifyIncomingTransaction(tx); err != nil {
+				tester.Fatal("invalid transaction in the client:", err)
+			}
+			if err = client.UpdateAppDataClient(&tx.Data); err != nil {
+				tester.Fatal("could not update the client:", err)
+			}
+			txBytes := client.ToBytes(tx)
+			txs[i], received[i] = new(Transaction), new(Transaction)
+			if peer.FromBytes(txBytes, txs[i]) != nil || light.FromBytes(txBytes, received[i]) != nil {
+				tester.Fatal("couldn't parse tx:", txType)
+			}
+		}
+		block, err := peer.ProposeBlock(txs)
+		if err != nil {
+			tester.Fatal("could not propose the block:", err)
+		}
+
+		for i := 0; i < len(txs); i++ {
+			proof, err := peer.InclusionProof(block, i)
+			if err != nil {
+				tester.Fatal("couldn't create the proof:", err, txType)
+			}
+			// the light peer checks the transaction without verifying it or the block
+			if err = light.VerifyInclusion(block.Root, received[i], proof); err != nil {
+				tester.Fatal("included transaction was rejected:", err, txType, i)
+			}
+			if err = light.VerifyInclusion(block.Root, received[(i+1)%len(txs)], proof); !errors.Is(err, ErrInvalidProof) {
+				tester.Fatal("proof of another transaction was accepted:", txType, i)
+			}
+		}
+	}
+}
@@ -0,0 +1,216 @@
+/**********************************************************************
+ * Copyright (c) 2017 Jayamine Alupotha                               *
+ * Distributed under the MIT software license, see the accompanying   *
+ * file COPYING or http://www.opensource.org/licenses/mit-license.php.*
+ **********************************************************************/
+
+package txhelper
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// The metadata keeps the settings that define the stored data and the in-memory counters, so contexts created with
+// WithOpenExisting continue from the stored state. Peers keep it in their store and clients in the meta table.
+// Integers are stored as varints. Only contexts created with WithOpenExisting save it, so other contexts don't pay an
+// extra write per transaction. Clients keep the unspent outputs of access patterns in the unspent table, which is
+// updated with the ids that were added or spent since the last save.
+
+// metaSchema returns the table of context metadata
+func metaSchema() string {
+	return "DROP TABLE IF EXISTS meta; " +
+		"CREATE TABLE meta(key TEXT PRIMARY KEY, value BLOB);"
+}
+
+// metaConfig returns the settings that must match the stored data
+func (ctx *ExeContext) metaConfig() map[string]int64 {
+	indexing := int64(0)
+	if ctx.enableIndexing {
+		indexing = 1
+	}
+	return map[string]int64{
+		"uType":       int64(ctx.uType),
+		"txModel":     int64(ctx.txModel),
+		"sigType":     int64(ctx.sigContext.SigType),
+		"payloadSize": int64(ctx.payloadSize),
+		"indexing":    indexing,
+	}
+}
+
+// unspentSchema returns the table of unspent outputs of clients
+func unspentSchema() string {
+	return "DROP TABLE IF EXISTS unspent; " +
+		"CREATE TABLE unspent(id INTEGER PRIMARY KEY);"
+}
+
+// metaCounters returns pointers to the counters that follow the stored data
+func (ctx *ExeContext) metaCounters() map[string]*int {
+	return map[string]*int{
+		"totalTx":        &ctx.TotalTx,
+		"totalBlock":     &ctx.TotalBlock,
+		"currentUsers":   &ctx.CurrentUsers,
+		"currentOutputs": &ctx.CurrentOutputs,
+		"deletedOutputs": &ctx.DeletedOutputs,
+		"inputPointer":   &ctx.inputPointer,
+		"outputPointer":  &ctx.outputPointer,
+	}
+}
+
+// putMeta saves key-value pairs to the peer store, or to the meta table of clients
+func (ctx *ExeContext) putMeta(values map[string][]byte) error {
+	if ctx.store != nil {
+		return ctx.store.PutMeta(values)
+	}
+	return putSQLMeta(ctx.db, values)
+}
+
+// initMeta saves the settings and the counters of a new database. Without WithOpenExisting, clients only delete old
+// metadata.
+func (ctx *ExeContext) initMeta() error {
+	if ctx.store == nil {
+		statement := metaSchema() + " " + unspentSchema()
+		if !ctx.openExisting {
+			statement = "DROP TABLE IF EXISTS meta; DROP TABLE IF EXISTS unspent;"
+		}
+		if _, err := ctx.db.Exec(statement); err != nil {
+			return dbError(err)
+		}
+	}
+	if !ctx.openExisting {
+		return nil
+	}
+	values := make(map[string][]byte)
+	for key, value := range ctx.metaConfig() {
+		values[key] = binary.AppendVarint(nil, value)
+	}
+	if err := ctx.putMeta(values); err != nil {
+		return err
+	}
+	return ctx.saveMeta()
+}
+
+// saveMeta saves the counters and the changes of unspent outputs if the context was created with WithOpenExisting
+func (ctx *ExeContext) saveMeta() error {
+	if !ctx.openExisting {
+		return nil
+	}
+	values := make(map[string][]byte)
+	for key, value := range ctx.metaCounters() {
+		values[key] = binary.AppendVarint(nil, int64(*value))
+	}
+	values["lastBlockHash"] = ctx.lastBlockHash
+	if err := ctx.putMeta(values); err != nil {
+		return err
+	}
+	return ctx.saveUnspent()
+}
+
+// addUnspent adds a new output to the unspent outputs of access patterns
+func (ctx *ExeContext) addUnspent(id int) {
+	ctx.unspent = append(ctx.unspent, id)
+	if ctx.openExisting {
+		ctx.unspentAdded = append(ctx.unspentAdded, id)
+	}
+}
+
+// spendUnspent removes the i-th unspent output and returns its id
+func (ctx *ExeContext) spendUnspent(i int) int {
+	id := ctx.unspent[i]
+	ctx.unspent = append(ctx.unspent[:i], ctx.unspent[i+1:]...)
+	if ctx.openExisting {
+		ctx.unspentSpent = append(ctx.unspentSpent, id)
+	}
+	return id
+}
+
+// saveUnspent applies the unspent outputs that were added or spent since the last save to the unspent table
+func (ctx *ExeContext) saveUnspent() error {
+	for _, id := range ctx.unspentAdded {
+		if _, err := ctx.db.Exec("INSERT INTO unspent(id) VALUES(?);", id); err != nil {
+			return dbError(err)
+		}
+	}
+	for _, id := range ctx.unspentSpent {
+		if _, err := ctx.db.Exec("DELETE FROM unspent WHERE id = ?;", id); err != nil {
+			return dbError(err)
+		}
+	}
+	ctx.unspentAdded = ctx.unspentAdded[:0]
+	ctx.unspentSpent = ctx.unspentSpent[:0]
+	return nil
+}
+
+// openClientMeta restores the counters and the unspent outputs of an existing client database. It returns false if
+// the database doesn't have a meta table.
+func (ctx *ExeContext) openClientMeta() (bool, error) {
+	tables := 0
+	if err := ctx.db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'meta';").Scan(&tables); err != nil {
+		return false, dbError(err)
+	}
+	if tables == 0 {
+		return false, nil
+	}
+	if err := ctx.openMeta(); err != nil {
+		return true, err
+	}
+
+	rows, err := ctx.db.Query("SELECT id FROM unspent ORDER BY id;")
+	if err != nil {
+		return true, dbError(err)
+	}
+	defer rows.Close()
+	ctx.unspent = nil
+	for rows.Next() {
+		var id int
+		if err = rows.Scan(&id); err != nil {
+			return true, dbError(err)
+		}
+		ctx.unspent = append(ctx.unspent, id)
+	}
+	if err = rows.Err(); err != nil {
+		return true, dbError(err)
+	}
+	return true, nil
+}
+
+// openMeta checks the settings of the stored data and restores the counters
+func (ctx *ExeContext) openMeta() error {
+	var stored map[string][]byte
+	var err error
+	if ctx.store != nil {
+		stored, err = ctx.store.Meta()
+	} else {
+		stored, err = readSQLMeta(ctx.db)
+	}
+	if err != nil {
+		return err
+	}
+	getInt := func(key string) (int64, error) {
+		value, n := binary.Varint(stored[key])
+		if n <= 0 || n != len(stored[key]) {
+			return 0, fmt.Errorf("%w: %s is missing in the meta table", ErrInvalidChain, key)
+		}
+		return value, nil
+	}
+
+	for key, want := range ctx.metaConfig() {
+		value, err := getInt(key)
+		if err != nil {
+			return err
+		}
+		if value != want {
+			return fmt.Errorf("%w: %s of the existing database is %d, not %d", ErrInvalidConfig, key, value, want)
+		}
+	}
+	for key, counter := range ctx.metaCounters() {
+		value, err := getInt(key)
+		if err != nil {
+			return err
+		}
+		*counter = int(value)
+	}
+	ctx.lastBlockHash = stored["lastBlockHash"]
+	ctx.resetTemps()
+	return nil
+}
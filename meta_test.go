@@ -0,0 +1,133 @@
+package txhelper
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestPeerRestart(tester *testing.T) {
+	for _, txType := range []int{1, 2, 5, 6} {
+		dir := tester.TempDir()
+		client := newTestContext(tester, 270, 1, txType, 1, 32, 10, 3, 4, 1, true, 2, WithDataDir(dir))
+		peer := newTestContext(tester, 270, 2, txType, 1, 32, 10, 3, 4, 1, true, 2, WithDataDir(dir), WithOpenExisting())
+		for i := 0; i < 2; i++ {
+			if err := peer.CommitBlock(testBlockFromClient(tester, &client, &peer, 4)); err != nil {
+				tester.Fatal("could not commit the block:", err, txType)
+			}
+		}
+		state := peer.peerState(tester)
+		peer.Close()
+
+		restarted := newTestContext(tester, 270, 2, txType, 1, 32, 10, 3, 4, 1, true, 2, WithDataDir(dir), WithOpenExisting())
+		if restarted.peerState(tester) != state {
+			tester.Fatal("restarted peer has a different state:", txType)
+		}
+		if err := restarted.VerifyStoredAllBlocks(); err != nil {
+			tester.Fatal("restarted peer has an invalid chain:", err, txType)
+		}
+		if err := restarted.CommitBlock(testBlockFromClient(tester, &client, &restarted, 4)); err != nil {
+			tester.Fatal("restarted peer could not commit the block:", err, txType)
+		}
+		if err := restarted.VerifyStoredAllBlocks(); err != nil {
+			tester.Fatal("invalid chain after restarting:", err, txType)
+		}
+		restarted.Close()
+
+		// the stored data must match the settings
+		_, err := NewContext(270, 2, txType, 2, 32, 10, 3, 4, 1, true, 2, WithDataDir(dir), WithOpenExisting())
+		if !errors.Is(err, ErrInvalidConfig) {
+			tester.Fatal("database of another signature scheme was opened:", err, txType)
+		}
+		_, err = NewContext(270, 2, txType, 1, 64, 10, 3, 4, 1, true, 2, WithDataDir(dir), WithOpenExisting())
+		if !errors.Is(err, ErrInvalidConfig) {
+			tester.Fatal("database of another payload size was opened:", err, txType)
+		}
+
+		// without WithOpenExisting, the database is created again
+		fresh := newTestContext(tester, 270, 2, txType, 1, 32, 10, 3, 4, 1, true, 2, WithDataDir(dir))
+		if fresh.TotalTx != 0 || fresh.TotalBlock != 0 {
+			tester.Fatal("new peer kept the old state:", txType)
+		}
+		fresh.Close()
+	}
+}
+
+func TestClientRestart(tester *testing.T) {
+	for _, txType := range []int{1, 2, 5, 6} {
+		dir := tester.TempDir()
+		client := newTestContext(tester, 271, 1, txType, 1, 32, 10, 3, 4, 1, false, 2, WithDataDir(dir), WithOpenExisting())
+		peer := newTestContext(tester, 271, 2, txType, 1, 32, 10, 3, 4, 1, false, 2, WithDataDir(dir), WithOpenExisting())
+		for i := 0; i < 5; i++ {
+			if err := peer.CommitTransaction(peer.TotalTx, testPeerTx(tester, &client, &peer)); err != nil {
+				tester.Fatal("could not commit the transaction:", err, txType)
+			}
+		}
+		client.Close()
+
+		restarted := newTestContext(tester, 271, 1, txType, 1, 32, 10, 3, 4, 1, false, 2, WithDataDir(dir), WithOpenExisting())
+		if restarted.CurrentUsers != client.CurrentUsers || restarted.CurrentOutputs != client.CurrentOutputs ||
+			restarted.inputPointer != client.inputPointer || restarted.outputPointer != client.outputPointer {
+			tester.Fatal("restarted client has different counters:", txType)
+		}
+		for i := 0; i < 5; i++ {
+			if err := peer.CommitTransaction(peer.TotalTx, testPeerTx(tester, &restarted, &peer)); err != nil {
+				tester.Fatal("could not commit the transaction of the restarted client:", err, txType)
+			}
+		}
+		if err := peer.VerifyStoredAllTransaction(); err != nil {
+			tester.Fatal("invalid chain was created:", err, txType)
+		}
+		if _, err := NewContext(271, 2, txType, 1, 32, 10, 3, 4, 1, false, 2, WithDataDir(dir), WithOpenExisting()); err != nil {
+			tester.Fatal("couldn't open the peer:", err, txType)
+		}
+		if _, err := NewContext(271, 1, txType%6+1, 1, 32, 10, 3, 4, 1, false, 2, WithDataDir(dir), WithOpenExisting()); !errors.Is(err, ErrInvalidConfig) {
+			tester.Fatal("database of another model was opened:", err, txType)
+		}
+	}
+}
+
+func TestMetaOnlyWithOpenExisting(tester *testing.T) {
+	dir := tester.TempDir()
+	// databases of contexts without WithOpenExisting are not restored
+	client := newTestContext(tester, 272, 1, 1, 1, 32, 10, 3, 4, 1, false, 2, WithDataDir(dir))
+	peer := newTestContext(tester, 272, 2, 1, 1, 32, 10, 3, 4, 1, false, 2, WithDataDir(dir))
+	for i := 0; i < 3; i++ {
+		if err := peer.CommitTransaction(peer.TotalTx, testPeerTx(tester, &client, &peer)); err != nil {
+			tester.Fatal("could not commit the transaction:", err)
+		}
+	}
+	client.Close()
+	peer.Close()
+	client = newTestContext(tester, 272, 1, 1, 1, 32, 10, 3, 4, 1, false, 2, WithDataDir(dir), WithOpenExisting())
+	peer = newTestContext(tester, 272, 2, 1, 1, 32, 10, 3, 4, 1, false, 2, WithDataDir(dir), WithOpenExisting())
+	if client.CurrentOutputs != 0 || peer.TotalTx != 0 {
+		tester.Fatal("database without metadata was restored")
+	}
+	client.Close()
+	peer.Close()
+}
+
+func TestClientRestartUnspent(tester *testing.T) {
+	opts := []Option{WithAccessPattern(UniformAccess{}), WithDataDir(tester.TempDir()), WithOpenExisting()}
+	client := newTestContext(tester, 273, 1, 1, 1, 32, 10, 3, 4, 1, false, 2, opts...)
+	peer := newTestContext(tester, 273, 2, 1, 1, 32, 10, 3, 4, 1, false, 2, opts...)
+	for i := 0; i < 6; i++ {
+		if err := peer.CommitTransaction(peer.TotalTx, testPeerTx(tester, &client, &peer)); err != nil {
+			tester.Fatal("could not commit the transaction:", err)
+		}
+	}
+	client.Close()
+
+	restarted := newTestContext(tester, 273, 1, 1, 1, 32, 10, 3, 4, 1, false, 2, opts...)
+	if fmt.Sprint(restarted.unspent) != fmt.Sprint(client.unspent) {
+		tester.Fatal("restarted client has different unspent outputs:", restarted.unspent, client.unspent)
+	}
+	for i := 0; i < 4; i++ {
+		if err := peer.CommitTransaction(peer.TotalTx, testPeerTx(tester, &restarted, &peer)); err != nil {
+			tester.Fatal("could not commit the transaction of the restarted client:", err)
+		}
+	}
+	restarted.Close()
+	peer.Close()
+}
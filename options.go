@@ -0,0 +1,177 @@
+/**********************************************************************
+ * Copyright (c) 2017 Jayamine Alupotha                               *
+ * Distributed under the MIT software license, see the accompanying   *
+ * file COPYING or http://www.opensource.org/licenses/mit-license.php.*
+ **********************************************************************/
+
+package txhelper
+
+import (
+	"fmt"
+	"io"
+	"math/rand"
+	"sync/atomic"
+)
+
+// Option changes optional settings of a context created by NewContext. Options are applied after the transaction
+// model and the signature scheme are selected, and before the database is initiated.
+type Option func(ctx *ExeContext) error
+
+// WithBlockAggregation makes peers aggregate the signatures of all transactions of a block into one block signature
+// (models 1-4). Transactions of proposed blocks don't carry signatures, so txHeaders.sigAll is empty, and other peers
+// verify all headers of the block with one aggregate verification. The signature scheme must produce fixed-size
+// aggregate signatures, e.g., BLS (2, 6, 7).
+func WithBlockAggregation() Option {
+	return func(ctx *ExeContext) error {
+		if ctx.model.IsOrigami() {
+			return fmt.Errorf("%w: block aggregation needs a classic model", ErrInvalidConfig)
+		}
+		if !ctx.sigContext.aggregates() || ctx.sigContext.variableAggregates() {
+			return fmt.Errorf("%w: block aggregation needs a signature scheme with fixed-size aggregates", ErrInvalidConfig)
+		}
+		ctx.blockAggregation = true
+		return nil
+	}
+}
+
+// WithPayloadDistribution draws payload sizes from d instead of the distribution of distributionType. Clients and
+// peers must use the same kind of distribution since only constant payloads are encoded without lengths.
+func WithPayloadDistribution(d PayloadDistribution) Option {
+	return func(ctx *ExeContext) error {
+		if d == nil {
+			return fmt.Errorf("%w: nil payload distribution", ErrInvalidConfig)
+		}
+		if constant, ok := d.(ConstantPayload); ok {
+			if constant.Size < 0 || constant.Size > 0xffff {
+				return fmt.Errorf("%w: invalid constant payload size", ErrInvalidConfig)
+			}
+			ctx.payloadSize = uint16(constant.Size)
+		}
+		ctx.payloads = d
+		return nil
+	}
+}
+
+// WithAccessPattern chooses the inputs of random transactions with p instead of the round-robin method
+func WithAccessPattern(p AccessPattern) Option {
+	return func(ctx *ExeContext) error {
+		if p == nil {
+			return fmt.Errorf("%w: nil access pattern", ErrInvalidConfig)
+		}
+		ctx.access = p
+		return nil
+	}
+}
+
+// WithShapeDistribution draws the number of inputs and outputs of random transactions from d instead of the uniform
+// distribution of AverageInputMax and AverageOutputMax
+func WithShapeDistribution(d ShapeDistribution) Option {
+	return func(ctx *ExeContext) error {
+		if d == nil {
+			return fmt.Errorf("%w: nil shape distribution", ErrInvalidConfig)
+		}
+		ctx.shapes = d
+		return nil
+	}
+}
+
+// WithWireFormat selects the encoding of ToBytes and FromBytes. Clients and peers must use the same format.
+func WithWireFormat(format WireFormat) Option {
+	return func(ctx *ExeContext) error {
+		if format != CompactFormat && format != EnvelopeFormat {
+			return fmt.Errorf("%w: unknown wire format %d", ErrInvalidConfig, format)
+		}
+		ctx.wireFormat = format
+		return nil
+	}
+}
+
+// WithOrigamiHeaders transmits the activity proof and, in model 5, the excess public key of Origami transactions.
+// Peers still recompute both fields and reject transactions whose transmitted fields don't match.
+func WithOrigamiHeaders() Option {
+	return func(ctx *ExeContext) error {
+		if !ctx.model.IsOrigami() {
+			return fmt.Errorf("%w: only Origami models have activity proofs", ErrInvalidConfig)
+		}
+		ctx.origamiHeaders = true
+		return nil
+	}
+}
+
+// WithTrace records every transaction created by RandomTransaction and FixedTransaction to w, so peers can replay
+// the same workload with ReplayTrace. The trace header is written after all options are applied.
+func WithTrace(w io.Writer) Option {
+	return func(ctx *ExeContext) error {
+		if ctx.uType != 1 {
+			return fmt.Errorf("%w: only clients create transactions to trace", ErrInvalidConfig)
+		}
+		ctx.trace = &TraceWriter{stream: NewTxStreamWriter(w, nil)}
+		return nil
+	}
+}
+
+// WithSeed makes the workload reproducible. All random choices of the context, including input/output counts,
+// payloads and key pairs, are taken from a source seeded with seed. Keys of seeded contexts are predictable, so they
+// must be used only for simulations.
+func WithSeed(seed int64) Option {
+	return func(ctx *ExeContext) error {
+		ctx.rand = rand.New(rand.NewSource(seed))
+		ctx.sigContext.rand = ctx.rand
+		return nil
+	}
+}
+
+// WithOpenExisting keeps the database of exeId if it exists instead of creating a new one, so a client or a peer can
+// restart without creating or syncing the whole chain again. The model, the signature scheme, the payload size and
+// indexing must match the stored ones, and the counters, e.g., TotalTx and CurrentUsers, continue from the stored
+// values. Temporary outputs of unfinished blocks are not kept. Only contexts created with WithOpenExisting save the
+// counters, so a database created without it is created again.
+func WithOpenExisting() Option {
+	return func(ctx *ExeContext) error {
+		ctx.openExisting = true
+		return nil
+	}
+}
+
+// WithDataDir creates the database file in dir instead of the working directory. dir is created if needed.
+func WithDataDir(dir string) Option {
+	return func(ctx *ExeContext) error {
+		ctx.dataDir = dir
+		return nil
+	}
+}
+
+// WithDSN opens the sqlite database of dsn, e.g., a file path or a "file:" URI, instead of client<exeId>.db or
+// peer<exeId>.db
+func WithDSN(dsn string) Option {
+	return func(ctx *ExeContext) error {
+		ctx.dsn = dsn
+		return nil
+	}
+}
+
+// WithInMemoryDB keeps the database in memory (file:<name>-<n>?mode=memory&cache=shared) instead of a file. Each
+// context has its own database, even with the same exeId, and it is deleted when the context is closed.
+func WithInMemoryDB() Option {
+	return func(ctx *ExeContext) error {
+		ctx.inMemory = true
+		ctx.memoryId = atomic.AddUint64(&inMemoryDBs, 1)
+		return nil
+	}
+}
+
+// WithPeerStore keeps the peer state in store instead of the sqlite database, e.g., NewMemoryStore() to measure
+// verification without the database cost or NewBoltStore("") for an embedded key-value store. WithDataDir and
+// WithOpenExisting apply to stores that support them.
+func WithPeerStore(store PeerStore) Option {
+	return func(ctx *ExeContext) error {
+		if ctx.uType != 2 {
+			return fmt.Errorf("%w: only peers use stores", ErrInvalidConfig)
+		}
+		if store == nil {
+			return fmt.Errorf("%w: nil store", ErrInvalidConfig)
+		}
+		ctx.store = store
+		return nil
+	}
+}
@@ -0,0 +1,61 @@
+package txhelper
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestOrigamiHeaderPeers(tester *testing.T) {
+	for i := 5; i <= 6; i++ {
+		for _, format := range []WireFormat{CompactFormat, EnvelopeFormat} {
+			ctx := newTestContext(tester, 190, 1, i, 1, 32, 10, 2, 3, 1, false, 2)
+			ctx.testPeerTransactions(10, tester, WithOrigamiHeaders(), WithWireFormat(format))
+		}
+	}
+	if _, err := NewContext(191, 1, 1, 1, 32, 10, 2, 3, 1, false, 2, WithOrigamiHeaders()); !errors.Is(err, ErrInvalidConfig) {
+		tester.Fatal("classic model transmitted origami headers:", err)
+	}
+}
+
+func TestOrigamiHeaderSizes(tester *testing.T) {
+	for i := 5; i <= 6; i++ {
+		client := newTestContext(tester, 192, 1, i, 1, 32, 10, 2, 3, 1, false, 2, WithOrigamiHeaders())
+		peer := newTestContext(tester, 192, 2, i, 1, 32, 10, 2, 3, 1, false, 2, WithOrigamiHeaders())
+		tx, err := client.FixedTransaction(0, 2)
+		if err != nil {
+			tester.Fatal("couldn't create tx:", err)
+		}
+		txBytes := client.ToBytes(tx)
+		without, with := client.TxSizes(tx)
+		headers := 33
+		if i == 5 {
+			headers += int(client.sigContext.PkSize)
+		}
+		if with != len(txBytes) || with-without != headers {
+			tester.Fatal("invalid sizes:", without, with, len(txBytes))
+		}
+
+		// transmitted fields must match the recomputed ones
+		var tx1 Transaction
+		txBytes[len(txBytes)-1] ^= 1
+		if err = peer.FromBytes(txBytes, &tx1); err != nil {
+			tester.Fatal("couldn't parse tx:", err)
+		}
+		if err = peer.VerifyIncomingTransaction(&tx1); err == nil {
+			tester.Fatal("modified origami header was accepted")
+		}
+		if err = peer.FromBytes(txBytes[:headers-1], &tx1); !errors.Is(err, ErrMalformedEncoding) {
+			tester.Fatal("missing origami header was accepted:", err)
+		}
+	}
+
+	// classic models have no origami headers
+	client := newTestContext(tester, 193, 1, 1, 1, 32, 10, 2, 3, 1, false, 2)
+	tx, err := client.FixedTransaction(0, 2)
+	if err != nil {
+		tester.Fatal("couldn't create tx:", err)
+	}
+	if without, with := client.TxSizes(tx); without != with || with != len(client.ToBytes(tx)) {
+		tester.Fatal("invalid classic sizes:", without, with)
+	}
+}
@@ -0,0 +1,221 @@
+/**********************************************************************
+ * Copyright (c) 2017 Jayamine Alupotha                               *
+ * Distributed under the MIT software license, see the accompanying   *
+ * file COPYING or http://www.opensource.org/licenses/mit-license.php.*
+ **********************************************************************/
+
+package txhelper
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// MaxPayloadSize is the maximum payload size of an output. Larger sizes drawn from distributions are truncated.
+const MaxPayloadSize = 1 << 20
+
+// PayloadDistribution draws the payload sizes of outputs. Payloads of all distributions except ConstantPayload are
+// encoded with their lengths.
+type PayloadDistribution interface {
+	// Name returns a short name of the distribution
+	Name() string
+	// Draw draws the size of the next payload
+	Draw(r *rand.Rand) int
+}
+
+// clampPayload rounds a drawn size into [0, MaxPayloadSize]
+func clampPayload(size float64) int {
+	if math.IsNaN(size) || size < 0 {
+		return 0
+	}
+	if size > MaxPayloadSize {
+		return MaxPayloadSize
+	}
+	return int(math.Round(size))
+}
+
+// ConstantPayload gives every output Size bytes (distributionType = 1)
+type ConstantPayload struct {
+	Size int
+}
+
+func (ConstantPayload) Name() string {
+	return "constant"
+}
+
+func (d ConstantPayload) Draw(*rand.Rand) int {
+	return d.Size
+}
+
+// UniformPayload draws sizes uniformly from [Min, Max] (distributionType = 2 uses [0, 2 * averageSize])
+type UniformPayload struct {
+	Min int
+	Max int
+}
+
+func (UniformPayload) Name() string {
+	return "uniform"
+}
+
+func (d UniformPayload) Draw(r *rand.Rand) int {
+	if d.Max <= d.Min {
+		return clampPayload(float64(d.Min))
+	}
+	return clampPayload(float64(d.Min + r.Intn(d.Max-d.Min+1)))
+}
+
+// NormalPayload draws sizes from N(Mean, StdDev^2) (distributionType = 3 uses N(averageSize, (averageSize/4)^2))
+type NormalPayload struct {
+	Mean   float64
+	StdDev float64
+}
+
+func (NormalPayload) Name() string {
+	return "normal"
+}
+
+func (d NormalPayload) Draw(r *rand.Rand) int {
+	return clampPayload(d.Mean + d.StdDev*r.NormFloat64())
+}
+
+// LogNormalPayload draws sizes whose logarithms are N(Mu, Sigma^2), so the mean is exp(Mu + Sigma^2/2).
+// distributionType = 4 uses Sigma = 1 with the mean averageSize.
+type LogNormalPayload struct {
+	Mu    float64
+	Sigma float64
+}
+
+func (LogNormalPayload) Name() string {
+	return "log-normal"
+}
+
+func (d LogNormalPayload) Draw(r *rand.Rand) int {
+	return clampPayload(math.Exp(d.Mu + d.Sigma*r.NormFloat64()))
+}
+
+// ParetoPayload draws sizes from a Pareto distribution with the minimum Scale and the tail index Shape, so the mean is
+// Shape * Scale / (Shape - 1) for Shape > 1. distributionType = 5 uses Shape = 2 with the mean averageSize.
+type ParetoPayload struct {
+	Scale float64
+	Shape float64
+}
+
+func (ParetoPayload) Name() string {
+	return "pareto"
+}
+
+func (d ParetoPayload) Draw(r *rand.Rand) int {
+	// 1 - Float64() is in (0, 1]
+	return clampPayload(d.Scale / math.Pow(1-r.Float64(), 1/d.Shape))
+}
+
+// EmpiricalPayload draws sizes from a histogram, where sizes[i] is drawn with the probability counts[i] / sum(counts)
+type EmpiricalPayload struct {
+	sizes      []int
+	cumulative []int64
+}
+
+// NewEmpiricalPayload creates a distribution from a histogram of sizes
+func NewEmpiricalPayload(sizes []int, counts []int64) (*EmpiricalPayload, error) {
+	if len(sizes) == 0 || len(sizes) != len(counts) {
+		return nil, fmt.Errorf("%w: histogram needs the same number of sizes and counts", ErrInvalidConfig)
+	}
+	d := EmpiricalPayload{sizes: make([]int, len(sizes)), cumulative: make([]int64, len(sizes))}
+	total := int64(0)
+	for i := range sizes {
+		if sizes[i] < 0 || sizes[i] > MaxPayloadSize || counts[i] < 0 {
+			return nil, fmt.Errorf("%w: invalid histogram bucket %d", ErrInvalidConfig, i)
+		}
+		total += counts[i]
+		d.sizes[i] = sizes[i]
+		d.cumulative[i] = total
+	}
+	if total == 0 {
+		return nil, fmt.Errorf("%w: empty histogram", ErrInvalidConfig)
+	}
+	return &d, nil
+}
+
+// LoadEmpiricalPayload reads a histogram with one "size count" pair per line. Empty lines and lines starting with #
+// are skipped, and the values can also be separated by a comma.
+func LoadEmpiricalPayload(path string) (*EmpiricalPayload, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var sizes []int
+	var counts []int64
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.FieldsFunc(text, func(r rune) bool { return r == ',' || r == ' ' || r == '\t' })
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("%w: %s:%d: expected size and count", ErrInvalidConfig, path, line)
+		}
+		size, err := strconv.Atoi(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s:%d: %w", ErrInvalidConfig, path, line, err)
+		}
+		count, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s:%d: %w", ErrInvalidConfig, path, line, err)
+		}
+		sizes = append(sizes, size)
+		counts = append(counts, count)
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, err
+	}
+	return NewEmpiricalPayload(sizes, counts)
+}
+
+func (*EmpiricalPayload) Name() string {
+	return "empirical"
+}
+
+func (d *EmpiricalPayload) Draw(r *rand.Rand) int {
+	x := r.Int63n(d.cumulative[len(d.cumulative)-1])
+	return d.sizes[sort.Search(len(d.cumulative), func(i int) bool { return d.cumulative[i] > x })]
+}
+
+// newPayloadDistribution returns the built-in distribution of distributionType with the mean averageSize
+func newPayloadDistribution(distributionType int, averageSize uint16) (PayloadDistribution, error) {
+	mean := float64(averageSize)
+	switch distributionType {
+	case 1:
+		return ConstantPayload{Size: int(averageSize)}, nil
+	case 2:
+		return UniformPayload{Min: 0, Max: 2 * int(averageSize)}, nil
+	case 3:
+		return NormalPayload{Mean: mean, StdDev: mean / 4}, nil
+	case 4:
+		return LogNormalPayload{Mu: math.Log(mean) - 0.5, Sigma: 1}, nil
+	case 5:
+		return ParetoPayload{Scale: mean / 2, Shape: 2}, nil
+	}
+	return nil, fmt.Errorf("%w: unknown distribution type %d", ErrInvalidConfig, distributionType)
+}
+
+// randomPayload returns random bytes of a size drawn from the payload distribution
+func (ctx *ExeContext) randomPayload() []byte {
+	data := make([]byte, ctx.payloads.Draw(ctx.rand))
+	ctx.rand.Read(data)
+	return data
+}
+
+// variablePayloads tells whether payloads are encoded with their lengths
+func (ctx *ExeContext) variablePayloads() bool {
+	_, constant := ctx.payloads.(ConstantPayload)
+	return !constant
+}
@@ -0,0 +1,127 @@
+package txhelper
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPayloadDistributions(tester *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for distributionType := 1; distributionType <= 5; distributionType++ {
+		d, err := newPayloadDistribution(distributionType, 256)
+		if err != nil {
+			tester.Fatal(err)
+		}
+		total := 0
+		minSize := MaxPayloadSize
+		for i := 0; i < 20000; i++ {
+			size := d.Draw(r)
+			if size < 0 || size > MaxPayloadSize {
+				tester.Fatal("invalid payload size:", d.Name(), size)
+			}
+			total += size
+			if size < minSize {
+				minSize = size
+			}
+		}
+		mean := float64(total) / 20000
+		if math.Abs(mean-256) > 256*0.2 {
+			tester.Fatal("invalid mean payload size:", d.Name(), mean)
+		}
+		if distributionType == 1 && minSize != 256 {
+			tester.Fatal("constant payloads have different sizes:", minSize)
+		}
+		if distributionType == 5 && minSize < 128 {
+			tester.Fatal("pareto payload is smaller than the scale:", minSize)
+		}
+	}
+
+	if _, err := newPayloadDistribution(6, 256); !errors.Is(err, ErrInvalidConfig) {
+		tester.Fatal("unknown distribution was accepted:", err)
+	}
+	if _, err := NewContext(140, 1, 1, 1, 32, 10, 4, 5, 0, false, 2); !errors.Is(err, ErrInvalidConfig) {
+		tester.Fatal("unknown distribution type was accepted:", err)
+	}
+}
+
+func TestEmpiricalPayload(tester *testing.T) {
+	path := filepath.Join(tester.TempDir(), "payloads.txt")
+	if err := os.WriteFile(path, []byte("# size count\n10 1\n\n1000,3\n"), 0o644); err != nil {
+		tester.Fatal(err)
+	}
+	d, err := LoadEmpiricalPayload(path)
+	if err != nil {
+		tester.Fatal(err)
+	}
+	r := rand.New(rand.NewSource(1))
+	large := 0
+	for i := 0; i < 4000; i++ {
+		switch d.Draw(r) {
+		case 10:
+		case 1000:
+			large++
+		default:
+			tester.Fatal("size is not in the histogram")
+		}
+	}
+	if large < 2800 || large > 3200 {
+		tester.Fatal("sizes don't follow the histogram:", large)
+	}
+
+	if err = os.WriteFile(path, []byte("10 1 2\n"), 0o644); err != nil {
+		tester.Fatal(err)
+	}
+	if _, err = LoadEmpiricalPayload(path); !errors.Is(err, ErrInvalidConfig) {
+		tester.Fatal("invalid histogram was accepted:", err)
+	}
+	if _, err = NewEmpiricalPayload([]int{10}, []int64{0}); !errors.Is(err, ErrInvalidConfig) {
+		tester.Fatal("empty histogram was accepted:", err)
+	}
+}
+
+func TestVariablePayloadPeers(tester *testing.T) {
+	for i := 1; i <= 6; i++ {
+		for distributionType := 2; distributionType <= 5; distributionType++ {
+			ctx := newTestContext(tester, 140, 1, i, 1, 64, 10, 4, 5, distributionType, false, 2)
+			ctx.testPeerTransactions(10, tester)
+		}
+		d, err := NewEmpiricalPayload([]int{0, 10, 3000}, []int64{1, 5, 1})
+		if err != nil {
+			tester.Fatal(err)
+		}
+		ctx := newTestContext(tester, 141, 1, i, 1, 64, 10, 4, 5, 1, false, 2)
+		ctx.testPeerTransactions(10, tester, WithPayloadDistribution(d))
+	}
+
+	// payloads of a transaction have different sizes
+	client := newTestContext(tester, 142, 1, 1, 1, 64, 10, 4, 5, 2, false, 2, WithSeed(3))
+	peer := newTestContext(tester, 142, 2, 1, 1, 64, 10, 4, 5, 2, false, 2)
+	sizes := make(map[int]bool)
+	for j := 0; j < 5; j++ {
+		tx, err := client.RandomTransaction()
+		if err != nil {
+			tester.Fatal("couldn't create tx:", err)
+		}
+		if err = client.UpdateAppDataClient(&tx.Data); err != nil {
+			tester.Fatal("could not update the client:", err)
+		}
+		var tx1 Transaction
+		txBytes := client.ToBytes(tx)
+		if err = peer.FromBytes(txBytes, &tx1); err != nil {
+			tester.Fatal("couldn't parse tx:", err)
+		}
+		for k := range tx1.Data.Outputs {
+			sizes[len(tx1.Data.Outputs[k].Data)] = true
+		}
+		if err = peer.FromBytes(txBytes[:len(txBytes)-len(tx.Txh.Kyber[0])-2], &tx1); err == nil {
+			tester.Fatal("truncated tx was accepted")
+		}
+	}
+	if len(sizes) < 2 {
+		tester.Fatal("payload sizes didn't change:", sizes)
+	}
+}
@@ -10,12 +10,9 @@ import "C"
 import (
 	"bytes"
 	"crypto/sha256"
-	"database/sql"
 	"errors"
+	"fmt"
 	_ "github.com/mattn/go-sqlite3"
-	"go.dedis.ch/kyber/v3"
-	"log"
-	"strconv"
 	"unsafe"
 )
 
@@ -45,113 +42,86 @@ func byte4toInt(bytes []byte) (a int) {
 }
 
 func (ctx *ExeContext) initPeerDB() (bool, error) {
-	var err error
-
-	ctx.db, err = sql.Open("sqlite3", "peer"+strconv.FormatInt(int64(ctx.exeId), 10)+".db")
+	if ctx.store == nil {
+		ctx.store = NewSQLiteStore()
+	}
+	cfg, err := ctx.storeConfig()
 	if err != nil {
 		return false, err
 	}
+	existing, err := ctx.store.Open(cfg, ctx.openExisting)
+	if err != nil {
+		return false, err
+	}
+	if existing {
+		return true, ctx.openMeta()
+	}
+	if err = ctx.initMeta(); err != nil {
+		return false, err
+	}
+	return true, nil
+}
 
-	if ctx.txModel >= 1 && ctx.txModel <= 4 {
+// classicSchema returns tables of models 1-4
+func (ctx *ExeContext) classicSchema() []string {
+	statements := []string{
 		// used - 0 (not used for inputs), 1 (used once), if used > 1 is invalid (used for verification)
-		statement := "DROP TABLE IF EXISTS outputs; " +
-			"CREATE TABLE outputs(id INTEGER PRIMARY KEY AUTOINCREMENT, h BLOB UNIQUE, pk BLOB, n INTEGER, Data BLOB, used INTEGER);"
-		_, err = ctx.db.Exec(statement)
-		if err != nil {
-			return false, err
-		}
+		"DROP TABLE IF EXISTS outputs; " +
+			"CREATE TABLE outputs(id INTEGER PRIMARY KEY AUTOINCREMENT, h BLOB UNIQUE, pk BLOB, n INTEGER, Data BLOB, used INTEGER);",
 		// allInIds - stores an int array of input ids
 		// allOutIds - stores an int array of allOutIds ids
-		statement = "DROP TABLE IF EXISTS txHeaders; " +
-			"CREATE TABLE txHeaders(txn INTEGER PRIMARY KEY AUTOINCREMENT, sigAll BLOB, allInIds BLOB, allOutIds BLOB);"
-		_, err = ctx.db.Exec(statement)
-		if err != nil {
-			return false, err
+		"DROP TABLE IF EXISTS txHeaders; " +
+			"CREATE TABLE txHeaders(txn INTEGER PRIMARY KEY AUTOINCREMENT, sigAll BLOB, allInIds BLOB, allOutIds BLOB);",
+	}
+	if ctx.enableIndexing {
+		// create an index table for h
+		statements = append(statements, "DROP INDEX IF EXISTS outputs_index; "+
+			"CREATE UNIQUE INDEX outputs_index ON outputs (h ASC);")
+		if ctx.model.IsAccount() {
+			// create an index table for pk
+			statements = append(statements, "DROP INDEX IF EXISTS outputs_index2; "+
+				"CREATE INDEX outputs_index2 ON outputs (pk ASC);")
 		}
+	}
+	return statements
+}
 
-		if ctx.enableIndexing {
-			// create an index table for h
-			statement = "DROP TABLE IF EXISTS outputs_index; " +
-				"CREATE UNIQUE INDEX outputs_index ON outputs (h ASC);"
-			_, err = ctx.db.Exec(statement)
-			if err != nil {
-				return false, err
-			}
-			if ctx.txModel == 2 || ctx.txModel == 4 {
-				// create an index table for pk
-				statement = "DROP INDEX IF EXISTS outputs_index2; " +
-					"CREATE INDEX outputs_index2 ON outputs (pk ASC);"
-				_, err = ctx.db.Exec(statement)
-				if err != nil {
-					return false, err
-				}
-			}
-		}
-	} else if ctx.txModel == 5 {
-		statement := "DROP TABLE IF EXISTS outputs; " +
-			"CREATE TABLE outputs(id INTEGER PRIMARY KEY AUTOINCREMENT, h BLOB UNIQUE, pk BLOB, n INTEGER, Data BLOB, used INTEGER);" //todo: add txn
-		_, err = ctx.db.Exec(statement)
-		if err != nil {
-			return false, err
-		}
-		statement = "DROP TABLE IF EXISTS txHeaders; " +
-			"CREATE TABLE txHeaders(txn INTEGER PRIMARY KEY AUTOINCREMENT, activity BLOB, excess BLOB, sig BLOB);"
-		_, err = ctx.db.Exec(statement)
-		if err != nil {
-			return false, err
-		}
+// origamiUtxoSchema returns tables of model 5
+func (ctx *ExeContext) origamiUtxoSchema() []string {
+	statements := []string{
+		"DROP TABLE IF EXISTS outputs; " +
+			"CREATE TABLE outputs(id INTEGER PRIMARY KEY AUTOINCREMENT, h BLOB UNIQUE, pk BLOB, n INTEGER, Data BLOB, used INTEGER);", //todo: add txn
+		"DROP TABLE IF EXISTS txHeaders; " +
+			"CREATE TABLE txHeaders(txn INTEGER PRIMARY KEY AUTOINCREMENT, activity BLOB, excess BLOB, sig BLOB);",
+	}
+	if ctx.enableIndexing {
+		// create index tables for h and pk
+		statements = append(statements, "DROP INDEX IF EXISTS outputs_index; "+
+			"CREATE UNIQUE INDEX outputs_index ON outputs (h ASC);",
+			"DROP INDEX IF EXISTS outputs_index2; "+
+				"CREATE UNIQUE INDEX outputs_index2 ON outputs (pk ASC);")
+	}
+	return statements
+}
 
-		if ctx.enableIndexing {
-			// create an index table for h
-			statement = "DROP INDEX IF EXISTS outputs_index; " +
-				"CREATE UNIQUE INDEX outputs_index ON outputs (h ASC);"
-			_, err = ctx.db.Exec(statement)
-			if err != nil {
-				return false, err
-			}
-			// create an index table for pk
-			statement = "DROP INDEX IF EXISTS outputs_index2; " +
-				"CREATE UNIQUE INDEX outputs_index2 ON outputs (pk ASC);"
-			_, err = ctx.db.Exec(statement)
-			if err != nil {
-				return false, err
-			}
-		}
-	} else if ctx.txModel == 6 {
+// origamiAccSchema returns tables of model 6
+func (ctx *ExeContext) origamiAccSchema() []string {
+	statements := []string{
 		// used - 0 (not used for inputs), 1 (used once), if used > 1 is invalid (used for verification)
-		statement := "DROP TABLE IF EXISTS outputs; " +
-			"CREATE TABLE outputs(id INTEGER PRIMARY KEY AUTOINCREMENT, h BLOB UNIQUE, pk BLOB UNIQUE, n INTEGER, Data BLOB, sig BLOB, Txns BLOB, used INTEGER);"
-		_, err = ctx.db.Exec(statement)
-		if err != nil {
-			return false, err
-		}
-		// allInIds - stores an int array of input ids
+		"DROP TABLE IF EXISTS outputs; " +
+			"CREATE TABLE outputs(id INTEGER PRIMARY KEY AUTOINCREMENT, h BLOB UNIQUE, pk BLOB UNIQUE, n INTEGER, Data BLOB, sig BLOB, Txns BLOB, used INTEGER);",
 		// allOutIds - stores an int array of allOutIds ids
-		statement = "DROP TABLE IF EXISTS txHeaders; " +
-			"CREATE TABLE txHeaders(txn INTEGER PRIMARY KEY AUTOINCREMENT, activity BLOB, allOutIds BLOB);"
-		_, err = ctx.db.Exec(statement)
-		if err != nil {
-			return false, err
-		}
-
-		if ctx.enableIndexing {
-			// create an index table for h
-			statement = "DROP INDEX IF EXISTS outputs_index; " +
-				"CREATE UNIQUE INDEX outputs_index ON outputs (h ASC);"
-			_, err = ctx.db.Exec(statement)
-			if err != nil {
-				return false, err
-			}
-			// create an index table for pk
-			statement = "DROP INDEX IF EXISTS outputs_index2; " +
-				"CREATE UNIQUE INDEX outputs_index2 ON outputs (pk ASC);"
-			_, err = ctx.db.Exec(statement)
-			if err != nil {
-				return false, err
-			}
-		}
+		"DROP TABLE IF EXISTS txHeaders; " +
+			"CREATE TABLE txHeaders(txn INTEGER PRIMARY KEY AUTOINCREMENT, activity BLOB, allOutIds BLOB);",
 	}
-	return true, nil
+	if ctx.enableIndexing {
+		// create index tables for h and pk
+		statements = append(statements, "DROP INDEX IF EXISTS outputs_index; "+
+			"CREATE UNIQUE INDEX outputs_index ON outputs (h ASC);",
+			"DROP INDEX IF EXISTS outputs_index2; "+
+				"CREATE UNIQUE INDEX outputs_index2 ON outputs (pk ASC);")
+	}
+	return statements
 }
 
 func getHeaderMapKey(h []byte) [32]byte {
@@ -175,31 +145,13 @@ func getPKMapKey(pk []byte, size int) [128]byte {
 
 // insertPeerOut enter an outputdata. For Origami, give txn as well.
 func (ctx *ExeContext) insertPeerOut(id int, h []byte, out *OutputData, sig []byte) (bool, error) {
-
-	if ctx.txModel >= 1 && ctx.txModel <= 5 {
-		stm, err := ctx.db.Prepare("INSERT INTO outputs(id, h, pk, n, Data, used) VALUES(?, ?, ?, ?, ?, ?);")
-		if err != nil {
-			return false, err
-		}
-		defer stm.Close()
-		_, err = stm.Exec(id, h, out.Pk, out.N, out.Data, 0)
-		if err != nil {
-			return false, err
-		}
-	} else if ctx.txModel == 6 {
-		txnBytes := make([]byte, len(out.u.Txns)*4)
-		for i := 0; i < len(out.u.Txns); i++ {
-			inttoByte4(out.u.Txns[i], txnBytes[i*4:])
-		}
-		stm, err := ctx.db.Prepare("INSERT INTO outputs(id, h, pk, n, Data, sig, Txns, used) VALUES(?, ?, ?, ?, ?, ?, ?, ?);")
-		if err != nil {
-			return false, err
-		}
-		defer stm.Close()
-		_, err = stm.Exec(id, h, out.Pk, out.N, out.Data, sig, txnBytes, 0)
-		if err != nil {
-			return false, err
-		}
+	stored := &StoredOutput{ID: id, H: h, Pk: out.Pk, N: out.N, Data: out.Data}
+	if ctx.origamiAccounts() {
+		stored.Sig = sig
+		stored.Txns = out.u.Txns
+	}
+	if err := ctx.store.PutOutput(stored); err != nil {
+		return false, err
 	}
 	return true, nil
 }
@@ -209,21 +161,21 @@ func (ctx *ExeContext) insertTempPeerOut(id int, h []byte, out *OutputData, sig
 	usedH, _ := ctx.usedPeerOutHeader(h)
 
 	if usedH {
-		return false, errors.New("TXHELPER_DUPLICATE_OUTPUTS")
+		return false, ErrDuplicateOutput
 	}
 
-	if ctx.txModel == 6 {
+	if ctx.origamiAccounts() {
 		usedPK, _ := ctx.usedPeerOutPublicKey(h)
 
 		if usedPK { // somebody is trying to replace already used pk in a later transactions
-			return false, errors.New("TXHELPER_DUPLICATE_PK")
+			return false, ErrReusedPublicKey
 		}
 
 		usedTxNum, foundPk := ctx.TempPKs[getPKMapKey(out.Pk, int(ctx.sigContext.PkSize))]
 		// somebody is trying to replace already used pk in a later transactions
 		if foundPk {
 			if usedTxNum <= txNum {
-				return false, errors.New("TXHELPER_DUPLICATE_PK")
+				return false, ErrReusedPublicKey
 			}
 			ctx.TempPKs[getPKMapKey(out.Pk, int(ctx.sigContext.PkSize))] = txNum
 		}
@@ -235,7 +187,7 @@ func (ctx *ExeContext) insertTempPeerOut(id int, h []byte, out *OutputData, sig
 	// somebody is trying to replace already used output in a later transactions
 	if found {
 		if tempUser.txNum <= txNum {
-			return false, errors.New("TXHELPER_DUPLICATE_OUTPUTS")
+			return false, ErrDuplicateOutput
 		}
 		// delete ctx.TempUsers[tempIndex]
 		delete(ctx.TempUsers, header)
@@ -247,11 +199,11 @@ func (ctx *ExeContext) insertTempPeerOut(id int, h []byte, out *OutputData, sig
 	tempUser.u.Keys = make([]byte, ctx.sigContext.PkSize)
 	copy(tempUser.u.Keys, out.Pk)
 	tempUser.u.N = out.N
-	tempUser.u.Data = make([]byte, ctx.payloadSize)
+	tempUser.u.Data = make([]byte, len(out.Data))
 	copy(tempUser.u.Data, out.Data)
 	tempUser.used = 0
 	tempUser.txNum = txNum
-	if ctx.txModel == 6 {
+	if ctx.origamiAccounts() {
 		tempUser.u.sig = make([]byte, ctx.sigContext.SigSize)
 		copy(tempUser.u.sig, sig)
 		tempUser.u.Txns = make([]int, len(out.u.Txns))
@@ -260,9 +212,6 @@ func (ctx *ExeContext) insertTempPeerOut(id int, h []byte, out *OutputData, sig
 		}
 	}
 	ctx.TempUsers[header] = tempUser
-	if ctx.TempUsers[header].u.id != id {
-		log.Fatal("temp user was not updated correctly")
-	}
 	return true, nil
 }
 
@@ -277,14 +226,7 @@ func (ctx *ExeContext) deleteTempOutputs(txNum int) {
 
 // deletePeerOut deletes an output from id
 func (ctx *ExeContext) deletePeerOut(id int) (bool, error) {
-
-	stm, err := ctx.db.Prepare("DELETE FROM outputs WHERE id = ?;")
-	if err != nil {
-		return false, err
-	}
-	defer stm.Close()
-	_, err = stm.Exec(id)
-	if err != nil {
+	if err := ctx.store.DeleteOutput(id); err != nil {
 		return false, err
 	}
 	return true, nil
@@ -293,31 +235,19 @@ func (ctx *ExeContext) deletePeerOut(id int) (bool, error) {
 // updatePeerOut only updates used in (1-4). for 6: updates " n = ?, data = ?, sig = ?, used = ?"
 func (ctx *ExeContext) updatePeerOut(id int, h []byte, n int, data []byte, sig []byte, txns []int, used int) (bool, error) {
 
-	if ctx.txModel >= 1 && ctx.txModel <= 4 {
-		stm, err := ctx.db.Prepare("UPDATE outputs SET used = used + ? WHERE id = ?;")
-		if err != nil {
+	if !ctx.model.IsOrigami() {
+		if err := ctx.store.AddUsed(id, used); err != nil {
 			return false, err
 		}
-		defer stm.Close()
-		_, err = stm.Exec(used, id)
-		if err != nil {
-			return false, err
-		}
-
-	} else if ctx.txModel == 5 {
-		log.Fatal("no need")
-	} else if ctx.txModel == 6 {
-		txnBytes := make([]byte, len(txns)*4)
-		for i := 0; i < len(txns); i++ {
-			inttoByte4(txns[i], txnBytes[i*4:])
-		}
-		stm, err := ctx.db.Prepare("UPDATE outputs SET h = ?, n = ?, data = ?, sig = ?, Txns = ?, used = ? WHERE id = ?;")
+	} else if !ctx.model.IsAccount() {
+		return false, fmt.Errorf("%w: outputs are deleted in %s", ErrUnknownTxModel, ctx.model.Name())
+	} else {
+		stored, err := ctx.store.OutputByID(id)
 		if err != nil {
 			return false, err
 		}
-		defer stm.Close()
-		_, err = stm.Exec(h, n, data, sig, txnBytes, used, id)
-		if err != nil {
+		stored.H, stored.N, stored.Data, stored.Sig, stored.Txns, stored.Used = h, uint8(n&0xff), data, sig, txns, used
+		if err = ctx.store.UpdateOutput(stored); err != nil {
 			return false, err
 		}
 	}
@@ -332,20 +262,20 @@ func (ctx *ExeContext) updateTempPeerOut(h []byte, newh []byte, n int, data []by
 	if !found {
 		foundDB, idDB, usedDB, _ := ctx.getPeerOut(h, &tempUser.u)
 		if !foundDB {
-			return false, errors.New("TXHELPER_NO_FOUND_INPUT")
+			return false, ErrUnknownInput
 		}
 		if usedDB == 1 {
-			return false, errors.New("TXHELPER_REUSED_INPUT")
+			return false, ErrDoubleSpend
 		}
 		tempUser.u.id = idDB
 	}
 
-	if ctx.txModel >= 1 && ctx.txModel <= 4 {
+	if !ctx.model.IsOrigami() {
 		tempUser.used = used
 		ctx.TempUsers[header] = tempUser
-	} else if ctx.txModel == 5 {
-		log.Fatal("no need")
-	} else if ctx.txModel == 6 {
+	} else if !ctx.model.IsAccount() {
+		return false, fmt.Errorf("%w: outputs are deleted in %s", ErrUnknownTxModel, ctx.model.Name())
+	} else {
 		copy(tempUser.u.H, newh)
 		tempUser.u.N = uint8(n & 0xff)
 		copy(tempUser.u.Data, data)
@@ -363,63 +293,58 @@ func (ctx *ExeContext) updateTempPeerOut(h []byte, newh []byte, n int, data []by
 }
 
 func (ctx *ExeContext) usedPeerOutHeader(h []byte) (bool, int) {
-	var err error
-	id := 0
-
-	row := ctx.db.QueryRow("SELECT id  FROM outputs WHERE h = ?;", h)
-	err = row.Scan(&id)
-	if errors.Is(err, sql.ErrNoRows) {
+	stored, err := ctx.store.OutputByH(h)
+	if err != nil {
 		return false, -1
 	}
-	return true, id
+	return true, stored.ID
 }
 
 func (ctx *ExeContext) usedPeerOutPublicKey(pk []byte) (bool, int) {
-	var err error
-	id := 0
-
-	row := ctx.db.QueryRow("SELECT id  FROM outputs WHERE pk = ?;", pk)
-	err = row.Scan(&id)
-	if errors.Is(err, sql.ErrNoRows) {
+	stored, err := ctx.store.OutputByPk(pk)
+	if err != nil {
 		return false, -1
 	}
-	return true, id
+	return true, stored.ID
+}
+
+// accountDeltas returns the activities of the transactions that updated an account (model 6). Temporary headers are
+// checked first if temps is set.
+func (ctx *ExeContext) accountDeltas(txns []int, temps bool) ([]byte, error) {
+	deltas := make([]byte, len(txns)*33)
+	for i := 0; i < len(txns); i++ {
+		if activity, found := ctx.TempTxH[txns[i]]; temps && found { // check temps
+			copy(deltas[i*33:], activity)
+			continue
+		}
+		// then check db
+		txh, err := ctx.store.TxHeader(txns[i])
+		if err != nil {
+			return nil, err
+		}
+		copy(deltas[i*33:], txh.Activity)
+	}
+	return deltas, nil
 }
 
 // getPeerOut returns found, id, used, err
 func (ctx *ExeContext) getPeerOut(h []byte, out *User) (bool, int, int, error) {
-	var err error
-	used := 0
-	id := 0
-
-	if ctx.txModel >= 1 && ctx.txModel <= 5 {
-		row := ctx.db.QueryRow("SELECT id, pk, n, data, used  FROM outputs WHERE h = ?;", h)
-		err = row.Scan(&id, &out.Keys, &out.N, &out.Data, &used)
-		if errors.Is(err, sql.ErrNoRows) {
-			return false, -1, -1, errors.New("TXHELPER_NOT_FOUND_OUT")
-		}
-	} else if ctx.txModel == 6 {
-		var outbuf []byte
-		row := ctx.db.QueryRow("SELECT id, pk, n, data, Txns, used  FROM outputs WHERE h = ?;", h)
-		err = row.Scan(&id, &out.Keys, &out.N, &out.Data, &outbuf, &used)
-		if errors.Is(err, sql.ErrNoRows) {
-			return false, -1, -1, errors.New("TXHELPER_NOT_FOUND_OUT")
-		}
+	stored, err := ctx.store.OutputByH(h)
+	if errors.Is(err, ErrNotStored) {
+		return false, -1, -1, ErrUnknownInput
+	}
+	if err != nil {
+		return false, -1, -1, err
+	}
+	out.Keys, out.N, out.Data = stored.Pk, stored.N, stored.Data
+	if ctx.origamiAccounts() {
 		// recover delta
-		out.Txns = make([]int, len(outbuf)/4)
-		out.UDelta = make([]byte, len(outbuf)/4*33)
-		activity := make([]byte, 33)
-		for i := 0; i < len(outbuf)/4; i++ {
-			out.Txns[i] = byte4toInt(outbuf[i*4:])
-			row = ctx.db.QueryRow("SELECT activity  FROM txHeaders WHERE txn = ?;", out.Txns[i])
-			err = row.Scan(&activity)
-			if errors.Is(err, sql.ErrNoRows) {
-				return false, -1, -1, err
-			}
-			copy(out.UDelta[i*33:], activity)
+		out.Txns = stored.Txns
+		if out.UDelta, err = ctx.accountDeltas(stored.Txns, false); err != nil {
+			return false, -1, -1, err
 		}
 	}
-	return true, id, used, nil
+	return true, stored.ID, stored.Used, nil
 }
 
 func (ctx *ExeContext) getTempPeerOut(h []byte, out *User) (bool, int, int, error) {
@@ -427,7 +352,7 @@ func (ctx *ExeContext) getTempPeerOut(h []byte, out *User) (bool, int, int, erro
 	tempUser, found := ctx.TempUsers[header]
 
 	if !found {
-		return false, -1, -1, errors.New("TXHELPER_NO_OUTPUT")
+		return false, -1, -1, ErrUnknownInput
 	}
 
 	out.id = tempUser.u.id
@@ -436,9 +361,9 @@ func (ctx *ExeContext) getTempPeerOut(h []byte, out *User) (bool, int, int, erro
 	out.Keys = make([]byte, ctx.sigContext.PkSize)
 	copy(out.Keys, tempUser.u.Keys)
 	out.N = tempUser.u.N
-	out.Data = make([]byte, ctx.payloadSize)
+	out.Data = make([]byte, len(tempUser.u.Data))
 	copy(out.Data, tempUser.u.Data)
-	if ctx.txModel == 6 {
+	if ctx.origamiAccounts() {
 		out.sig = make([]byte, ctx.sigContext.SigSize)
 		copy(out.sig, tempUser.u.sig)
 		txSize := len(tempUser.u.Txns)
@@ -446,21 +371,9 @@ func (ctx *ExeContext) getTempPeerOut(h []byte, out *User) (bool, int, int, erro
 		for i := 0; i < txSize; i++ {
 			out.Txns[i] = tempUser.u.Txns[i]
 		}
-		out.UDelta = make([]byte, txSize*33)
-		activity := make([]byte, 33)
-		for i := 0; i < txSize; i++ {
-			activity, found = ctx.TempTxH[out.Txns[i]] // check temps
-			if found {
-				copy(out.UDelta[i*33:], activity)
-				continue
-			}
-			// then check db
-			row := ctx.db.QueryRow("SELECT activity  FROM txHeaders WHERE txn = ?;", out.Txns[i])
-			err := row.Scan(&activity)
-			if errors.Is(err, sql.ErrNoRows) {
-				return false, -1, -1, err
-			}
-			copy(out.UDelta[i*33:], activity)
+		var err error
+		if out.UDelta, err = ctx.accountDeltas(out.Txns, true); err != nil {
+			return false, -1, -1, err
 		}
 	}
 
@@ -468,216 +381,156 @@ func (ctx *ExeContext) getTempPeerOut(h []byte, out *User) (bool, int, int, erro
 }
 
 func (ctx *ExeContext) getPeerOutFromID(id int, out *User) (bool, int, error) {
-	var err error
-	used := 0
-
-	if ctx.txModel >= 1 && ctx.txModel <= 5 {
-		row := ctx.db.QueryRow("SELECT h, id, pk, n, data, used  FROM outputs WHERE id = ?;", id)
-		err = row.Scan(&out.H, &out.Keys, &out.N, &out.Data, &used)
-		if errors.Is(err, sql.ErrNoRows) {
-			return false, -1, err
-		}
-	} else if ctx.txModel == 6 {
-		var outbuf []byte
-		row := ctx.db.QueryRow("SELECT h, pk, n, data, sig, Txns, used  FROM outputs WHERE id = ?;", id)
-		err = row.Scan(&out.H, &out.Keys, &out.N, &out.Data, &out.sig, &outbuf, &used)
-		if errors.Is(err, sql.ErrNoRows) {
+	stored, err := ctx.store.OutputByID(id)
+	if err != nil {
+		return false, -1, err
+	}
+	out.H, out.Keys, out.N, out.Data = stored.H, stored.Pk, stored.N, stored.Data
+	if ctx.origamiAccounts() {
+		// recover delta
+		out.sig = stored.Sig
+		out.Txns = stored.Txns
+		if out.UDelta, err = ctx.accountDeltas(stored.Txns, false); err != nil {
 			return false, -1, err
 		}
+	}
+	return true, stored.Used, nil
+}
 
-		// recover delta
-		out.Txns = make([]int, len(outbuf)/4)
-		out.UDelta = make([]byte, len(outbuf)/4*33)
-		activity := make([]byte, 33)
-		for i := 0; i < len(outbuf)/4; i++ {
-			out.Txns[i] = byte4toInt(outbuf[i*4:])
-			row = ctx.db.QueryRow("SELECT activity  FROM txHeaders WHERE txn = ?;", out.Txns[i])
-			err = row.Scan(&activity)
-			if errors.Is(err, sql.ErrNoRows) {
-				return false, -1, err
-			}
-			copy(out.UDelta[i*33:], activity)
-		}
+// insertClassicTxHeader saves signatures, input ids and output ids of a transaction (models 1-4)
+func (ctx *ExeContext) insertClassicTxHeader(txn int, tx *Transaction) error {
+	// collect signatures into a byte array
+	sigbuf := make([]byte, 0, len(tx.Txh.Kyber)*int(ctx.sigContext.SigSize))
+	for i := 0; i < len(tx.Txh.Kyber); i++ {
+		sigbuf = append(sigbuf, tx.Txh.Kyber[i]...)
+	}
+	txh := &StoredTxHeader{Txn: txn, Sig: sigbuf}
+	txh.InIds = make([]int, len(tx.Data.Inputs))
+	for i := 0; i < len(tx.Data.Inputs); i++ {
+		txh.InIds[i] = tx.Data.Inputs[i].u.id
+	}
+	txh.OutIds = make([]int, len(tx.Data.Outputs))
+	for i := 0; i < len(tx.Data.Outputs); i++ {
+		txh.OutIds[i] = tx.Data.Outputs[i].u.id
 	}
-	return true, used, nil
+	return ctx.store.PutTxHeader(txh)
 }
 
-func (ctx *ExeContext) insertPeerTxHeader(txn int, tx *Transaction) (bool, error) {
-	if ctx.txModel >= 1 && ctx.txModel <= 4 {
-		// collect signatures into a byte array
-		sigbuf := make([]byte, len(tx.Txh.Kyber)*int(ctx.sigContext.SigSize))
-		if ctx.sigContext.SigType == 1 || ctx.sigContext.SigType == 2 {
-			for i := 0; i < len(tx.Txh.Kyber); i++ {
-				copy(sigbuf[i*int(ctx.sigContext.SigSize):], tx.Txh.Kyber[i])
-			}
-		} else {
-			log.Fatal("unknown signature type:", ctx.sigContext.SigType)
-		}
-		// collect input ids into an int array
-		inbuf := make([]byte, 4*len(tx.Data.Inputs))
-		for i := 0; i < len(tx.Data.Inputs); i++ {
-			inttoByte4(tx.Data.Inputs[i].u.id, inbuf[i*4:])
-		}
-		// collect output ids into an int array
-		outbuf := make([]byte, 4*len(tx.Data.Outputs))
-		for i := 0; i < len(tx.Data.Outputs); i++ {
-			inttoByte4(tx.Data.Outputs[i].u.id, outbuf[i*4:])
-			//fmt.Println("insert id (txH)", tx.Data.Outputs[i].u.id, tx.Data.Outputs[i].Pk)
-		}
-		stm, err := ctx.db.Prepare("INSERT INTO txHeaders(txn, sigAll, allInIds, allOutIds) VALUES(?, ?, ?, ?);")
-		if err != nil {
-			return false, err
-		}
-		defer stm.Close()
-		_, err = stm.Exec(txn, sigbuf, inbuf, outbuf)
-		if err != nil {
-			return false, err
-		}
-	} else if ctx.txModel == 5 {
-		stm, err := ctx.db.Prepare("INSERT INTO txHeaders(txn, activity, excess, sig) VALUES(?, ?, ?, ?);")
-		if err != nil {
-			return false, err
-		}
-		defer stm.Close()
-		_, err = stm.Exec(txn, tx.Txh.activityProof, tx.Txh.excessPK, tx.Txh.Kyber[0])
-		if err != nil {
-			return false, err
-		}
-	} else if ctx.txModel == 6 {
-		if len(tx.Txh.activityProof) != 33 {
-			return false, errors.New("unverified transactions")
-		}
-		// collect output ids into an int array
-		outbuf := make([]byte, 4*len(tx.Data.Outputs))
-		for i := 0; i < len(tx.Data.Inputs); i++ {
-			inttoByte4(tx.Data.Inputs[i].u.id, outbuf[i*4:])
-		}
-		for i := len(tx.Data.Inputs); i < len(tx.Data.Outputs); i++ {
-			inttoByte4(tx.Data.Outputs[i].u.id, outbuf[i*4:])
-		}
-		stm, err := ctx.db.Prepare("INSERT INTO txHeaders(txn, activity, allOutIds) VALUES(?, ?, ?);")
-		if err != nil {
-			return false, err
-		}
-		defer stm.Close()
-		_, err = stm.Exec(txn, tx.Txh.activityProof, outbuf)
-		if err != nil {
-			return false, err
-		}
+// insertOrigamiUtxoTxHeader saves the activity proof, the excess public key and the signature (model 5)
+func (ctx *ExeContext) insertOrigamiUtxoTxHeader(txn int, tx *Transaction) error {
+	if len(tx.Txh.Kyber) != 1 {
+		return ErrUnverified
 	}
-	return true, nil
+	return ctx.store.PutTxHeader(&StoredTxHeader{Txn: txn, Activity: tx.Txh.activityProof, Excess: tx.Txh.excessPK, Sig: tx.Txh.Kyber[0]})
 }
 
-func (ctx *ExeContext) getStoredTx(txn int) (*Transaction, bool, error) {
-	var inBuf []byte
-	var outBuf []byte
-	var sigAll []byte
-	used := 0
+// insertOrigamiAccTxHeader saves the activity proof and ids of the updated accounts (model 6)
+func (ctx *ExeContext) insertOrigamiAccTxHeader(txn int, tx *Transaction) error {
+	if len(tx.Txh.activityProof) != 33 {
+		return ErrUnverified
+	}
+	// the first outputs update the accounts of inputs
+	outIds := make([]int, len(tx.Data.Outputs))
+	for i := 0; i < len(tx.Data.Inputs); i++ {
+		outIds[i] = tx.Data.Inputs[i].u.id
+	}
+	for i := len(tx.Data.Inputs); i < len(tx.Data.Outputs); i++ {
+		outIds[i] = tx.Data.Outputs[i].u.id
+	}
+	return ctx.store.PutTxHeader(&StoredTxHeader{Txn: txn, Activity: tx.Txh.activityProof, OutIds: outIds})
+}
 
+func (ctx *ExeContext) getStoredTx(txn int) (*Transaction, bool, error) {
 	var tx Transaction
 
-	if ctx.txModel >= 1 && ctx.txModel <= 4 {
+	if !ctx.model.IsOrigami() {
 		// get txheader
-		row := ctx.db.QueryRow("SELECT sigAll, allInIds, allOutIds  FROM txHeaders WHERE txn = ?;", txn)
-		err := row.Scan(&sigAll, &inBuf, &outBuf)
-		if errors.Is(err, sql.ErrNoRows) {
+		txh, err := ctx.store.TxHeader(txn)
+		if err != nil {
 			return nil, false, err
 		}
 		// get inputs
-		tx.Data.Inputs = make([]InputData, len(inBuf)/4)
-		for i := 0; i < len(inBuf)/4; i++ {
-			tx.Data.Inputs[i].u.id = byte4toInt(inBuf[i*4:])
-			row = ctx.db.QueryRow("SELECT h, pk, n, Data, used from outputs WHERE id = ?;", tx.Data.Inputs[i].u.id)
-			err = row.Scan(&tx.Data.Inputs[i].Header, &tx.Data.Inputs[i].u.Keys, &tx.Data.Inputs[i].u.N, &tx.Data.Inputs[i].u.Data, &used)
-			if errors.Is(err, sql.ErrNoRows) {
+		tx.Data.Inputs = make([]InputData, len(txh.InIds))
+		for i := 0; i < len(txh.InIds); i++ {
+			stored, err := ctx.store.OutputByID(txh.InIds[i])
+			if err != nil {
 				return nil, false, err
 			}
+			in := &tx.Data.Inputs[i]
+			in.u.id, in.Header, in.u.Keys, in.u.N, in.u.Data = stored.ID, stored.H, stored.Pk, stored.N, stored.Data
 		}
 		// get outputs
-		tx.Data.Outputs = make([]OutputData, len(outBuf)/4)
-		for i := 0; i < len(outBuf)/4; i++ {
-			tx.Data.Outputs[i].u.id = byte4toInt(outBuf[i*4:])
-			row = ctx.db.QueryRow("SELECT h, pk, n, Data, used from outputs WHERE id = ?;", tx.Data.Outputs[i].u.id)
-			err = row.Scan(&tx.Data.Outputs[i].u.H, &tx.Data.Outputs[i].Pk, &tx.Data.Outputs[i].N, &tx.Data.Outputs[i].Data, &used)
-			if errors.Is(err, sql.ErrNoRows) {
+		tx.Data.Outputs = make([]OutputData, len(txh.OutIds))
+		for i := 0; i < len(txh.OutIds); i++ {
+			stored, err := ctx.store.OutputByID(txh.OutIds[i])
+			if err != nil {
 				return nil, false, err
 			}
-			//fmt.Println("got id (txH)", tx.Data.Outputs[i].u.id, tx.Data.Outputs[i].Pk)
+			out := &tx.Data.Outputs[i]
+			out.u.id, out.u.H, out.Pk, out.N, out.Data = stored.ID, stored.H, stored.Pk, stored.N, stored.Data
 		}
-		// arrange signature
-		if ctx.sigContext.SigType == 1 || ctx.sigContext.SigType == 2 {
+		// arrange signature, variable aggregates are stored as one signature
+		sigAll := txh.Sig
+		if ctx.sigContext.variableAggregates() {
+			tx.Txh.Kyber = []Signature{sigAll}
+		} else {
 			tx.Txh.Kyber = make([]Signature, len(sigAll)/int(ctx.sigContext.SigSize))
 			for i := 0; i < len(sigAll)/int(ctx.sigContext.SigSize); i++ {
 				tx.Txh.Kyber[i] = make([]byte, ctx.sigContext.SigSize)
 				copy(tx.Txh.Kyber[i], sigAll[i*int(ctx.sigContext.SigSize):])
-				//fmt.Println("sig:", i, tx.Txh.Kyber[i])
 			}
-		} else {
-			log.Fatal("unknown sigType ", ctx.sigContext.SigType)
 		}
 
-	} else if ctx.txModel == 5 || ctx.txModel == 6 {
-		log.Fatal("does not need ", ctx.txModel)
 	} else {
-		log.Fatal("unknown txModel ", ctx.txModel)
+		return nil, false, fmt.Errorf("%w: %s does not store full transactions", ErrUnknownTxModel, ctx.model.Name())
 	}
 	return &tx, true, nil
 }
 
 // getTxHeader returns headers data for origami utxo verification
 func (ctx *ExeContext) getTxHeader(txn int, txh *TxHeader) (bool, error) {
-	row := ctx.db.QueryRow("SELECT activity, excess, sig  FROM txHeaders WHERE txn = ?;", txn)
-	txh.Kyber = make([]Signature, 1)
-	err := row.Scan(&txh.activityProof, &txh.excessPK, &txh.Kyber[0])
-	if errors.Is(err, sql.ErrNoRows) {
+	stored, err := ctx.store.TxHeader(txn)
+	if err != nil {
 		return false, err
 	}
-
+	txh.activityProof, txh.excessPK, txh.Kyber = stored.Activity, stored.Excess, []Signature{stored.Sig}
 	return true, nil
 }
 
 // getAggregateOutData returns aggregated data for origami utxo verification
 func (ctx *ExeContext) getAggregateOutData() (bool, []byte, []byte, error) {
-	id := 0
-	used := 0
-	var out User
-	stmt, _ := ctx.db.Prepare("SELECT id, pk, n, data, used  FROM outputs;") //todo: add txn
-	rows, err := stmt.Query()
-	if err != nil {
-		return false, nil, nil, err
-	}
-
 	temp := C.BN_new()
 	totalD := C.BN_new()
 	C.BN_bin2bn((*C.uchar)(unsafe.Pointer(&ctx.bnOne[0])), 33, temp)
 	C.BN_copy(totalD, temp)
-	var pk Pubkey
-	var totalExcess kyber.Point
-	first := 0
-	for rows.Next() {
-		err = rows.Scan(&id, &out.Keys, &out.N, &out.Data, &used)
-		if errors.Is(err, sql.ErrNoRows) {
-			return false, nil, nil, err
-		}
-		header := ctx.computeOutIdentifier(out.Keys, out.N, out.Data)
+	var excessKeys []*Pubkey
+	err := ctx.store.ForEachOutput(func(out *StoredOutput) error { //todo: add txn
+		header := ctx.computeOutIdentifier(out.Pk, out.N, out.Data)
 
 		C.BN_bin2bn((*C.uchar)(unsafe.Pointer(&header[0])), 32, temp)
 		C.BN_mod_mul(totalD, totalD, temp, ctx.bnQ, ctx.bnCtx)
 
-		ctx.sigContext.unmarshelPublicKeysFromBytes(&pk, out.Keys)
-		ctx.sigContext.selfMultiplyPubKey(&pk, header) //todo: modify
-		if first == 0 {
-			totalExcess = pk.kyber.Clone()
-			first++
-		} else {
-			totalExcess.Add(totalExcess, pk.kyber)
+		var pk Pubkey
+		if err := ctx.sigContext.unmarshelPublicKeysFromBytes(&pk, out.Pk); err != nil {
+			return err
 		}
+		if err := ctx.sigContext.selfMultiplyPubKey(&pk, header); err != nil { //todo: modify
+			return err
+		}
+		excessKeys = append(excessKeys, &pk)
+		return nil
+	})
+	if err != nil {
+		return false, nil, nil, err
 	}
 	HProd := make([]byte, 33)
 	C.BN_bn2binpad(totalD, (*C.uchar)(unsafe.Pointer(&HProd[0])), 33)
-	excessBytes, err1 := totalExcess.MarshalBinary()
-	if err1 != nil {
-		return false, nil, nil, err1
+	if len(excessKeys) == 0 {
+		return false, nil, nil, fmt.Errorf("%w: no outputs", ErrInvalidChain)
+	}
+	excessBytes, err := ctx.sigContext.diffPK(excessKeys, nil)
+	if err != nil {
+		return false, nil, nil, err
 	}
 
 	return true, excessBytes, HProd, nil
@@ -685,8 +538,8 @@ func (ctx *ExeContext) getAggregateOutData() (bool, []byte, []byte, error) {
 
 // setActivityTable arrange db data for origami account verification
 func (ctx *ExeContext) setActivityTable() ([]bytes.Buffer, []byte, error) {
-	if ctx.txModel != 6 {
-		log.Fatal("these functions are not needed")
+	if !ctx.origamiAccounts() {
+		return nil, nil, fmt.Errorf("%w: activity table is only for origami accounts", ErrUnknownTxModel)
 	}
 	temp := C.BN_new()
 	d := C.BN_new()
@@ -694,50 +547,88 @@ func (ctx *ExeContext) setActivityTable() ([]bytes.Buffer, []byte, error) {
 	C.BN_set_bit(d, 255)
 
 	activities := make([]bytes.Buffer, ctx.CurrentUsers)
-	var activity []byte
-	var outBuf []byte
-	txn := 0
-	id := 0
-
-	stmt, err := ctx.db.Prepare("SELECT txn, activity, allOutIds  FROM txHeaders;")
-	if err != nil {
-		return nil, nil, err
-	}
-	defer stmt.Close()
-	rows, err := stmt.Query()
-	if err != nil {
-		return nil, nil, err
-	}
-	defer rows.Close()
-
-	for rows.Next() {
-		rows.Scan(&txn, &activity, &outBuf)
-
-		if len(activity) != 33 { // check current length
-			return nil, nil, errors.New("TXHELPER_INVALID_ACTIVITY")
+	err := ctx.store.ForEachTxHeader(func(txh *StoredTxHeader) error {
+		if len(txh.Activity) != 33 { // check current length
+			return ErrInvalidActivity
 		}
 
 		// update the prod of activities
-		C.BN_bin2bn((*C.uchar)(unsafe.Pointer(&activity[0])), 33, temp)
+		C.BN_bin2bn((*C.uchar)(unsafe.Pointer(&txh.Activity[0])), 33, temp)
 		C.BN_mod_mul(d, d, temp, ctx.bnQ, ctx.bnCtx)
 
-		for i := 0; i < len(outBuf)/4; i++ {
-			for j := i + 1; j < len(outBuf)/4; j++ {
-				if byte4toInt(outBuf[i*4:]) == byte4toInt(outBuf[j*4:]) {
-					return nil, nil, errors.New("TXHELPER_REUSED_PK")
+		for i := 0; i < len(txh.OutIds); i++ {
+			for j := i + 1; j < len(txh.OutIds); j++ {
+				if txh.OutIds[i] == txh.OutIds[j] {
+					return ErrReusedPublicKey
 				}
 			}
 		}
 
-		for i := 0; i < len(outBuf)/4; i++ {
-			id = byte4toInt(outBuf[i*4:])
-			activities[id].Write(activity)
+		for i := 0; i < len(txh.OutIds); i++ {
+			activities[txh.OutIds[i]].Write(txh.Activity)
 		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
 	}
 	activityProd := make([]byte, 33)
 	C.BN_bn2binpad(d, (*C.uchar)(unsafe.Pointer(&activityProd[0])), 33)
-	if err = rows.Err(); err != nil {
-		return nil, nil, errors.New("TXHELPER_INVALID_TXH")
-	}
 	return activities, activityProd, nil
 }
+
+// insertPeerBlock saves block metadata. Transactions of the block must be inserted before.
+func (ctx *ExeContext) insertPeerBlock(b *Block, hash []byte, firstTxn int) (bool, error) {
+	err := ctx.store.PutBlock(&StoredBlock{Height: b.Height, Hash: hash, Parent: b.ParentHash, Root: b.Root,
+		Sig: []byte(b.Sig), FirstTxn: firstTxn, TxCount: len(b.Txs)})
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// getPeerBlock returns found, hash, parent, root, sig, firstTxn, txCount, err
+func (ctx *ExeContext) getPeerBlock(height int) (bool, []byte, []byte, []byte, []byte, int, int, error) {
+	b, err := ctx.store.Block(height)
+	if err != nil {
+		return false, nil, nil, nil, nil, -1, -1, err
+	}
+	return true, b.Hash, b.Parent, b.Root, b.Sig, b.FirstTxn, b.TxCount, nil
+}
+
+// AccountHistorySizes returns the number of history transactions (Txns) of each account in the order of ids (model 6)
+func (ctx *ExeContext) AccountHistorySizes() ([]int, error) {
+	if ctx.uType != 2 {
+		return nil, ErrNotPeer
+	}
+	if !ctx.origamiAccounts() {
+		return nil, fmt.Errorf("%w: %s doesn't keep account histories", ErrUnknownTxModel, ctx.model.Name())
+	}
+	var sizes []int
+	err := ctx.store.ForEachOutput(func(out *StoredOutput) error {
+		sizes = append(sizes, len(out.Txns))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return sizes, nil
+}
+
+// getStoredOrigamiAccTx returns the activity and output public keys of an origami account transaction
+func (ctx *ExeContext) getStoredOrigamiAccTx(txn int, tx *Transaction) (bool, error) {
+	txh, err := ctx.store.TxHeader(txn)
+	if err != nil {
+		return false, err
+	}
+	tx.Txh.activityProof = txh.Activity
+	tx.Data.Outputs = make([]OutputData, len(txh.OutIds))
+	for i := 0; i < len(txh.OutIds); i++ {
+		out, err := ctx.store.OutputByID(txh.OutIds[i])
+		if err != nil {
+			return false, err
+		}
+		tx.Data.Outputs[i].Pk = out.Pk
+	}
+	return true, nil
+}
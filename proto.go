@@ -0,0 +1,298 @@
+/**********************************************************************
+ * Copyright (c) 2017 Jayamine Alupotha                               *
+ * Distributed under the MIT software license, see the accompanying   *
+ * file COPYING or http://www.opensource.org/licenses/mit-license.php.*
+ **********************************************************************/
+
+package txhelper
+
+import (
+	"fmt"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Protobuf encodings of the messages in txhelper.proto. Encodings are deterministic: fields are written in the order
+// of their numbers, and empty scalar fields are omitted. Decoders accept any valid encoding and skip unknown fields.
+// Like protobuf, a repeated embedded message is merged into the earlier one: its repeated fields are appended, and its
+// scalar fields replace the earlier values.
+
+// protoError wraps protobuf parse errors
+func protoError(n int) error {
+	return fmt.Errorf("%w: protobuf: %v", ErrMalformedEncoding, protowire.ParseError(n))
+}
+
+// appendProtoBytes writes a bytes field if it is not empty
+func appendProtoBytes(b []byte, num protowire.Number, v []byte) []byte {
+	if len(v) == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, v)
+}
+
+// appendProtoMessage writes an embedded message, including empty messages of repeated fields if always is true
+func appendProtoMessage(b []byte, num protowire.Number, msg []byte, always bool) []byte {
+	if len(msg) == 0 && !always {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, msg)
+}
+
+// appendProtoVarint writes a varint field if it is not zero
+func appendProtoVarint(b []byte, num protowire.Number, v uint64) []byte {
+	if v == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.VarintType)
+	return protowire.AppendVarint(b, v)
+}
+
+// protoFields calls field with the number, the type and the value of every field in b. field returns the length of
+// the consumed value, or -1 to skip the value.
+func protoFields(b []byte, field func(num protowire.Number, typ protowire.Type, b []byte) (int, error)) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return protoError(n)
+		}
+		b = b[n:]
+		n, err := field(num, typ, b)
+		if err != nil {
+			return err
+		}
+		if n < 0 {
+			if n = protowire.ConsumeFieldValue(num, typ, b); n < 0 {
+				return protoError(n)
+			}
+		}
+		b = b[n:]
+	}
+	return nil
+}
+
+// consumeProtoBytes parses a bytes field and copies its value
+func consumeProtoBytes(typ protowire.Type, b []byte) ([]byte, int, error) {
+	if typ != protowire.BytesType {
+		return nil, 0, fmt.Errorf("%w: protobuf: expected bytes", ErrMalformedEncoding)
+	}
+	v, n := protowire.ConsumeBytes(b)
+	if n < 0 {
+		return nil, 0, protoError(n)
+	}
+	return append([]byte{}, v...), n, nil
+}
+
+// consumeProtoVarint parses a varint field
+func consumeProtoVarint(typ protowire.Type, b []byte) (uint64, int, error) {
+	if typ != protowire.VarintType {
+		return 0, 0, fmt.Errorf("%w: protobuf: expected varint", ErrMalformedEncoding)
+	}
+	v, n := protowire.ConsumeVarint(b)
+	if n < 0 {
+		return 0, 0, protoError(n)
+	}
+	return v, n, nil
+}
+
+// MarshalProto encodes the application data as the AppData message
+func (data *AppData) MarshalProto() []byte {
+	var b []byte
+	for i := range data.Inputs {
+		b = appendProtoMessage(b, 1, appendProtoBytes(nil, 1, data.Inputs[i].Header), true)
+	}
+	for i := range data.Outputs {
+		var out []byte
+		out = appendProtoBytes(out, 1, data.Outputs[i].Pk)
+		out = appendProtoVarint(out, 2, uint64(data.Outputs[i].N))
+		out = appendProtoBytes(out, 3, data.Outputs[i].Data)
+		b = appendProtoMessage(b, 2, out, true)
+	}
+	return b
+}
+
+// UnmarshalProto parses the AppData message
+func (data *AppData) UnmarshalProto(b []byte) error {
+	*data = AppData{}
+	return data.mergeProto(b)
+}
+
+// mergeProto parses the AppData message into data
+func (data *AppData) mergeProto(b []byte) error {
+	return protoFields(b, func(num protowire.Number, typ protowire.Type, b []byte) (int, error) {
+		switch num {
+		case 1:
+			msg, n, err := consumeProtoBytes(typ, b)
+			if err != nil {
+				return 0, err
+			}
+			var in InputData
+			err = protoFields(msg, func(num protowire.Number, typ protowire.Type, b []byte) (int, error) {
+				if num != 1 {
+					return -1, nil
+				}
+				var n int
+				var err error
+				in.Header, n, err = consumeProtoBytes(typ, b)
+				return n, err
+			})
+			data.Inputs = append(data.Inputs, in)
+			return n, err
+		case 2:
+			msg, n, err := consumeProtoBytes(typ, b)
+			if err != nil {
+				return 0, err
+			}
+			var out OutputData
+			err = protoFields(msg, func(num protowire.Number, typ protowire.Type, b []byte) (int, error) {
+				switch num {
+				case 1:
+					var n int
+					var err error
+					out.Pk, n, err = consumeProtoBytes(typ, b)
+					return n, err
+				case 2:
+					v, n, err := consumeProtoVarint(typ, b)
+					if err == nil && v > 0xff {
+						err = fmt.Errorf("%w: protobuf: n is larger than 255", ErrMalformedEncoding)
+					}
+					out.N = uint8(v)
+					return n, err
+				case 3:
+					var n int
+					var err error
+					out.Data, n, err = consumeProtoBytes(typ, b)
+					return n, err
+				}
+				return -1, nil
+			})
+			data.Outputs = append(data.Outputs, out)
+			return n, err
+		}
+		return -1, nil
+	})
+}
+
+// MarshalProto encodes the header as the TxHeader message, including the Origami activity proof and excess public key
+func (txh *TxHeader) MarshalProto() []byte {
+	var b []byte
+	for i := range txh.Kyber {
+		b = protowire.AppendTag(b, 1, protowire.BytesType)
+		b = protowire.AppendBytes(b, txh.Kyber[i])
+	}
+	b = appendProtoBytes(b, 2, txh.activityProof)
+	return appendProtoBytes(b, 3, txh.excessPK)
+}
+
+// UnmarshalProto parses the TxHeader message
+func (txh *TxHeader) UnmarshalProto(b []byte) error {
+	*txh = TxHeader{}
+	return txh.mergeProto(b)
+}
+
+// mergeProto parses the TxHeader message into txh
+func (txh *TxHeader) mergeProto(b []byte) error {
+	return protoFields(b, func(num protowire.Number, typ protowire.Type, b []byte) (int, error) {
+		switch num {
+		case 1:
+			sig, n, err := consumeProtoBytes(typ, b)
+			txh.Kyber = append(txh.Kyber, sig)
+			return n, err
+		case 2:
+			var n int
+			var err error
+			txh.activityProof, n, err = consumeProtoBytes(typ, b)
+			return n, err
+		case 3:
+			var n int
+			var err error
+			txh.excessPK, n, err = consumeProtoBytes(typ, b)
+			return n, err
+		}
+		return -1, nil
+	})
+}
+
+// MarshalProto encodes the transaction as the Transaction message
+func (tx *Transaction) MarshalProto() []byte {
+	var b []byte
+	b = appendProtoVarint(b, 1, uint64(int64(tx.N)))
+	b = appendProtoMessage(b, 2, tx.Txh.MarshalProto(), false)
+	return appendProtoMessage(b, 3, tx.Data.MarshalProto(), false)
+}
+
+// UnmarshalProto parses the Transaction message
+func (tx *Transaction) UnmarshalProto(b []byte) error {
+	*tx = Transaction{}
+	return protoFields(b, func(num protowire.Number, typ protowire.Type, b []byte) (int, error) {
+		switch num {
+		case 1:
+			v, n, err := consumeProtoVarint(typ, b)
+			tx.N = int32(v)
+			return n, err
+		case 2:
+			msg, n, err := consumeProtoBytes(typ, b)
+			if err != nil {
+				return 0, err
+			}
+			return n, tx.Txh.mergeProto(msg)
+		case 3:
+			msg, n, err := consumeProtoBytes(typ, b)
+			if err != nil {
+				return 0, err
+			}
+			return n, tx.Data.mergeProto(msg)
+		}
+		return -1, nil
+	})
+}
+
+// MarshalProto encodes the block as the Block message
+func (b *Block) MarshalProto() []byte {
+	var buf []byte
+	buf = appendProtoVarint(buf, 1, uint64(int64(b.Height)))
+	buf = appendProtoBytes(buf, 2, b.ParentHash)
+	buf = appendProtoBytes(buf, 3, b.Root)
+	for i := range b.Txs {
+		buf = appendProtoMessage(buf, 4, b.Txs[i].MarshalProto(), true)
+	}
+	return appendProtoBytes(buf, 5, b.Sig)
+}
+
+// UnmarshalProto parses the Block message
+func (b *Block) UnmarshalProto(buf []byte) error {
+	*b = Block{}
+	return protoFields(buf, func(num protowire.Number, typ protowire.Type, buf []byte) (int, error) {
+		switch num {
+		case 1:
+			v, n, err := consumeProtoVarint(typ, buf)
+			b.Height = int(int64(v))
+			return n, err
+		case 2:
+			var n int
+			var err error
+			b.ParentHash, n, err = consumeProtoBytes(typ, buf)
+			return n, err
+		case 3:
+			var n int
+			var err error
+			b.Root, n, err = consumeProtoBytes(typ, buf)
+			return n, err
+		case 4:
+			msg, n, err := consumeProtoBytes(typ, buf)
+			if err != nil {
+				return 0, err
+			}
+			tx := new(Transaction)
+			b.Txs = append(b.Txs, tx)
+			return n, tx.UnmarshalProto(msg)
+		case 5:
+			var n int
+			var err error
+			b.Sig, n, err = consumeProtoBytes(typ, buf)
+			return n, err
+		}
+		return -1, nil
+	})
+}
@@ -0,0 +1,137 @@
+/**********************************************************************
+ * Copyright (c) 2017 Jayamine Alupotha                               *
+ * Distributed under the MIT software license, see the accompanying   *
+ * file COPYING or http://www.opensource.org/licenses/mit-license.php.*
+ **********************************************************************/
+
+package txhelper
+
+import (
+	"crypto/sha512"
+	"encoding/binary"
+	"go.dedis.ch/kyber/v3"
+)
+
+// halfAggTag separates the hashes of half-aggregation from other hashes
+var halfAggTag = []byte("txhelper/schnorr-halfagg")
+
+// SchnorrHalfAgg is the Schnorr signature of sigType 1 with non-interactive half-aggregation (sigType 8).
+// Individual signatures R || s are the same as KyberSchnorr signatures. n signatures are aggregated into
+// R_1 || ... || R_n || s, where s = sum(z_i * s_i) and z_i are derived from all R_i, public keys and messages.
+// Hence, an aggregate signature is 32(n+1) bytes instead of 64n bytes, but verification still takes n multiplications.
+type SchnorrHalfAgg struct {
+	KyberSchnorr
+}
+
+// NewSchnorrHalfAgg returns the half-aggregated Schnorr scheme of sigType 8
+func NewSchnorrHalfAgg() SchnorrHalfAgg {
+	return SchnorrHalfAgg{NewKyberSchnorr()}
+}
+
+func (SchnorrHalfAgg) Name() string {
+	return "schnorr-halfagg"
+}
+
+// AggregateSize returns the size of an aggregate of n signatures
+func (SchnorrHalfAgg) AggregateSize(n int) int32 {
+	return int32(32 * (n + 1))
+}
+
+// challenge returns H(R || pk || msg) of the kyber Schnorr signature
+func (SchnorrHalfAgg) challenge(R kyber.Point, pk kyber.Point, msg []byte) kyber.Scalar {
+	h := sha512.New()
+	_, _ = R.MarshalTo(h)
+	_, _ = pk.MarshalTo(h)
+	h.Write(msg)
+	return ed25519Suite.Scalar().SetBytes(h.Sum(nil))
+}
+
+// coefficients returns z_i = H(tag || R_1..R_n || pk_1..pk_n || msg_1..msg_n || i)
+func (SchnorrHalfAgg) coefficients(Rs []kyber.Point, pks []Pubkey, msgs [][]byte) []kyber.Scalar {
+	h := sha512.New()
+	h.Write(halfAggTag)
+	for i := range Rs {
+		_, _ = Rs[i].MarshalTo(h)
+	}
+	for i := range pks {
+		_, _ = pks[i].kyber.MarshalTo(h)
+	}
+	var size [4]byte
+	for i := range msgs {
+		binary.BigEndian.PutUint32(size[:], uint32(len(msgs[i])))
+		h.Write(size[:])
+		h.Write(msgs[i])
+	}
+	transcript := h.Sum(nil)
+
+	z := make([]kyber.Scalar, len(Rs))
+	for i := range z {
+		h.Reset()
+		h.Write(transcript)
+		binary.BigEndian.PutUint32(size[:], uint32(i))
+		h.Write(size[:])
+		z[i] = ed25519Suite.Scalar().SetBytes(h.Sum(nil))
+	}
+	return z
+}
+
+func (s SchnorrHalfAgg) AggregateSignatures(pks []Pubkey, msgs [][]byte, sigs []Signature) (Signature, error) {
+	Rs := make([]kyber.Point, len(sigs))
+	ss := make([]kyber.Scalar, len(sigs))
+	for i := range sigs {
+		if len(sigs[i]) != 64 {
+			return nil, newTxError("aggregate signatures", i, ErrMalformedEncoding)
+		}
+		Rs[i] = ed25519Suite.Point()
+		ss[i] = ed25519Suite.Scalar()
+		if err := Rs[i].UnmarshalBinary(sigs[i][:32]); err != nil {
+			return nil, newTxError("aggregate signatures", i, ErrMalformedEncoding)
+		}
+		if err := ss[i].UnmarshalBinary(sigs[i][32:]); err != nil {
+			return nil, newTxError("aggregate signatures", i, ErrMalformedEncoding)
+		}
+	}
+
+	z := s.coefficients(Rs, pks, msgs)
+	aggregate := ed25519Suite.Scalar().Zero()
+	sig := make([]byte, 0, s.AggregateSize(len(sigs)))
+	for i := range sigs {
+		aggregate.Add(aggregate, ed25519Suite.Scalar().Mul(z[i], ss[i]))
+		sig = append(sig, sigs[i][:32]...)
+	}
+	sBytes, err := aggregate.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	return append(sig, sBytes...), nil
+}
+
+// VerifyAggregate checks s * G = sum(z_i * (R_i + H(R_i || pk_i || msg_i) * pk_i))
+func (s SchnorrHalfAgg) VerifyAggregate(pks []Pubkey, msgs [][]byte, sig Signature) bool {
+	if len(pks) == 0 || len(pks) != len(msgs) || len(sig) != int(s.AggregateSize(len(pks))) {
+		return false
+	}
+	Rs := make([]kyber.Point, len(pks))
+	for i := range Rs {
+		Rs[i] = ed25519Suite.Point()
+		if err := Rs[i].UnmarshalBinary(sig[32*i : 32*(i+1)]); err != nil {
+			return false
+		}
+	}
+	aggregate := ed25519Suite.Scalar()
+	if err := aggregate.UnmarshalBinary(sig[32*len(pks):]); err != nil {
+		return false
+	}
+
+	z := s.coefficients(Rs, pks, msgs)
+	expected := ed25519Suite.Point().Null()
+	for i := range pks {
+		if pks[i].kyber == nil {
+			return false
+		}
+		term := ed25519Suite.Point().Mul(s.challenge(Rs[i], pks[i].kyber, msgs[i]), pks[i].kyber)
+		term.Add(term, Rs[i])
+		expected.Add(expected, ed25519Suite.Point().Mul(z[i], term))
+	}
+	return ed25519Suite.Point().Mul(aggregate, nil).Equal(expected)
+}
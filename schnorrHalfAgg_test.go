@@ -0,0 +1,83 @@
+package txhelper
+
+import (
+	"crypto/rand"
+	"testing"
+)
+
+func TestSchnorrHalfAgg(tester *testing.T) {
+	sigCtx, err := NewSigContext(8)
+	if err != nil {
+		tester.Fatal(err)
+	}
+	if !sigCtx.aggregates() || !sigCtx.variableAggregates() || !sigCtx.tweakable() {
+		tester.Fatal("invalid scheme capabilities")
+	}
+	schnorr, err := NewSigContext(1)
+	if err != nil {
+		tester.Fatal(err)
+	}
+
+	for n := 1; n <= 5; n++ {
+		pks := make([]Pubkey, n)
+		msgs := make([][]byte, n)
+		sigs := make([]Signature, n)
+		for j := 0; j < n; j++ {
+			var keys SigKeyPair
+			if err := sigCtx.generate(&keys); err != nil {
+				tester.Fatal(err)
+			}
+			pks[j] = sigCtx.getPubKey(&keys)
+			msgs[j] = make([]byte, 32)
+			rand.Read(msgs[j])
+			if sigs[j], err = sigCtx.sign(&keys, msgs[j]); err != nil {
+				tester.Fatal(err)
+			}
+			// individual signatures are Schnorr signatures of sigType 1
+			if !schnorr.verify(&pks[j], msgs[j], sigs[j]) {
+				tester.Fatal("invalid individual signature")
+			}
+		}
+
+		sig, err := sigCtx.aggregateSignaturesMultipleMsg(pks, msgs, sigs)
+		if err != nil {
+			tester.Fatal(err)
+		}
+		if len(sig) != 32*(n+1) {
+			tester.Fatal("invalid aggregate size:", n, len(sig))
+		}
+		if !sigCtx.batchVerifyMultipleMsg(pks, msgs, sig) {
+			tester.Fatal("invalid aggregated signature:", n)
+		}
+
+		tampered := append(Signature{}, sig...)
+		tampered[len(tampered)-1] ^= 1
+		if sigCtx.batchVerifyMultipleMsg(pks, msgs, tampered) {
+			tester.Fatal("tampered aggregated signature was accepted:", n)
+		}
+		if sigCtx.batchVerifyMultipleMsg(pks, msgs, sig[:len(sig)-1]) {
+			tester.Fatal("truncated aggregated signature was accepted:", n)
+		}
+		if sigCtx.scheme.(AggregateScheme).VerifyAggregate(pks, msgs[1:], sig) {
+			tester.Fatal("aggregated signature with missing messages was accepted:", n)
+		}
+		if n > 1 {
+			msgs[0], msgs[1] = msgs[1], msgs[0]
+			if sigCtx.batchVerifyMultipleMsg(pks, msgs, sig) {
+				tester.Fatal("aggregated signature of swapped messages was accepted:", n)
+			}
+		}
+	}
+}
+
+func TestSchnorrHalfAggPeers(tester *testing.T) {
+	for i := 1; i <= 4; i++ {
+		ctx := newTestContext(tester, 400, 1, i, 8, 32, 10, 4, 5, 1, false, 2)
+		ctx.testPeerTransactions(10, tester)
+		ctx.testPeerBatchTransactions(10, tester)
+	}
+	for i := 5; i <= 6; i++ {
+		ctx := newTestContext(tester, 401, 1, i, 8, 32, 10, 4, 5, 1, false, 2)
+		ctx.testPeerTransactions(10, tester)
+	}
+}
@@ -0,0 +1,201 @@
+/**********************************************************************
+ * Copyright (c) 2017 Jayamine Alupotha                               *
+ * Distributed under the MIT software license, see the accompanying   *
+ * file COPYING or http://www.opensource.org/licenses/mit-license.php.*
+ **********************************************************************/
+
+package txhelper
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/ecdsa"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"io"
+)
+
+// secpKeys implements secret key generation and encoding of secp256k1 schemes. Keys are encoded as pk || sk.
+type secpKeys struct{}
+
+func (secpKeys) Generate(rand io.Reader, keys *SigKeyPair) error {
+	var skBytes [32]byte
+	for {
+		if _, err := io.ReadFull(rand, skBytes[:]); err != nil {
+			return err
+		}
+		sk, _ := btcec.PrivKeyFromBytes(skBytes[:])
+		if !sk.Key.IsZero() {
+			keys.key = sk
+			return nil
+		}
+	}
+}
+
+func (secpKeys) secretKey(keys *SigKeyPair) (*btcec.PrivateKey, error) {
+	sk, ok := keys.key.(*btcec.PrivateKey)
+	if !ok {
+		return nil, errors.New("secp256k1: invalid secret key")
+	}
+	return sk, nil
+}
+
+func (secpKeys) publicKey(pk *Pubkey) (*btcec.PublicKey, bool) {
+	key, ok := pk.key.(*btcec.PublicKey)
+	return key, ok
+}
+
+func (secpKeys) PublicKey(keys *SigKeyPair) Pubkey {
+	return Pubkey{key: keys.key.(*btcec.PrivateKey).PubKey()}
+}
+
+// SecpECDSA is the ECDSA signature on secp256k1 of Bitcoin and Ethereum (sigType 4). Messages are hashed with sha256,
+// public keys are 33-byte compressed points, and signatures are 64-byte r || s. Like BIP-146, only signatures with
+// s <= n/2 are valid, so a signature can't be changed into another valid encoding.
+type SecpECDSA struct {
+	secpKeys
+}
+
+func (SecpECDSA) Name() string {
+	return "secp256k1-ecdsa"
+}
+
+func (SecpECDSA) Sizes() (int32, int32, int32) {
+	return btcec.PrivKeyBytesLen, btcec.PubKeyBytesLenCompressed, 64
+}
+
+func (e SecpECDSA) Sign(keys *SigKeyPair, msg []byte) (Signature, error) {
+	sk, err := e.secretKey(keys)
+	if err != nil {
+		return nil, err
+	}
+	hash := sha256.Sum256(msg)
+	sig, err := ecdsa.SignCompact(sk, hash[:], true)
+	if err != nil {
+		return nil, err
+	}
+	return sig[1:], nil // remove the recovery code
+}
+
+func (e SecpECDSA) Verify(pk *Pubkey, msg []byte, sig Signature) bool {
+	key, ok := e.publicKey(pk)
+	if !ok || len(sig) != 64 {
+		return false
+	}
+	var r, s btcec.ModNScalar
+	if r.SetByteSlice(sig[:32]) || s.SetByteSlice(sig[32:]) || r.IsZero() || s.IsZero() || s.IsOverHalfOrder() {
+		return false
+	}
+	hash := sha256.Sum256(msg)
+	return ecdsa.NewSignature(&r, &s).Verify(hash[:], key)
+}
+
+func (e SecpECDSA) MarshalKeys(keys *SigKeyPair, buf *bytes.Buffer) {
+	sk := keys.key.(*btcec.PrivateKey)
+	buf.Write(sk.PubKey().SerializeCompressed())
+	buf.Write(sk.Serialize())
+}
+
+func (e SecpECDSA) UnmarshalKeys(keys *SigKeyPair, buf []byte) error {
+	pkSize := btcec.PubKeyBytesLenCompressed
+	sk, pk := btcec.PrivKeyFromBytes(buf[pkSize : pkSize+btcec.PrivKeyBytesLen])
+	if sk.Key.IsZero() || !bytes.Equal(pk.SerializeCompressed(), buf[:pkSize]) {
+		return errors.New("secp256k1: public key doesn't match the secret key")
+	}
+	keys.key = sk
+	return nil
+}
+
+func (e SecpECDSA) MarshalPublicKey(pk *Pubkey) ([]byte, error) {
+	key, ok := e.publicKey(pk)
+	if !ok {
+		return nil, errors.New("secp256k1: invalid public key")
+	}
+	return key.SerializeCompressed(), nil
+}
+
+func (e SecpECDSA) UnmarshalPublicKey(pk *Pubkey, buf []byte) error {
+	if !btcec.IsCompressedPubKey(buf) {
+		return errors.New("secp256k1: public key is not compressed")
+	}
+	key, err := btcec.ParsePubKey(buf)
+	if err != nil {
+		return err
+	}
+	pk.key = key
+	return nil
+}
+
+// SecpSchnorr is the BIP-340 Schnorr signature on secp256k1 of Bitcoin taproot (sigType 5). Messages are hashed with
+// sha256, public keys are 32-byte x-only points, and signatures are 64 bytes.
+type SecpSchnorr struct {
+	secpKeys
+}
+
+func (SecpSchnorr) Name() string {
+	return "secp256k1-schnorr"
+}
+
+func (SecpSchnorr) Sizes() (int32, int32, int32) {
+	return btcec.PrivKeyBytesLen, schnorr.PubKeyBytesLen, schnorr.SignatureSize
+}
+
+func (b SecpSchnorr) Sign(keys *SigKeyPair, msg []byte) (Signature, error) {
+	sk, err := b.secretKey(keys)
+	if err != nil {
+		return nil, err
+	}
+	hash := sha256.Sum256(msg)
+	sig, err := schnorr.Sign(sk, hash[:])
+	if err != nil {
+		return nil, err
+	}
+	return sig.Serialize(), nil
+}
+
+func (b SecpSchnorr) Verify(pk *Pubkey, msg []byte, sig Signature) bool {
+	key, ok := b.publicKey(pk)
+	if !ok {
+		return false
+	}
+	parsed, err := schnorr.ParseSignature(sig)
+	if err != nil {
+		return false
+	}
+	hash := sha256.Sum256(msg)
+	return parsed.Verify(hash[:], key)
+}
+
+func (b SecpSchnorr) MarshalKeys(keys *SigKeyPair, buf *bytes.Buffer) {
+	sk := keys.key.(*btcec.PrivateKey)
+	buf.Write(schnorr.SerializePubKey(sk.PubKey()))
+	buf.Write(sk.Serialize())
+}
+
+func (b SecpSchnorr) UnmarshalKeys(keys *SigKeyPair, buf []byte) error {
+	pkSize := schnorr.PubKeyBytesLen
+	sk, pk := btcec.PrivKeyFromBytes(buf[pkSize : pkSize+btcec.PrivKeyBytesLen])
+	if sk.Key.IsZero() || !bytes.Equal(schnorr.SerializePubKey(pk), buf[:pkSize]) {
+		return errors.New("secp256k1: public key doesn't match the secret key")
+	}
+	keys.key = sk
+	return nil
+}
+
+func (b SecpSchnorr) MarshalPublicKey(pk *Pubkey) ([]byte, error) {
+	key, ok := b.publicKey(pk)
+	if !ok {
+		return nil, errors.New("secp256k1: invalid public key")
+	}
+	return schnorr.SerializePubKey(key), nil
+}
+
+func (b SecpSchnorr) UnmarshalPublicKey(pk *Pubkey, buf []byte) error {
+	key, err := schnorr.ParsePubKey(buf)
+	if err != nil {
+		return err
+	}
+	pk.key = key
+	return nil
+}
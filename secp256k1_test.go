@@ -0,0 +1,106 @@
+package txhelper
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/ecdsa"
+	"testing"
+)
+
+func TestSecp256k1(tester *testing.T) {
+	pkSizes := map[int32]int32{4: 33, 5: 32}
+	for sigType, pkSize := range pkSizes {
+		sigCtx, err := NewSigContext(sigType)
+		if err != nil {
+			tester.Fatal(err)
+		}
+		if sigCtx.SkSize != 32 || sigCtx.PkSize != pkSize || sigCtx.SigSize != 64 {
+			tester.Fatal("invalid sizes:", sigType, sigCtx.SkSize, sigCtx.PkSize, sigCtx.SigSize)
+		}
+		for j := 0; j < 10; j++ {
+			msg := make([]byte, 32)
+			rand.Read(msg)
+			var keys SigKeyPair
+			var keys1 SigKeyPair
+			var kp bytes.Buffer
+			if err := sigCtx.generate(&keys); err != nil {
+				tester.Fatal(err)
+			}
+			sigCtx.marshelKeys(&keys, &kp)
+			if kp.Len() != int(sigCtx.PkSize+sigCtx.SkSize) {
+				tester.Fatal("invalid key size:", sigType, kp.Len())
+			}
+			if err := sigCtx.unmarshelKeys(&keys1, kp.Bytes()); err != nil {
+				tester.Fatal(err)
+			}
+			sig, err := sigCtx.sign(&keys1, msg)
+			if err != nil {
+				tester.Fatal(err)
+			}
+
+			var pk Pubkey
+			if err := sigCtx.unmarshelPublicKeysFromBytes(&pk, kp.Bytes()[:sigCtx.PkSize]); err != nil {
+				tester.Fatal(err)
+			}
+			if !sigCtx.verify(&pk, msg, sig) {
+				tester.Fatal("invalid signature:", sigType)
+			}
+			sig[0] ^= 1
+			if sigCtx.verify(&pk, msg, sig) {
+				tester.Fatal("tampered signature was accepted:", sigType)
+			}
+		}
+	}
+}
+
+func TestSecpECDSAHighS(tester *testing.T) {
+	sigCtx, err := NewSigContext(4)
+	if err != nil {
+		tester.Fatal(err)
+	}
+	msg := make([]byte, 32)
+	rand.Read(msg)
+	var keys SigKeyPair
+	if err := sigCtx.generate(&keys); err != nil {
+		tester.Fatal(err)
+	}
+	pk := sigCtx.getPubKey(&keys)
+	sig, err := sigCtx.sign(&keys, msg)
+	if err != nil {
+		tester.Fatal(err)
+	}
+
+	// (r, n - s) is a valid ECDSA signature of the same message
+	var r, s btcec.ModNScalar
+	r.SetByteSlice(sig[:32])
+	s.SetByteSlice(sig[32:])
+	s.Negate()
+	hash := sha256.Sum256(msg)
+	if !ecdsa.NewSignature(&r, &s).Verify(hash[:], pk.key.(*btcec.PublicKey)) {
+		tester.Fatal("couldn't create the high-S signature")
+	}
+	highS := append(Signature{}, sig[:32]...)
+	sBytes := s.Bytes()
+	highS = append(highS, sBytes[:]...)
+	if sigCtx.verify(&pk, msg, highS) {
+		tester.Fatal("high-S signature was accepted")
+	}
+	if !sigCtx.verify(&pk, msg, sig) {
+		tester.Fatal("low-S signature was rejected")
+	}
+}
+
+func TestSecp256k1Peers(tester *testing.T) {
+	for sigType := int32(4); sigType <= 5; sigType++ {
+		for i := 1; i <= 4; i++ {
+			ctx := newTestContext(tester, 380, 1, i, sigType, 32, 10, 4, 5, 1, false, 2)
+			ctx.testPeerTransactions(10, tester)
+		}
+		if _, err := NewContext(380, 2, 5, sigType, 32, 10, 4, 5, 1, false, 2); !errors.Is(err, ErrInvalidConfig) {
+			tester.Fatal("origami utxo accepted secp256k1:", err)
+		}
+	}
+}
@@ -0,0 +1,184 @@
+/**********************************************************************
+ * Copyright (c) 2017 Jayamine Alupotha                               *
+ * Distributed under the MIT software license, see the accompanying   *
+ * file COPYING or http://www.opensource.org/licenses/mit-license.php.*
+ **********************************************************************/
+
+package txhelper
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+)
+
+// MaxShapeSize is the maximum number of inputs or outputs drawn by shape distributions, since counts are encoded as
+// single bytes
+const MaxShapeSize = 0xfe
+
+// TxShape is the number of inputs and outputs of a transaction
+type TxShape struct {
+	Inputs  uint8
+	Outputs uint8
+}
+
+// ShapeDistribution draws the number of inputs and outputs of random transactions. Account models can't have more
+// inputs than outputs, so extra inputs are ignored. If there are not enough inputs, the input size is reduced.
+type ShapeDistribution interface {
+	// Name returns a short name of the distribution
+	Name() string
+	// Draw draws the shape of the next transaction
+	Draw(r *rand.Rand) TxShape
+}
+
+// clampShape rounds a drawn count into [low, MaxShapeSize]
+func clampShape(size int, low int) uint8 {
+	if size < low {
+		return uint8(low)
+	}
+	if size > MaxShapeSize {
+		return MaxShapeSize
+	}
+	return uint8(size)
+}
+
+// FixedShape gives every transaction the same shape
+type FixedShape TxShape
+
+func (FixedShape) Name() string {
+	return "fixed"
+}
+
+func (d FixedShape) Draw(*rand.Rand) TxShape {
+	return TxShape(d)
+}
+
+// UniformShape draws inputs from [0, MaxInputs] and outputs from [1, MaxOutputs]. This is the default distribution
+// with AverageInputMax and AverageOutputMax.
+type UniformShape struct {
+	MaxInputs  uint8
+	MaxOutputs uint8
+}
+
+func (UniformShape) Name() string {
+	return "uniform"
+}
+
+func (d UniformShape) Draw(r *rand.Rand) TxShape {
+	return TxShape{
+		Inputs:  clampShape(r.Intn(int(d.MaxInputs)+1), 0),
+		Outputs: clampShape(r.Intn(int(math.Max(float64(d.MaxOutputs), 1)))+1, 1),
+	}
+}
+
+// PoissonShape draws inputs from Poisson(InputMean) and outputs from Poisson(OutputMean), with at least one output
+type PoissonShape struct {
+	InputMean  float64
+	OutputMean float64
+}
+
+func (PoissonShape) Name() string {
+	return "poisson"
+}
+
+// poisson draws from Poisson(mean) with Knuth's method, which is enough for small means of transaction shapes
+func poisson(r *rand.Rand, mean float64) int {
+	if mean <= 0 {
+		return 0
+	}
+	if mean > 100 {
+		return int(math.Round(mean + math.Sqrt(mean)*r.NormFloat64()))
+	}
+	limit := math.Exp(-mean)
+	k := 0
+	for p := r.Float64(); p > limit; p *= r.Float64() {
+		k++
+	}
+	return k
+}
+
+func (d PoissonShape) Draw(r *rand.Rand) TxShape {
+	return TxShape{Inputs: clampShape(poisson(r, d.InputMean), 0), Outputs: clampShape(poisson(r, d.OutputMean), 1)}
+}
+
+// ConsolidationShape merges [MinInputs, MaxInputs] inputs into one output, e.g., coin consolidation of UTXO wallets
+type ConsolidationShape struct {
+	MinInputs uint8
+	MaxInputs uint8
+}
+
+func (ConsolidationShape) Name() string {
+	return "consolidation"
+}
+
+func (d ConsolidationShape) Draw(r *rand.Rand) TxShape {
+	inputs := int(d.MinInputs)
+	if d.MaxInputs > d.MinInputs {
+		inputs += r.Intn(int(d.MaxInputs-d.MinInputs) + 1)
+	}
+	return TxShape{Inputs: clampShape(inputs, 0), Outputs: 1}
+}
+
+// FanOutShape spends one input into [MinOutputs, MaxOutputs] outputs, e.g., batch payouts of exchanges
+type FanOutShape struct {
+	MinOutputs uint8
+	MaxOutputs uint8
+}
+
+func (FanOutShape) Name() string {
+	return "fan-out"
+}
+
+func (d FanOutShape) Draw(r *rand.Rand) TxShape {
+	outputs := int(d.MinOutputs)
+	if d.MaxOutputs > d.MinOutputs {
+		outputs += r.Intn(int(d.MaxOutputs-d.MinOutputs) + 1)
+	}
+	return TxShape{Inputs: 1, Outputs: clampShape(outputs, 1)}
+}
+
+// EmpiricalShape draws shapes from a histogram, where shapes[i] is drawn with the probability counts[i] / sum(counts)
+type EmpiricalShape struct {
+	shapes     []TxShape
+	cumulative []int64
+}
+
+// NewEmpiricalShape creates a distribution from a histogram of shapes
+func NewEmpiricalShape(shapes []TxShape, counts []int64) (*EmpiricalShape, error) {
+	if len(shapes) == 0 || len(shapes) != len(counts) {
+		return nil, fmt.Errorf("%w: histogram needs the same number of shapes and counts", ErrInvalidConfig)
+	}
+	d := EmpiricalShape{shapes: make([]TxShape, len(shapes)), cumulative: make([]int64, len(shapes))}
+	total := int64(0)
+	for i := range shapes {
+		if shapes[i].Outputs == 0 || shapes[i].Inputs > MaxShapeSize || shapes[i].Outputs > MaxShapeSize || counts[i] < 0 {
+			return nil, fmt.Errorf("%w: invalid histogram bucket %d", ErrInvalidConfig, i)
+		}
+		total += counts[i]
+		d.shapes[i] = shapes[i]
+		d.cumulative[i] = total
+	}
+	if total == 0 {
+		return nil, fmt.Errorf("%w: empty histogram", ErrInvalidConfig)
+	}
+	return &d, nil
+}
+
+// BitcoinLikeShape returns a rough histogram of Bitcoin transactions, which are mostly 1-in/2-out payments with some
+// 1-in/1-out transfers, consolidations and batch payouts
+func BitcoinLikeShape() *EmpiricalShape {
+	d, _ := NewEmpiricalShape(
+		[]TxShape{{1, 2}, {1, 1}, {2, 2}, {2, 1}, {3, 2}, {5, 1}, {10, 1}, {1, 10}, {1, 50}},
+		[]int64{55, 15, 10, 6, 5, 4, 2, 2, 1})
+	return d
+}
+
+func (*EmpiricalShape) Name() string {
+	return "empirical"
+}
+
+func (d *EmpiricalShape) Draw(r *rand.Rand) TxShape {
+	x := r.Int63n(d.cumulative[len(d.cumulative)-1])
+	return d.shapes[sort.Search(len(d.cumulative), func(i int) bool { return d.cumulative[i] > x })]
+}
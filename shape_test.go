@@ -0,0 +1,102 @@
+package txhelper
+
+import (
+	"errors"
+	"math/rand"
+	"testing"
+)
+
+func TestShapeDistributions(tester *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	draws := 20000
+	distributions := []ShapeDistribution{FixedShape{Inputs: 2, Outputs: 3}, UniformShape{MaxInputs: 4, MaxOutputs: 5},
+		PoissonShape{InputMean: 2, OutputMean: 3}, ConsolidationShape{MinInputs: 5, MaxInputs: 20},
+		FanOutShape{MinOutputs: 10, MaxOutputs: 100}, BitcoinLikeShape()}
+	inputs := make([]int, len(distributions))
+	outputs := make([]int, len(distributions))
+	for i, d := range distributions {
+		for j := 0; j < draws; j++ {
+			shape := d.Draw(r)
+			if shape.Outputs == 0 || shape.Outputs > MaxShapeSize || shape.Inputs > MaxShapeSize {
+				tester.Fatal("invalid shape:", d.Name(), shape)
+			}
+			inputs[i] += int(shape.Inputs)
+			outputs[i] += int(shape.Outputs)
+		}
+	}
+	if inputs[0] != 2*draws || outputs[0] != 3*draws {
+		tester.Fatal("fixed shape changed:", inputs[0], outputs[0])
+	}
+	if inputs[1] < draws*19/10 || inputs[1] > draws*21/10 || outputs[1] < draws*29/10 || outputs[1] > draws*31/10 {
+		tester.Fatal("uniform shape has invalid means:", inputs[1], outputs[1])
+	}
+	if inputs[2] < draws*19/10 || inputs[2] > draws*21/10 || outputs[2] < draws*29/10 || outputs[2] > draws*32/10 {
+		tester.Fatal("poisson shape has invalid means:", inputs[2], outputs[2])
+	}
+	if inputs[3] < draws*5 || outputs[3] != draws {
+		tester.Fatal("consolidation doesn't merge inputs:", inputs[3], outputs[3])
+	}
+	if inputs[4] != draws || outputs[4] < draws*10 {
+		tester.Fatal("fan-out doesn't split inputs:", inputs[4], outputs[4])
+	}
+
+	// most bitcoin-like transactions are 1-in/2-out payments
+	payments := 0
+	d := BitcoinLikeShape()
+	for j := 0; j < draws; j++ {
+		if d.Draw(r) == (TxShape{Inputs: 1, Outputs: 2}) {
+			payments++
+		}
+	}
+	if payments < draws/2 || payments > draws*6/10 {
+		tester.Fatal("bitcoin-like shape doesn't follow the histogram:", payments)
+	}
+
+	if _, err := NewEmpiricalShape([]TxShape{{1, 0}}, []int64{1}); !errors.Is(err, ErrInvalidConfig) {
+		tester.Fatal("shape without outputs was accepted:", err)
+	}
+	if _, err := NewEmpiricalShape([]TxShape{{1, 2}}, []int64{0}); !errors.Is(err, ErrInvalidConfig) {
+		tester.Fatal("empty histogram was accepted:", err)
+	}
+}
+
+func TestShapeDistributionPeers(tester *testing.T) {
+	shapes := []ShapeDistribution{BitcoinLikeShape(), ConsolidationShape{MinInputs: 3, MaxInputs: 8},
+		FanOutShape{MinOutputs: 2, MaxOutputs: 6}, PoissonShape{InputMean: 2, OutputMean: 2}}
+	for i := 1; i <= 6; i++ {
+		for _, shape := range shapes {
+			ctx := newTestContext(tester, 160, 1, i, 1, 32, 60, 2, 3, 1, false, 2)
+			ctx.testPeerTransactions(10, tester, WithShapeDistribution(shape))
+		}
+	}
+}
+
+func TestConsolidationWorkload(tester *testing.T) {
+	// UTXO models can spend more inputs than they create outputs
+	for _, i := range []int{1, 3, 5} {
+		client := newTestContext(tester, 161, 1, i, 1, 32, 60, 6, 2, 1, false, 2)
+		tx, err := client.FixedTransaction(0, 20)
+		if err != nil {
+			tester.Fatal("couldn't create tx:", err)
+		}
+		if err = client.UpdateAppDataClient(&tx.Data); err != nil {
+			tester.Fatal("could not update the client:", err)
+		}
+		if tx, err = client.RandomTransaction(); err != nil {
+			tester.Fatal("couldn't create tx:", err)
+		}
+		if len(tx.Data.Outputs) > 2 {
+			tester.Fatal("too many outputs:", len(tx.Data.Outputs))
+		}
+		if err = client.UpdateAppDataClient(&tx.Data); err != nil {
+			tester.Fatal("could not update the client:", err)
+		}
+		client.shapes = ConsolidationShape{MinInputs: 10, MaxInputs: 10}
+		if tx, err = client.RandomTransaction(); err != nil {
+			tester.Fatal("couldn't create tx:", err)
+		}
+		if len(tx.Data.Inputs) != 10 || len(tx.Data.Outputs) != 1 {
+			tester.Fatal("invalid consolidation:", len(tx.Data.Inputs), len(tx.Data.Outputs))
+		}
+	}
+}
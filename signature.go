@@ -9,23 +9,12 @@ package txhelper
 import (
 	"bytes"
 	"crypto/cipher"
-	"errors"
+	"crypto/rand"
+	"fmt"
 	"go.dedis.ch/kyber/v3"
-	"go.dedis.ch/kyber/v3/group/edwards25519"
-	"go.dedis.ch/kyber/v3/pairing"
-	"go.dedis.ch/kyber/v3/pairing/bn256"
-	"go.dedis.ch/kyber/v3/sign/bdn"
-	"go.dedis.ch/kyber/v3/sign/schnorr"
-	"go.dedis.ch/kyber/v3/util/key"
-	"go.dedis.ch/kyber/v3/util/random"
-	"log"
+	"io"
 )
 
-type Suite interface {
-	kyber.Group
-	kyber.Random
-}
-
 type Signature []byte
 
 type Generator interface {
@@ -38,452 +27,250 @@ type hashablePoint interface {
 
 type Pubkey struct {
 	kyber kyber.Point
+	key   any // public key of schemes that don't use kyber points
 }
 
 type SigKeyPair struct {
-	Pk kyber.Point  `json:"p"`
-	Sk kyber.Scalar `json:"s"`
+	Pk  kyber.Point  `json:"p"`
+	Sk  kyber.Scalar `json:"s"`
+	key any          // secret key of schemes that don't use kyber scalars
 }
 
 type SignatureContext struct {
-	SigType      int32         // signature module
-	suite        key.Suite     // signature suite, e.g., curve
-	pairingSuite pairing.Suite // signature suite, e.g., curve
-	rand         kyber.Random
-	SkSize       int32
-	PkSize       int32
-	SigSize      int32
+	SigType int32           // signature module
+	scheme  SignatureScheme // registered implementation of SigType
+	rand    io.Reader       // randomness of key generation
+	SkSize  int32
+	PkSize  int32
+	SigSize int32
 }
 
-// NewSigContext assigns ctx objects
+// NewSigContext assigns ctx objects of the scheme registered for sigType
 // 1 - Schnorr
 // 2 - BLS
-func NewSigContext(sigType int32) *SignatureContext {
-	log.SetFlags(log.LstdFlags | log.Lshortfile)
-	if sigType != 1 && sigType != 2 {
-		log.Fatal("unknown sig type")
+// 3 - Ed25519
+// 4 - secp256k1 ECDSA
+// 5 - secp256k1 Schnorr (BIP-340)
+// 6 - BLS12-381 min-pk
+// 7 - BLS12-381 min-sig
+// 8 - half-aggregated Schnorr
+func NewSigContext(sigType int32) (*SignatureContext, error) {
+	scheme, err := GetSignatureScheme(sigType)
+	if err != nil {
+		return nil, err
 	}
 	ctx := SignatureContext{
 		SigType: sigType,
+		scheme:  scheme,
+		rand:    rand.Reader,
 	}
-	if ctx.SigType == 1 { // Schnorr signature
-		ctx.suite = edwards25519.NewBlakeSHA256Ed25519()
-		ctx.SkSize = 32
-		ctx.PkSize = 32
-		ctx.SigSize = 64
-
-	}
-	if ctx.SigType == 2 { // Schnorr signature
-		ctx.suite = edwards25519.NewBlakeSHA256Ed25519()
-		ctx.pairingSuite = pairing.NewSuiteBn256()
-		ctx.SkSize = 32
-		ctx.PkSize = 128
-		ctx.SigSize = 64
-	}
-	return &ctx
-}
-
-func (ctx *SignatureContext) generate(keys *SigKeyPair) {
-	var k *key.Pair
-	if ctx.SigType == 1 {
-		k = key.NewKeyPair(ctx.suite)
-		keys.Sk = k.Private
-		keys.Pk = k.Public
-	} else if ctx.SigType == 2 {
-		suite := bn256.NewSuite()
-		keys.Sk, keys.Pk = bdn.NewKeyPair(suite, random.New())
-	}
+	ctx.SkSize, ctx.PkSize, ctx.SigSize = scheme.Sizes()
+	return &ctx, nil
 }
 
-func (ctx *SignatureContext) getPubKey(keys *SigKeyPair) Pubkey {
-	var pk Pubkey
-	if ctx.SigType == 1 || ctx.SigType == 2 { // Schnorr signature
-		pk.kyber = keys.Pk
-	}
-	return pk
-}
-
-func (ctx *SignatureContext) sign(kp *SigKeyPair, msg []byte) Signature {
-	var err error
-	var sig Signature
-
-	if ctx.SigType == 1 { // Schnorr signature
-		sig, err = schnorr.Sign(ctx.suite, kp.Sk, msg)
-		if err != nil {
-			panic("could not schnorr-sign")
-		}
-	} else if ctx.SigType == 2 {
-		sig, err = signBLS(ctx.pairingSuite, kp.Sk, kp.Pk, msg)
-		if err != nil && ctx.SigType == 2 {
-			panic("could not bls-sign")
-		}
-	}
-	return sig
+// aggregates tells whether the scheme publicly aggregates signatures
+func (ctx *SignatureContext) aggregates() bool {
+	_, ok := ctx.scheme.(AggregateScheme)
+	return ok
 }
 
-func (ctx *SignatureContext) verify(pk *Pubkey, msg []byte, sig Signature) bool {
-	var err error
-
-	if len(sig) != int(ctx.SigSize) {
-		log.Print("invalid signature size")
-		return false
-	}
+// variableAggregates tells whether the size of aggregate signatures depends on the number of signatures
+func (ctx *SignatureContext) variableAggregates() bool {
+	_, ok := ctx.scheme.(VariableAggregateScheme)
+	return ok
+}
 
-	if ctx.SigType == 1 { // Schnorr and bls signatures
-		err = schnorr.Verify(ctx.suite, pk.kyber, msg, sig)
-		if err != nil {
-			return false
-		}
-	} else if ctx.SigType == 2 { // Schnorr and bls signatures
-		err = verifyBLS(ctx.pairingSuite, pk.kyber, msg, sig)
-		if err != nil {
-			return false
-		}
+// tweakable tells whether the scheme supports difference signatures
+func (ctx *SignatureContext) tweakable() bool {
+	_, ok := ctx.scheme.(TweakableScheme)
+	return ok
+}
 
+func (ctx *SignatureContext) tweaks() (TweakableScheme, error) {
+	tweakable, ok := ctx.scheme.(TweakableScheme)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s doesn't support difference signatures", ErrInvalidConfig, ctx.scheme.Name())
 	}
-	return true
-}
-
-func (ctx *SignatureContext) diffSign(kps []*SigKeyPair, negkps []*SigKeyPair, diffPK *Pubkey, msg []byte) Signature {
-	var err error
-	var sig Signature
-
-	if ctx.SigType == 1 { // Schnorr and bls signature
-		var aggregateSk kyber.Scalar
-		aggregateSk = kps[0].Sk.Clone()
-		for i := 1; i < len(kps); i++ {
-			aggregateSk = aggregateSk.Add(aggregateSk, kps[i].Sk)
-		}
-		if negkps != nil && len(negkps) >= 1 {
-			for i := 0; i < len(negkps); i++ {
-				aggregateSk = aggregateSk.Sub(aggregateSk, negkps[i].Sk)
-			}
-		}
-
-		sig, err = schnorr.Sign(ctx.suite, aggregateSk, msg)
-		if err != nil && ctx.SigType == 1 {
-			panic("could not schnorr-aggregate-sign")
-		}
-	} else if ctx.SigType == 2 { // Schnorr and bls signatures
-		var aggregateSk kyber.Scalar
-		aggregateSk = kps[0].Sk.Clone()
-		for i := 1; i < len(kps); i++ {
-			aggregateSk = aggregateSk.Add(aggregateSk, kps[i].Sk)
-		}
-		if negkps != nil && len(negkps) >= 1 {
-			for i := 0; i < len(negkps); i++ {
-				aggregateSk = aggregateSk.Sub(aggregateSk, negkps[i].Sk)
-			}
-		}
-
-		sig, err = signBLS(ctx.pairingSuite, aggregateSk, diffPK.kyber, msg)
-		if err != nil && ctx.SigType == 2 {
-			panic("could not bls-aggregate-sign")
-		}
-	} else {
-		log.Fatal("unknown sigType:", ctx.SigType)
-	}
+	return tweakable, nil
+}
 
-	return sig
-}
-
-func (ctx *SignatureContext) getDiffPubKeyFromKeyPairs(keys []*SigKeyPair, negKeys []*SigKeyPair, pk *Pubkey) {
-	var aggregatePk kyber.Point
-	if ctx.SigType == 1 {
-		aggregatePk = keys[0].Pk.Clone()
-		for i := 1; i < len(keys); i++ {
-			aggregatePk.Add(aggregatePk, keys[i].Pk)
-		}
-		if negKeys != nil && len(negKeys) >= 1 {
-			for i := 0; i < len(negKeys); i++ {
-				aggregatePk.Sub(aggregatePk, negKeys[i].Pk)
-			}
-		}
-	} else if ctx.SigType == 2 {
-		aggregatePk = keys[0].Pk.Clone()
-		for i := 1; i < len(keys); i++ {
-			aggregatePk.Add(aggregatePk, keys[i].Pk)
-		}
-		if negKeys != nil && len(negKeys) >= 1 {
-			for i := 0; i < len(negKeys); i++ {
-				aggregatePk.Sub(aggregatePk, negKeys[i].Pk)
-			}
-		}
-	}
-	pk.kyber = aggregatePk.Clone()
-}
-
-func (ctx *SignatureContext) getDiffPubKey(keys []*Pubkey, negKeys []*Pubkey, pk *Pubkey) {
-	var aggregatePk kyber.Point
-	if ctx.SigType == 1 {
-		aggregatePk = keys[0].kyber.Clone()
-		for i := 1; i < len(keys); i++ {
-			aggregatePk.Add(aggregatePk, keys[i].kyber)
-		}
-		if negKeys != nil && len(negKeys) >= 1 {
-			for i := 0; i < len(negKeys); i++ {
-				aggregatePk.Sub(aggregatePk, negKeys[i].kyber)
-			}
-		}
-	} else if ctx.SigType == 2 {
-		aggregatePk = keys[0].kyber.Clone()
-		for i := 1; i < len(keys); i++ {
-			aggregatePk.Add(aggregatePk, keys[i].kyber)
-		}
-		if negKeys != nil && len(negKeys) >= 1 {
-			for i := 0; i < len(negKeys); i++ {
-				aggregatePk.Sub(aggregatePk, negKeys[i].kyber)
-			}
-		}
+func (ctx *SignatureContext) generate(keys *SigKeyPair) error {
+	if err := ctx.scheme.Generate(ctx.rand, keys); err != nil {
+		return fmt.Errorf("%w: %w", ErrSigning, err)
 	}
-	pk.kyber = aggregatePk.Clone()
+	return nil
 }
 
-func (ctx *SignatureContext) diffPK(kps []*Pubkey, negKeys []*Pubkey) []byte {
-	var pk Pubkey
-	aggregatePkBytes := new(bytes.Buffer)
-	ctx.getDiffPubKey(kps, negKeys, &pk)
-	size, _ := pk.kyber.MarshalTo(aggregatePkBytes)
-	if size != int(ctx.PkSize) {
-		log.Fatal("different pk sizes")
-	}
-	return aggregatePkBytes.Bytes()
+func (ctx *SignatureContext) getPubKey(keys *SigKeyPair) Pubkey {
+	return ctx.scheme.PublicKey(keys)
 }
 
-func (ctx *SignatureContext) diffPKFromPairs(kps []*SigKeyPair, negKeys []*SigKeyPair) []byte {
-	var pk Pubkey
-	aggregatePkBytes := new(bytes.Buffer)
-	ctx.getDiffPubKeyFromKeyPairs(kps, negKeys, &pk)
-	size, _ := pk.kyber.MarshalTo(aggregatePkBytes)
-	if size != int(ctx.PkSize) {
-		log.Fatal("different pk sizes")
-	}
-	return aggregatePkBytes.Bytes()
-}
-
-// aggregateSignatures This is modified to remove copying signature bytes (AggregateSignatures from dedis/kyber)
-func (ctx *SignatureContext) aggregateSignatures(sigs []Signature) Signature {
-	sig := ctx.pairingSuite.G1().Point()
-	for i := 0; i < len(sigs); i++ {
-		sigToAdd := ctx.pairingSuite.G1().Point()
-		if err := sigToAdd.UnmarshalBinary(sigs[i]); err != nil {
-			return nil
-		}
-		sig.Add(sig, sigToAdd)
+func (ctx *SignatureContext) sign(kp *SigKeyPair, msg []byte) (Signature, error) {
+	sig, err := ctx.scheme.Sign(kp, msg)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrSigning, err)
 	}
-	sigByte, _ := sig.MarshalBinary()
-	return sigByte
+	return sig, nil
 }
 
-// batchVerify This is modified to remove copying public key bytes (BatchVerify from dedis/kyber)
-func (ctx *SignatureContext) batchVerify(publics []Pubkey, msg []byte, sig []byte) bool {
-	s := ctx.pairingSuite.G1().Point()
-	if err := s.UnmarshalBinary(sig); err != nil {
+func (ctx *SignatureContext) verify(pk *Pubkey, msg []byte, sig Signature) bool {
+	if len(sig) != int(ctx.SigSize) {
 		return false
 	}
+	return ctx.scheme.Verify(pk, msg, sig)
+}
 
-	var aggregatedLeft kyber.Point
-	for i := range publics {
-		hashable, ok := ctx.pairingSuite.G1().Point().(hashablePoint)
-		if !ok {
-			return false
-		}
-		pkBytes, _ := publics[i].kyber.MarshalBinary()
-		HM := hashable.Hash(append(msg, pkBytes...))
-		pair := ctx.pairingSuite.Pair(HM, publics[i].kyber)
-
-		if i == 0 {
-			aggregatedLeft = pair
-		} else {
-			aggregatedLeft.Add(aggregatedLeft, pair)
-		}
+// diffSign signs msg with sum(kps) - sum(negkps)
+func (ctx *SignatureContext) diffSign(kps []*SigKeyPair, negkps []*SigKeyPair, msg []byte) (Signature, error) {
+	tweakable, err := ctx.tweaks()
+	if err != nil {
+		return nil, err
 	}
+	aggregateKeys := tweakable.AddKeyPairs(kps, negkps)
+	return ctx.sign(&aggregateKeys, msg)
+}
 
-	right := ctx.pairingSuite.Pair(s, ctx.pairingSuite.G2().Point().Base())
-	if !aggregatedLeft.Equal(right) {
-		return false
+func (ctx *SignatureContext) getDiffPubKeyFromKeyPairs(keys []*SigKeyPair, negKeys []*SigKeyPair, pk *Pubkey) error {
+	tweakable, err := ctx.tweaks()
+	if err != nil {
+		return err
 	}
-	return true
+	aggregateKeys := tweakable.AddKeyPairs(keys, negKeys)
+	*pk = ctx.getPubKey(&aggregateKeys)
+	return nil
 }
 
-// batchVerify This is modified to remove copying public key bytes (BatchVerify from dedis/kyber)
-func (ctx *SignatureContext) batchVerifyMultipleMsg(publics []Pubkey, msgs [][]byte, sig []byte) bool {
-	s := ctx.pairingSuite.G1().Point()
-	if err := s.UnmarshalBinary(sig); err != nil {
-		return false
+func (ctx *SignatureContext) getDiffPubKey(keys []*Pubkey, negKeys []*Pubkey, pk *Pubkey) error {
+	tweakable, err := ctx.tweaks()
+	if err != nil {
+		return err
 	}
+	*pk = tweakable.AddPublicKeys(keys, negKeys)
+	return nil
+}
 
-	var aggregatedLeft kyber.Point
-	for i := range publics {
-		hashable, ok := ctx.pairingSuite.G1().Point().(hashablePoint)
-		if !ok {
-			return false
-		}
-		pkBytes, _ := publics[i].kyber.MarshalBinary()
-		HM := hashable.Hash(append(msgs[i], pkBytes...))
-		pair := ctx.pairingSuite.Pair(HM, publics[i].kyber)
-
-		if i == 0 {
-			aggregatedLeft = pair
-		} else {
-			aggregatedLeft.Add(aggregatedLeft, pair)
-		}
+func (ctx *SignatureContext) diffPK(kps []*Pubkey, negKeys []*Pubkey) ([]byte, error) {
+	var pk Pubkey
+	if err := ctx.getDiffPubKey(kps, negKeys, &pk); err != nil {
+		return nil, err
 	}
+	return ctx.marshelPublicKey(&pk)
+}
 
-	right := ctx.pairingSuite.Pair(s, ctx.pairingSuite.G2().Point().Base())
-	if !aggregatedLeft.Equal(right) {
-		return false
+func (ctx *SignatureContext) diffPKFromPairs(kps []*SigKeyPair, negKeys []*SigKeyPair) ([]byte, error) {
+	var pk Pubkey
+	if err := ctx.getDiffPubKeyFromKeyPairs(kps, negKeys, &pk); err != nil {
+		return nil, err
 	}
-	return true
+	return ctx.marshelPublicKey(&pk)
 }
 
-func (ctx *SignatureContext) marshelKeys(kp *SigKeyPair, buf *bytes.Buffer) {
-	if ctx.SigType == 1 || ctx.SigType == 2 {
-		_, _ = kp.Pk.MarshalTo(buf)
-		_, _ = kp.Sk.MarshalTo(buf)
+// aggregateSignatures aggregates signatures of the same message
+func (ctx *SignatureContext) aggregateSignatures(publics []Pubkey, msg []byte, sigs []Signature) (Signature, error) {
+	msgs := make([][]byte, len(publics))
+	for i := range msgs {
+		msgs[i] = msg
 	}
+	return ctx.aggregateSignaturesMultipleMsg(publics, msgs, sigs)
 }
 
-func (ctx *SignatureContext) unmarshelKeys(kp *SigKeyPair, buf []byte) {
-	if ctx.SigType == 1 {
-		kp.Pk = ctx.suite.Point().Base()
-		kp.Sk = ctx.suite.Scalar()
-		_ = kp.Pk.UnmarshalBinary(buf[:ctx.PkSize])
-		_ = kp.Sk.UnmarshalBinary(buf[ctx.PkSize:])
+// aggregateSignaturesMultipleMsg aggregates signatures, where sigs[i] is a signature of msgs[i] by publics[i]
+func (ctx *SignatureContext) aggregateSignaturesMultipleMsg(publics []Pubkey, msgs [][]byte, sigs []Signature) (Signature, error) {
+	aggregator, ok := ctx.scheme.(AggregateScheme)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s doesn't aggregate signatures", ErrInvalidConfig, ctx.scheme.Name())
 	}
-	if ctx.SigType == 2 {
-		kp.Pk = ctx.pairingSuite.G2().Point().Base()
-		kp.Sk = ctx.pairingSuite.G2().Scalar()
-		_ = kp.Pk.UnmarshalBinary(buf[:ctx.PkSize])
-		_ = kp.Sk.UnmarshalBinary(buf[ctx.PkSize:])
+	if len(publics) != len(sigs) || len(msgs) != len(sigs) {
+		return nil, fmt.Errorf("%w: different number of keys and signatures", ErrMalformedEncoding)
 	}
+	return aggregator.AggregateSignatures(publics, msgs, sigs)
 }
 
-func (ctx *SignatureContext) marshelPublicKey(kp *SigKeyPair, buf *bytes.Buffer) {
-	if buf.Len() != int(ctx.PkSize) {
-		log.Fatal("invalid pk size")
-	}
-	if ctx.SigType == 1 || ctx.SigType == 2 {
-		_, _ = kp.Pk.MarshalTo(buf)
+// combineAggregates combines aggregate signatures of different signers and messages
+func (ctx *SignatureContext) combineAggregates(sigs []Signature) (Signature, error) {
+	aggregator, ok := ctx.scheme.(AggregateScheme)
+	if !ok || ctx.variableAggregates() {
+		return nil, fmt.Errorf("%w: %s can't combine aggregate signatures", ErrInvalidConfig, ctx.scheme.Name())
 	}
+	return aggregator.AggregateSignatures(nil, nil, sigs)
 }
 
-func (ctx *SignatureContext) unmarshelPublicKeys(pk *Pubkey, buf *bytes.Buffer) {
-	if ctx.SigType == 1 {
-		pk.kyber = ctx.suite.Point().Base()
-		_, _ = pk.kyber.UnmarshalFrom(buf)
-	}
-	if ctx.SigType == 2 {
-		pk.kyber = ctx.pairingSuite.G2().Point().Base()
-		_, _ = pk.kyber.UnmarshalFrom(buf)
+// batchVerify verifies an aggregate signature of the same message
+func (ctx *SignatureContext) batchVerify(publics []Pubkey, msg []byte, sig []byte) bool {
+	msgs := make([][]byte, len(publics))
+	for i := range msgs {
+		msgs[i] = msg
 	}
+	return ctx.batchVerifyMultipleMsg(publics, msgs, sig)
 }
-func (ctx *SignatureContext) unmarshelPublicKeysFromBytes(pk *Pubkey, pkBytes []byte) {
-	if ctx.SigType == 1 {
-		pk.kyber = ctx.suite.Point().Base()
-		_ = pk.kyber.UnmarshalBinary(pkBytes)
-	}
-	if ctx.SigType == 2 {
-		pk.kyber = ctx.pairingSuite.G2().Point().Base()
-		_ = pk.kyber.UnmarshalBinary(pkBytes)
+
+// batchVerifyMultipleMsg verifies an aggregate signature, where msgs[i] was signed by publics[i]
+func (ctx *SignatureContext) batchVerifyMultipleMsg(publics []Pubkey, msgs [][]byte, sig []byte) bool {
+	aggregator, ok := ctx.scheme.(AggregateScheme)
+	if !ok || len(publics) != len(msgs) {
+		return false
 	}
+	return aggregator.VerifyAggregate(publics, msgs, sig)
 }
 
-func (ctx *SignatureContext) selfMultiplyPubKey(pk *Pubkey, a []byte) {
+func (ctx *SignatureContext) marshelKeys(kp *SigKeyPair, buf *bytes.Buffer) {
+	ctx.scheme.MarshalKeys(kp, buf)
+}
 
-	if ctx.SigType == 1 {
-		s := ctx.suite.Scalar().SetBytes(a)
-		pk.kyber = ctx.suite.Point().Mul(s, pk.kyber)
+func (ctx *SignatureContext) unmarshelKeys(kp *SigKeyPair, buf []byte) error {
+	if len(buf) < int(ctx.PkSize+ctx.SkSize) {
+		return fmt.Errorf("%w: invalid key size", ErrMalformedEncoding)
 	}
-	if ctx.SigType == 2 {
-		s := ctx.pairingSuite.G2().Scalar().SetBytes(a)
-		pk.kyber = ctx.pairingSuite.G2().Point().Mul(s, pk.kyber)
+	if err := ctx.scheme.UnmarshalKeys(kp, buf); err != nil {
+		return fmt.Errorf("%w: invalid keys: %w", ErrMalformedEncoding, err)
 	}
-
+	return nil
 }
 
-func (ctx *SignatureContext) selfMultiplyKeyPairs(kp *SigKeyPair, a []byte) {
-
-	if ctx.SigType == 1 {
-		s := ctx.suite.Scalar().SetBytes(a)
-		kp.Sk = ctx.suite.Scalar().Mul(s, kp.Sk)
-		kp.Pk = ctx.suite.Point().Base().Mul(kp.Sk, nil)
-	}
-	if ctx.SigType == 2 {
-		s := ctx.pairingSuite.G2().Scalar().SetBytes(a)
-		kp.Sk = ctx.pairingSuite.G2().Scalar().Mul(s, kp.Sk)
-		kp.Pk = ctx.pairingSuite.G2().Point().Base().Mul(kp.Sk, nil)
+func (ctx *SignatureContext) marshelPublicKey(pk *Pubkey) ([]byte, error) {
+	pkBytes, err := ctx.scheme.MarshalPublicKey(pk)
+	if err != nil || len(pkBytes) != int(ctx.PkSize) {
+		return nil, fmt.Errorf("%w: different pk sizes", ErrMalformedEncoding)
 	}
+	return pkBytes, nil
 }
 
-func (ctx *SignatureContext) selfMultiplyPubKeydiff(pk *Pubkey, a []byte, b []byte) {
+func (ctx *SignatureContext) unmarshelPublicKeys(pk *Pubkey, buf *bytes.Buffer) error {
+	return ctx.unmarshelPublicKeysFromBytes(pk, buf.Next(int(ctx.PkSize)))
+}
 
-	if ctx.SigType == 1 {
-		s := ctx.suite.Scalar().SetBytes(a)
-		s2 := ctx.suite.Scalar().SetBytes(b)
-		s = s.Sub(s, s2)
-		pk.kyber = ctx.suite.Point().Mul(s, pk.kyber)
+func (ctx *SignatureContext) unmarshelPublicKeysFromBytes(pk *Pubkey, pkBytes []byte) error {
+	if len(pkBytes) != int(ctx.PkSize) {
+		return fmt.Errorf("%w: invalid public key size", ErrMalformedEncoding)
 	}
-	if ctx.SigType == 2 {
-		s := ctx.pairingSuite.G2().Scalar().SetBytes(a)
-		s2 := ctx.pairingSuite.G2().Scalar().SetBytes(b)
-		s = s.Sub(s, s2)
-		pk.kyber = ctx.pairingSuite.G2().Point().Mul(s, pk.kyber)
+	if err := ctx.scheme.UnmarshalPublicKey(pk, pkBytes); err != nil {
+		return fmt.Errorf("%w: invalid public key: %w", ErrMalformedEncoding, err)
 	}
+	return nil
 }
 
-func (ctx *SignatureContext) selfMultiplyKeyPairsdiff(kp *SigKeyPair, a []byte, b []byte) {
-
-	if ctx.SigType == 1 {
-		s := ctx.suite.Scalar().SetBytes(a)
-		s2 := ctx.suite.Scalar().SetBytes(b)
-		s = s.Sub(s, s2)
-		kp.Sk = ctx.suite.Scalar().Mul(s, kp.Sk)
-		kp.Pk = ctx.suite.Point().Base().Mul(kp.Sk, nil)
-	}
-	if ctx.SigType == 2 {
-		s := ctx.pairingSuite.G2().Scalar().SetBytes(a)
-		s2 := ctx.pairingSuite.G2().Scalar().SetBytes(b)
-		s = s.Sub(s, s2)
-		kp.Sk = ctx.pairingSuite.G2().Scalar().Mul(s, kp.Sk)
-		kp.Pk = ctx.pairingSuite.G2().Point().Base().Mul(kp.Sk, nil)
-	}
+func (ctx *SignatureContext) selfMultiplyPubKey(pk *Pubkey, a []byte) error {
+	return ctx.selfMultiplyPubKeydiff(pk, a, nil)
 }
 
-// BLSSign is an updated version of original dedis/kyber bls signing for pk-based message signing to avoid searching for duplicate msgs
-// because we already make sure that public keys are unique
-func signBLS(suite pairing.Suite, sk kyber.Scalar, pk kyber.Point, msg []byte) ([]byte, error) {
-	hashable, ok := suite.G1().Point().(hashablePoint)
-	if !ok {
-		return nil, errors.New("point needs to implement hashablePoint")
-	}
-	pkBytes, _ := pk.MarshalBinary()
-	HM := hashable.Hash(append(msg, pkBytes...))
-	xHM := HM.Mul(sk, HM)
+func (ctx *SignatureContext) selfMultiplyKeyPairs(kp *SigKeyPair, a []byte) error {
+	return ctx.selfMultiplyKeyPairsdiff(kp, a, nil)
+}
 
-	s, err := xHM.MarshalBinary()
+func (ctx *SignatureContext) selfMultiplyPubKeydiff(pk *Pubkey, a []byte, b []byte) error {
+	tweakable, err := ctx.tweaks()
 	if err != nil {
-		return nil, err
+		return err
 	}
-	return s, nil
+	tweakable.MulPublicKey(pk, a, b)
+	return nil
 }
 
-func verifyBLS(suite pairing.Suite, pk kyber.Point, msg, sig []byte) error {
-	hashable, ok := suite.G1().Point().(hashablePoint)
-	if !ok {
-		return errors.New("bls: point needs to implement hashablePoint")
-	}
-	var HM kyber.Point
-	pkBytes, _ := pk.MarshalBinary()
-	HM = hashable.Hash(append(msg, pkBytes...))
-	left := suite.Pair(HM, pk)
-	s := suite.G1().Point()
-	if err := s.UnmarshalBinary(sig); err != nil {
+func (ctx *SignatureContext) selfMultiplyKeyPairsdiff(kp *SigKeyPair, a []byte, b []byte) error {
+	tweakable, err := ctx.tweaks()
+	if err != nil {
 		return err
 	}
-	right := suite.Pair(s, suite.G2().Point().Base())
-	if !left.Equal(right) {
-		return errors.New("bls: invalid signature")
-	}
+	tweakable.MulKeyPair(kp, a, b)
 	return nil
 }
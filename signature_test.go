@@ -3,14 +3,22 @@ package txhelper
 import (
 	"bytes"
 	"crypto/rand"
+	"crypto/sha512"
 	"fmt"
 	"testing"
 	"time"
+
+	"go.dedis.ch/kyber/v3/group/edwards25519"
+	"go.dedis.ch/kyber/v3/sign/schnorr"
+	"go.dedis.ch/kyber/v3/xof/blake2xb"
 )
 
 func TestSig(tester *testing.T) {
 	for i := int32(1); i < 3; i++ {
-		sigCtx := NewSigContext(i)
+		sigCtx, err := NewSigContext(i)
+		if err != nil {
+			tester.Fatal(err)
+		}
 		for j := 0; j < 10; j++ {
 			msg := make([]byte, 32)
 			rand.Read(msg)
@@ -20,7 +28,10 @@ func TestSig(tester *testing.T) {
 			var kp2 bytes.Buffer
 			sigCtx.generate(&keys)
 			sigCtx.generate(&keys1)
-			sig := sigCtx.sign(&keys, msg)
+			sig, err := sigCtx.sign(&keys, msg)
+			if err != nil {
+				tester.Fatal(err)
+			}
 			pk := sigCtx.getPubKey(&keys)
 			if len(sig) != int(sigCtx.SigSize) {
 				tester.Errorf("invalid sig size: mentioned %q, wanted %q", keys.Sk.MarshalSize(), sigCtx.SigSize)
@@ -45,9 +56,48 @@ func TestSig(tester *testing.T) {
 	}
 }
 
+func TestSchnorrNonce(tester *testing.T) {
+	sigCtx, err := NewSigContext(1)
+	if err != nil {
+		tester.Fatal(err)
+	}
+	var keys SigKeyPair
+	sigCtx.generate(&keys)
+	skBytes, _ := keys.Sk.MarshalBinary()
+	msg := []byte("message")
+	sig, err := sigCtx.sign(&keys, msg)
+	if err != nil {
+		tester.Fatal(err)
+	}
+	again, _ := sigCtx.sign(&keys, msg)
+	if !bytes.Equal(sig, again) {
+		tester.Fatal("signatures of the same message are different")
+	}
+	other, _ := sigCtx.sign(&keys, []byte("other message"))
+	if bytes.Equal(sig[:32], other[:32]) {
+		tester.Fatal("messages have the same nonce")
+	}
+	if after, _ := keys.Sk.MarshalBinary(); !bytes.Equal(skBytes, after) {
+		tester.Fatal("signing changed the secret key")
+	}
+
+	// the nonce isn't the hash of the secret key and the message alone
+	seed := sha512.Sum512(append(append([]byte{}, skBytes...), msg...))
+	untagged, err := schnorr.Sign(edwards25519.NewBlakeSHA256Ed25519WithRand(blake2xb.New(seed[:])), keys.Sk, msg)
+	if err != nil {
+		tester.Fatal(err)
+	}
+	if bytes.Equal(sig[:32], untagged[:32]) {
+		tester.Fatal("nonce is not domain separated")
+	}
+}
+
 func TestDiffSig(tester *testing.T) {
 	for i := int32(1); i < 3; i++ {
-		sigCtx := NewSigContext(i)
+		sigCtx, err := NewSigContext(i)
+		if err != nil {
+			tester.Fatal(err)
+		}
 		for j := 0; j < 10; j++ {
 			msg := make([]byte, 32)
 			rand.Read(msg) // some msg, doesn't have to be purely random
@@ -79,28 +129,51 @@ func TestDiffSig(tester *testing.T) {
 				pks1[i] = &pks[i]
 				negpks1[i] = &negpks[i]
 			}
-			pk1Bytes := sigCtx.diffPK(pks1, negpks1)
-			sigCtx.unmarshelPublicKeysFromBytes(&pk2, pk1Bytes)
+			pk1Bytes, err := sigCtx.diffPK(pks1, negpks1)
+			if err != nil {
+				tester.Fatal(err)
+			}
+			if err := sigCtx.unmarshelPublicKeysFromBytes(&pk2, pk1Bytes); err != nil {
+				tester.Fatal(err)
+			}
 
-			sig := sigCtx.diffSign(keys1, negkeys1, &pk2, msg)
+			sig, err := sigCtx.diffSign(keys1, negkeys1, msg)
+			if err != nil {
+				tester.Fatal(err)
+			}
 			if !sigCtx.verify(&pk2, msg, sig) {
 				tester.Errorf("invalid diff signature")
 			}
 
-			pk1Bytes = sigCtx.diffPK(pks1, negpks1)
-			sigCtx.unmarshelPublicKeysFromBytes(&pk2, pk1Bytes)
+			pk1Bytes, err = sigCtx.diffPK(pks1, negpks1)
+			if err != nil {
+				tester.Fatal(err)
+			}
+			if err := sigCtx.unmarshelPublicKeysFromBytes(&pk2, pk1Bytes); err != nil {
+				tester.Fatal(err)
+			}
 			if !sigCtx.verify(&pk2, msg, sig) {
 				tester.Errorf("invalid difference pks1")
 			}
 
-			pk2Bytes := sigCtx.diffPKFromPairs(keys1, negkeys1)
-			sigCtx.unmarshelPublicKeysFromBytes(&pk3, pk2Bytes)
+			pk2Bytes, err := sigCtx.diffPKFromPairs(keys1, negkeys1)
+			if err != nil {
+				tester.Fatal(err)
+			}
+			if err := sigCtx.unmarshelPublicKeysFromBytes(&pk3, pk2Bytes); err != nil {
+				tester.Fatal(err)
+			}
 			if !sigCtx.verify(&pk3, msg, sig) {
 				tester.Errorf("invalid difference keys1")
 			}
 
-			pk2Bytes = sigCtx.diffPKFromPairs(keys2, negkeys2)
-			sigCtx.unmarshelPublicKeysFromBytes(&pk3, pk2Bytes)
+			pk2Bytes, err = sigCtx.diffPKFromPairs(keys2, negkeys2)
+			if err != nil {
+				tester.Fatal(err)
+			}
+			if err := sigCtx.unmarshelPublicKeysFromBytes(&pk3, pk2Bytes); err != nil {
+				tester.Fatal(err)
+			}
 			if !sigCtx.verify(&pk3, msg, sig) {
 				tester.Errorf("invalid difference keys2")
 			}
@@ -109,7 +182,10 @@ func TestDiffSig(tester *testing.T) {
 }
 
 func TestAggregateBLSSig(tester *testing.T) {
-	sigCtx := NewSigContext(2)
+	sigCtx, err := NewSigContext(2)
+	if err != nil {
+		tester.Fatal(err)
+	}
 	for j := 0; j < 10; j++ {
 		msg := make([]byte, 32)
 		rand.Read(msg) // some msg, doesn't have to be purely random
@@ -121,59 +197,84 @@ func TestAggregateBLSSig(tester *testing.T) {
 		for i := 0; i < num; i++ {
 			sigCtx.generate(&keys[i])
 			pks[i] = sigCtx.getPubKey(&keys[i])
-			sigs[i] = sigCtx.sign(&keys[i], msg)
+			sigs[i], err = sigCtx.sign(&keys[i], msg)
+			if err != nil {
+				tester.Fatal(err)
+			}
 			if !sigCtx.verify(&pks[i], msg, sigs[i]) {
 				tester.Fatal("invalid individual signature")
 			}
 		}
 
-		aggregateSig := sigCtx.aggregateSignatures(sigs)
+		aggregateSig, err := sigCtx.aggregateSignatures(pks, msg, sigs)
+		if err != nil {
+			tester.Fatal(err)
+		}
 		if !sigCtx.batchVerify(pks, msg, aggregateSig) {
 			tester.Fatal("invalid aggregate BLS signature")
 		}
+		scheme := sigCtx.scheme.(AggregateScheme)
+		if scheme.VerifyAggregate(nil, nil, aggregateSig) || scheme.VerifyAggregate(pks, [][]byte{msg}, aggregateSig) {
+			tester.Fatal("aggregate signature of other signers was accepted")
+		}
 	}
 }
 
 var result bool
 
-func BenchmarkSignatureContext_VerifySchnor(b *testing.B) {
-	sigCtx := NewSigContext(1)
+func BenchmarkSignatureContext_VerifySchnor(tester *testing.B) {
+	sigCtx, err := NewSigContext(1)
+	if err != nil {
+		tester.Fatal(err)
+	}
 	msg := make([]byte, 32)
 	rand.Read(msg)
 	var keys SigKeyPair
 	sigCtx.generate(&keys)
-	sig := sigCtx.sign(&keys, msg)
+	sig, err := sigCtx.sign(&keys, msg)
+	if err != nil {
+		tester.Fatal(err)
+	}
 	pk := sigCtx.getPubKey(&keys)
 	var s bool
 	start := time.Now()
-	for i := 0; i < b.N; i++ {
+	for i := 0; i < tester.N; i++ {
 		s = sigCtx.verify(&pk, msg, sig)
 	}
 	end := time.Since(start)
-	fmt.Println("Schnorr: ", b.N, (end / time.Duration(b.N)).Microseconds())
+	fmt.Println("Schnorr: ", tester.N, (end / time.Duration(tester.N)).Microseconds())
 	result = s
 }
 
-func BenchmarkSignatureContext_VerifyBLS(b *testing.B) {
-	sigCtx := NewSigContext(2)
+func BenchmarkSignatureContext_VerifyBLS(tester *testing.B) {
+	sigCtx, err := NewSigContext(2)
+	if err != nil {
+		tester.Fatal(err)
+	}
 	msg := make([]byte, 32)
 	rand.Read(msg)
 	var keys SigKeyPair
 	sigCtx.generate(&keys)
-	sig := sigCtx.sign(&keys, msg)
+	sig, err := sigCtx.sign(&keys, msg)
+	if err != nil {
+		tester.Fatal(err)
+	}
 	pk := sigCtx.getPubKey(&keys)
 	var s bool
 	start := time.Now()
-	for i := 0; i < b.N; i++ {
+	for i := 0; i < tester.N; i++ {
 		s = sigCtx.verify(&pk, msg, sig)
 	}
 	end := time.Since(start)
-	fmt.Println("BLS1: ", b.N, (end / time.Duration(b.N)).Microseconds())
+	fmt.Println("BLS1: ", tester.N, (end / time.Duration(tester.N)).Microseconds())
 	result = s
 }
 
 func BenchmarkSignatureContext_BatchVerify5(tester *testing.B) {
-	sigCtx := NewSigContext(2)
+	sigCtx, err := NewSigContext(2)
+	if err != nil {
+		tester.Fatal(err)
+	}
 	num := 5
 	msg := make([]byte, 32)
 	rand.Read(msg) // some msg, doesn't have to be purely random
@@ -184,13 +285,19 @@ func BenchmarkSignatureContext_BatchVerify5(tester *testing.B) {
 	for i := 0; i < num; i++ {
 		sigCtx.generate(&keys[i])
 		pks[i] = sigCtx.getPubKey(&keys[i])
-		sigs[i] = sigCtx.sign(&keys[i], msg)
+		sigs[i], err = sigCtx.sign(&keys[i], msg)
+		if err != nil {
+			tester.Fatal(err)
+		}
 		if !sigCtx.verify(&pks[i], msg, sigs[i]) {
 			tester.Fatal("invalid individual signature")
 		}
 	}
 
-	aggregateSig := sigCtx.aggregateSignatures(sigs)
+	aggregateSig, err := sigCtx.aggregateSignatures(pks, msg, sigs)
+	if err != nil {
+		tester.Fatal(err)
+	}
 	var s bool
 	start := time.Now()
 	for i := 0; i < tester.N; i++ {
@@ -205,7 +312,10 @@ func BenchmarkSignatureContext_BatchVerify5(tester *testing.B) {
 }
 
 /* func BenchmarkSignatureContext_BatchVerify50(tester *testing.B) {
-	sigCtx := NewSigContext(2)
+	sigCtx, err := NewSigContext(2)
+	if err != nil {
+		tester.Fatal(err)
+	}
 	num := 50
 	msg := make([]byte, 32)
 	rand.Read(msg) // some msg, doesn't have to be purely random
@@ -216,13 +326,19 @@ func BenchmarkSignatureContext_BatchVerify5(tester *testing.B) {
 	for i := 0; i < num; i++ {
 		sigCtx.generate(&keys[i])
 		pks[i] = sigCtx.getPubKey(&keys[i])
-		sigs[i] = sigCtx.sign(&keys[i], msg)
+		sigs[i], err = sigCtx.sign(&keys[i], msg)
+		if err != nil {
+			tester.Fatal(err)
+		}
 		if !sigCtx.verify(&pks[i], msg, sigs[i]) {
 			tester.Fatal("invalid individual signature")
 		}
 	}
 
-	aggregateSig := sigCtx.aggregateSignatures(sigs)
+	aggregateSig, err := sigCtx.aggregateSignatures(pks, msg, sigs)
+	if err != nil {
+		tester.Fatal(err)
+	}
 	var s bool
 	for i := 0; i < tester.N; i++ {
 		s = sigCtx.batchVerify(pks, msg, aggregateSig)
@@ -234,7 +350,10 @@ func BenchmarkSignatureContext_BatchVerify5(tester *testing.B) {
 }
 
 func BenchmarkSignatureContext_BatchVerify500(tester *testing.B) {
-	sigCtx := NewSigContext(2)
+	sigCtx, err := NewSigContext(2)
+	if err != nil {
+		tester.Fatal(err)
+	}
 	num := 500
 	msg := make([]byte, 32)
 	rand.Read(msg) // some msg, doesn't have to be purely random
@@ -245,13 +364,19 @@ func BenchmarkSignatureContext_BatchVerify500(tester *testing.B) {
 	for i := 0; i < num; i++ {
 		sigCtx.generate(&keys[i])
 		pks[i] = sigCtx.getPubKey(&keys[i])
-		sigs[i] = sigCtx.sign(&keys[i], msg)
+		sigs[i], err = sigCtx.sign(&keys[i], msg)
+		if err != nil {
+			tester.Fatal(err)
+		}
 		if !sigCtx.verify(&pks[i], msg, sigs[i]) {
 			tester.Fatal("invalid individual signature")
 		}
 	}
 
-	aggregateSig := sigCtx.aggregateSignatures(sigs)
+	aggregateSig, err := sigCtx.aggregateSignatures(pks, msg, sigs)
+	if err != nil {
+		tester.Fatal(err)
+	}
 	var s bool
 	for i := 0; i < tester.N; i++ {
 		s = sigCtx.batchVerify(pks, msg, aggregateSig)
@@ -0,0 +1,117 @@
+/**********************************************************************
+ * Copyright (c) 2017 Jayamine Alupotha                               *
+ * Distributed under the MIT software license, see the accompanying   *
+ * file COPYING or http://www.opensource.org/licenses/mit-license.php.*
+ **********************************************************************/
+
+package txhelper
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// SignatureScheme defines how keys are generated and encoded, and how messages are signed and verified.
+// NewSigContext selects the scheme registered for its sigType. The built-in schemes are
+// 1 - KyberSchnorr, 2 - KyberBLS, 3 - Ed25519, 4 - SecpECDSA, 5 - SecpSchnorr,
+// 6 - BLS12381MinPk, 7 - BLS12381MinSig, 8 - SchnorrHalfAgg.
+// Schemes can also implement AggregateScheme and TweakableScheme.
+type SignatureScheme interface {
+	// Name returns a short name of the scheme
+	Name() string
+	// Sizes returns the encoded sizes of a secret key, a public key and a signature
+	Sizes() (skSize int32, pkSize int32, sigSize int32)
+
+	// Generate creates a new key pair from the randomness of rand
+	Generate(rand io.Reader, keys *SigKeyPair) error
+	// PublicKey returns the public key of a key pair
+	PublicKey(keys *SigKeyPair) Pubkey
+	// Sign signs msg
+	Sign(keys *SigKeyPair, msg []byte) (Signature, error)
+	// Verify verifies a signature of msg
+	Verify(pk *Pubkey, msg []byte, sig Signature) bool
+
+	// MarshalKeys writes pk || sk to buf
+	MarshalKeys(keys *SigKeyPair, buf *bytes.Buffer)
+	// UnmarshalKeys parses pk || sk created by MarshalKeys
+	UnmarshalKeys(keys *SigKeyPair, buf []byte) error
+	// MarshalPublicKey outputs the public key as PkSize bytes
+	MarshalPublicKey(pk *Pubkey) ([]byte, error)
+	// UnmarshalPublicKey parses a public key created by MarshalPublicKey
+	UnmarshalPublicKey(pk *Pubkey, buf []byte) error
+}
+
+// AggregateScheme is implemented by schemes that can publicly aggregate signatures of different signers.
+// Classic headers carry only one aggregate signature with these schemes.
+type AggregateScheme interface {
+	// AggregateSignatures aggregates sigs, where sigs[i] is a signature of msgs[i] by pks[i]
+	AggregateSignatures(pks []Pubkey, msgs [][]byte, sigs []Signature) (Signature, error)
+	// VerifyAggregate verifies an aggregate signature of msgs[i] by pks[i]
+	VerifyAggregate(pks []Pubkey, msgs [][]byte, sig Signature) bool
+}
+
+// VariableAggregateScheme is implemented by aggregate schemes whose aggregate signatures grow with the number of
+// signatures, such as half-aggregated Schnorr signatures. Their signatures are encoded with a 2-byte length.
+type VariableAggregateScheme interface {
+	AggregateScheme
+	// AggregateSize returns the size of an aggregate of n signatures
+	AggregateSize(n int) int32
+}
+
+// TweakableScheme is implemented by schemes with additive keys, which are required by the difference signatures
+// of Origami UTXO.
+type TweakableScheme interface {
+	// AddKeyPairs returns sum(keys) - sum(negKeys)
+	AddKeyPairs(keys []*SigKeyPair, negKeys []*SigKeyPair) SigKeyPair
+	// AddPublicKeys returns sum(keys) - sum(negKeys)
+	AddPublicKeys(keys []*Pubkey, negKeys []*Pubkey) Pubkey
+	// MulKeyPair multiplies the key pair by (a - b), or by a if b is nil
+	MulKeyPair(keys *SigKeyPair, a []byte, b []byte)
+	// MulPublicKey multiplies the public key by (a - b), or by a if b is nil
+	MulPublicKey(pk *Pubkey, a []byte, b []byte)
+}
+
+var (
+	sigSchemesLock sync.RWMutex
+	sigSchemes     = map[int32]SignatureScheme{
+		1: NewKyberSchnorr(),
+		2: NewKyberBLS(),
+		3: Ed25519{},
+		4: SecpECDSA{},
+		5: SecpSchnorr{},
+		6: BLS12381MinPk{},
+		7: BLS12381MinSig{},
+		8: NewSchnorrHalfAgg(),
+	}
+)
+
+// RegisterSignatureScheme registers a signature scheme for sigType, so it can be used with NewContext
+func RegisterSignatureScheme(sigType int32, scheme SignatureScheme) error {
+	if scheme == nil {
+		return fmt.Errorf("%w: nil signature scheme", ErrInvalidConfig)
+	}
+	_, pkSize, sigSize := scheme.Sizes()
+	if pkSize <= 0 || pkSize > 128 || sigSize <= 0 {
+		return fmt.Errorf("%w: invalid key or signature size", ErrInvalidConfig)
+	}
+	sigSchemesLock.Lock()
+	defer sigSchemesLock.Unlock()
+	if _, found := sigSchemes[sigType]; found {
+		return fmt.Errorf("%w: signature scheme %d is already registered", ErrInvalidConfig, sigType)
+	}
+	sigSchemes[sigType] = scheme
+	return nil
+}
+
+// GetSignatureScheme returns the signature scheme registered for sigType
+func GetSignatureScheme(sigType int32) (SignatureScheme, error) {
+	sigSchemesLock.RLock()
+	defer sigSchemesLock.RUnlock()
+	scheme, found := sigSchemes[sigType]
+	if !found {
+		return nil, fmt.Errorf("%w: %d", ErrUnknownSigType, sigType)
+	}
+	return scheme, nil
+}
@@ -0,0 +1,69 @@
+package txhelper
+
+import (
+	"errors"
+	"testing"
+)
+
+// countingScheme is an experimental scheme that hides the optional interfaces of a scheme and counts signatures
+type countingScheme struct {
+	SignatureScheme
+	signed *int
+}
+
+func (countingScheme) Name() string {
+	return "countingScheme"
+}
+
+func (s countingScheme) Sign(keys *SigKeyPair, msg []byte) (Signature, error) {
+	*s.signed++
+	return s.SignatureScheme.Sign(keys, msg)
+}
+
+func TestRegisterSignatureScheme(tester *testing.T) {
+	signed := 0
+	if err := RegisterSignatureScheme(101, countingScheme{SignatureScheme: NewKyberSchnorr(), signed: &signed}); err != nil {
+		tester.Fatal("couldn't register the scheme:", err)
+	}
+	if err := RegisterSignatureScheme(101, NewKyberBLS()); !errors.Is(err, ErrInvalidConfig) {
+		tester.Fatal("a scheme was registered twice:", err)
+	}
+	if err := RegisterSignatureScheme(2, NewKyberSchnorr()); !errors.Is(err, ErrInvalidConfig) {
+		tester.Fatal("a built-in scheme was replaced:", err)
+	}
+	if _, err := GetSignatureScheme(102); !errors.Is(err, ErrUnknownSigType) {
+		tester.Fatal("unknown scheme was found:", err)
+	}
+
+	for txType := 1; txType <= 4; txType++ {
+		ctx := newTestContext(tester, 350+txType, 2, txType, 101, 32, 10, 4, 5, 1, false, 2)
+		ctx.testPeerTransactions(10, tester)
+	}
+	if signed == 0 {
+		tester.Fatal("the registered scheme was not used")
+	}
+
+	// Origami UTXO needs difference signatures
+	_, err := NewContext(360, 2, 5, 101, 32, 10, 4, 5, 1, false, 2)
+	if !errors.Is(err, ErrInvalidConfig) {
+		tester.Fatal("origami utxo accepted a scheme without difference signatures:", err)
+	}
+	ctx := newTestContext(tester, 361, 2, 6, 101, 32, 10, 4, 5, 1, false, 2)
+	ctx.testPeerTransactions(10, tester)
+}
+
+func TestBuiltinSignatureSchemes(tester *testing.T) {
+	names := []string{"schnorr", "bls"}
+	for i, name := range names {
+		sigCtx, err := NewSigContext(int32(i + 1))
+		if err != nil {
+			tester.Fatal("missing built-in scheme:", err)
+		}
+		if sigCtx.scheme.Name() != name {
+			tester.Fatal("invalid scheme:", i+1, sigCtx.scheme.Name())
+		}
+		if sigCtx.aggregates() != (i == 1) || !sigCtx.tweakable() {
+			tester.Fatal("invalid scheme capabilities:", name)
+		}
+	}
+}
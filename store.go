@@ -0,0 +1,319 @@
+/**********************************************************************
+ * Copyright (c) 2017 Jayamine Alupotha                               *
+ * Distributed under the MIT software license, see the accompanying   *
+ * file COPYING or http://www.opensource.org/licenses/mit-license.php.*
+ **********************************************************************/
+
+package txhelper
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+// PeerStore keeps the state of a peer: outputs, transaction headers, blocks, undo records and the metadata of
+// WithOpenExisting. Peers use the sqlite store by default, and WithPeerStore selects another one, e.g., the in-memory
+// store to measure verification without the database cost. A store is used by one context at a time.
+//
+// Errors of stores wrap ErrDatabase, and missing records also wrap ErrNotStored, including outputs given to UpdateOutput
+// and AddUsed. Stores must reject outputs with an existing id or h, and outputs with an existing public key if
+// StoreConfig.UniquePk is set. Commit and Rollback fail without Begin.
+type PeerStore interface {
+	// Open prepares the store of cfg. If keep is set and the store has data, the data is kept and Open returns true.
+	// Otherwise, all stored data is deleted.
+	Open(cfg StoreConfig, keep bool) (bool, error)
+	// Close releases the store
+	Close() error
+
+	// Begin starts an atomic update. Changes are saved with Commit or discarded with Rollback.
+	Begin() error
+	Commit() error
+	Rollback() error
+
+	// PutOutput inserts a new output
+	PutOutput(out *StoredOutput) error
+	// UpdateOutput replaces the output of out.ID
+	UpdateOutput(out *StoredOutput) error
+	// AddUsed adds delta to the used counter of an output
+	AddUsed(id int, delta int) error
+	DeleteOutput(id int) error
+	OutputByID(id int) (*StoredOutput, error)
+	OutputByH(h []byte) (*StoredOutput, error)
+	OutputByPk(pk []byte) (*StoredOutput, error)
+	// ForEachOutput calls f for all outputs in the order of ids
+	ForEachOutput(f func(out *StoredOutput) error) error
+
+	PutTxHeader(txh *StoredTxHeader) error
+	TxHeader(txn int) (*StoredTxHeader, error)
+	DeleteTxHeader(txn int) error
+	// ForEachTxHeader calls f for all transaction headers in the order of transaction numbers
+	ForEachTxHeader(f func(txh *StoredTxHeader) error) error
+
+	PutBlock(b *StoredBlock) error
+	Block(height int) (*StoredBlock, error)
+	DeleteBlock(height int) error
+
+	// PutUndo saves the undo record of a transaction, replacing an old one
+	PutUndo(txn int, record []byte) error
+	Undo(txn int) ([]byte, error)
+	DeleteUndo(txn int) error
+
+	// PutMeta saves or replaces metadata values
+	PutMeta(values map[string][]byte) error
+	// Meta returns all metadata values
+	Meta() (map[string][]byte, error)
+}
+
+// StoreConfig tells a store where and what to store
+type StoreConfig struct {
+	Name     string   // peer<exeId>
+	Dir      string   // directory of WithDataDir
+	Source   string   // sqlite data source
+	InMemory bool     // WithInMemoryDB
+	Model    TxModel  // transaction model of the peer
+	Schema   []string // sqlite tables of the model
+	UniquePk bool     // public keys of outputs are unique
+}
+
+// StoredOutput is an output as peers store it
+type StoredOutput struct {
+	ID   int
+	H    []byte // output identifier
+	Pk   []byte
+	N    uint8
+	Data []byte
+	Sig  []byte // (model 6) signature of the account
+	Txns []int  // (model 6) transactions that updated the account
+	Used int    // (models 1-4) number of times the output was spent
+}
+
+// StoredTxHeader is a transaction header as peers store it
+type StoredTxHeader struct {
+	Txn      int
+	Sig      []byte // (models 1-4) all signatures, (model 5) the signature
+	Activity []byte // (models 5-6) activity proof
+	Excess   []byte // (model 5) excess public key
+	InIds    []int  // (models 1-4) ids of inputs
+	OutIds   []int  // (models 1-4) ids of outputs, (model 6) ids of updated and new accounts
+}
+
+// StoredBlock is the metadata of a committed block. Transactions of the block are [FirstTxn, FirstTxn + TxCount).
+type StoredBlock struct {
+	Height   int
+	Hash     []byte
+	Parent   []byte
+	Root     []byte
+	Sig      []byte
+	FirstTxn int
+	TxCount  int
+}
+
+// notStored is returned by stores for missing records
+func notStored(kind string, key any) error {
+	return dbError(fmt.Errorf("%w: %s %v", ErrNotStored, kind, key))
+}
+
+// Store returns the store of a peer, or nil for clients. Models in other packages keep their state with it; changes of
+// Apply, Revert and StoreTxHeader are part of the running atomic update.
+func (ctx *ExeContext) Store() PeerStore {
+	return ctx.store
+}
+
+// storeConfig returns the store configuration of a peer
+func (ctx *ExeContext) storeConfig() (StoreConfig, error) {
+	source, err := ctx.dbSource()
+	if err != nil {
+		return StoreConfig{}, err
+	}
+	return StoreConfig{
+		Name:     ctx.dbName(),
+		Dir:      ctx.dataDir,
+		Source:   source,
+		InMemory: ctx.inMemory,
+		Model:    ctx.model,
+		Schema:   ctx.model.Schema(ctx),
+		UniquePk: ctx.model.IsOrigami() && (ctx.model.IsAccount() || ctx.enableIndexing),
+	}, nil
+}
+
+// idsToBytes writes ids as 4-byte integers
+func idsToBytes(ids []int) []byte {
+	buf := make([]byte, 4*len(ids))
+	for i := 0; i < len(ids); i++ {
+		inttoByte4(ids[i], buf[i*4:])
+	}
+	return buf
+}
+
+// bytesToIds reads ids written by idsToBytes
+func bytesToIds(buf []byte) []int {
+	ids := make([]int, len(buf)/4)
+	for i := range ids {
+		ids[i] = byte4toInt(buf[i*4:])
+	}
+	return ids
+}
+
+func cloneIds(ids []int) []int {
+	if ids == nil {
+		return nil
+	}
+	return append(make([]int, 0, len(ids)), ids...)
+}
+
+func (out *StoredOutput) clone() *StoredOutput {
+	c := *out
+	c.H, c.Pk, c.Data, c.Sig = bytes.Clone(out.H), bytes.Clone(out.Pk), bytes.Clone(out.Data), bytes.Clone(out.Sig)
+	c.Txns = cloneIds(out.Txns)
+	return &c
+}
+
+func (txh *StoredTxHeader) clone() *StoredTxHeader {
+	c := *txh
+	c.Sig, c.Activity, c.Excess = bytes.Clone(txh.Sig), bytes.Clone(txh.Activity), bytes.Clone(txh.Excess)
+	c.InIds, c.OutIds = cloneIds(txh.InIds), cloneIds(txh.OutIds)
+	return &c
+}
+
+func (b *StoredBlock) clone() *StoredBlock {
+	c := *b
+	c.Hash, c.Parent, c.Root, c.Sig = bytes.Clone(b.Hash), bytes.Clone(b.Parent), bytes.Clone(b.Root), bytes.Clone(b.Sig)
+	return &c
+}
+
+// Records of key-value stores and undo records are written as varints and length-prefixed byte arrays
+
+func appendRecordBytes(b []byte, v []byte) []byte {
+	b = binary.AppendUvarint(b, uint64(len(v)))
+	return append(b, v...)
+}
+
+func appendRecordInts(b []byte, v []int) []byte {
+	b = binary.AppendUvarint(b, uint64(len(v)))
+	for i := 0; i < len(v); i++ {
+		b = binary.AppendVarint(b, int64(v[i]))
+	}
+	return b
+}
+
+// recordReader reads a record and keeps the first error
+type recordReader struct {
+	r   *bytes.Reader
+	err error
+}
+
+func newRecordReader(b []byte) *recordReader {
+	return &recordReader{r: bytes.NewReader(b)}
+}
+
+func (r *recordReader) int() int {
+	if r.err != nil {
+		return 0
+	}
+	v, err := binary.ReadVarint(r.r)
+	r.err = err
+	return int(v)
+}
+
+func (r *recordReader) byte() uint8 {
+	if r.err != nil {
+		return 0
+	}
+	v, err := r.r.ReadByte()
+	r.err = err
+	return v
+}
+
+func (r *recordReader) bytes() []byte {
+	if r.err != nil {
+		return nil
+	}
+	size, err := binary.ReadUvarint(r.r)
+	if err != nil || size > uint64(r.r.Len()) {
+		r.err = fmt.Errorf("invalid length")
+		return nil
+	}
+	v := make([]byte, size)
+	r.r.Read(v)
+	return v
+}
+
+func (r *recordReader) ints() []int {
+	if r.err != nil {
+		return nil
+	}
+	size, err := binary.ReadUvarint(r.r)
+	if err != nil || size > uint64(r.r.Len()) {
+		r.err = fmt.Errorf("invalid length")
+		return nil
+	}
+	v := make([]int, size)
+	for i := range v {
+		v[i] = r.int()
+	}
+	return v
+}
+
+// done returns an error if the record was corrupted
+func (r *recordReader) done(kind string) error {
+	if r.err == nil && r.r.Len() != 0 {
+		r.err = fmt.Errorf("trailing bytes")
+	}
+	if r.err != nil {
+		return fmt.Errorf("%w: corrupted %s: %v", ErrInvalidChain, kind, r.err)
+	}
+	return nil
+}
+
+func (out *StoredOutput) encode() []byte {
+	b := binary.AppendVarint(nil, int64(out.ID))
+	b = binary.AppendVarint(b, int64(out.Used))
+	b = append(b, out.N)
+	b = appendRecordBytes(b, out.H)
+	b = appendRecordBytes(b, out.Pk)
+	b = appendRecordBytes(b, out.Data)
+	b = appendRecordBytes(b, out.Sig)
+	return appendRecordInts(b, out.Txns)
+}
+
+func (out *StoredOutput) read(r *recordReader) {
+	out.ID, out.Used, out.N = r.int(), r.int(), r.byte()
+	out.H, out.Pk, out.Data, out.Sig = r.bytes(), r.bytes(), r.bytes(), r.bytes()
+	out.Txns = r.ints()
+}
+
+func (txh *StoredTxHeader) encode() []byte {
+	b := binary.AppendVarint(nil, int64(txh.Txn))
+	b = appendRecordBytes(b, txh.Sig)
+	b = appendRecordBytes(b, txh.Activity)
+	b = appendRecordBytes(b, txh.Excess)
+	b = appendRecordInts(b, txh.InIds)
+	return appendRecordInts(b, txh.OutIds)
+}
+
+func (txh *StoredTxHeader) decode(b []byte) error {
+	r := newRecordReader(b)
+	txh.Txn = r.int()
+	txh.Sig, txh.Activity, txh.Excess = r.bytes(), r.bytes(), r.bytes()
+	txh.InIds, txh.OutIds = r.ints(), r.ints()
+	return r.done("transaction header")
+}
+
+func (b *StoredBlock) encode() []byte {
+	buf := binary.AppendVarint(nil, int64(b.Height))
+	buf = appendRecordBytes(buf, b.Hash)
+	buf = appendRecordBytes(buf, b.Parent)
+	buf = appendRecordBytes(buf, b.Root)
+	buf = appendRecordBytes(buf, b.Sig)
+	buf = binary.AppendVarint(buf, int64(b.FirstTxn))
+	return binary.AppendVarint(buf, int64(b.TxCount))
+}
+
+func (b *StoredBlock) decode(buf []byte) error {
+	r := newRecordReader(buf)
+	b.Height = r.int()
+	b.Hash, b.Parent, b.Root, b.Sig = r.bytes(), r.bytes(), r.bytes(), r.bytes()
+	b.FirstTxn, b.TxCount = r.int(), r.int()
+	return r.done("block")
+}
@@ -0,0 +1,458 @@
+/**********************************************************************
+ * Copyright (c) 2017 Jayamine Alupotha                               *
+ * Distributed under the MIT software license, see the accompanying   *
+ * file COPYING or http://www.opensource.org/licenses/mit-license.php.*
+ **********************************************************************/
+
+package txhelper
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// Buckets of the bbolt store. Integer keys are 8-byte big-endian, so buckets iterate in the order of ids.
+var (
+	boltOutputs   = []byte("outputs")   // id -> output
+	boltByH       = []byte("outputsH")  // h -> id
+	boltByPk      = []byte("outputsPk") // pk -> id, only if public keys are unique
+	boltTxHeaders = []byte("txHeaders") // txn -> header
+	boltBlocks    = []byte("blocks")    // height -> block
+	boltUndo      = []byte("undo")      // txn -> undo record
+	boltMeta      = []byte("meta")      // key -> value
+	boltBuckets   = [][]byte{boltOutputs, boltByH, boltByPk, boltTxHeaders, boltBlocks, boltUndo, boltMeta}
+)
+
+// BoltStore keeps the peer state in a bbolt file, an embedded B+tree key-value store. Updates outside atomic
+// updates are committed one by one.
+type BoltStore struct {
+	path     string
+	db       *bolt.DB
+	tx       *bolt.Tx // writable transaction of the running atomic update
+	uniquePk bool
+}
+
+// NewBoltStore creates a bbolt store of the file at path. If path is empty, the file is <name>.bolt in the data
+// directory or the working directory.
+func NewBoltStore(path string) *BoltStore {
+	return &BoltStore{path: path}
+}
+
+func boltKey(i int) []byte {
+	return binary.BigEndian.AppendUint64(nil, uint64(i))
+}
+
+func boltError(err error) error {
+	if err == nil {
+		return nil
+	}
+	return dbError(err)
+}
+
+// update runs f in the running atomic update, or in a new writable transaction
+func (s *BoltStore) update(f func(tx *bolt.Tx) error) error {
+	if s.tx != nil {
+		return f(s.tx)
+	}
+	return s.db.Update(f)
+}
+
+// view runs f in the running atomic update, or in a new read-only transaction
+func (s *BoltStore) view(f func(tx *bolt.Tx) error) error {
+	if s.tx != nil {
+		return f(s.tx)
+	}
+	return s.db.View(f)
+}
+
+func (s *BoltStore) Open(cfg StoreConfig, keep bool) (bool, error) {
+	path := s.path
+	if path == "" {
+		path = filepath.Join(cfg.Dir, cfg.Name+".bolt")
+	}
+	var err error
+	if s.db, err = bolt.Open(path, 0o600, &bolt.Options{Timeout: time.Second}); err != nil {
+		return false, dbError(err)
+	}
+	s.uniquePk = cfg.UniquePk
+
+	existing := false
+	err = s.db.Update(func(tx *bolt.Tx) error {
+		if meta := tx.Bucket(boltMeta); keep && meta != nil {
+			if key, _ := meta.Cursor().First(); key != nil {
+				existing = true
+				return nil
+			}
+		}
+		for _, name := range boltBuckets {
+			if tx.Bucket(name) != nil {
+				if err := tx.DeleteBucket(name); err != nil {
+					return err
+				}
+			}
+			if _, err := tx.CreateBucket(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return existing, boltError(err)
+}
+
+func (s *BoltStore) Close() error {
+	if s.db == nil {
+		return nil
+	}
+	err := s.db.Close()
+	s.db = nil
+	return boltError(err)
+}
+
+func (s *BoltStore) Begin() error {
+	if s.tx != nil {
+		return dbError(fmt.Errorf("nested transaction"))
+	}
+	tx, err := s.db.Begin(true)
+	if err != nil {
+		return dbError(err)
+	}
+	s.tx = tx
+	return nil
+}
+
+func (s *BoltStore) Commit() error {
+	if s.tx == nil {
+		return dbError(fmt.Errorf("no running transaction"))
+	}
+	tx := s.tx
+	s.tx = nil
+	return boltError(tx.Commit())
+}
+
+func (s *BoltStore) Rollback() error {
+	if s.tx == nil {
+		return dbError(fmt.Errorf("no running transaction"))
+	}
+	tx := s.tx
+	s.tx = nil
+	return boltError(tx.Rollback())
+}
+
+// decodeBoltOutput parses a stored output
+func decodeBoltOutput(buf []byte) (*StoredOutput, error) {
+	out := new(StoredOutput)
+	r := newRecordReader(buf)
+	out.read(r)
+	return out, r.done("output")
+}
+
+// getBoltOutput reads the output of id, or returns nil
+func getBoltOutput(tx *bolt.Tx, id []byte) (*StoredOutput, error) {
+	buf := tx.Bucket(boltOutputs).Get(id)
+	if buf == nil {
+		return nil, nil
+	}
+	return decodeBoltOutput(buf)
+}
+
+// putOutput checks the unique keys and replaces the output and its indexes
+func (s *BoltStore) putOutput(tx *bolt.Tx, out *StoredOutput, old *StoredOutput) error {
+	id := boltKey(out.ID)
+	byH, byPk := tx.Bucket(boltByH), tx.Bucket(boltByPk)
+	if other := byH.Get(out.H); other != nil && !bytes.Equal(other, id) {
+		return fmt.Errorf("UNIQUE constraint failed: outputs.h")
+	}
+	if other := byPk.Get(out.Pk); s.uniquePk && other != nil && !bytes.Equal(other, id) {
+		return fmt.Errorf("UNIQUE constraint failed: outputs.pk")
+	}
+	if old != nil {
+		if err := s.deleteIndexes(tx, old); err != nil {
+			return err
+		}
+	}
+	if err := byH.Put(out.H, id); err != nil {
+		return err
+	}
+	if s.uniquePk {
+		if err := byPk.Put(out.Pk, id); err != nil {
+			return err
+		}
+	}
+	return tx.Bucket(boltOutputs).Put(id, out.encode())
+}
+
+func (s *BoltStore) deleteIndexes(tx *bolt.Tx, out *StoredOutput) error {
+	if err := tx.Bucket(boltByH).Delete(out.H); err != nil {
+		return err
+	}
+	if s.uniquePk {
+		return tx.Bucket(boltByPk).Delete(out.Pk)
+	}
+	return nil
+}
+
+func (s *BoltStore) PutOutput(out *StoredOutput) error {
+	return boltError(s.update(func(tx *bolt.Tx) error {
+		if tx.Bucket(boltOutputs).Get(boltKey(out.ID)) != nil {
+			return fmt.Errorf("UNIQUE constraint failed: outputs.id")
+		}
+		return s.putOutput(tx, out, nil)
+	}))
+}
+
+func (s *BoltStore) UpdateOutput(out *StoredOutput) error {
+	return boltError(s.update(func(tx *bolt.Tx) error {
+		old, err := getBoltOutput(tx, boltKey(out.ID))
+		if err != nil {
+			return err
+		}
+		if old == nil {
+			return fmt.Errorf("%w: output %d", ErrNotStored, out.ID)
+		}
+		return s.putOutput(tx, out, old)
+	}))
+}
+
+func (s *BoltStore) AddUsed(id int, delta int) error {
+	return boltError(s.update(func(tx *bolt.Tx) error {
+		out, err := getBoltOutput(tx, boltKey(id))
+		if err != nil {
+			return err
+		}
+		if out == nil {
+			return fmt.Errorf("%w: output %d", ErrNotStored, id)
+		}
+		out.Used += delta
+		return tx.Bucket(boltOutputs).Put(boltKey(id), out.encode())
+	}))
+}
+
+func (s *BoltStore) DeleteOutput(id int) error {
+	return boltError(s.update(func(tx *bolt.Tx) error {
+		out, err := getBoltOutput(tx, boltKey(id))
+		if err != nil || out == nil {
+			return err
+		}
+		if err = s.deleteIndexes(tx, out); err != nil {
+			return err
+		}
+		return tx.Bucket(boltOutputs).Delete(boltKey(id))
+	}))
+}
+
+// outputBy returns the output whose id is stored under key in the index bucket, or under key in outputs if index is nil
+func (s *BoltStore) outputBy(index []byte, key []byte, name any) (*StoredOutput, error) {
+	var out *StoredOutput
+	err := s.view(func(tx *bolt.Tx) error {
+		id := key
+		if index != nil {
+			if id = tx.Bucket(index).Get(key); id == nil {
+				return nil
+			}
+		}
+		var err error
+		out, err = getBoltOutput(tx, id)
+		return err
+	})
+	if err != nil {
+		return nil, boltError(err)
+	}
+	if out == nil {
+		return nil, notStored("output", name)
+	}
+	return out, nil
+}
+
+func (s *BoltStore) OutputByID(id int) (*StoredOutput, error) {
+	return s.outputBy(nil, boltKey(id), id)
+}
+
+func (s *BoltStore) OutputByH(h []byte) (*StoredOutput, error) {
+	return s.outputBy(boltByH, h, h)
+}
+
+func (s *BoltStore) OutputByPk(pk []byte) (*StoredOutput, error) {
+	if s.uniquePk {
+		return s.outputBy(boltByPk, pk, pk)
+	}
+	var found *StoredOutput
+	err := s.ForEachOutput(func(out *StoredOutput) error {
+		if found == nil && bytes.Equal(out.Pk, pk) {
+			found = out
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if found == nil {
+		return nil, notStored("output", pk)
+	}
+	return found, nil
+}
+
+func (s *BoltStore) ForEachOutput(f func(out *StoredOutput) error) error {
+	var fErr error
+	err := s.view(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltOutputs).ForEach(func(_ []byte, buf []byte) error {
+			out, err := decodeBoltOutput(buf)
+			if err != nil {
+				return err
+			}
+			fErr = f(out)
+			return fErr
+		})
+	})
+	if fErr != nil {
+		return fErr
+	}
+	return boltError(err)
+}
+
+func (s *BoltStore) PutTxHeader(txh *StoredTxHeader) error {
+	return boltError(s.update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(boltTxHeaders)
+		if bucket.Get(boltKey(txh.Txn)) != nil {
+			return fmt.Errorf("UNIQUE constraint failed: txHeaders.txn")
+		}
+		return bucket.Put(boltKey(txh.Txn), txh.encode())
+	}))
+}
+
+func (s *BoltStore) TxHeader(txn int) (*StoredTxHeader, error) {
+	var txh *StoredTxHeader
+	err := s.view(func(tx *bolt.Tx) error {
+		buf := tx.Bucket(boltTxHeaders).Get(boltKey(txn))
+		if buf == nil {
+			return nil
+		}
+		txh = new(StoredTxHeader)
+		return txh.decode(buf)
+	})
+	if err != nil {
+		return nil, boltError(err)
+	}
+	if txh == nil {
+		return nil, notStored("transaction", txn)
+	}
+	return txh, nil
+}
+
+func (s *BoltStore) DeleteTxHeader(txn int) error {
+	return boltError(s.update(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltTxHeaders).Delete(boltKey(txn))
+	}))
+}
+
+func (s *BoltStore) ForEachTxHeader(f func(txh *StoredTxHeader) error) error {
+	var fErr error
+	err := s.view(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltTxHeaders).ForEach(func(_ []byte, buf []byte) error {
+			txh := new(StoredTxHeader)
+			if err := txh.decode(buf); err != nil {
+				return err
+			}
+			fErr = f(txh)
+			return fErr
+		})
+	})
+	if fErr != nil {
+		return fErr
+	}
+	return boltError(err)
+}
+
+func (s *BoltStore) PutBlock(b *StoredBlock) error {
+	return boltError(s.update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(boltBlocks)
+		if bucket.Get(boltKey(b.Height)) != nil {
+			return fmt.Errorf("UNIQUE constraint failed: blocks.height")
+		}
+		return bucket.Put(boltKey(b.Height), b.encode())
+	}))
+}
+
+func (s *BoltStore) Block(height int) (*StoredBlock, error) {
+	var b *StoredBlock
+	err := s.view(func(tx *bolt.Tx) error {
+		buf := tx.Bucket(boltBlocks).Get(boltKey(height))
+		if buf == nil {
+			return nil
+		}
+		b = new(StoredBlock)
+		return b.decode(buf)
+	})
+	if err != nil {
+		return nil, boltError(err)
+	}
+	if b == nil {
+		return nil, notStored("block", height)
+	}
+	return b, nil
+}
+
+func (s *BoltStore) DeleteBlock(height int) error {
+	return boltError(s.update(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltBlocks).Delete(boltKey(height))
+	}))
+}
+
+func (s *BoltStore) PutUndo(txn int, record []byte) error {
+	return boltError(s.update(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltUndo).Put(boltKey(txn), record)
+	}))
+}
+
+func (s *BoltStore) Undo(txn int) ([]byte, error) {
+	var record []byte
+	err := s.view(func(tx *bolt.Tx) error {
+		if buf := tx.Bucket(boltUndo).Get(boltKey(txn)); buf != nil {
+			record = append([]byte{}, buf...)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, boltError(err)
+	}
+	if record == nil {
+		return nil, notStored("undo record", txn)
+	}
+	return record, nil
+}
+
+func (s *BoltStore) DeleteUndo(txn int) error {
+	return boltError(s.update(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltUndo).Delete(boltKey(txn))
+	}))
+}
+
+func (s *BoltStore) PutMeta(values map[string][]byte) error {
+	return boltError(s.update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(boltMeta)
+		for key, value := range values {
+			if err := bucket.Put([]byte(key), value); err != nil {
+				return err
+			}
+		}
+		return nil
+	}))
+}
+
+func (s *BoltStore) Meta() (map[string][]byte, error) {
+	stored := make(map[string][]byte)
+	err := s.view(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltMeta).ForEach(func(key []byte, value []byte) error {
+			stored[string(key)] = append([]byte{}, value...)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, boltError(err)
+	}
+	return stored, nil
+}
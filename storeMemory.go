@@ -0,0 +1,335 @@
+/**********************************************************************
+ * Copyright (c) 2017 Jayamine Alupotha                               *
+ * Distributed under the MIT software license, see the accompanying   *
+ * file COPYING or http://www.opensource.org/licenses/mit-license.php.*
+ **********************************************************************/
+
+package txhelper
+
+import (
+	"fmt"
+	"sort"
+)
+
+// MemoryStore keeps the peer state in maps. It doesn't have a database cost, so verification can be measured alone.
+// The data lives as long as the store, so a store given to a new context with WithOpenExisting keeps its data.
+type MemoryStore struct {
+	uniquePk  bool
+	outputs   map[int]*StoredOutput
+	byH       map[string]int
+	byPk      map[string][]int // ids of outputs of a public key in order
+	txHeaders map[int]*StoredTxHeader
+	blocks    map[int]*StoredBlock
+	undo      map[int][]byte
+	meta      map[string][]byte
+
+	inTx    bool
+	journal []func() // reverts changes of the running atomic update in the reverse order
+}
+
+// NewMemoryStore creates an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{}
+}
+
+func (s *MemoryStore) Open(cfg StoreConfig, keep bool) (bool, error) {
+	if keep && len(s.meta) > 0 {
+		if s.uniquePk != cfg.UniquePk {
+			return false, fmt.Errorf("%w: stored outputs have a different public key index", ErrInvalidConfig)
+		}
+		return true, nil
+	}
+	s.uniquePk = cfg.UniquePk
+	s.outputs = make(map[int]*StoredOutput)
+	s.byH = make(map[string]int)
+	s.byPk = make(map[string][]int)
+	s.txHeaders = make(map[int]*StoredTxHeader)
+	s.blocks = make(map[int]*StoredBlock)
+	s.undo = make(map[int][]byte)
+	s.meta = make(map[string][]byte)
+	return false, nil
+}
+
+// Close keeps the data, so the store can be opened again
+func (s *MemoryStore) Close() error {
+	return nil
+}
+
+func (s *MemoryStore) Begin() error {
+	if s.inTx {
+		return dbError(fmt.Errorf("nested transaction"))
+	}
+	s.inTx = true
+	s.journal = s.journal[:0]
+	return nil
+}
+
+func (s *MemoryStore) Commit() error {
+	if !s.inTx {
+		return dbError(fmt.Errorf("no running transaction"))
+	}
+	s.inTx = false
+	s.journal = s.journal[:0]
+	return nil
+}
+
+func (s *MemoryStore) Rollback() error {
+	if !s.inTx {
+		return dbError(fmt.Errorf("no running transaction"))
+	}
+	for i := len(s.journal) - 1; i >= 0; i-- {
+		s.journal[i]()
+	}
+	s.inTx = false
+	s.journal = s.journal[:0]
+	return nil
+}
+
+// record saves how to revert a change if an atomic update is running
+func (s *MemoryStore) record(revert func()) {
+	if s.inTx {
+		s.journal = append(s.journal, revert)
+	}
+}
+
+// setOutput replaces the output of id and its indexes, or deletes it if out is nil
+func (s *MemoryStore) setOutput(id int, out *StoredOutput) {
+	if old, found := s.outputs[id]; found {
+		delete(s.byH, string(old.H))
+		ids := s.byPk[string(old.Pk)]
+		i := sort.SearchInts(ids, id)
+		if ids = append(ids[:i], ids[i+1:]...); len(ids) == 0 {
+			delete(s.byPk, string(old.Pk))
+		} else {
+			s.byPk[string(old.Pk)] = ids
+		}
+		delete(s.outputs, id)
+	}
+	if out == nil {
+		return
+	}
+	s.outputs[id] = out
+	s.byH[string(out.H)] = id
+	ids := s.byPk[string(out.Pk)]
+	i := sort.SearchInts(ids, id)
+	ids = append(ids, 0)
+	copy(ids[i+1:], ids[i:])
+	ids[i] = id
+	s.byPk[string(out.Pk)] = ids
+}
+
+// changeOutput checks the unique keys and replaces the output of id
+func (s *MemoryStore) changeOutput(id int, out *StoredOutput) error {
+	if other, found := s.byH[string(out.H)]; found && other != id {
+		return dbError(fmt.Errorf("UNIQUE constraint failed: outputs.h"))
+	}
+	if others := s.byPk[string(out.Pk)]; s.uniquePk && len(others) > 0 && others[0] != id {
+		return dbError(fmt.Errorf("UNIQUE constraint failed: outputs.pk"))
+	}
+	old := s.outputs[id]
+	s.record(func() { s.setOutput(id, old) })
+	s.setOutput(id, out.clone())
+	return nil
+}
+
+func (s *MemoryStore) PutOutput(out *StoredOutput) error {
+	if _, found := s.outputs[out.ID]; found {
+		return dbError(fmt.Errorf("UNIQUE constraint failed: outputs.id"))
+	}
+	return s.changeOutput(out.ID, out)
+}
+
+func (s *MemoryStore) UpdateOutput(out *StoredOutput) error {
+	if _, found := s.outputs[out.ID]; !found {
+		return notStored("output", out.ID)
+	}
+	return s.changeOutput(out.ID, out)
+}
+
+func (s *MemoryStore) AddUsed(id int, delta int) error {
+	out, found := s.outputs[id]
+	if !found {
+		return notStored("output", id)
+	}
+	out.Used += delta
+	s.record(func() { out.Used -= delta })
+	return nil
+}
+
+func (s *MemoryStore) DeleteOutput(id int) error {
+	old, found := s.outputs[id]
+	if !found {
+		return nil
+	}
+	s.record(func() { s.setOutput(id, old) })
+	s.setOutput(id, nil)
+	return nil
+}
+
+func (s *MemoryStore) OutputByID(id int) (*StoredOutput, error) {
+	out, found := s.outputs[id]
+	if !found {
+		return nil, notStored("output", id)
+	}
+	return out.clone(), nil
+}
+
+func (s *MemoryStore) OutputByH(h []byte) (*StoredOutput, error) {
+	id, found := s.byH[string(h)]
+	if !found {
+		return nil, notStored("output", h)
+	}
+	return s.outputs[id].clone(), nil
+}
+
+func (s *MemoryStore) OutputByPk(pk []byte) (*StoredOutput, error) {
+	ids := s.byPk[string(pk)]
+	if len(ids) == 0 {
+		return nil, notStored("output", pk)
+	}
+	return s.outputs[ids[0]].clone(), nil
+}
+
+// outputIds returns the ids of outputs in order
+func (s *MemoryStore) outputIds() []int {
+	ids := make([]int, 0, len(s.outputs))
+	for id := range s.outputs {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	return ids
+}
+
+func (s *MemoryStore) ForEachOutput(f func(out *StoredOutput) error) error {
+	for _, id := range s.outputIds() {
+		if err := f(s.outputs[id].clone()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *MemoryStore) PutTxHeader(txh *StoredTxHeader) error {
+	if _, found := s.txHeaders[txh.Txn]; found {
+		return dbError(fmt.Errorf("UNIQUE constraint failed: txHeaders.txn"))
+	}
+	txn := txh.Txn
+	s.txHeaders[txn] = txh.clone()
+	s.record(func() { delete(s.txHeaders, txn) })
+	return nil
+}
+
+func (s *MemoryStore) TxHeader(txn int) (*StoredTxHeader, error) {
+	txh, found := s.txHeaders[txn]
+	if !found {
+		return nil, notStored("transaction", txn)
+	}
+	return txh.clone(), nil
+}
+
+func (s *MemoryStore) DeleteTxHeader(txn int) error {
+	old, found := s.txHeaders[txn]
+	if !found {
+		return nil
+	}
+	delete(s.txHeaders, txn)
+	s.record(func() { s.txHeaders[txn] = old })
+	return nil
+}
+
+func (s *MemoryStore) ForEachTxHeader(f func(txh *StoredTxHeader) error) error {
+	txns := make([]int, 0, len(s.txHeaders))
+	for txn := range s.txHeaders {
+		txns = append(txns, txn)
+	}
+	sort.Ints(txns)
+	for _, txn := range txns {
+		if err := f(s.txHeaders[txn].clone()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *MemoryStore) PutBlock(b *StoredBlock) error {
+	if _, found := s.blocks[b.Height]; found {
+		return dbError(fmt.Errorf("UNIQUE constraint failed: blocks.height"))
+	}
+	height := b.Height
+	s.blocks[height] = b.clone()
+	s.record(func() { delete(s.blocks, height) })
+	return nil
+}
+
+func (s *MemoryStore) Block(height int) (*StoredBlock, error) {
+	b, found := s.blocks[height]
+	if !found {
+		return nil, notStored("block", height)
+	}
+	return b.clone(), nil
+}
+
+func (s *MemoryStore) DeleteBlock(height int) error {
+	old, found := s.blocks[height]
+	if !found {
+		return nil
+	}
+	delete(s.blocks, height)
+	s.record(func() { s.blocks[height] = old })
+	return nil
+}
+
+func (s *MemoryStore) PutUndo(txn int, record []byte) error {
+	old, found := s.undo[txn]
+	s.undo[txn] = append([]byte{}, record...)
+	s.record(func() {
+		if found {
+			s.undo[txn] = old
+		} else {
+			delete(s.undo, txn)
+		}
+	})
+	return nil
+}
+
+func (s *MemoryStore) Undo(txn int) ([]byte, error) {
+	record, found := s.undo[txn]
+	if !found {
+		return nil, notStored("undo record", txn)
+	}
+	return append([]byte{}, record...), nil
+}
+
+func (s *MemoryStore) DeleteUndo(txn int) error {
+	old, found := s.undo[txn]
+	if !found {
+		return nil
+	}
+	delete(s.undo, txn)
+	s.record(func() { s.undo[txn] = old })
+	return nil
+}
+
+func (s *MemoryStore) PutMeta(values map[string][]byte) error {
+	for key, value := range values {
+		key := key
+		old, found := s.meta[key]
+		s.meta[key] = append([]byte{}, value...)
+		s.record(func() {
+			if found {
+				s.meta[key] = old
+			} else {
+				delete(s.meta, key)
+			}
+		})
+	}
+	return nil
+}
+
+func (s *MemoryStore) Meta() (map[string][]byte, error) {
+	stored := make(map[string][]byte, len(s.meta))
+	for key, value := range s.meta {
+		stored[key] = append([]byte{}, value...)
+	}
+	return stored, nil
+}
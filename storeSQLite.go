@@ -0,0 +1,427 @@
+/**********************************************************************
+ * Copyright (c) 2017 Jayamine Alupotha                               *
+ * Distributed under the MIT software license, see the accompanying   *
+ * file COPYING or http://www.opensource.org/licenses/mit-license.php.*
+ **********************************************************************/
+
+package txhelper
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// sqlExecutor runs statements on the database directly or inside an SQL transaction
+type sqlExecutor interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+// SQLiteStore keeps the peer state in the sqlite tables of the transaction model. It is the default store of peers.
+type SQLiteStore struct {
+	db       *sql.DB
+	conn     *sql.Conn // connection that keeps an in-memory database
+	tx       *sql.Tx   // SQL transaction of the running atomic update
+	accounts bool      // origami accounts keep signatures and histories in outputs
+	model    TxModel
+}
+
+// NewSQLiteStore creates the sqlite store. The database is opened from StoreConfig.Source.
+func NewSQLiteStore() *SQLiteStore {
+	return &SQLiteStore{}
+}
+
+// exec returns the SQL transaction of the running atomic update, or the database
+func (s *SQLiteStore) exec() sqlExecutor {
+	if s.tx != nil {
+		return s.tx
+	}
+	return s.db
+}
+
+// rowError converts errors of single-row queries
+func rowError(err error, kind string, key any) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return notStored(kind, key)
+	}
+	return dbError(err)
+}
+
+// updateOutputRow runs a statement that updates the output of id, which must exist
+func (s *SQLiteStore) updateOutputRow(id int, query string, args ...any) error {
+	result, err := s.exec().Exec(query, args...)
+	if err != nil {
+		return dbError(err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return dbError(err)
+	}
+	if rows == 0 {
+		return notStored("output", id)
+	}
+	return nil
+}
+
+// blocksSchema returns the table of committed blocks
+func blocksSchema() string {
+	return "DROP TABLE IF EXISTS blocks; " +
+		"CREATE TABLE blocks(height INTEGER PRIMARY KEY, hash BLOB UNIQUE, parent BLOB, root BLOB, sig BLOB, firstTxn INTEGER, txCount INTEGER);"
+}
+
+func (s *SQLiteStore) Open(cfg StoreConfig, keep bool) (bool, error) {
+	var err error
+	if s.db, s.conn, err = openSQLite(cfg.Source, cfg.InMemory); err != nil {
+		return false, err
+	}
+	s.model = cfg.Model
+	s.accounts = cfg.Model.IsOrigami() && cfg.Model.IsAccount()
+
+	if keep {
+		tables := 0
+		if err = s.db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'meta';").Scan(&tables); err != nil {
+			return false, dbError(err)
+		}
+		rows := 0
+		if tables > 0 {
+			if err = s.db.QueryRow("SELECT COUNT(*) FROM meta;").Scan(&rows); err != nil {
+				return false, dbError(err)
+			}
+		}
+		if rows > 0 {
+			return true, nil
+		}
+	}
+	statements := append(append([]string{}, cfg.Schema...), blocksSchema(), undoSchema(), metaSchema())
+	for _, statement := range statements {
+		if _, err = s.db.Exec(statement); err != nil {
+			return false, dbError(err)
+		}
+	}
+	return false, nil
+}
+
+func (s *SQLiteStore) Close() error {
+	if s.db == nil {
+		return nil
+	}
+	err := closeSQLite(s.db, s.conn)
+	s.db, s.conn = nil, nil
+	return err
+}
+
+func (s *SQLiteStore) Begin() error {
+	if s.tx != nil {
+		return dbError(fmt.Errorf("nested SQL transaction"))
+	}
+	tx, err := s.db.Begin()
+	if err != nil {
+		return dbError(err)
+	}
+	s.tx = tx
+	return nil
+}
+
+func (s *SQLiteStore) Commit() error {
+	if s.tx == nil {
+		return dbError(fmt.Errorf("no running SQL transaction"))
+	}
+	tx := s.tx
+	s.tx = nil
+	if err := tx.Commit(); err != nil {
+		return dbError(err)
+	}
+	return nil
+}
+
+func (s *SQLiteStore) Rollback() error {
+	if s.tx == nil {
+		return dbError(fmt.Errorf("no running SQL transaction"))
+	}
+	tx := s.tx
+	s.tx = nil
+	if err := tx.Rollback(); err != nil {
+		return dbError(err)
+	}
+	return nil
+}
+
+func (s *SQLiteStore) PutOutput(out *StoredOutput) error {
+	var err error
+	if !s.accounts {
+		_, err = s.exec().Exec("INSERT INTO outputs(id, h, pk, n, Data, used) VALUES(?, ?, ?, ?, ?, ?);",
+			out.ID, out.H, out.Pk, out.N, out.Data, out.Used)
+	} else {
+		_, err = s.exec().Exec("INSERT INTO outputs(id, h, pk, n, Data, sig, Txns, used) VALUES(?, ?, ?, ?, ?, ?, ?, ?);",
+			out.ID, out.H, out.Pk, out.N, out.Data, out.Sig, idsToBytes(out.Txns), out.Used)
+	}
+	if err != nil {
+		return dbError(err)
+	}
+	return nil
+}
+
+func (s *SQLiteStore) UpdateOutput(out *StoredOutput) error {
+	if !s.accounts {
+		return s.updateOutputRow(out.ID, "UPDATE outputs SET h = ?, pk = ?, n = ?, Data = ?, used = ? WHERE id = ?;",
+			out.H, out.Pk, out.N, out.Data, out.Used, out.ID)
+	}
+	return s.updateOutputRow(out.ID, "UPDATE outputs SET h = ?, pk = ?, n = ?, Data = ?, sig = ?, Txns = ?, used = ? WHERE id = ?;",
+		out.H, out.Pk, out.N, out.Data, out.Sig, idsToBytes(out.Txns), out.Used, out.ID)
+}
+
+func (s *SQLiteStore) AddUsed(id int, delta int) error {
+	return s.updateOutputRow(id, "UPDATE outputs SET used = used + ? WHERE id = ?;", delta, id)
+}
+
+func (s *SQLiteStore) DeleteOutput(id int) error {
+	if _, err := s.exec().Exec("DELETE FROM outputs WHERE id = ?;", id); err != nil {
+		return dbError(err)
+	}
+	return nil
+}
+
+// outputColumns returns the selected columns of outputs
+func (s *SQLiteStore) outputColumns() string {
+	if !s.accounts {
+		return "id, h, pk, n, Data, used"
+	}
+	return "id, h, pk, n, Data, used, sig, Txns"
+}
+
+// scanOutput reads a row of outputColumns
+func (s *SQLiteStore) scanOutput(scan func(dest ...any) error) (*StoredOutput, error) {
+	out := new(StoredOutput)
+	if !s.accounts {
+		return out, scan(&out.ID, &out.H, &out.Pk, &out.N, &out.Data, &out.Used)
+	}
+	var txns []byte
+	err := scan(&out.ID, &out.H, &out.Pk, &out.N, &out.Data, &out.Used, &out.Sig, &txns)
+	out.Txns = bytesToIds(txns)
+	return out, err
+}
+
+// outputBy returns the output whose column is key
+func (s *SQLiteStore) outputBy(column string, key any) (*StoredOutput, error) {
+	row := s.exec().QueryRow("SELECT "+s.outputColumns()+" FROM outputs WHERE "+column+" = ? LIMIT 1;", key)
+	out, err := s.scanOutput(row.Scan)
+	if err != nil {
+		return nil, rowError(err, "output", key)
+	}
+	return out, nil
+}
+
+func (s *SQLiteStore) OutputByID(id int) (*StoredOutput, error) {
+	return s.outputBy("id", id)
+}
+
+func (s *SQLiteStore) OutputByH(h []byte) (*StoredOutput, error) {
+	return s.outputBy("h", h)
+}
+
+func (s *SQLiteStore) OutputByPk(pk []byte) (*StoredOutput, error) {
+	return s.outputBy("pk", pk)
+}
+
+func (s *SQLiteStore) ForEachOutput(f func(out *StoredOutput) error) error {
+	rows, err := s.exec().Query("SELECT " + s.outputColumns() + " FROM outputs ORDER BY id;")
+	if err != nil {
+		return dbError(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		out, err := s.scanOutput(rows.Scan)
+		if err != nil {
+			return dbError(err)
+		}
+		if err = f(out); err != nil {
+			return err
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return dbError(err)
+	}
+	return nil
+}
+
+// txHeaderColumns returns the columns of txHeaders, which depend on the model
+func (s *SQLiteStore) txHeaderColumns() string {
+	switch {
+	case !s.model.IsOrigami():
+		return "txn, sigAll, allInIds, allOutIds"
+	case !s.model.IsAccount():
+		return "txn, activity, excess, sig"
+	}
+	return "txn, activity, allOutIds"
+}
+
+// scanTxHeader reads a row of txHeaderColumns
+func (s *SQLiteStore) scanTxHeader(scan func(dest ...any) error) (*StoredTxHeader, error) {
+	txh := new(StoredTxHeader)
+	var inBuf, outBuf []byte
+	var err error
+	switch {
+	case !s.model.IsOrigami():
+		err = scan(&txh.Txn, &txh.Sig, &inBuf, &outBuf)
+		txh.InIds, txh.OutIds = bytesToIds(inBuf), bytesToIds(outBuf)
+	case !s.model.IsAccount():
+		err = scan(&txh.Txn, &txh.Activity, &txh.Excess, &txh.Sig)
+	default:
+		err = scan(&txh.Txn, &txh.Activity, &outBuf)
+		txh.OutIds = bytesToIds(outBuf)
+	}
+	return txh, err
+}
+
+func (s *SQLiteStore) PutTxHeader(txh *StoredTxHeader) error {
+	var err error
+	statement := "INSERT INTO txHeaders(" + s.txHeaderColumns() + ") VALUES("
+	switch {
+	case !s.model.IsOrigami():
+		_, err = s.exec().Exec(statement+"?, ?, ?, ?);", txh.Txn, txh.Sig, idsToBytes(txh.InIds), idsToBytes(txh.OutIds))
+	case !s.model.IsAccount():
+		_, err = s.exec().Exec(statement+"?, ?, ?, ?);", txh.Txn, txh.Activity, txh.Excess, txh.Sig)
+	default:
+		_, err = s.exec().Exec(statement+"?, ?, ?);", txh.Txn, txh.Activity, idsToBytes(txh.OutIds))
+	}
+	if err != nil {
+		return dbError(err)
+	}
+	return nil
+}
+
+func (s *SQLiteStore) TxHeader(txn int) (*StoredTxHeader, error) {
+	row := s.exec().QueryRow("SELECT "+s.txHeaderColumns()+" FROM txHeaders WHERE txn = ?;", txn)
+	txh, err := s.scanTxHeader(row.Scan)
+	if err != nil {
+		return nil, rowError(err, "transaction", txn)
+	}
+	return txh, nil
+}
+
+func (s *SQLiteStore) DeleteTxHeader(txn int) error {
+	if _, err := s.exec().Exec("DELETE FROM txHeaders WHERE txn = ?;", txn); err != nil {
+		return dbError(err)
+	}
+	return nil
+}
+
+func (s *SQLiteStore) ForEachTxHeader(f func(txh *StoredTxHeader) error) error {
+	rows, err := s.exec().Query("SELECT " + s.txHeaderColumns() + " FROM txHeaders ORDER BY txn;")
+	if err != nil {
+		return dbError(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		txh, err := s.scanTxHeader(rows.Scan)
+		if err != nil {
+			return dbError(err)
+		}
+		if err = f(txh); err != nil {
+			return err
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return dbError(err)
+	}
+	return nil
+}
+
+func (s *SQLiteStore) PutBlock(b *StoredBlock) error {
+	_, err := s.exec().Exec("INSERT INTO blocks(height, hash, parent, root, sig, firstTxn, txCount) VALUES(?, ?, ?, ?, ?, ?, ?);",
+		b.Height, b.Hash, b.Parent, b.Root, b.Sig, b.FirstTxn, b.TxCount)
+	if err != nil {
+		return dbError(err)
+	}
+	return nil
+}
+
+func (s *SQLiteStore) Block(height int) (*StoredBlock, error) {
+	b := &StoredBlock{Height: height}
+	row := s.exec().QueryRow("SELECT hash, parent, root, sig, firstTxn, txCount FROM blocks WHERE height = ?;", height)
+	if err := row.Scan(&b.Hash, &b.Parent, &b.Root, &b.Sig, &b.FirstTxn, &b.TxCount); err != nil {
+		return nil, rowError(err, "block", height)
+	}
+	return b, nil
+}
+
+func (s *SQLiteStore) DeleteBlock(height int) error {
+	if _, err := s.exec().Exec("DELETE FROM blocks WHERE height = ?;", height); err != nil {
+		return dbError(err)
+	}
+	return nil
+}
+
+func (s *SQLiteStore) PutUndo(txn int, record []byte) error {
+	if _, err := s.exec().Exec("INSERT OR REPLACE INTO undo(txn, record) VALUES(?, ?);", txn, record); err != nil {
+		return dbError(err)
+	}
+	return nil
+}
+
+func (s *SQLiteStore) Undo(txn int) ([]byte, error) {
+	var record []byte
+	if err := s.exec().QueryRow("SELECT record FROM undo WHERE txn = ?;", txn).Scan(&record); err != nil {
+		return nil, rowError(err, "undo record", txn)
+	}
+	return record, nil
+}
+
+func (s *SQLiteStore) DeleteUndo(txn int) error {
+	if _, err := s.exec().Exec("DELETE FROM undo WHERE txn = ?;", txn); err != nil {
+		return dbError(err)
+	}
+	return nil
+}
+
+// PutMeta saves all values with one statement
+func (s *SQLiteStore) PutMeta(values map[string][]byte) error {
+	return putSQLMeta(s.exec(), values)
+}
+
+func (s *SQLiteStore) Meta() (map[string][]byte, error) {
+	return readSQLMeta(s.exec())
+}
+
+// putSQLMeta saves key-value pairs to the meta table with one statement
+func putSQLMeta(db sqlExecutor, values map[string][]byte) error {
+	var statement strings.Builder
+	args := make([]any, 0, 2*len(values))
+	statement.WriteString("INSERT OR REPLACE INTO meta(key, value) VALUES")
+	for key, value := range values {
+		if len(args) > 0 {
+			statement.WriteString(",")
+		}
+		statement.WriteString(" (?, ?)")
+		args = append(args, key, value)
+	}
+	if _, err := db.Exec(statement.String()+";", args...); err != nil {
+		return dbError(err)
+	}
+	return nil
+}
+
+// readSQLMeta returns all key-value pairs of the meta table
+func readSQLMeta(db sqlExecutor) (map[string][]byte, error) {
+	rows, err := db.Query("SELECT key, value FROM meta;")
+	if err != nil {
+		return nil, dbError(err)
+	}
+	defer rows.Close()
+	stored := make(map[string][]byte)
+	for rows.Next() {
+		var key string
+		var value []byte
+		if err = rows.Scan(&key, &value); err != nil {
+			return nil, dbError(err)
+		}
+		stored[key] = value
+	}
+	if err = rows.Err(); err != nil {
+		return nil, dbError(err)
+	}
+	return stored, nil
+}
@@ -0,0 +1,167 @@
+package txhelper
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestPeerStores(tester *testing.T) {
+	for txType := 1; txType <= 6; txType++ {
+		dir := tester.TempDir()
+		shape := WithShapeDistribution(FixedShape{Inputs: 1, Outputs: 3})
+		client := newTestContext(tester, 290, 1, txType, 1, 32, 10, 3, 4, 1, true, 2, shape)
+		proposer := newTestContext(tester, 290, 2, txType, 1, 32, 10, 3, 4, 1, true, 2)
+		peers := []ExeContext{
+			newTestContext(tester, 291, 2, txType, 1, 32, 10, 3, 4, 1, true, 2, WithPeerStore(NewMemoryStore())),
+			newTestContext(tester, 291, 2, txType, 1, 32, 10, 3, 4, 1, true, 2, WithPeerStore(NewBoltStore("")), WithDataDir(dir)),
+		}
+
+		// all stores must keep the same state
+		var states []string
+		for i := 0; i < 3; i++ {
+			states = append(states, proposer.peerState(tester))
+			block := testBlockFromClient(tester, &client, &proposer, 4)
+			if err := proposer.CommitBlock(block); err != nil {
+				tester.Fatal("could not commit the block:", err, txType)
+			}
+			for j := range peers {
+				if err := peers[j].VerifyBlock(block); err != nil {
+					tester.Fatal("could not verify the block:", err, txType, j)
+				}
+				if err := peers[j].CommitBlock(block); err != nil {
+					tester.Fatal("could not commit the block:", err, txType, j)
+				}
+				if peers[j].peerState(tester) != proposer.peerState(tester) {
+					tester.Fatal("stores have different states:", txType, j)
+				}
+			}
+		}
+
+		// a failed block doesn't change the stores
+		block := testBlockFromClient(tester, &client, &proposer, 3)
+		block.Txs[2].Data.Outputs[2].header = block.Txs[2].Data.Outputs[1].header
+		for j := range peers {
+			if err := peers[j].CommitBlock(block); !errors.Is(err, ErrDatabase) {
+				tester.Fatal("block with a duplicate output was committed:", err, txType, j)
+			}
+			if peers[j].peerState(tester) != proposer.peerState(tester) {
+				tester.Fatal("failed block commit changed the store:", txType, j)
+			}
+		}
+		for j := range peers {
+			if err := peers[j].VerifyStoredAllBlocks(); err != nil {
+				tester.Fatal("invalid chain was stored:", err, txType, j)
+			}
+			if err := peers[j].RevertBlock(); err != nil {
+				tester.Fatal("could not revert the block:", err, txType, j)
+			}
+			if peers[j].peerState(tester) != states[2] {
+				tester.Fatal("reverted state is different:", txType, j)
+			}
+			peers[j].Close()
+		}
+		proposer.Close()
+		client.Close()
+	}
+}
+
+func TestStoreRestart(tester *testing.T) {
+	for _, txType := range []int{1, 5, 6} {
+		dir := tester.TempDir()
+		memory := NewMemoryStore()
+		for j, store := range []func() Option{
+			func() Option { return WithPeerStore(memory) },
+			func() Option { return WithPeerStore(NewBoltStore("")) },
+		} {
+			client := newTestContext(tester, 292, 1, txType, 1, 32, 10, 3, 4, 1, false, 2)
+			peer := newTestContext(tester, 292, 2, txType, 1, 32, 10, 3, 4, 1, false, 2, store(), WithDataDir(dir), WithOpenExisting())
+			for i := 0; i < 2; i++ {
+				if err := peer.CommitBlock(testBlockFromClient(tester, &client, &peer, 4)); err != nil {
+					tester.Fatal("could not commit the block:", err, txType, j)
+				}
+			}
+			state := peer.peerState(tester)
+			peer.Close()
+
+			restarted := newTestContext(tester, 292, 2, txType, 1, 32, 10, 3, 4, 1, false, 2, store(), WithDataDir(dir), WithOpenExisting())
+			if restarted.peerState(tester) != state {
+				tester.Fatal("restarted peer has a different state:", txType, j)
+			}
+			if err := restarted.CommitBlock(testBlockFromClient(tester, &client, &restarted, 4)); err != nil {
+				tester.Fatal("restarted peer could not commit the block:", err, txType, j)
+			}
+			if err := restarted.VerifyStoredAllBlocks(); err != nil {
+				tester.Fatal("invalid chain after restarting:", err, txType, j)
+			}
+			restarted.Close()
+			client.Close()
+		}
+	}
+}
+
+func TestStoreConformance(tester *testing.T) {
+	dir := tester.TempDir()
+	peer := newTestContext(tester, 293, 2, 1, 1, 32, 10, 3, 4, 1, false, 2, WithDataDir(dir))
+	defer peer.Close()
+	cfg, err := peer.storeConfig()
+	if err != nil {
+		tester.Fatal("couldn't create the store configuration:", err)
+	}
+	cfg.Name, cfg.Source = "conformance", filepath.Join(dir, "conformance.db")
+
+	for _, store := range []PeerStore{NewSQLiteStore(), NewMemoryStore(), NewBoltStore("")} {
+		if _, err = store.Open(cfg, false); err != nil {
+			tester.Fatalf("%T couldn't open: %v", store, err)
+		}
+		if err = store.Commit(); !errors.Is(err, ErrDatabase) {
+			tester.Fatalf("%T committed without a transaction: %v", store, err)
+		}
+		if err = store.Rollback(); !errors.Is(err, ErrDatabase) {
+			tester.Fatalf("%T rolled back without a transaction: %v", store, err)
+		}
+		if err = store.AddUsed(1, 1); !errors.Is(err, ErrNotStored) {
+			tester.Fatalf("%T updated a missing output: %v", store, err)
+		}
+		if err = store.UpdateOutput(&StoredOutput{ID: 1, H: []byte{1}, Pk: []byte{1}}); !errors.Is(err, ErrNotStored) {
+			tester.Fatalf("%T replaced a missing output: %v", store, err)
+		}
+
+		// outputs of a public key are found in the order of ids
+		for _, id := range []int{3, 1, 2} {
+			if err = store.PutOutput(&StoredOutput{ID: id, H: []byte{byte(id)}, Pk: []byte{byte(id % 2)}}); err != nil {
+				tester.Fatalf("%T couldn't insert the output: %v", store, err)
+			}
+		}
+		for _, id := range []int{1, 3} {
+			out, err := store.OutputByPk([]byte{1})
+			if err != nil || out.ID != id {
+				tester.Fatalf("%T found a wrong output of the public key: %v %v", store, out, err)
+			}
+			if err = store.DeleteOutput(id); err != nil {
+				tester.Fatalf("%T couldn't delete the output: %v", store, err)
+			}
+		}
+		if _, err = store.OutputByPk([]byte{1}); !errors.Is(err, ErrNotStored) {
+			tester.Fatalf("%T found a deleted output: %v", store, err)
+		}
+
+		// rolled back updates are discarded
+		if err = store.AddUsed(2, 1); err != nil {
+			tester.Fatalf("%T couldn't update the output: %v", store, err)
+		}
+		if err = store.Begin(); err != nil {
+			tester.Fatalf("%T couldn't begin: %v", store, err)
+		}
+		if err = store.AddUsed(2, 1); err != nil {
+			tester.Fatalf("%T couldn't update the output: %v", store, err)
+		}
+		if err = store.Rollback(); err != nil {
+			tester.Fatalf("%T couldn't roll back: %v", store, err)
+		}
+		if out, err := store.OutputByID(2); err != nil || out.Used != 1 {
+			tester.Fatalf("%T kept a rolled back update: %v %v", store, out, err)
+		}
+		store.Close()
+	}
+}
@@ -0,0 +1,135 @@
+/**********************************************************************
+ * Copyright (c) 2017 Jayamine Alupotha                               *
+ * Distributed under the MIT software license, see the accompanying   *
+ * file COPYING or http://www.opensource.org/licenses/mit-license.php.*
+ **********************************************************************/
+
+package txhelper
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+)
+
+const (
+	// streamItemOverhead bounds the encoding of an input or output without its payload (keys, signatures and lengths)
+	streamItemOverhead = 1 << 12
+	// maxStreamRecord bounds records of corrupted streams. The largest transactions have 255 inputs and 255 outputs of
+	// MaxPayloadSize payloads, about 257 MiB.
+	maxStreamRecord = 255*MaxPayloadSize + 2*255*streamItemOverhead + streamItemOverhead
+	// streamChunk is the most a record grows the buffer before its bytes are read, so a corrupted length can't
+	// allocate more than the stream has
+	streamChunk = 1 << 16
+)
+
+// crcTable is the CRC-32C (Castagnoli) table of stream records
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// TxStreamWriter writes transactions as records of the length (varint), the encoded transaction and its CRC-32C
+// (4 bytes, big-endian). The record buffer is reused, and every record is written with one call of w.Write, so
+// buffer w if needed.
+type TxStreamWriter struct {
+	w     io.Writer
+	ctx   *ExeContext
+	buf   []byte
+	Count int // number of written records
+}
+
+// NewTxStreamWriter writes transactions encoded by ctx to w. ctx can be nil if only WriteBytes is used.
+func NewTxStreamWriter(w io.Writer, ctx *ExeContext) *TxStreamWriter {
+	return &TxStreamWriter{w: w, ctx: ctx}
+}
+
+// Write encodes the transaction with ToBytes and writes it
+func (s *TxStreamWriter) Write(tx *Transaction) error {
+	return s.WriteBytes(s.ctx.ToBytes(tx))
+}
+
+// WriteBytes writes an encoded transaction. Records that readers would reject give ErrMalformedEncoding.
+func (s *TxStreamWriter) WriteBytes(txBytes []byte) error {
+	if len(txBytes) > maxStreamRecord {
+		return newTxError("write stream", s.Count, fmt.Errorf("%w: record of %d bytes", ErrMalformedEncoding, len(txBytes)))
+	}
+	s.buf = binary.AppendUvarint(s.buf[:0], uint64(len(txBytes)))
+	s.buf = append(s.buf, txBytes...)
+	s.buf = binary.BigEndian.AppendUint32(s.buf, crc32.Checksum(txBytes, crcTable))
+	if _, err := s.w.Write(s.buf); err != nil {
+		return err
+	}
+	s.Count++
+	return nil
+}
+
+// TxStreamReader reads records written by TxStreamWriter. The record buffer is reused, so reading records with Next
+// doesn't allocate after the largest record is read. Read parses transactions with FromBytes, which allocates the
+// inputs, outputs and signatures of every transaction.
+type TxStreamReader struct {
+	r         *bufio.Reader
+	ctx       *ExeContext
+	buf       []byte
+	crc       [4]byte
+	checksums bool // records have checksums (always except in version 1 traces)
+	Count     int  // number of read records
+}
+
+// NewTxStreamReader reads transactions for ctx from r. ctx can be nil if only Next is used.
+func NewTxStreamReader(r io.Reader, ctx *ExeContext) *TxStreamReader {
+	reader, ok := r.(*bufio.Reader)
+	if !ok {
+		reader = bufio.NewReader(r)
+	}
+	return &TxStreamReader{r: reader, ctx: ctx, checksums: true}
+}
+
+// Next returns the next encoded transaction, or io.EOF at the end of the stream. The returned bytes are valid until
+// the next call. Truncated and corrupted records give ErrMalformedEncoding.
+func (s *TxStreamReader) Next() ([]byte, error) {
+	length, err := binary.ReadUvarint(s.r)
+	if err == io.EOF {
+		return nil, io.EOF
+	}
+	if err != nil {
+		return nil, newTxError("read stream", s.Count, fmt.Errorf("%w: record length: %w", ErrMalformedEncoding, err))
+	}
+	if length > maxStreamRecord {
+		return nil, newTxError("read stream", s.Count, fmt.Errorf("%w: record of %d bytes", ErrMalformedEncoding, length))
+	}
+	s.buf = s.buf[:0]
+	for uint64(len(s.buf)) < length {
+		n := len(s.buf)
+		chunk := int(length) - n
+		if n+chunk > cap(s.buf) && chunk > streamChunk {
+			chunk = streamChunk
+		}
+		s.buf = append(s.buf, make([]byte, chunk)...)
+		if _, err = io.ReadFull(s.r, s.buf[n:]); err != nil {
+			return nil, newTxError("read stream", s.Count, fmt.Errorf("%w: record: %w", ErrMalformedEncoding, err))
+		}
+	}
+	if s.checksums {
+		if _, err = io.ReadFull(s.r, s.crc[:]); err != nil {
+			return nil, newTxError("read stream", s.Count, fmt.Errorf("%w: checksum: %w", ErrMalformedEncoding, err))
+		}
+		if binary.BigEndian.Uint32(s.crc[:]) != crc32.Checksum(s.buf, crcTable) {
+			return nil, newTxError("read stream", s.Count, fmt.Errorf("%w: checksum mismatch", ErrMalformedEncoding))
+		}
+	}
+	s.Count++
+	return s.buf, nil
+}
+
+// Read parses the next transaction with FromBytes, or returns io.EOF at the end of the stream. Unlike Next, it allocates
+// the parsed transaction.
+func (s *TxStreamReader) Read(tx *Transaction) error {
+	txBytes, err := s.Next()
+	if err != nil {
+		return err
+	}
+	if err = s.ctx.FromBytes(txBytes, tx); err != nil {
+		return newTxError("read stream", s.Count-1, err)
+	}
+	return nil
+}
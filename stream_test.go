@@ -0,0 +1,157 @@
+package txhelper
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"runtime"
+	"testing"
+)
+
+func TestTxStream(tester *testing.T) {
+	for i := 1; i <= 6; i++ {
+		var stream bytes.Buffer
+		client := newTestContext(tester, 210, 1, i, 1, 32, 10, 2, 3, 1, false, 2)
+		peer := newTestContext(tester, 210, 2, i, 1, 32, 10, 2, 3, 1, false, 2)
+		writer := NewTxStreamWriter(&stream, &client)
+		for j := 0; j < 10; j++ {
+			tx, err := client.RandomTransaction()
+			if err != nil {
+				tester.Fatal("couldn't create tx:", err)
+			}
+			if err = client.VerifyIncomingTransaction(tx); err != nil {
+				tester.Fatal("invalid transaction in the client:", err)
+			}
+			if err = client.UpdateAppDataClient(&tx.Data); err != nil {
+				tester.Fatal("could not update the client:", err)
+			}
+			if err = writer.Write(tx); err != nil {
+				tester.Fatal("couldn't write tx:", err)
+			}
+		}
+
+		reader := NewTxStreamReader(bytes.NewReader(stream.Bytes()), &peer)
+		for j := 0; ; j++ {
+			var tx Transaction
+			err := reader.Read(&tx)
+			if err == io.EOF {
+				if j != 10 {
+					tester.Fatal("invalid number of transactions:", j)
+				}
+				break
+			}
+			if err != nil {
+				tester.Fatal("couldn't read tx:", err)
+			}
+			if err = peer.VerifyIncomingTransaction(&tx); err != nil {
+				tester.Fatal("invalid transaction:", err)
+			}
+			if err = peer.CommitTransaction(j, &tx); err != nil {
+				tester.Fatal("could not commit the transaction:", err)
+			}
+		}
+		if err := peer.VerifyStoredAllTransaction(); err != nil {
+			tester.Fatal("invalid blockchain was created:", err)
+		}
+	}
+}
+
+func TestTxStreamCorruption(tester *testing.T) {
+	var stream bytes.Buffer
+	writer := NewTxStreamWriter(&stream, nil)
+	for j := 0; j < 3; j++ {
+		if err := writer.WriteBytes(bytes.Repeat([]byte{byte(j)}, 100)); err != nil {
+			tester.Fatal("couldn't write record:", err)
+		}
+	}
+	corrupted := append([]byte(nil), stream.Bytes()...)
+	corrupted[len(corrupted)/2] ^= 1
+	reader := NewTxStreamReader(bytes.NewReader(corrupted), nil)
+	if _, err := reader.Next(); err != nil {
+		tester.Fatal("couldn't read the first record:", err)
+	}
+	var txErr *TxError
+	if _, err := reader.Next(); !errors.Is(err, ErrMalformedEncoding) || !errors.As(err, &txErr) || txErr.Index != 1 {
+		tester.Fatal("corrupted record was read:", err)
+	}
+	reader = NewTxStreamReader(bytes.NewReader(stream.Bytes()[:stream.Len()-2]), nil)
+	for j := 0; j < 2; j++ {
+		if _, err := reader.Next(); err != nil {
+			tester.Fatal("couldn't read record:", err)
+		}
+	}
+	if _, err := reader.Next(); !errors.Is(err, ErrMalformedEncoding) {
+		tester.Fatal("truncated record was read:", err)
+	}
+}
+
+func TestTxStreamCorruptedLength(tester *testing.T) {
+	// a corrupted length of 16 MiB followed by a few bytes
+	corrupted := append(binary.AppendUvarint(nil, 1<<24), bytes.Repeat([]byte{1}, 10)...)
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	reader := NewTxStreamReader(bytes.NewReader(corrupted), nil)
+	if _, err := reader.Next(); !errors.Is(err, ErrMalformedEncoding) {
+		tester.Fatal("record with a corrupted length was read:", err)
+	}
+	runtime.ReadMemStats(&after)
+	if after.TotalAlloc-before.TotalAlloc > 1<<20 {
+		tester.Fatal("corrupted length allocated:", after.TotalAlloc-before.TotalAlloc)
+	}
+	reader = NewTxStreamReader(bytes.NewReader(binary.AppendUvarint(nil, maxStreamRecord+1)), nil)
+	if _, err := reader.Next(); !errors.Is(err, ErrMalformedEncoding) {
+		tester.Fatal("too large record was accepted:", err)
+	}
+	var stream bytes.Buffer
+	writer := NewTxStreamWriter(&stream, nil)
+	if err := writer.WriteBytes(make([]byte, maxStreamRecord+1)); !errors.Is(err, ErrMalformedEncoding) || stream.Len() != 0 {
+		tester.Fatal("too large record was written:", err)
+	}
+}
+
+func TestTxStreamAllocations(tester *testing.T) {
+	var stream bytes.Buffer
+	writer := NewTxStreamWriter(&stream, nil)
+	record := bytes.Repeat([]byte{7}, 300)
+	for j := 0; j < 110; j++ {
+		if err := writer.WriteBytes(record); err != nil {
+			tester.Fatal("couldn't write record:", err)
+		}
+	}
+	reader := NewTxStreamReader(bytes.NewReader(stream.Bytes()), nil)
+	if _, err := reader.Next(); err != nil {
+		tester.Fatal("couldn't read record:", err)
+	}
+	allocs := testing.AllocsPerRun(100, func() {
+		if txBytes, err := reader.Next(); err != nil || !bytes.Equal(txBytes, record) {
+			tester.Fatal("couldn't read record:", err)
+		}
+	})
+	if allocs != 0 {
+		tester.Fatal("reading records allocates:", allocs)
+	}
+	allocs = testing.AllocsPerRun(100, func() {
+		if err := writer.WriteBytes(record); err != nil {
+			tester.Fatal("couldn't write record:", err)
+		}
+	})
+	if allocs != 0 {
+		tester.Fatal("writing records allocates:", allocs)
+	}
+}
+
+func TestTraceVersion1(tester *testing.T) {
+	// version 1 records don't have checksums
+	trace := []byte{'T', 'X', 'H', 'T', 1, 1, 0, 1, 0, 32, 0, 3, 1, 2, 3}
+	reader, err := NewTraceReader(bytes.NewReader(trace))
+	if err != nil {
+		tester.Fatal("couldn't read the trace:", err)
+	}
+	if txBytes, err := reader.Next(); err != nil || !bytes.Equal(txBytes, []byte{1, 2, 3}) {
+		tester.Fatal("couldn't read the record:", err)
+	}
+	if _, err = reader.Next(); err != io.EOF {
+		tester.Fatal("trace didn't end:", err)
+	}
+}
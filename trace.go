@@ -0,0 +1,178 @@
+/**********************************************************************
+ * Copyright (c) 2017 Jayamine Alupotha                               *
+ * Distributed under the MIT software license, see the accompanying   *
+ * file COPYING or http://www.opensource.org/licenses/mit-license.php.*
+ **********************************************************************/
+
+package txhelper
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// traceMagic starts every trace file
+var traceMagic = [4]byte{'T', 'X', 'H', 'T'}
+
+// traceVersion 2 adds checksums to the records of version 1
+const traceVersion = 2
+
+// TraceHeader describes the client that recorded a trace. Peers can replay the trace only if they decode
+// transactions in the same way.
+type TraceHeader struct {
+	TxModel          int
+	SigType          int32
+	PayloadSize      uint16
+	VariablePayloads bool       // payloads are encoded with their lengths
+	WireFormat       WireFormat // encoding of transactions
+	OrigamiHeaders   bool       // Origami header fields are transmitted
+}
+
+// traceHeader returns the trace header of the context
+func (ctx *ExeContext) traceHeader() TraceHeader {
+	return TraceHeader{TxModel: ctx.txModel, SigType: ctx.sigContext.SigType, PayloadSize: ctx.payloadSize,
+		VariablePayloads: ctx.variablePayloads(), WireFormat: ctx.wireFormat, OrigamiHeaders: ctx.origamiHeaders}
+}
+
+// matches tells whether transactions of the trace can be decoded by ctx
+func (h TraceHeader) matches(ctx *ExeContext) error {
+	own := ctx.traceHeader()
+	if h.TxModel != own.TxModel || h.SigType != own.SigType || h.WireFormat != own.WireFormat ||
+		h.OrigamiHeaders != own.OrigamiHeaders {
+		return fmt.Errorf("%w: trace of model %d and sig type %d can't be replayed with model %d and sig type %d",
+			ErrInvalidConfig, h.TxModel, h.SigType, own.TxModel, own.SigType)
+	}
+	// envelopes carry payload lengths
+	if h.WireFormat == EnvelopeFormat {
+		return nil
+	}
+	if h.VariablePayloads != own.VariablePayloads {
+		return fmt.Errorf("%w: trace and peer use different payload encodings", ErrInvalidConfig)
+	}
+	if !h.VariablePayloads && h.PayloadSize != own.PayloadSize {
+		return fmt.Errorf("%w: trace has payloads of %d bytes, not %d", ErrInvalidConfig, h.PayloadSize, own.PayloadSize)
+	}
+	return nil
+}
+
+// TraceWriter records transactions in the ToBytes format. A trace starts with the magic "TXHT", the version,
+// the transaction model, the signature type, the payload size and flags (1 - variable payloads, 2 - envelopes,
+// 4 - Origami headers). Transactions follow as TxStreamWriter records. Every transaction is written with one call of
+// w.Write, so buffer w if needed.
+type TraceWriter struct {
+	stream *TxStreamWriter
+	header TraceHeader
+	Count  int // number of recorded transactions
+}
+
+// NewTraceWriter writes the trace header of ctx to w
+func NewTraceWriter(w io.Writer, ctx *ExeContext) (*TraceWriter, error) {
+	trace := &TraceWriter{stream: NewTxStreamWriter(w, nil)}
+	if err := trace.writeHeader(ctx.traceHeader()); err != nil {
+		return nil, err
+	}
+	return trace, nil
+}
+
+// writeHeader starts the trace. Models above 255 and signature types above 65535 don't fit in the header.
+func (t *TraceWriter) writeHeader(header TraceHeader) error {
+	if header.TxModel < 0 || header.TxModel > 0xff {
+		return fmt.Errorf("%w: model %d can't be recorded in a trace", ErrInvalidConfig, header.TxModel)
+	}
+	if header.SigType < 0 || header.SigType > 0xffff {
+		return fmt.Errorf("%w: sig type %d can't be recorded in a trace", ErrInvalidConfig, header.SigType)
+	}
+	t.header = header
+	head := make([]byte, 0, 11)
+	head = append(head, traceMagic[:]...)
+	head = append(head, traceVersion, uint8(header.TxModel))
+	head = binary.BigEndian.AppendUint16(head, uint16(header.SigType))
+	head = binary.BigEndian.AppendUint16(head, header.PayloadSize)
+	flags := uint8(0)
+	if header.VariablePayloads {
+		flags |= 1
+	}
+	if header.WireFormat == EnvelopeFormat {
+		flags |= 2
+	}
+	if header.OrigamiHeaders {
+		flags |= 4
+	}
+	head = append(head, flags)
+	_, err := t.stream.w.Write(head)
+	return err
+}
+
+// WriteBytes records an encoded transaction
+func (t *TraceWriter) WriteBytes(txBytes []byte) error {
+	if err := t.stream.WriteBytes(txBytes); err != nil {
+		return err
+	}
+	t.Count++
+	return nil
+}
+
+// TraceReader reads transactions recorded by TraceWriter
+type TraceReader struct {
+	stream *TxStreamReader
+	Header TraceHeader
+}
+
+// NewTraceReader reads the trace header from r. Traces of version 1 don't have checksums.
+func NewTraceReader(r io.Reader) (*TraceReader, error) {
+	trace := &TraceReader{stream: NewTxStreamReader(r, nil)}
+	head := make([]byte, 11)
+	if _, err := io.ReadFull(trace.stream.r, head); err != nil {
+		return nil, fmt.Errorf("%w: trace header: %w", ErrMalformedEncoding, err)
+	}
+	if [4]byte(head[:4]) != traceMagic || head[4] < 1 || head[4] > traceVersion || head[10] > 7 {
+		return nil, fmt.Errorf("%w: invalid trace header", ErrMalformedEncoding)
+	}
+	trace.stream.checksums = head[4] >= 2
+	trace.Header = TraceHeader{
+		TxModel:          int(head[5]),
+		SigType:          int32(binary.BigEndian.Uint16(head[6:8])),
+		PayloadSize:      binary.BigEndian.Uint16(head[8:10]),
+		VariablePayloads: head[10]&1 == 1,
+		WireFormat:       WireFormat(head[10] >> 1 & 1),
+		OrigamiHeaders:   head[10]&4 == 4,
+	}
+	return trace, nil
+}
+
+// Next returns the next encoded transaction, or io.EOF at the end of the trace. The returned bytes are valid until
+// the next call.
+func (t *TraceReader) Next() ([]byte, error) {
+	return t.stream.Next()
+}
+
+// ReplayTrace parses, verifies and stores all transactions of the trace in the peer. It returns the number of
+// replayed transactions.
+func (ctx *ExeContext) ReplayTrace(trace *TraceReader) (int, error) {
+	if ctx.uType != 2 {
+		return 0, ErrNotPeer
+	}
+	if err := trace.Header.matches(ctx); err != nil {
+		return 0, err
+	}
+	for i := 0; ; i++ {
+		txBytes, err := trace.Next()
+		if err == io.EOF { // truncated records are wrapped with ErrMalformedEncoding
+			return i, nil
+		}
+		if err != nil {
+			return i, newTxError("replay trace", i, err)
+		}
+		var tx Transaction
+		if err = ctx.FromBytes(txBytes, &tx); err != nil {
+			return i, newTxError("replay trace", i, err)
+		}
+		if err = ctx.VerifyIncomingTransaction(&tx); err != nil {
+			return i, newTxError("replay trace", i, err)
+		}
+		if err = ctx.CommitTransaction(ctx.TotalTx, &tx); err != nil {
+			return i, newTxError("replay trace", i, err)
+		}
+	}
+}
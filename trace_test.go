@@ -0,0 +1,114 @@
+package txhelper
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestTraceReplay(tester *testing.T) {
+	for i := 1; i <= 6; i++ {
+		var trace bytes.Buffer
+		client := newTestContext(tester, 170, 1, i, 1, 32, 10, 2, 3, 1, false, 2, WithTrace(&trace))
+		for j := 0; j < 10; j++ {
+			tx, err := client.RandomTransaction()
+			if err != nil {
+				tester.Fatal("couldn't create tx:", err)
+			}
+			if err = client.VerifyIncomingTransaction(tx); err != nil {
+				tester.Fatal("invalid transaction in the client:", err)
+			}
+			if err = client.UpdateAppDataClient(&tx.Data); err != nil {
+				tester.Fatal("could not update the client:", err)
+			}
+		}
+		if client.trace.Count != 10 {
+			tester.Fatal("invalid number of recorded transactions:", client.trace.Count)
+		}
+
+		// the same workload with and without indexing
+		for _, indexing := range []bool{false, true} {
+			peer := newTestContext(tester, 170, 2, i, 1, 32, 10, 2, 3, 1, indexing, 2)
+			reader, err := NewTraceReader(bytes.NewReader(trace.Bytes()))
+			if err != nil {
+				tester.Fatal("couldn't read the trace:", err)
+			}
+			if reader.Header.TxModel != i || reader.Header.SigType != 1 || reader.Header.PayloadSize != 32 {
+				tester.Fatal("invalid trace header:", reader.Header)
+			}
+			n, err := peer.ReplayTrace(reader)
+			if err != nil || n != 10 {
+				tester.Fatal("couldn't replay the trace:", n, err)
+			}
+			if err = peer.VerifyStoredAllTransaction(); err != nil {
+				tester.Fatal("invalid blockchain was created:", err)
+			}
+		}
+
+		// peers must decode transactions in the same way
+		peer := newTestContext(tester, 171, 2, i, 1, 64, 10, 2, 3, 1, false, 2)
+		reader, err := NewTraceReader(bytes.NewReader(trace.Bytes()))
+		if err != nil {
+			tester.Fatal("couldn't read the trace:", err)
+		}
+		if _, err = peer.ReplayTrace(reader); !errors.Is(err, ErrInvalidConfig) {
+			tester.Fatal("trace of another payload size was replayed:", err)
+		}
+
+		// truncated traces are malformed
+		peer = newTestContext(tester, 172, 2, i, 1, 32, 10, 2, 3, 1, false, 2)
+		if reader, err = NewTraceReader(bytes.NewReader(trace.Bytes()[:trace.Len()-1])); err != nil {
+			tester.Fatal("couldn't read the trace:", err)
+		}
+		if n, err := peer.ReplayTrace(reader); !errors.Is(err, ErrMalformedEncoding) || n != 9 {
+			tester.Fatal("truncated trace was replayed:", n, err)
+		}
+	}
+	// envelopes can be replayed by peers with other payload sizes
+	var trace bytes.Buffer
+	client := newTestContext(tester, 173, 1, 1, 1, 32, 10, 2, 3, 1, false, 2, WithTrace(&trace), WithWireFormat(EnvelopeFormat))
+	for j := 0; j < 5; j++ {
+		tx, err := client.RandomTransaction()
+		if err != nil {
+			tester.Fatal("couldn't create tx:", err)
+		}
+		if err = client.VerifyIncomingTransaction(tx); err != nil {
+			tester.Fatal("invalid transaction in the client:", err)
+		}
+		if err = client.UpdateAppDataClient(&tx.Data); err != nil {
+			tester.Fatal("could not update the client:", err)
+		}
+	}
+	reader, err := NewTraceReader(bytes.NewReader(trace.Bytes()))
+	if err != nil || reader.Header.WireFormat != EnvelopeFormat {
+		tester.Fatal("couldn't read the trace:", err)
+	}
+	peer := newTestContext(tester, 173, 2, 1, 1, 64, 10, 2, 3, 1, false, 2, WithWireFormat(EnvelopeFormat))
+	if n, err := peer.ReplayTrace(reader); err != nil || n != 5 {
+		tester.Fatal("couldn't replay the trace:", n, err)
+	}
+
+	if _, err := NewTraceReader(bytes.NewReader([]byte("TXHX"))); !errors.Is(err, ErrMalformedEncoding) {
+		tester.Fatal("invalid trace header was accepted:", err)
+	}
+}
+
+func TestTraceHeaderRange(tester *testing.T) {
+	// registered models and signature types that don't fit in the header can't be recorded
+	if err := RegisterTxModel(300, ClassicUTXO{}); err != nil {
+		tester.Fatal("couldn't register the model:", err)
+	}
+	var trace bytes.Buffer
+	if _, err := NewContext(174, 1, 300, 1, 32, 10, 2, 3, 1, false, 2, WithTrace(&trace)); !errors.Is(err, ErrInvalidConfig) {
+		tester.Fatal("model 300 was recorded:", err)
+	}
+	if err := RegisterSignatureScheme(70000, NewKyberSchnorr()); err != nil {
+		tester.Fatal("couldn't register the signature scheme:", err)
+	}
+	if _, err := NewContext(174, 1, 1, 70000, 32, 10, 2, 3, 1, false, 2, WithTrace(&trace)); !errors.Is(err, ErrInvalidConfig) {
+		tester.Fatal("sig type 70000 was recorded:", err)
+	}
+	if trace.Len() != 0 {
+		tester.Fatal("trace header was written")
+	}
+}
@@ -9,11 +9,8 @@ package txhelper
 import (
 	"bytes"
 	"crypto/sha256"
-	"go.dedis.ch/kyber/v3"
-	"golang.org/x/crypto/sha3"
-	"log"
-	"math/rand"
-	"strconv"
+	"encoding/binary"
+	"fmt"
 	"unsafe"
 )
 
@@ -39,38 +36,41 @@ txModel:4 - classicAACC
 txModel:5 - origamiUTXO
 txModel:6 - origamiACC
 */
-func (ctx *ExeContext) RandomTransaction() *Transaction {
+func (ctx *ExeContext) RandomTransaction() (*Transaction, error) {
 	// variable sizes
-	inSize := uint8(rand.Int() % int(ctx.AverageInputMax+1))
-	outSize := uint8(rand.Int()%int(ctx.AverageOutputMax)) + 1
+	shape := ctx.shapes.Draw(ctx.rand)
 
-	var tx = new(Transaction)
-	ctx.RandomAppData(&tx.Data, inSize, outSize, ctx.payloadSize)
-	ctx.CreateTxHeader(&tx.Txh, &tx.Data)
-	return tx
+	return ctx.FixedTransaction(shape.Inputs, shape.Outputs)
 }
 
-func (ctx *ExeContext) FixedTransaction(inSize uint8, outSize uint8) *Transaction {
-	// variable sizes
+// FixedTransaction outputs a transaction with the given number of inputs and outputs if there are enough users
+func (ctx *ExeContext) FixedTransaction(inSize uint8, outSize uint8) (*Transaction, error) {
 	var tx = new(Transaction)
-	ctx.RandomAppData(&tx.Data, inSize, outSize, ctx.payloadSize)
-	ctx.CreateTxHeader(&tx.Txh, &tx.Data)
-	return tx
+	if err := ctx.RandomAppData(&tx.Data, inSize, outSize, ctx.payloadSize); err != nil {
+		return nil, err
+	}
+	if err := ctx.CreateTxHeader(&tx.Txh, &tx.Data); err != nil {
+		return nil, err
+	}
+	if ctx.trace != nil {
+		if err := ctx.trace.WriteBytes(ctx.ToBytes(tx)); err != nil {
+			return nil, err
+		}
+	}
+	return tx, nil
 }
 
-func (ctx *ExeContext) checkUniqueness(tx *Transaction) (bool, *string) {
+func (ctx *ExeContext) checkUniqueness(tx *Transaction) error {
 	// unique headers
 	for j := 0; j < len(tx.Data.Inputs); j++ {
 		for l := j + 1; l < len(tx.Data.Inputs); l++ {
 			if bytes.Equal(tx.Data.Inputs[j].Header, tx.Data.Inputs[l].Header) {
-				errM := " duplicate headers in inputs : " + strconv.FormatInt(int64(ctx.txModel), 10)
-				return false, &errM
+				return newTxError("check inputs", l, ErrDuplicateInput)
 			}
 		}
 		for l := 0; l < len(tx.Data.Outputs); l++ {
 			if bytes.Equal(tx.Data.Outputs[l].u.H, tx.Data.Inputs[j].Header) {
-				errM := " duplicate headers in outputs : " + strconv.FormatInt(int64(ctx.txModel), 10)
-				return false, &errM
+				return newTxError("check outputs", l, ErrDuplicateOutput)
 			}
 		}
 	}
@@ -85,315 +85,346 @@ func (ctx *ExeContext) checkUniqueness(tx *Transaction) (bool, *string) {
 				// then check db
 				foundDB, _ := ctx.usedPeerOutHeader(tx.Data.Outputs[i].header)
 				if foundDB {
-					errM := " reused headers in outputs : " + strconv.FormatInt(int64(ctx.txModel), 10)
-					return false, &errM
+					return newTxError("check outputs", i, ErrDuplicateOutput)
 				}
 			} else {
-				errM := " reused headers in outputs : " + strconv.FormatInt(int64(ctx.txModel), 10)
-				return false, &errM
+				return newTxError("check outputs", i, ErrDuplicateOutput)
 			}
 		}
 	}
 
-	if (ctx.txModel == 2 || ctx.txModel == 4 || ctx.txModel == 6) && ctx.uType == 2 {
+	if ctx.model.IsAccount() && ctx.uType == 2 {
 		for j := len(tx.Data.Inputs); j < len(tx.Data.Outputs); j++ {
 			found, _ := ctx.usedPeerOutPublicKey(tx.Data.Outputs[j].Pk)
 			if found {
-				errM := "used public keys in new accounts"
-				return false, &errM
+				return newTxError("check outputs", j, ErrReusedPublicKey)
 			}
 		}
 	}
 
 	// unique accounts
-	if ctx.txModel == 2 || ctx.txModel == 4 || ctx.txModel == 6 {
+	if ctx.model.IsAccount() {
 		for j := 0; j < len(tx.Data.Outputs); j++ {
 			for l := j + 1; l < len(tx.Data.Outputs); l++ {
 				if bytes.Equal(tx.Data.Outputs[j].Pk, tx.Data.Outputs[l].Pk) {
-					errM := "duplicate pk in outputs"
-					return false, &errM
+					return newTxError("check outputs", l, ErrReusedPublicKey)
 				}
 			}
 		}
 	}
-	return true, nil
+	return nil
 }
 
 // VerifyIncomingTransaction verifies a raw transaction
-func (ctx *ExeContext) VerifyIncomingTransaction(tx *Transaction) (bool, *string) {
+func (ctx *ExeContext) VerifyIncomingTransaction(tx *Transaction) error {
+	var err error
 	if ctx.uType == 2 {
-		_, err := ctx.PrepareAppDataPeer(&tx.Data)
-		if err != nil {
-			errM := err.Error()
-			return false, &errM
-		}
+		err = ctx.PrepareAppDataPeer(&tx.Data)
 	} else if ctx.uType == 1 {
-		_, err := ctx.PrepareAppDataClient(&tx.Data)
-		if err != nil {
-			errM := err.Error()
-			return false, &errM
-		}
+		err = ctx.PrepareAppDataClient(&tx.Data)
+	}
+	if err != nil {
+		return err
 	}
 
-	ok, err := ctx.checkUniqueness(tx)
-	if !ok {
-		return false, err
+	if err = ctx.checkUniqueness(tx); err != nil {
+		return err
 	}
 
 	return ctx.VerifyTxHeader(&tx.Txh, &tx.Data)
 }
 
 // VerifyIncomingTransactionWithTemp verifies a raw transaction including temps
-func (ctx *ExeContext) VerifyIncomingTransactionWithTemp(tx *Transaction) (bool, *string) {
+func (ctx *ExeContext) VerifyIncomingTransactionWithTemp(tx *Transaction) error {
+	var err error
 	if ctx.uType == 2 {
-		_, err := ctx.PrepareAppDataPeerWithTemps(&tx.Data)
-		if err != nil {
-			errM := err.Error()
-			return false, &errM
-		}
+		err = ctx.PrepareAppDataPeerWithTemps(&tx.Data)
 	} else if ctx.uType == 1 {
-		_, err := ctx.PrepareAppDataClient(&tx.Data)
-		if err != nil {
-			errM := err.Error()
-			return false, &errM
-		}
+		err = ctx.PrepareAppDataClient(&tx.Data)
+	}
+	if err != nil {
+		return err
 	}
 
-	ok, err := ctx.checkUniqueness(tx)
-	if !ok {
-		return false, err
+	if err = ctx.checkUniqueness(tx); err != nil {
+		return err
 	}
 
 	return ctx.VerifyTxHeader(&tx.Txh, &tx.Data)
 }
 
-// InsertTxHeader adds a transaction header, which was verified before.
-func (ctx *ExeContext) InsertTxHeader(txn int, tx *Transaction) (bool, *string) {
-	if ctx.uType == 2 {
-		ok, err := ctx.insertPeerTxHeader(txn, tx)
-		if !ok {
-			errM := "could not insert header:" + err.Error()
-			return false, &errM
-		}
-		ctx.TotalTx += 1
-	} else {
-		log.Fatal("only peers can add txHeaders")
+// CommitTransaction applies a verified transaction and saves its header in one SQL transaction. If it fails, neither
+// the database nor the peer counters are changed.
+func (ctx *ExeContext) CommitTransaction(txn int, tx *Transaction) error {
+	if ctx.uType != 2 {
+		return ErrNotPeer
 	}
-	return true, nil
+	return ctx.atomic(func() error {
+		if err := ctx.applyTx(txn, tx); err != nil {
+			return err
+		}
+		return ctx.insertTxHeader(txn, tx)
+	})
 }
 
-// GetTxHeaderIdentifier outputs an identifier a special hash to be included into the tx block hash computation
-// for classics: hash is the hash of the entire transaction
-// for origami: hash is the hash of (activity, all account pks)
-func (ctx *ExeContext) GetTxHeaderIdentifier(tx *Transaction, txBytes []byte) (bool, []byte, *string) {
-	var txIdentifier []byte
-	if ctx.txModel >= 1 && ctx.txModel <= 4 {
-		if txBytes == nil {
-			txBytes = ctx.ToBytes(tx)
-		}
-		hasher := sha3.New256()
-		txIdentifier = hasher.Sum(txBytes)
-	} else if ctx.txModel == 5 {
-		hasher := sha3.New256()
-		if len(tx.Txh.activityProof) != 33 {
-			errM := "activity is empty. Did yoy verify the transaction?"
-			return false, nil, &errM
-		}
-		hasher.Write(tx.Txh.activityProof)
-		hasher.Write(tx.Txh.excessPK)
-		txIdentifier = hasher.Sum(tx.Txh.Kyber[0])
-	} else if ctx.txModel == 6 {
-		hasher := sha3.New256()
-		if len(tx.Txh.activityProof) != 33 {
-			errM := "activity is empty. Did yoy verify the transaction?"
-			return false, nil, &errM
-		}
-		hasher.Write(tx.Txh.activityProof)
-		for i := 0; i < len(tx.Data.Outputs); i++ {
-			hasher.Write(tx.Data.Outputs[i].Pk)
-		}
-		txIdentifier = hasher.Sum(nil)
+// insertTxHeader saves the header of an applied transaction
+func (ctx *ExeContext) insertTxHeader(txn int, tx *Transaction) error {
+	if err := ctx.model.StoreTxHeader(ctx, txn, tx); err != nil {
+		return newTxError("insert tx header", txn, err)
 	}
-	return true, txIdentifier, nil
+	ctx.TotalTx += 1
+	return nil
 }
 
 // VerifyStoredAllTransaction verifies all stored transactions
-func (ctx *ExeContext) VerifyStoredAllTransaction() (bool, *string) {
-
-	if ctx.txModel >= 1 && ctx.txModel <= 4 {
-		//set used to 0
-		used := make([]uint8, ctx.CurrentOutputs)
-		usedHeader := make([][]byte, ctx.CurrentOutputs)
-		//verify all tx from 0 while resetting used
-		for i := 0; i < ctx.TotalTx; i++ {
-			tx, ok, err := ctx.getStoredTx(i)
-			if !ok {
-				errM := err.Error()
-				return false, &errM
+func (ctx *ExeContext) VerifyStoredAllTransaction() error {
+	return ctx.model.AuditChain(ctx)
+}
+
+// verifyStoredClassic verifies all stored transactions one by one (models 1-4)
+func (ctx *ExeContext) verifyStoredClassic() error {
+	//set used to 0
+	used := make([]uint8, ctx.CurrentOutputs)
+	usedHeader := make([][]byte, ctx.CurrentOutputs)
+	txns, err := ctx.storedTxns()
+	if err != nil {
+		return err
+	}
+	// headers of aggregated blocks are verified with the signatures of all blocks
+	if ctx.blockAggregation {
+		ctx.blockSigs = &blockSigs{}
+		defer func() { ctx.blockSigs = nil }()
+	}
+	//verify all tx from 0 while resetting used
+	for _, txn := range txns {
+		tx, ok, err := ctx.getStoredTx(txn)
+		if !ok {
+			return newTxError("get stored tx", txn, err)
+		}
+		// unique headers
+		for j := 0; j < len(tx.Data.Inputs); j++ {
+			//check if they were used before
+			if used[tx.Data.Inputs[j].u.id] != 0 && bytes.Equal(usedHeader[tx.Data.Inputs[j].u.id], tx.Data.Inputs[j].Header) {
+				return newTxError("verify stored tx", txn, ErrDoubleSpend)
 			}
+			used[tx.Data.Inputs[j].u.id] += 1
+			usedHeader[tx.Data.Inputs[j].u.id] = tx.Data.Inputs[j].Header
+
 			// unique headers
-			for j := 0; j < len(tx.Data.Inputs); j++ {
-				//check if they were used before
-				if used[tx.Data.Inputs[j].u.id] != 0 && bytes.Equal(usedHeader[tx.Data.Inputs[j].u.id], tx.Data.Inputs[j].Header) {
-					errM := "double spent inputs"
-					return false, &errM
+			for l := j + 1; l < len(tx.Data.Inputs); l++ {
+				if bytes.Equal(tx.Data.Inputs[j].Header, tx.Data.Inputs[l].Header) {
+					return newTxError("verify stored tx", txn, ErrDuplicateInput)
 				}
-				used[tx.Data.Inputs[j].u.id] += 1
-				usedHeader[tx.Data.Inputs[j].u.id] = tx.Data.Inputs[j].Header
-
-				// unique headers
-				for l := j + 1; l < len(tx.Data.Inputs); l++ {
-					if bytes.Equal(tx.Data.Inputs[j].Header, tx.Data.Inputs[l].Header) {
-						errM := "duplicate headers in inputs"
-						return false, &errM
-					}
-				}
-				for l := 0; l < len(tx.Data.Outputs); l++ {
-					if bytes.Equal(tx.Data.Inputs[j].Header, tx.Data.Outputs[l].u.H) {
-						errM := "duplicate headers in inputs/outputs"
-						return false, &errM
-					}
+			}
+			for l := 0; l < len(tx.Data.Outputs); l++ {
+				if bytes.Equal(tx.Data.Inputs[j].Header, tx.Data.Outputs[l].u.H) {
+					return newTxError("verify stored tx", txn, ErrDuplicateOutput)
 				}
 			}
-			// unique accounts
-			if ctx.txModel == 2 || ctx.txModel == 4 || ctx.txModel == 6 {
-				for j := 0; j < len(tx.Data.Outputs); j++ {
-					for l := j + 1; l < len(tx.Data.Outputs); l++ {
-						if bytes.Equal(tx.Data.Outputs[j].Pk, tx.Data.Outputs[l].Pk) {
-							errM := "duplicate accounts"
-							return false, &errM
-						}
+		}
+		// unique accounts
+		if ctx.model.IsAccount() {
+			for j := 0; j < len(tx.Data.Outputs); j++ {
+				for l := j + 1; l < len(tx.Data.Outputs); l++ {
+					if bytes.Equal(tx.Data.Outputs[j].Pk, tx.Data.Outputs[l].Pk) {
+						return newTxError("verify stored tx", txn, ErrReusedPublicKey)
 					}
 				}
 			}
-			val, errM := ctx.VerifyTxHeader(&tx.Txh, &tx.Data)
-			if !val {
-				return false, errM
-			}
 		}
-
-	} else if ctx.txModel == 5 {
-		temp := C.BN_new()
-		totalD := C.BN_new()
-		C.BN_bin2bn((*C.uchar)(unsafe.Pointer(&ctx.bnOne[0])), 33, temp)
-		C.BN_copy(totalD, temp)
-		var txh TxHeader
-		var pk Pubkey
-		var totalExcess kyber.Point
-		buffer := make([]byte, 33+ctx.sigContext.PkSize)
-		for i := 0; i < ctx.TotalTx; i++ {
-			val, err := ctx.getTxHeader(i, &txh)
-			if !val {
-				errM := "Error in tx header data:" + err.Error()
-				return false, &errM
-			}
-			copy(buffer, txh.activityProof)
-			copy(buffer[33:], txh.excessPK)
-
-			ctx.sigContext.unmarshelPublicKeysFromBytes(&pk, txh.excessPK)
-			if !ctx.sigContext.verify(&pk, buffer, txh.Kyber[0]) {
-				err := "invalid sig"
-				return false, &err
-			}
-			if i == 0 {
-				totalExcess = pk.kyber.Clone()
-			} else {
-				totalExcess.Add(totalExcess, pk.kyber)
-			}
-			C.BN_bin2bn((*C.uchar)(unsafe.Pointer(&txh.activityProof[0])), 33, temp)
-			C.BN_mod_mul(totalD, totalD, temp, ctx.bnQ, ctx.bnCtx)
+		if err = ctx.VerifyTxHeader(&tx.Txh, &tx.Data); err != nil {
+			return newTxError("verify stored tx", txn, err)
 		}
+	}
+	if ctx.blockAggregation {
+		return ctx.verifyStoredBlockSigs()
+	}
+	return nil
+}
 
-		val, excessBytes, HProd, err := ctx.getAggregateOutData()
+// verifyStoredOrigamiUtxo verifies stored headers against the aggregated outputs (model 5)
+func (ctx *ExeContext) verifyStoredOrigamiUtxo() error {
+	temp := C.BN_new()
+	totalD := C.BN_new()
+	C.BN_bin2bn((*C.uchar)(unsafe.Pointer(&ctx.bnOne[0])), 33, temp)
+	C.BN_copy(totalD, temp)
+	var txh TxHeader
+	var excessKeys []*Pubkey
+	buffer := make([]byte, 33+ctx.sigContext.PkSize)
+	txns, err := ctx.storedTxns()
+	if err != nil {
+		return err
+	}
+	for _, txn := range txns {
+		val, err := ctx.getTxHeader(txn, &txh)
 		if !val {
-			errM := "Error in aggregate data:" + err.Error()
-			return false, &errM
+			return newTxError("get stored tx", txn, err)
+		}
+		if len(txh.activityProof) != 33 {
+			return newTxError("verify stored tx", txn, ErrInvalidActivity)
+		}
+		copy(buffer, txh.activityProof)
+		copy(buffer[33:], txh.excessPK)
+
+		var pk Pubkey
+		if err = ctx.sigContext.unmarshelPublicKeysFromBytes(&pk, txh.excessPK); err != nil {
+			return newTxError("verify stored tx", txn, err)
+		}
+		if !ctx.sigContext.verify(&pk, buffer, txh.Kyber[0]) {
+			return newTxError("verify stored tx", txn, ErrInvalidSignature)
 		}
+		excessKeys = append(excessKeys, &pk)
+		C.BN_bin2bn((*C.uchar)(unsafe.Pointer(&txh.activityProof[0])), 33, temp)
+		C.BN_mod_mul(totalD, totalD, temp, ctx.bnQ, ctx.bnCtx)
+	}
+
+	val, excessBytes, HProd, err := ctx.getAggregateOutData()
+	if !val {
+		return newTxError("aggregate outputs", -1, err)
+	}
 
-		userHProd := make([]byte, 33)
-		C.BN_bn2binpad(totalD, (*C.uchar)(unsafe.Pointer(&userHProd[0])), 33)
-		if !bytes.Equal(userHProd, HProd) {
-			errM := "Error in aggregate delta"
-			return false, &errM
+	userHProd := make([]byte, 33)
+	C.BN_bn2binpad(totalD, (*C.uchar)(unsafe.Pointer(&userHProd[0])), 33)
+	if !bytes.Equal(userHProd, HProd) {
+		return newTxError("verify aggregate activity", -1, ErrInvalidChain)
+	}
+
+	if len(excessKeys) == 0 {
+		return newTxError("aggregate excess keys", -1, ErrInvalidChain)
+	}
+	userHProd, err = ctx.sigContext.diffPK(excessKeys, nil)
+	if err != nil {
+		return newTxError("aggregate excess keys", -1, err)
+	}
+	if !bytes.Equal(excessBytes, userHProd) {
+		return newTxError("verify aggregate excess key", -1, ErrInvalidChain)
+	}
+	return nil
+}
+
+// verifyStoredOrigamiAcc verifies stored accounts against the activity proofs (model 6)
+func (ctx *ExeContext) verifyStoredOrigamiAcc() error {
+	var user User
+	temp := C.BN_new()
+	d := C.BN_new()
+	C.BN_set_bit(temp, 255)
+	C.BN_set_bit(d, 255)
+
+	activities, activityProd, err := ctx.setActivityTable()
+	if err != nil {
+		return newTxError("set activity table", -1, err)
+	}
+	for i := 0; i < ctx.CurrentUsers; i++ {
+		found, _, err := ctx.getPeerOutFromID(i, &user)
+		if !found {
+			return newTxError("get user", i, err)
+		}
+		// check the validity of activities
+		if !bytes.Equal(activities[i].Bytes(), user.UDelta) {
+			return newTxError("verify user activities", i, ErrInvalidActivity)
+		}
+		if int(user.N) != len(user.Txns) {
+			return newTxError("verify user transactions", i, ErrInvalidChain)
 		}
+		// prod of user identifiers
+		C.BN_bin2bn((*C.uchar)(unsafe.Pointer(&user.H[0])), 32, temp)
+		C.BN_mod_mul(d, d, temp, ctx.bnQ, ctx.bnCtx)
+		C.BN_clear(temp)
+
+		// signature
+		buf := new(bytes.Buffer)
+		var pk Pubkey
+
+		buf.Write(user.Keys)
+		buf.WriteByte(user.N)
+		buf.Write(user.Data)
+		buf.Write(user.UDelta)
 
-		userHProd, err = totalExcess.MarshalBinary()
-		if err != nil {
-			errM := "Error while aggregating pk" + err.Error()
-			return false, &errM
+		//buf.Write(ctx.computeUserWmark(user.UDelta, user.H))
+		if err = ctx.sigContext.unmarshelPublicKeysFromBytes(&pk, user.Keys); err != nil {
+			return newTxError("verify user", i, err)
 		}
-		if !bytes.Equal(excessBytes, userHProd) {
-			errM := "Error in aggregate pk"
-			return false, &errM
+		if ctx.sigContext.verify(&pk, buf.Bytes(), user.sig) == false {
+			return newTxError("verify user", i, ErrInvalidSignature)
 		}
+		buf.Reset()
+	}
+	// prof activities ?= prod user identifiers
+	userHProd := make([]byte, 33)
+	C.BN_bn2binpad(d, (*C.uchar)(unsafe.Pointer(&userHProd[0])), 33)
+	if !bytes.Equal(userHProd, activityProd) {
+		return newTxError("verify aggregate activity", -1, ErrInvalidActivity)
+	}
+	return nil
+}
 
-	} else if ctx.txModel == 6 {
-		var user User
-		temp := C.BN_new()
-		d := C.BN_new()
-		C.BN_set_bit(temp, 255)
-		C.BN_set_bit(d, 255)
+// ToBytes encodes a transaction according to the tx model, or in EnvelopeFormat if it is set with WithWireFormat.
+// With WithOrigamiHeaders, the activity proof and the excess public key follow the transaction.
+func (ctx *ExeContext) ToBytes(tx *Transaction) []byte {
+	if ctx.wireFormat == EnvelopeFormat {
+		return ctx.encodeEnvelope(tx, ctx.origamiHeaders)
+	}
+	txBytes := ctx.model.Encode(ctx, tx)
+	if ctx.origamiHeaders {
+		txBytes = append(append(txBytes, tx.Txh.activityProof...), tx.Txh.excessPK...)
+	}
+	return txBytes
+}
 
-		activities, activityProd, err := ctx.setActivityTable()
-		if err != nil {
-			errM := err.Error()
-			return false, &errM
+// FromBytes parses a transaction created by ToBytes. Malformed transactions give ErrMalformedEncoding, and envelopes
+// also give a DecodeError with the failed field.
+func (ctx *ExeContext) FromBytes(arr []byte, tx *Transaction) error {
+	if ctx.wireFormat == EnvelopeFormat {
+		return ctx.decodeEnvelope(arr, tx)
+	}
+	if ctx.origamiHeaders {
+		activitySize, excessSize := ctx.origamiHeaderSizes()
+		if len(arr) < activitySize+excessSize {
+			return fmt.Errorf("%w: missing origami header", ErrMalformedEncoding)
 		}
-		for i := 0; i < ctx.CurrentUsers; i++ {
-			found, _, err := ctx.getPeerOutFromID(i, &user)
-			if !found {
-				errM := "user doesn't exist:" + err.Error()
-				return false, &errM
-			}
-			// check the validity of activities
-			if !bytes.Equal(activities[i].Bytes(), user.UDelta) {
-				errM := "total user activities do not match"
-				return false, &errM
-			}
-			if int(user.N) != len(user.Txns) {
-				errM := "total user transaction count does not match"
-				return false, &errM
-			}
-			// prod of user identifiers
-			C.BN_bin2bn((*C.uchar)(unsafe.Pointer(&user.H[0])), 32, temp)
-			C.BN_mod_mul(d, d, temp, ctx.bnQ, ctx.bnCtx)
-			C.BN_clear(temp)
-
-			// signature
-			buf := new(bytes.Buffer)
-			keybuffer := new(bytes.Buffer)
-			var pk Pubkey
-
-			buf.Write(user.Keys)
-			buf.WriteByte(user.N)
-			buf.Write(user.Data)
-			buf.Write(user.UDelta)
-
-			//buf.Write(ctx.computeUserWmark(user.UDelta, user.H))
-			if ctx.sigContext.SigType == 1 || ctx.sigContext.SigType == 2 {
-				keybuffer.Write(user.Keys)
-				ctx.sigContext.unmarshelPublicKeys(&pk, keybuffer)
-				if ctx.sigContext.verify(&pk, buf.Bytes(), user.sig) == false {
-					errM := "invalid sig " + strconv.FormatInt(int64(i), 10)
-					return false, &errM
-				}
-				keybuffer.Reset()
-			}
-			buf.Reset()
+		headers := arr[len(arr)-activitySize-excessSize:]
+		arr = arr[:len(arr)-activitySize-excessSize]
+		if err := ctx.model.Decode(ctx, arr, tx); err != nil {
+			return err
 		}
-		// prof activities ?= prod user identifiers
-		userHProd := make([]byte, 33)
-		C.BN_bn2binpad(d, (*C.uchar)(unsafe.Pointer(&userHProd[0])), 33)
-		if !bytes.Equal(userHProd, activityProd) {
-			errM := "products of activities do not match"
-			return false, &errM
+		tx.Txh.activityProof = append([]byte(nil), headers[:activitySize]...)
+		if excessSize > 0 {
+			tx.Txh.excessPK = append([]byte(nil), headers[activitySize:]...)
 		}
+		return nil
 	}
+	return ctx.model.Decode(ctx, arr, tx)
+}
 
-	return true, nil
+// origamiHeaderSizes returns the sizes of the activity proof and the excess public key that peers recompute
+func (ctx *ExeContext) origamiHeaderSizes() (int, int) {
+	if !ctx.model.IsOrigami() {
+		return 0, 0
+	}
+	if ctx.model.IsAccount() {
+		return 33, 0
+	}
+	return 33, int(ctx.sigContext.PkSize)
 }
 
-func (ctx *ExeContext) ToBytes(tx *Transaction) []byte {
+// TxSizes returns the encoded size of a transaction without and with the Origami header fields (the activity proof
+// and, in model 5, the excess public key). Both sizes are the same for classic models.
+func (ctx *ExeContext) TxSizes(tx *Transaction) (int, int) {
+	if ctx.wireFormat == EnvelopeFormat {
+		return len(ctx.encodeEnvelope(tx, false)), len(ctx.encodeEnvelope(tx, ctx.model.IsOrigami()))
+	}
+	activitySize, excessSize := ctx.origamiHeaderSizes()
+	size := len(ctx.model.Encode(ctx, tx))
+	return size, size + activitySize + excessSize
+}
+
+// encodeTx outputs inputs, outputs and signatures. Outputs that update accounts do not carry public keys.
+func (ctx *ExeContext) encodeTx(tx *Transaction) []byte {
+	return ctx.encodeCompact(tx, ctx.variablePayloads())
+}
+
+// encodeCompact outputs the compact encoding of encodeTx with length-prefixed payloads if variable is set
+func (ctx *ExeContext) encodeCompact(tx *Transaction, variable bool) []byte {
 	buffer := new(bytes.Buffer)
 
 	buffer.WriteByte(uint8(len(tx.Data.Inputs) % 0xff))
@@ -403,23 +434,33 @@ func (ctx *ExeContext) ToBytes(tx *Transaction) []byte {
 		buffer.Write(tx.Data.Inputs[i].Header)
 	}
 	for i := 0; i < len(tx.Data.Outputs); i++ {
-		if i >= len(tx.Data.Inputs) || ctx.txModel == 1 || ctx.txModel == 3 || ctx.txModel == 5 {
+		if i >= len(tx.Data.Inputs) || !ctx.model.IsAccount() {
 			buffer.Write(tx.Data.Outputs[i].Pk)
 			buffer.WriteByte(tx.Data.Outputs[i].N)
 		}
+		if variable {
+			buffer.Write(binary.AppendUvarint(nil, uint64(len(tx.Data.Outputs[i].Data))))
+		}
 		buffer.Write(tx.Data.Outputs[i].Data)
 	}
 	buffer.WriteByte(uint8(len(tx.Txh.Kyber) % 0xff))
+	variableSigs := ctx.sigContext.variableAggregates()
 	for i := 0; i < len(tx.Txh.Kyber); i++ {
+		if variableSigs {
+			var size [2]byte
+			binary.BigEndian.PutUint16(size[:], uint16(len(tx.Txh.Kyber[i])))
+			buffer.Write(size[:])
+		}
 		buffer.Write(tx.Txh.Kyber[i])
 	}
 
 	return buffer.Bytes()
 }
 
-func (ctx *ExeContext) FromBytes(arr []byte, tx *Transaction) bool {
+// decodeTx parses a transaction created by encodeTx
+func (ctx *ExeContext) decodeTx(arr []byte, tx *Transaction) error {
 	if len(arr) <= 2 {
-		return false
+		return fmt.Errorf("%w: too short", ErrMalformedEncoding)
 	}
 	var i uint8
 	pointer := 0
@@ -431,7 +472,7 @@ func (ctx *ExeContext) FromBytes(arr []byte, tx *Transaction) bool {
 	pointer += 1
 
 	if len(arr) < pointer+sha256.Size*int(inSize) {
-		return false
+		return fmt.Errorf("%w: truncated inputs", ErrMalformedEncoding)
 	}
 
 	for i = 0; i < inSize; i++ {
@@ -440,18 +481,29 @@ func (ctx *ExeContext) FromBytes(arr []byte, tx *Transaction) bool {
 		pointer += sha256.Size
 	}
 
-	if ctx.txModel == 1 || ctx.txModel == 3 || ctx.txModel == 5 {
-		if len(arr) < pointer+(int(ctx.sigContext.PkSize)+1)*(int(outSize)-int(inSize))+int(ctx.payloadSize)*int(outSize) {
-			return false
-		}
-	} else {
-		if len(arr) < pointer+int(ctx.payloadSize)*int(outSize) {
-			return false
+	// outputs of utxo models and new accounts carry public keys
+	newKeys := int(outSize)
+	if ctx.model.IsAccount() {
+		newKeys = int(outSize) - int(inSize)
+		if newKeys < 0 {
+			newKeys = 0
 		}
 	}
+	// variable payloads are prefixed with their lengths, so only keys can be checked here
+	variable := ctx.variablePayloads()
+	payloads := int(ctx.payloadSize) * int(outSize)
+	if variable {
+		payloads = 0
+	}
+	if len(arr) < pointer+(int(ctx.sigContext.PkSize)+1)*newKeys+payloads {
+		return fmt.Errorf("%w: truncated outputs", ErrMalformedEncoding)
+	}
 
 	for i = 0; i < outSize; i++ {
-		if int(i) >= len(tx.Data.Inputs) || ctx.txModel == 1 || ctx.txModel == 3 || ctx.txModel == 5 {
+		if int(i) >= len(tx.Data.Inputs) || !ctx.model.IsAccount() {
+			if len(arr) < pointer+int(ctx.sigContext.PkSize)+1 {
+				return fmt.Errorf("%w: truncated outputs", ErrMalformedEncoding)
+			}
 			tx.Data.Outputs[i].Pk = make([]byte, ctx.sigContext.PkSize)
 			copy(tx.Data.Outputs[i].Pk, arr[pointer:])
 			pointer += int(ctx.sigContext.PkSize)
@@ -460,27 +512,58 @@ func (ctx *ExeContext) FromBytes(arr []byte, tx *Transaction) bool {
 			pointer += 1
 		}
 
-		tx.Data.Outputs[i].Data = make([]byte, ctx.payloadSize)
+		size := int(ctx.payloadSize)
+		if variable {
+			length, n := binary.Uvarint(arr[pointer:])
+			if n <= 0 || length > MaxPayloadSize || len(arr) < pointer+n+int(length) {
+				return newTxError("decode payload", int(i), fmt.Errorf("%w: invalid payload length", ErrMalformedEncoding))
+			}
+			pointer += n
+			size = int(length)
+		}
+		tx.Data.Outputs[i].Data = make([]byte, size)
 		copy(tx.Data.Outputs[i].Data, arr[pointer:])
-		pointer += int(ctx.payloadSize)
+		pointer += size
 	}
 
 	if len(arr) < pointer+1 {
-		return false
+		return fmt.Errorf("%w: missing signatures", ErrMalformedEncoding)
 	}
 
 	sigSize := arr[pointer]
 	pointer += 1
 
-	if len(arr) < pointer+int(sigSize)*int(ctx.sigContext.SigSize) {
-		return false
+	// signatures of variable aggregate schemes are prefixed with their 2-byte length
+	if ctx.sigContext.variableAggregates() {
+		tx.Txh.Kyber = make([]Signature, sigSize)
+		for i = 0; i < sigSize; i++ {
+			if len(arr) < pointer+2 {
+				return fmt.Errorf("%w: truncated signatures", ErrMalformedEncoding)
+			}
+			size := int(binary.BigEndian.Uint16(arr[pointer:]))
+			pointer += 2
+			if len(arr) < pointer+size {
+				return fmt.Errorf("%w: truncated signatures", ErrMalformedEncoding)
+			}
+			tx.Txh.Kyber[i] = make([]byte, size)
+			copy(tx.Txh.Kyber[i], arr[pointer:])
+			pointer += size
+		}
+	} else {
+		if len(arr) < pointer+int(sigSize)*int(ctx.sigContext.SigSize) {
+			return fmt.Errorf("%w: truncated signatures", ErrMalformedEncoding)
+		}
+
+		tx.Txh.Kyber = make([]Signature, sigSize)
+		for i = 0; i < sigSize; i++ {
+			tx.Txh.Kyber[i] = make([]byte, ctx.sigContext.SigSize)
+			copy(tx.Txh.Kyber[i], arr[pointer:])
+			pointer += int(ctx.sigContext.SigSize)
+		}
 	}
 
-	tx.Txh.Kyber = make([]Signature, sigSize)
-	for i = 0; i < sigSize; i++ {
-		tx.Txh.Kyber[i] = make([]byte, ctx.sigContext.SigSize)
-		copy(tx.Txh.Kyber[i], arr[pointer:])
-		pointer += int(ctx.sigContext.SigSize)
+	if pointer != len(arr) {
+		return fmt.Errorf("%w: %d trailing bytes", ErrMalformedEncoding, len(arr)-pointer)
 	}
-	return true
+	return nil
 }
@@ -3,37 +3,52 @@ package txhelper
 import (
 	"fmt"
 	"log"
-	"math/rand"
 	"os"
-	"strconv"
 	"testing"
 	"time"
 )
 
+// newTestContext creates a context or stops the test. The database is created in a temporary directory of the test
+// unless opts select another one with WithDataDir.
+func newTestContext(tester testing.TB, exeId int, uType int, txType int, sigType int32, averageSize uint16, totalUsers int,
+	averageInputMax uint8, averageOutputMax uint8, distributionType int, enableIndexing bool, publicKeyReuse int, opts ...Option) ExeContext {
+	opts = append([]Option{WithDataDir(tester.TempDir())}, opts...)
+	ctx, err := NewContext(exeId, uType, txType, sigType, averageSize, totalUsers, averageInputMax, averageOutputMax,
+		distributionType, enableIndexing, publicKeyReuse, opts...)
+	if err != nil {
+		tester.Fatal("couldn't create the context:", err)
+	}
+	return ctx
+}
+
 func (ctx *ExeContext) testClientTransactions(num int, tester *testing.T) {
 	var txBytes []byte
 	var tx1 Transaction
 	for i := 0; i < num; i++ {
-		tx := ctx.RandomTransaction()
+		tx, err := ctx.RandomTransaction()
+		if err != nil {
+			tester.Fatal("couldn't create tx:", err, ctx.txModel)
+		}
 
 		txBytes = ctx.ToBytes(tx)
-		ok := ctx.FromBytes(txBytes, &tx1)
-		if !ok {
-			tester.Fatal("couldn't parse tx:", ctx.txModel, ctx.sigContext.SigType)
+		if err := ctx.FromBytes(txBytes, &tx1); err != nil {
+			tester.Fatal("couldn't parse tx:", err, ctx.txModel, ctx.sigContext.SigType)
 		}
 
-		val, err := ctx.VerifyIncomingTransaction(tx)
-		if !val {
-			tester.Fatal("invalid transaction creation:"+*err, ctx.txModel, ctx.sigContext.SigType)
+		if err := ctx.VerifyIncomingTransaction(tx); err != nil {
+			tester.Fatal("invalid transaction creation:", err, ctx.txModel, ctx.sigContext.SigType)
 		}
 
-		ctx.PrepareAppDataClient(&tx1.Data)
-		val, err = ctx.VerifyIncomingTransaction(&tx1)
-		if !val {
-			tester.Fatal("invalid transaction convertion:"+*err, ctx.txModel)
+		if err := ctx.PrepareAppDataClient(&tx1.Data); err != nil {
+			tester.Fatal("couldn't prepare tx:", err, ctx.txModel)
+		}
+		if err := ctx.VerifyIncomingTransaction(&tx1); err != nil {
+			tester.Fatal("invalid transaction convertion:", err, ctx.txModel)
 		}
 
-		ctx.UpdateAppDataClient(&tx.Data)
+		if err := ctx.UpdateAppDataClient(&tx.Data); err != nil {
+			tester.Fatal("could not update tx in the client:", err, ctx.txModel)
+		}
 	}
 }
 
@@ -41,27 +56,27 @@ func TestClients(tester *testing.T) {
 	txNum := 1
 	totalUsers := 3
 	for i := 1; i <= 6; i++ {
-		ctx := NewContext(100, 1, i, 1, 32, totalUsers, 2, 3, 1, false, 2)
+		ctx := newTestContext(tester, 100, 1, i, 1, 32, totalUsers, 2, 3, 1, false, 2)
 		ctx.testClientTransactions(txNum, tester)
-		ctx = NewContext(100, 1, i, 2, 32, totalUsers, 2, 3, 1, false, 2)
+		ctx = newTestContext(tester, 100, 1, i, 2, 32, totalUsers, 2, 3, 1, false, 2)
 		ctx.testClientTransactions(txNum, tester)
 	}
 
 	txNum = 10
 	totalUsers = 3
 	for i := 1; i <= 6; i++ {
-		ctx := NewContext(100, 1, i, 1, 32, totalUsers, 2, 3, 1, false, 2)
+		ctx := newTestContext(tester, 100, 1, i, 1, 32, totalUsers, 2, 3, 1, false, 2)
 		ctx.testClientTransactions(txNum, tester)
-		ctx = NewContext(100, 1, i, 2, 32, totalUsers, 2, 3, 1, false, 2)
+		ctx = newTestContext(tester, 100, 1, i, 2, 32, totalUsers, 2, 3, 1, false, 2)
 		ctx.testClientTransactions(txNum, tester)
 	}
 
 	txNum = 10
 	totalUsers = 10
 	for i := 1; i <= 6; i++ {
-		ctx := NewContext(100, 1, i, 1, 32, totalUsers, 4, 5, 1, false, 2)
+		ctx := newTestContext(tester, 100, 1, i, 1, 32, totalUsers, 4, 5, 1, false, 2)
 		ctx.testClientTransactions(txNum, tester)
-		ctx = NewContext(100, 1, i, 2, 32, totalUsers, 4, 5, 1, false, 2)
+		ctx = newTestContext(tester, 100, 1, i, 2, 32, totalUsers, 4, 5, 1, false, 2)
 		ctx.testClientTransactions(txNum, tester)
 	}
 }
@@ -70,7 +85,7 @@ func TestPeers(tester *testing.T) {
 	txNum := 1
 	totalUsers := 3
 	for i := 1; i <= 6; i++ {
-		ctx := NewContext(100, 1, i, 1, 32, totalUsers, 2, 3, 1, false, 2)
+		ctx := newTestContext(tester, 100, 1, i, 1, 32, totalUsers, 2, 3, 1, false, 2)
 		ctx.testPeerTransactions(txNum, tester)
 		//ctx = NewContext(100, 1, i, 2, 32, totalUsers, 2, 3, 1, false)
 		//ctx.testPeerTransactions(txNum, tester)
@@ -79,66 +94,59 @@ func TestPeers(tester *testing.T) {
 	txNum = 2
 	totalUsers = 3
 	for i := 1; i <= 6; i++ {
-		ctx := NewContext(100, 1, i, 1, 32, totalUsers, 2, 3, 1, false, 2)
+		ctx := newTestContext(tester, 100, 1, i, 1, 32, totalUsers, 2, 3, 1, false, 2)
 		ctx.testPeerTransactions(txNum, tester)
-		ctx = NewContext(100, 1, i, 2, 32, totalUsers, 2, 3, 1, false, 2)
+		ctx = newTestContext(tester, 100, 1, i, 2, 32, totalUsers, 2, 3, 1, false, 2)
 		ctx.testPeerTransactions(txNum, tester)
 	}
 
 	txNum = 10
 	totalUsers = 10
 	for i := 1; i <= 6; i++ {
-		ctx := NewContext(100, 1, i, 1, 32, totalUsers, 4, 5, 1, false, 2)
+		ctx := newTestContext(tester, 100, 1, i, 1, 32, totalUsers, 4, 5, 1, false, 2)
 		ctx.testPeerTransactions(txNum, tester)
-		ctx = NewContext(100, 1, i, 2, 32, totalUsers, 4, 5, 1, false, 2)
+		ctx = newTestContext(tester, 100, 1, i, 2, 32, totalUsers, 4, 5, 1, false, 2)
 		ctx.testPeerTransactions(txNum, tester)
 	}
 }
 
-func (ctx *ExeContext) testPeerTransactions(num int, tester *testing.T) {
+func (ctx *ExeContext) testPeerTransactions(num int, tester *testing.T, opts ...Option) {
 	var txBytes []byte
-	var tx *Transaction
 	var tx1 Transaction
 
-	rand.NewSource(0)
-
-	ctxClient := NewContext(ctx.exeId+115, 1, ctx.txModel, ctx.sigContext.SigType, ctx.payloadSize, ctx.TotalUsers, ctx.AverageInputMax, ctx.AverageOutputMax, ctx.distributionType, ctx.enableIndexing, ctx.PublicKeyReuse)
-	ctxPeer := NewContext(ctx.exeId+115, 2, ctx.txModel, ctx.sigContext.SigType, ctx.payloadSize, ctx.TotalUsers, ctx.AverageInputMax, ctx.AverageOutputMax, ctx.distributionType, ctx.enableIndexing, ctx.PublicKeyReuse)
+	ctxClient := newTestContext(tester, ctx.exeId+115, 1, ctx.txModel, ctx.sigContext.SigType, ctx.payloadSize, ctx.TotalUsers, ctx.AverageInputMax, ctx.AverageOutputMax, ctx.distributionType, ctx.enableIndexing, ctx.PublicKeyReuse, opts...)
+	ctxPeer := newTestContext(tester, ctx.exeId+115, 2, ctx.txModel, ctx.sigContext.SigType, ctx.payloadSize, ctx.TotalUsers, ctx.AverageInputMax, ctx.AverageOutputMax, ctx.distributionType, ctx.enableIndexing, ctx.PublicKeyReuse, opts...)
 
 	for i := 0; i < num; i++ {
-		tx = ctxClient.RandomTransaction()
+		tx, err := ctxClient.RandomTransaction()
+		if err != nil {
+			tester.Fatal("couldn't create tx:", err, ctxClient.txModel)
+		}
 
 		txBytes = ctxClient.ToBytes(tx)
-		ok := ctxPeer.FromBytes(txBytes, &tx1)
-		if !ok {
-			tester.Fatal("couldn't parse tx:", ctxPeer.txModel, ctxPeer.sigContext)
+		if err := ctxPeer.FromBytes(txBytes, &tx1); err != nil {
+			tester.Fatal("couldn't parse tx:", err, ctxPeer.txModel, ctxPeer.sigContext)
 		}
 
-		val, err := ctxClient.VerifyIncomingTransaction(tx)
-		if !val {
-			tester.Fatal("invalid transaction convertion in the client:"+*err, ctx.txModel)
+		if err := ctxClient.VerifyIncomingTransaction(tx); err != nil {
+			tester.Fatal("invalid transaction convertion in the client:", err, ctx.txModel)
 		}
 
-		ctxClient.UpdateAppDataClient(&tx.Data)
-
-		val, err = ctxPeer.VerifyIncomingTransaction(&tx1)
-		if !val {
-			tester.Fatal("invalid transaction convertion in the peer:"+*err, ctx.txModel)
+		if err := ctxClient.UpdateAppDataClient(&tx.Data); err != nil {
+			tester.Fatal("could not update tx in the client:", err, ctx.txModel)
 		}
 
-		val, err = ctxPeer.UpdateAppDataPeer(i, &tx1)
-		if !val {
-			tester.Fatal("could not update tx in the peer:"+*err, ctx.txModel)
+		if err := ctxPeer.VerifyIncomingTransaction(&tx1); err != nil {
+			tester.Fatal("invalid transaction convertion in the peer:", err, ctx.txModel)
 		}
-		val, err = ctxPeer.InsertTxHeader(i, &tx1)
-		if !val {
-			tester.Fatal("could not insert tx header in the peer:"+*err, ctx.txModel)
+
+		if err := ctxPeer.CommitTransaction(i, &tx1); err != nil {
+			tester.Fatal("could not commit tx in the peer:", err, ctx.txModel)
 		}
 	}
 
-	val, err := ctxPeer.VerifyStoredAllTransaction()
-	if !val {
-		tester.Fatal("invalid blockchain was created:" + *err)
+	if err := ctxPeer.VerifyStoredAllTransaction(); err != nil {
+		tester.Fatal("invalid blockchain was created:", err)
 	}
 }
 
@@ -146,9 +154,9 @@ func TestPeersBatch(tester *testing.T) {
 	txNum := 10
 	totalUsers := 10
 	for i := 1; i <= 6; i++ {
-		ctx := NewContext(100, 1, i, 1, 32, totalUsers, 4, 5, 1, false, 2)
+		ctx := newTestContext(tester, 100, 1, i, 1, 32, totalUsers, 4, 5, 1, false, 2)
 		ctx.testPeerBatchTransactions(txNum, tester)
-		ctx = NewContext(100, 1, i, 2, 32, totalUsers, 4, 5, 1, false, 2)
+		ctx = newTestContext(tester, 100, 1, i, 2, 32, totalUsers, 4, 5, 1, false, 2)
 		ctx.testPeerBatchTransactions(txNum, tester)
 	}
 }
@@ -159,60 +167,51 @@ func (ctx *ExeContext) testPeerBatchTransactions(num int, tester *testing.T) {
 	tx := make([]*Transaction, batchSize)
 	tx1 := make([]Transaction, batchSize)
 
-	rand.NewSource(0)
-
-	ctxClient := NewContext(ctx.exeId+115, 1, ctx.txModel, ctx.sigContext.SigType, ctx.payloadSize, ctx.TotalUsers, ctx.AverageInputMax, ctx.AverageOutputMax, ctx.distributionType, ctx.enableIndexing, ctx.PublicKeyReuse)
-	ctxPeer := NewContext(ctx.exeId+115, 2, ctx.txModel, ctx.sigContext.SigType, ctx.payloadSize, ctx.TotalUsers, ctx.AverageInputMax, ctx.AverageOutputMax, ctx.distributionType, ctx.enableIndexing, ctx.PublicKeyReuse)
+	ctxClient := newTestContext(tester, ctx.exeId+115, 1, ctx.txModel, ctx.sigContext.SigType, ctx.payloadSize, ctx.TotalUsers, ctx.AverageInputMax, ctx.AverageOutputMax, ctx.distributionType, ctx.enableIndexing, ctx.PublicKeyReuse)
+	ctxPeer := newTestContext(tester, ctx.exeId+115, 2, ctx.txModel, ctx.sigContext.SigType, ctx.payloadSize, ctx.TotalUsers, ctx.AverageInputMax, ctx.AverageOutputMax, ctx.distributionType, ctx.enableIndexing, ctx.PublicKeyReuse)
 
 	for i := 0; i < num; i++ {
 
 		for j := 0; j < batchSize; j++ {
-			tx[j] = ctxClient.RandomTransaction()
+			var err error
+			tx[j], err = ctxClient.RandomTransaction()
+			if err != nil {
+				tester.Fatal("couldn't create tx:", err, ctx.txModel)
+			}
 
-			val, err := ctxClient.VerifyIncomingTransaction(tx[j])
-			if !val {
-				tester.Fatal("invalid transaction conversion in the client:"+*err, ctx.txModel)
+			if err := ctxClient.VerifyIncomingTransaction(tx[j]); err != nil {
+				tester.Fatal("invalid transaction conversion in the client:", err, ctx.txModel)
 			}
 
-			val, errM := ctxClient.UpdateAppDataClient(&tx[j].Data)
-			if !val {
-				tester.Fatal("invalid transaction conversion in the client:"+errM.Error(), ctx.txModel)
+			if err := ctxClient.UpdateAppDataClient(&tx[j].Data); err != nil {
+				tester.Fatal("invalid transaction conversion in the client:", err, ctx.txModel)
 			}
 		}
 
 		for j := 0; j < batchSize; j++ {
 			txBytes = ctxClient.ToBytes(tx[j])
-			ok := ctxPeer.FromBytes(txBytes, &tx1[j])
-			if !ok {
-				tester.Fatal("couldn't parse tx:", ctxPeer.txModel, ctxPeer.sigContext)
+			if err := ctxPeer.FromBytes(txBytes, &tx1[j]); err != nil {
+				tester.Fatal("couldn't parse tx:", err, ctxPeer.txModel, ctxPeer.sigContext)
 			}
 
-			val, err := ctxPeer.VerifyIncomingTransactionWithTemp(&tx1[j])
-			if !val {
-				tester.Fatal("invalid transaction in the peer:"+*err, ctx.txModel, i, j)
+			if err := ctxPeer.VerifyIncomingTransactionWithTemp(&tx1[j]); err != nil {
+				tester.Fatal("invalid transaction in the peer:", err, ctx.txModel, i, j)
 			}
 
-			val, err = ctxPeer.UpdateAppDataPeerToTemp(i*batchSize+j, &tx1[j])
-			if !val {
-				tester.Fatal("could not update tx in the peer:"+*err, ctx.txModel, i, j)
+			if err := ctxPeer.applyTemp(i*batchSize+j, &tx1[j]); err != nil {
+				tester.Fatal("could not update tx in the peer:", err, ctx.txModel, i, j)
 			}
 		}
 
 		for j := 0; j < batchSize; j++ {
-			val, err := ctxPeer.UpdateAppDataPeer(i*batchSize+j, &tx1[j])
-			if !val {
-				tester.Fatal("could not update tx in the peer:"+*err, ctx.txModel)
-			}
-			val, err = ctxPeer.InsertTxHeader(i*batchSize+j, &tx1[j])
-			if !val {
-				tester.Fatal("could not insert tx header in the peer:"+*err, ctx.txModel)
+			if err := ctxPeer.CommitTransaction(i*batchSize+j, &tx1[j]); err != nil {
+				tester.Fatal("could not commit tx in the peer:", err, ctx.txModel)
 			}
 		}
 	}
 
-	val, err := ctxPeer.VerifyStoredAllTransaction()
-	if !val {
-		tester.Fatal("invalid blockchain was created:", ctxPeer.txModel, *err)
+	if err := ctxPeer.VerifyStoredAllTransaction(); err != nil {
+		tester.Fatal("invalid blockchain was created:", err, ctxPeer.txModel)
 	}
 
 }
@@ -220,7 +219,6 @@ func (ctx *ExeContext) testPeerBatchTransactions(num int, tester *testing.T) {
 func testFixedTransactionTemp(txType int, sigType int32, txNum int, inSize uint8, outSize uint8, totalUsers int, payload uint16, testname string,
 	tester *testing.B, enableIndexing bool) {
 	var txBytes []byte
-	var tx *Transaction
 	var tx1 Transaction
 
 	averageTxSize := 0
@@ -228,75 +226,74 @@ func testFixedTransactionTemp(txType int, sigType int32, txNum int, inSize uint8
 	averagePrepareTime := time.Duration(0)
 	averageUTime := time.Duration(0)
 	averageHeaderTime := time.Duration(0)
-	rand.NewSource(0)
 
-	ctxClient := NewContext(100+txType, 1, txType, sigType, payload, totalUsers, 1, 3, 1, enableIndexing, 1)
-	ctxPeerTemp := NewContext(100+txType, 2, txType, sigType, payload, totalUsers, 1, 3, 1, enableIndexing, 1)
+	ctxClient := newTestContext(tester, 100+txType, 1, txType, sigType, payload, totalUsers, 1, 3, 1, enableIndexing, 1)
+	ctxPeerTemp := newTestContext(tester, 100+txType, 2, txType, sigType, payload, totalUsers, 1, 3, 1, enableIndexing, 1)
 
 	for i := 0; i < txNum; i++ {
-		tx = ctxClient.RandomTransaction()
+		tx, err := ctxClient.RandomTransaction()
+		if err != nil {
+			tester.Fatal("couldn't create tx:", err, ctxClient.txModel)
+		}
 
 		txBytes = ctxClient.ToBytes(tx)
-		ok := ctxPeerTemp.FromBytes(txBytes, &tx1)
-		if !ok {
-			tester.Fatal("couldn't parse tx:", ctxPeerTemp.txModel, ctxPeerTemp.sigContext)
+		if err := ctxPeerTemp.FromBytes(txBytes, &tx1); err != nil {
+			tester.Fatal("couldn't parse tx:", err, ctxPeerTemp.txModel, ctxPeerTemp.sigContext)
 		}
 
-		_, _ = ctxClient.VerifyIncomingTransaction(tx)
-		ok, _ = ctxClient.UpdateAppDataClient(&tx.Data)
+		_ = ctxClient.VerifyIncomingTransaction(tx)
+		_ = ctxClient.UpdateAppDataClient(&tx.Data)
 
-		_, err := ctxPeerTemp.VerifyIncomingTransactionWithTemp(&tx1)
-		ctxPeerTemp.UpdateAppDataPeerToTemp(i, &tx1)
-		//ctxPeerTemp.InsertTxHeader(i, &tx1)
+		err = ctxPeerTemp.VerifyIncomingTransactionWithTemp(&tx1)
+		ctxPeerTemp.applyTemp(i, &tx1)
 
 		if err != nil {
-			log.Fatal(i, *err)
+			log.Fatal(i, err)
 		}
 	}
 	for i := txNum; i < txNum+1; i++ {
-		tx = ctxClient.FixedTransaction(inSize, outSize)
+		tx, err := ctxClient.FixedTransaction(inSize, outSize)
+		if err != nil {
+			tester.Fatal("couldn't create tx:", err, ctxClient.txModel)
+		}
 
 		txBytes = ctxClient.ToBytes(tx)
-		ok := ctxPeerTemp.FromBytes(txBytes, &tx1)
-		if !ok {
-			tester.Fatal("couldn't parse tx:", ctxPeerTemp.txModel, ctxPeerTemp.sigContext)
+		if err := ctxPeerTemp.FromBytes(txBytes, &tx1); err != nil {
+			tester.Fatal("couldn't parse tx:", err, ctxPeerTemp.txModel, ctxPeerTemp.sigContext)
 		}
 
 		averageTxSize += len(txBytes)
 
-		_, _ = ctxClient.VerifyIncomingTransaction(tx)
-		ok, _ = ctxClient.UpdateAppDataClient(&tx.Data)
+		_ = ctxClient.VerifyIncomingTransaction(tx)
+		_ = ctxClient.UpdateAppDataClient(&tx.Data)
 
 		for trial := 0; trial < 1000; trial++ {
 			start := time.Now()
-			_, err := ctxPeerTemp.PrepareAppDataPeerWithTemps(&tx1.Data)
+			err := ctxPeerTemp.PrepareAppDataPeerWithTemps(&tx1.Data)
 			averagePrepareTime += time.Since(start)
 			if err != nil {
-				errM := err.Error()
-				log.Fatal(i, errM)
+				log.Fatal(i, err)
 			}
 
 			start = time.Now()
-			ok, err1 := ctxPeerTemp.checkUniqueness(&tx1)
+			err1 := ctxPeerTemp.checkUniqueness(&tx1)
 			averageUTime += time.Since(start)
-			if !ok {
+			if err1 != nil {
 				log.Fatal(i, err1)
 			}
 
 			start = time.Now()
-			ok, err2 := ctxPeerTemp.VerifyTxHeader(&tx1.Txh, &tx1.Data)
+			err2 := ctxPeerTemp.VerifyTxHeader(&tx1.Txh, &tx1.Data)
 			averageHeaderTime += time.Since(start)
-			if !ok {
+			if err2 != nil {
 				log.Fatal(i, err2)
 			}
 		}
 
-		ctxPeerTemp.UpdateAppDataPeerToTemp(i, &tx1)
+		ctxPeerTemp.applyTemp(i, &tx1)
 	}
-	ctxClient.db.Close()
-	ctxPeerTemp.db.Close()
-	os.Remove("client" + strconv.FormatInt(int64(100+txType), 10) + ".db")
-	os.Remove("peer" + strconv.FormatInt(int64(100+txType), 10) + ".db")
+	ctxClient.Close()
+	ctxPeerTemp.Close()
 
 	file1, err2 := os.OpenFile("data"+testname+".csv", os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0600)
 	if err2 != nil {
@@ -421,7 +418,6 @@ func BenchmarkExeContext_MicroBenchmarks_payloads(tester *testing.B) {
 
 func testFixedTransactionPeer(sigType int32, txNum int, inSize uint8, outSize uint8, totalUsers int, payload uint16, tester *testing.B, enableIndexing bool) {
 	var txBytes []byte
-	var tx *Transaction
 	var tx1 Transaction
 
 	for txType := 1; txType <= 6; txType++ {
@@ -430,84 +426,81 @@ func testFixedTransactionPeer(sigType int32, txNum int, inSize uint8, outSize ui
 		averagePrepareTime := time.Duration(0)
 		averageUTime := time.Duration(0)
 		averageHeaderTime := time.Duration(0)
-		rand.NewSource(0)
 
-		ctxClient := NewContext(100+txType, 1, txType, sigType, payload, totalUsers, 1, 3, 1, enableIndexing, 1)
-		ctxPeer := NewContext(100+txType, 2, txType, sigType, payload, totalUsers, 1, 3, 1, enableIndexing, 1)
+		ctxClient := newTestContext(tester, 100+txType, 1, txType, sigType, payload, totalUsers, 1, 3, 1, enableIndexing, 1)
+		ctxPeer := newTestContext(tester, 100+txType, 2, txType, sigType, payload, totalUsers, 1, 3, 1, enableIndexing, 1)
 		//ctxPeerTemp := NewContext(100+txType, 2, txType, sigType, payload, totalUsers, 1, 3, 1, enableIndexing, 1)
 
 		for i := 0; i < txNum; i++ {
-			tx = ctxClient.RandomTransaction()
+			tx, err := ctxClient.RandomTransaction()
+			if err != nil {
+				tester.Fatal("couldn't create tx:", err, ctxClient.txModel)
+			}
 
 			txBytes = ctxClient.ToBytes(tx)
-			ok := ctxPeer.FromBytes(txBytes, &tx1)
-			if !ok {
-				tester.Fatal("couldn't parse tx:", ctxPeer.txModel, ctxPeer.sigContext)
+			if err := ctxPeer.FromBytes(txBytes, &tx1); err != nil {
+				tester.Fatal("couldn't parse tx:", err, ctxPeer.txModel, ctxPeer.sigContext)
 			}
 
-			_, err := ctxClient.VerifyIncomingTransaction(tx)
-			ok, _ = ctxClient.UpdateAppDataClient(&tx.Data)
-			if !ok {
-				tester.Fatal("could not update")
+			err = ctxClient.VerifyIncomingTransaction(tx)
+			if err := ctxClient.UpdateAppDataClient(&tx.Data); err != nil {
+				tester.Fatal("could not update", err)
 			}
 			if err != nil {
-				fmt.Println(i, *err)
+				fmt.Println(i, err)
 			}
 
-			_, err = ctxPeer.VerifyIncomingTransaction(&tx1)
+			err = ctxPeer.VerifyIncomingTransaction(&tx1)
 
-			ctxPeer.UpdateAppDataPeer(i, &tx1)
-			ctxPeer.InsertTxHeader(i, &tx1)
+			ctxPeer.CommitTransaction(i, &tx1)
 
 			if err != nil {
-				log.Fatal(i, *err)
+				log.Fatal(i, err)
 			}
 
 		}
 		for i := txNum; i < txNum+1; i++ {
-			tx = ctxClient.FixedTransaction(inSize, outSize)
+			tx, err := ctxClient.FixedTransaction(inSize, outSize)
+			if err != nil {
+				tester.Fatal("couldn't create tx:", err, ctxClient.txModel)
+			}
 
 			txBytes = ctxClient.ToBytes(tx)
-			ok := ctxPeer.FromBytes(txBytes, &tx1)
-			if !ok {
-				tester.Fatal("couldn't parse tx:", ctxPeer.txModel, ctxPeer.sigContext)
+			if err := ctxPeer.FromBytes(txBytes, &tx1); err != nil {
+				tester.Fatal("couldn't parse tx:", err, ctxPeer.txModel, ctxPeer.sigContext)
 			}
 
 			averageTxSize += len(txBytes)
 
-			ok, _ = ctxClient.UpdateAppDataClient(&tx.Data)
+			_ = ctxClient.UpdateAppDataClient(&tx.Data)
 
 			for trial := 0; trial < 1000; trial++ {
 				start := time.Now()
-				_, err := ctxPeer.PrepareAppDataPeerWithTemps(&tx1.Data)
+				err := ctxPeer.PrepareAppDataPeerWithTemps(&tx1.Data)
 				averagePrepareTime += time.Since(start)
 				if err != nil {
-					errM := err.Error()
-					log.Fatal(i, errM)
+					log.Fatal(i, err)
 				}
 
 				start = time.Now()
-				ok, err1 := ctxPeer.checkUniqueness(&tx1)
+				err1 := ctxPeer.checkUniqueness(&tx1)
 				averageUTime += time.Since(start)
-				if !ok {
+				if err1 != nil {
 					log.Fatal(i, err1)
 				}
 
 				start = time.Now()
-				ok, err2 := ctxPeer.VerifyTxHeader(&tx1.Txh, &tx1.Data)
+				err2 := ctxPeer.VerifyTxHeader(&tx1.Txh, &tx1.Data)
 				averageHeaderTime += time.Since(start)
-				if !ok {
+				if err2 != nil {
 					log.Fatal(i, err2)
 				}
 			}
-			ctxPeer.UpdateAppDataPeer(i, &tx1)
-			ctxPeer.InsertTxHeader(i, &tx1)
+			ctxPeer.CommitTransaction(i, &tx1)
 
 		}
-		ctxClient.db.Close()
-		ctxPeer.db.Close()
-		os.Remove("client" + strconv.FormatInt(int64(100+txType), 10) + ".db")
-		os.Remove("peer" + strconv.FormatInt(int64(100+txType), 10) + ".db")
+		ctxClient.Close()
+		ctxPeer.Close()
 
 		fmt.Println(txType, sigType, payload, inSize, outSize, averageTxSize, averageTxVerTime/time.Duration(1000), averagePrepareTime/time.Duration(1000),
 			averageUTime/time.Duration(1000), averageHeaderTime/time.Duration(1000), (averagePrepareTime+averageUTime+averageHeaderTime)/time.Duration(1000))
@@ -524,7 +517,6 @@ func BenchmarkExeContext_VerifyStoredAllTransactionPeers26(tester *testing.B) {
 
 func testRandomTransactionPeer(sigType int32, txNum int, totalUsers int, payload uint16, tester *testing.B, enableIndexing bool, input uint8) {
 	var txBytes []byte
-	var tx *Transaction
 	var tx1 Transaction
 	block := 0
 
@@ -538,90 +530,93 @@ func testRandomTransactionPeer(sigType int32, txNum int, totalUsers int, payload
 		averageOutSize := 0
 		averageTxSize := 0
 		averageTxVerTime := time.Duration(0)
-		rand.NewSource(0)
 
-		ctxClient := NewContext(100+txType, 1, txType, sigType, payload, totalUsers, input, 3, 1, enableIndexing, 1)
-		ctxPeer := NewContext(100+txType, 2, txType, sigType, payload, totalUsers, input, 3, 1, enableIndexing, 1)
+		ctxClient := newTestContext(tester, 100+txType, 1, txType, sigType, payload, totalUsers, input, 3, 1, enableIndexing, 1)
+		ctxPeer := newTestContext(tester, 100+txType, 2, txType, sigType, payload, totalUsers, input, 3, 1, enableIndexing, 1)
 
 		for i := 0; i < txNum; i++ {
 			block++
 
+			var tx *Transaction
+			var err error
 			if block == 500 {
-				tx = ctxClient.FixedTransaction(2, 2)
+				tx, err = ctxClient.FixedTransaction(2, 2)
 			} else {
-				tx = ctxClient.RandomTransaction()
+				tx, err = ctxClient.RandomTransaction()
+			}
+			if err != nil {
+				tester.Fatal("couldn't create tx:", err, ctxClient.txModel)
 			}
 
 			txBytes = ctxClient.ToBytes(tx)
-			ok := ctxPeer.FromBytes(txBytes, &tx1)
-			if !ok {
-				tester.Fatal("couldn't parse tx:", ctxPeer.txModel, ctxPeer.sigContext)
+			if err := ctxPeer.FromBytes(txBytes, &tx1); err != nil {
+				tester.Fatal("couldn't parse tx:", err, ctxPeer.txModel, ctxPeer.sigContext)
 			}
 
 			averageTxSize += len(txBytes)
 
-			_, err := ctxClient.VerifyIncomingTransaction(tx)
-			ok, _ = ctxClient.UpdateAppDataClient(&tx.Data)
-			if !ok {
-				tester.Fatal("could not update")
+			err = ctxClient.VerifyIncomingTransaction(tx)
+			if err := ctxClient.UpdateAppDataClient(&tx.Data); err != nil {
+				tester.Fatal("could not update", err)
 			}
 			if err != nil {
-				fmt.Println(i, *err)
+				fmt.Println(i, err)
 			}
 
 			if block == 5000 {
 				averageTxVerTime = time.Duration(0)
 				for trial := 0; trial < 1000; trial++ {
 					start := time.Now()
-					_, err = ctxPeer.VerifyIncomingTransaction(&tx1)
+					err = ctxPeer.VerifyIncomingTransaction(&tx1)
 					averageTxVerTime += time.Since(start)
 				}
 			}
 
-			_, err = ctxPeer.VerifyIncomingTransaction(&tx1)
-			ctxPeer.UpdateAppDataPeer(i, &tx1)
-			ctxPeer.InsertTxHeader(i, &tx1)
+			err = ctxPeer.VerifyIncomingTransaction(&tx1)
+			ctxPeer.CommitTransaction(i, &tx1)
 
 			if err != nil {
-				log.Fatal(i, *err)
+				log.Fatal(i, err)
 			}
 			averageInSize += len(tx1.Data.Inputs)
 			averageOutSize += len(tx1.Data.Outputs)
 
 			if block == 1000 {
-				var val bool
-				var err *string
 				start := time.Now()
 				for i := 0; i < tester.N; i++ {
-					val, err = ctxPeer.VerifyStoredAllTransaction()
+					err = ctxPeer.VerifyStoredAllTransaction()
 				}
 				ChainVerification := time.Since(start)
-				result = val
-				if err != nil || val == false {
-					tester.Fatal("verification failed:", val, ", ", *err)
+				result = err == nil
+				if err != nil {
+					tester.Fatal("verification failed:", err)
 				}
 
 				start = time.Now()
 				for i := 0; i < tester.N; i++ {
-					val, err = ctxPeer.VerifyStoredAllTransaction()
+					err = ctxPeer.VerifyStoredAllTransaction()
 				}
 				ChainVerification += time.Since(start)
-				result = val
-				if err != nil || val == false {
-					tester.Fatal("verification failed:", val, ", ", *err)
+				result = err == nil
+				if err != nil {
+					tester.Fatal("verification failed:", err)
 				}
 
 				start = time.Now()
 				for i := 0; i < tester.N; i++ {
-					val, err = ctxPeer.VerifyStoredAllTransaction()
+					err = ctxPeer.VerifyStoredAllTransaction()
 				}
 				ChainVerification += time.Since(start)
-				result = val
-				if err != nil || val == false {
-					tester.Fatal("verification failed:", val, ", ", *err)
+				result = err == nil
+				if err != nil {
+					tester.Fatal("verification failed:", err)
 				}
 
-				file, err1 := os.Open("peer" + strconv.FormatInt(int64(ctxPeer.exeId), 10) + ".db")
+				source, err1 := ctxPeer.dbSource()
+				if err1 != nil {
+					log.Fatal(err1)
+				}
+				file, err1 := os.Open(source)
 				if err1 != nil {
 					log.Fatal(err1)
 				}
@@ -646,10 +641,8 @@ func testRandomTransactionPeer(sigType int32, txNum int, totalUsers int, payload
 			}
 		}
 
-		ctxClient.db.Close()
-		ctxPeer.db.Close()
-		os.Remove("client" + strconv.FormatInt(int64(100+txType), 10) + ".db")
-		os.Remove("peer" + strconv.FormatInt(int64(100+txType), 10) + ".db")
+		ctxClient.Close()
+		ctxPeer.Close()
 	}
 }
 
@@ -680,3 +673,37 @@ func BenchmarkExeContext_VerifyStoredAllTransactionPeers5(tester *testing.B) {
 	testRandomTransactionPeer(1, txNum, totalUsers, 64, tester, true, 3)
 	testRandomTransactionPeer(2, txNum, totalUsers, 64, tester, true, 3)
 }
+
+func TestSeededWorkload(tester *testing.T) {
+	for i := 1; i <= 6; i++ {
+		for _, sigType := range []int32{1, 2, 3} {
+			if i == 5 && sigType == 3 {
+				continue
+			}
+			ctx1 := newTestContext(tester, 130, 1, i, sigType, 32, 10, 4, 5, 1, false, 2, WithSeed(7))
+			ctx2 := newTestContext(tester, 131, 1, i, sigType, 32, 10, 4, 5, 1, false, 2, WithSeed(7))
+			ctx3 := newTestContext(tester, 132, 1, i, sigType, 32, 10, 4, 5, 1, false, 2, WithSeed(8))
+			different := false
+			for j := 0; j < 10; j++ {
+				txBytes := make([][]byte, 3)
+				for k, ctx := range []*ExeContext{&ctx1, &ctx2, &ctx3} {
+					tx, err := ctx.RandomTransaction()
+					if err != nil {
+						tester.Fatal("couldn't create tx:", err, i, sigType)
+					}
+					if err = ctx.UpdateAppDataClient(&tx.Data); err != nil {
+						tester.Fatal("could not update the client:", err, i, sigType)
+					}
+					txBytes[k] = ctx.ToBytes(tx)
+				}
+				if string(txBytes[0]) != string(txBytes[1]) {
+					tester.Fatal("same seeds created different transactions:", i, sigType, j)
+				}
+				different = different || string(txBytes[0]) != string(txBytes[2])
+			}
+			if !different {
+				tester.Fatal("different seeds created the same transactions:", i, sigType)
+			}
+		}
+	}
+}
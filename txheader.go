@@ -8,11 +8,11 @@ package txhelper
 
 import (
 	"bytes"
-	"log"
+	"fmt"
 )
 
 // #cgo CFLAGS: -g -Wall
-// #cgo LDFLAGS: -lcrypto -lsecp256k1 -lexelayers
+// #cgo LDFLAGS: -lcrypto -lexelayers
 // #include <stdlib.h>
 // #include <stdint.h>
 // #include <openssl/bn.h>
@@ -25,159 +25,65 @@ type TxHeader struct {
 }
 
 // CreateTxHeader creates a transaction header
-func (ctx *ExeContext) CreateTxHeader(txh *TxHeader, data *AppData) {
-	switch ctx.txModel {
-	case 1:
-		ctx.utxoClassicTxHeader(txh, data)
-	case 2:
-		ctx.accClassicTxHeader(txh, data)
-	case 3:
-		ctx.utxoAccountableClassicTxHeader(txh, data)
-	case 4:
-		ctx.accAccountableClassicTxHeader(txh, data)
-	case 5:
-		ctx.utxoOrigamiTxHeader(txh, data)
-	case 6:
-		ctx.accOrigamiTxHeader(txh, data)
-	default:
-		log.Fatal("unknown txModel")
-	}
+func (ctx *ExeContext) CreateTxHeader(txh *TxHeader, data *AppData) error {
+	return ctx.model.CreateTxHeader(ctx, txh, data)
 }
 
 // VerifyTxHeader verifies a transaction header
-func (ctx *ExeContext) VerifyTxHeader(txh *TxHeader, data *AppData) (bool, *string) {
-	valid := false
-	var err *string
-	switch ctx.txModel {
-	case 1:
-		valid, err = ctx.verifyUtxoClassicTxHeader(txh, data)
-	case 2:
-		valid, err = ctx.verifyAccClassicTxHeader(txh, data)
-	case 3:
-		valid, err = ctx.verifyUtxoAccountableClassicTxHeader(txh, data)
-	case 4:
-		valid, err = ctx.verifyAccAccountableClassicTxHeader(txh, data)
-	case 5:
-		valid, err = ctx.verifyUtxoOrigamiTxHeader(txh, data)
-	case 6:
-		valid, err = ctx.verifyAccOrigamiTxHeader(txh, data)
-	default:
-		log.Fatal("unknown txModel")
-	}
-	return valid, err
+func (ctx *ExeContext) VerifyTxHeader(txh *TxHeader, data *AppData) error {
+	return ctx.model.VerifyTxHeader(ctx, txh, data)
 }
 
-func (ctx *ExeContext) utxoClassicTxHeader(txh *TxHeader, data *AppData) {
-	buffer := new(bytes.Buffer)
-	var keys SigKeyPair
+// signError wraps an error of the i-th signer
+func signError(i int, err error) error {
+	return newTxError("sign tx header", i, err)
+}
 
-	for i := 0; i < len(data.Inputs); i++ {
-		buffer.Write(data.Inputs[i].Header)
-	}
-	for i := 0; i < len(data.Outputs); i++ {
-		buffer.Write(data.Outputs[i].Pk)
-		buffer.WriteByte(data.Outputs[i].N)
-		buffer.Write(data.Outputs[i].Data)
-	}
+// invalidSig returns ErrInvalidSignature for the i-th signature
+func invalidSig(i int) error {
+	return newTxError("verify tx header", i, ErrInvalidSignature)
+}
 
-	if ctx.sigContext.SigType == 1 {
-		// if there are no inputs, all output owners must sign
-		if len(data.Inputs) == 0 {
-			txh.Kyber = make([]Signature, len(data.Outputs))
-			for i := 0; i < len(data.Outputs); i++ {
-				ctx.sigContext.unmarshelKeys(&keys, data.Outputs[i].u.Keys)
-				txh.Kyber[i] = ctx.sigContext.sign(&keys, buffer.Bytes())
-			}
-		} else { // otherwise, only input owners sign
-			txh.Kyber = make([]Signature, len(data.Inputs))
-			for i := 0; i < len(data.Inputs); i++ {
-				ctx.sigContext.unmarshelKeys(&keys, data.Inputs[i].u.Keys)
-				txh.Kyber[i] = ctx.sigContext.sign(&keys, buffer.Bytes())
-			}
-		}
-	}
+// malformedHeader wraps errors caused by malformed keys or signatures of the i-th signer
+func malformedHeader(i int, err error) error {
+	return newTxError("verify tx header", i, err)
+}
 
-	if ctx.sigContext.SigType == 2 {
-		// if there are no inputs, all output owners must sign
-		var sigs []Signature
-		if len(data.Inputs) == 0 {
-			sigs = make([]Signature, len(data.Outputs))
-			for i := 0; i < len(data.Outputs); i++ {
-				ctx.sigContext.unmarshelKeys(&keys, data.Outputs[i].u.Keys)
-				sigs[i] = ctx.sigContext.sign(&keys, buffer.Bytes())
-			}
-		} else { // otherwise, only input owners sign
-			sigs = make([]Signature, len(data.Inputs))
-			for i := 0; i < len(data.Inputs); i++ {
-				ctx.sigContext.unmarshelKeys(&keys, data.Inputs[i].u.Keys)
-				sigs[i] = ctx.sigContext.sign(&keys, buffer.Bytes())
-			}
+// checkOrigamiHeader compares transmitted Origami header fields with the recomputed ones and keeps the recomputed
+// fields in the header
+func (ctx *ExeContext) checkOrigamiHeader(txh *TxHeader, activityProof []byte, excessPK []byte) error {
+	if ctx.origamiHeaders {
+		if !bytes.Equal(txh.activityProof, activityProof) {
+			return malformedHeader(-1, fmt.Errorf("%w: transmitted activity proof doesn't match", ErrInvalidActivity))
+		}
+		if !bytes.Equal(txh.excessPK, excessPK) {
+			return malformedHeader(-1, fmt.Errorf("%w: transmitted excess public key doesn't match", ErrInvalidSignature))
 		}
-		txh.Kyber = make([]Signature, 1)
-		txh.Kyber[0] = ctx.sigContext.aggregateSignatures(sigs)
 	}
+	txh.activityProof = activityProof
+	txh.excessPK = excessPK
+	return nil
 }
 
-func (ctx *ExeContext) verifyUtxoClassicTxHeader(txh *TxHeader, data *AppData) (bool, *string) {
-	buffer := new(bytes.Buffer)
-	var pk Pubkey
-	var err string
-
-	for i := 0; i < len(data.Inputs); i++ {
-		buffer.Write(data.Inputs[i].Header)
-	}
-	for i := 0; i < len(data.Outputs); i++ {
-		buffer.Write(data.Outputs[i].Pk)
-		buffer.WriteByte(data.Outputs[i].N)
-		buffer.Write(data.Outputs[i].Data)
-	}
-
-	if ctx.sigContext.SigType == 1 {
-		if len(data.Inputs) == 0 {
-			for i := 0; i < len(data.Outputs); i++ {
-				ctx.sigContext.unmarshelPublicKeysFromBytes(&pk, data.Outputs[i].Pk)
-				if ctx.sigContext.verify(&pk, buffer.Bytes(), txh.Kyber[i]) == false {
-					err = "invalid sig"
-					return false, &err
-				}
-			}
-		} else {
-			for i := 0; i < len(data.Inputs); i++ {
-				ctx.sigContext.unmarshelPublicKeysFromBytes(&pk, data.Inputs[i].u.Keys[:ctx.sigContext.PkSize])
-				if ctx.sigContext.verify(&pk, buffer.Bytes(), txh.Kyber[i]) == false {
-					err = "invalid sig"
-					return false, &err
-				}
-			}
-		}
+// classicSigCount returns the number of signatures of a classic header with the given number of signers
+func (ctx *ExeContext) classicSigCount(signers int) int {
+	if ctx.sigContext.aggregates() {
+		return 1
 	}
+	return signers
+}
 
-	if ctx.sigContext.SigType == 2 {
-		var pks []Pubkey
-		if len(data.Inputs) == 0 {
-			pks = make([]Pubkey, len(data.Outputs))
-			for i := 0; i < len(data.Outputs); i++ {
-				ctx.sigContext.unmarshelPublicKeysFromBytes(&pks[i], data.Outputs[i].Pk)
-			}
-		} else {
-			pks = make([]Pubkey, len(data.Inputs))
-			for i := 0; i < len(data.Inputs); i++ {
-				ctx.sigContext.unmarshelPublicKeysFromBytes(&pks[i], data.Inputs[i].u.Keys)
-			}
-		}
-		if !ctx.sigContext.batchVerify(pks, buffer.Bytes(), txh.Kyber[0]) {
-			err = "invalid aggregate sig"
-			return false, &err
-		}
+// checkSigCount makes sure that the header has n signatures, so malformed headers can't panic
+func checkSigCount(txh *TxHeader, n int) error {
+	if len(txh.Kyber) != n {
+		return malformedHeader(-1, fmt.Errorf("%w: expected %d signatures, got %d", ErrMalformedEncoding, n, len(txh.Kyber)))
 	}
-
-	return true, nil
+	return nil
 }
 
-func (ctx *ExeContext) accClassicTxHeader(txh *TxHeader, data *AppData) {
+// classicMessage returns the message signed in classic headers, (input headers, output pk, n, data)
+func classicMessage(data *AppData) []byte {
 	buffer := new(bytes.Buffer)
-	var keys SigKeyPair
-
 	for i := 0; i < len(data.Inputs); i++ {
 		buffer.Write(data.Inputs[i].Header)
 	}
@@ -186,330 +92,193 @@ func (ctx *ExeContext) accClassicTxHeader(txh *TxHeader, data *AppData) {
 		buffer.WriteByte(data.Outputs[i].N)
 		buffer.Write(data.Outputs[i].Data)
 	}
-
-	if ctx.sigContext.SigType == 1 {
-		if len(data.Inputs) == 0 { // output owners must sign if there are no inputs
-			txh.Kyber = make([]Signature, len(data.Outputs))
-			for i := 0; i < len(data.Outputs); i++ {
-				ctx.sigContext.unmarshelKeys(&keys, data.Outputs[i].u.Keys)
-				txh.Kyber[i] = ctx.sigContext.sign(&keys, buffer.Bytes())
-			}
-		} else { // Otherwise, only input owners sign
-			txh.Kyber = make([]Signature, len(data.Inputs))
-			for i := 0; i < len(data.Inputs); i++ {
-				ctx.sigContext.unmarshelKeys(&keys, data.Inputs[i].u.Keys)
-				txh.Kyber[i] = ctx.sigContext.sign(&keys, buffer.Bytes())
-			}
-		}
-	}
-
-	if ctx.sigContext.SigType == 2 {
-		var sigs []Signature
-		if len(data.Inputs) == 0 { // output owners must sign if there are no inputs
-			sigs = make([]Signature, len(data.Outputs))
-			for i := 0; i < len(data.Outputs); i++ {
-				ctx.sigContext.unmarshelKeys(&keys, data.Outputs[i].u.Keys)
-				sigs[i] = ctx.sigContext.sign(&keys, buffer.Bytes())
-			}
-		} else { // Otherwise, only input owners sign
-			sigs = make([]Signature, len(data.Inputs))
-			for i := 0; i < len(data.Inputs); i++ {
-				ctx.sigContext.unmarshelKeys(&keys, data.Inputs[i].u.Keys)
-				sigs[i] = ctx.sigContext.sign(&keys, buffer.Bytes())
-			}
-		}
-		txh.Kyber = make([]Signature, 1)
-		txh.Kyber[0] = ctx.sigContext.aggregateSignatures(sigs)
-	}
+	return buffer.Bytes()
 }
 
-func (ctx *ExeContext) verifyAccClassicTxHeader(txh *TxHeader, data *AppData) (bool, *string) {
-	buffer := new(bytes.Buffer)
-	var pk Pubkey
-	var err string
+// classicSign signs msg with the encoded key pairs of signers. Signatures are aggregated into one signature if the
+// signature scheme supports it.
+func (ctx *ExeContext) classicSign(txh *TxHeader, signers [][]byte, msg []byte) error {
+	var keys SigKeyPair
+	var err error
 
-	for i := 0; i < len(data.Inputs); i++ {
-		buffer.Write(data.Inputs[i].Header)
+	sigs := make([]Signature, len(signers))
+	pks := make([]Pubkey, len(signers))
+	for i := 0; i < len(signers); i++ {
+		if err = ctx.sigContext.unmarshelKeys(&keys, signers[i]); err != nil {
+			return signError(i, err)
+		}
+		sigs[i], err = ctx.sigContext.sign(&keys, msg)
+		if err != nil {
+			return signError(i, err)
+		}
+		pks[i] = ctx.sigContext.getPubKey(&keys)
 	}
-	for i := 0; i < len(data.Outputs); i++ {
-		buffer.Write(data.Outputs[i].Pk)
-		buffer.WriteByte(data.Outputs[i].N)
-		buffer.Write(data.Outputs[i].Data)
+	if !ctx.sigContext.aggregates() {
+		txh.Kyber = sigs
+		return nil
 	}
+	txh.Kyber = make([]Signature, 1)
+	txh.Kyber[0], err = ctx.sigContext.aggregateSignatures(pks, msg, sigs)
+	return err
+}
 
-	if ctx.sigContext.SigType == 1 {
-		if len(data.Inputs) == 0 {
-			for i := 0; i < len(data.Outputs); i++ {
-				ctx.sigContext.unmarshelPublicKeysFromBytes(&pk, data.Outputs[i].Pk)
-				if ctx.sigContext.verify(&pk, buffer.Bytes(), txh.Kyber[i]) == false {
-					err = "invalid sig"
-					return false, &err
-				}
-			}
-
-		} else {
-			for i := 0; i < len(data.Inputs); i++ {
-				ctx.sigContext.unmarshelPublicKeysFromBytes(&pk, data.Inputs[i].u.Keys[:ctx.sigContext.PkSize])
-				if ctx.sigContext.verify(&pk, buffer.Bytes(), txh.Kyber[i]) == false {
-					err = "invalid sig"
-					return false, &err
-				}
-			}
+// classicVerify verifies the signatures of signers (encoded public keys) on msg. Headers without signatures are
+// collected to be verified with the block signature if blockSigs is set.
+func (ctx *ExeContext) classicVerify(txh *TxHeader, signers [][]byte, msg []byte) error {
+	collect := ctx.blockSigs != nil && len(txh.Kyber) == 0
+	if !collect {
+		if err := checkSigCount(txh, ctx.classicSigCount(len(signers))); err != nil {
+			return err
 		}
 	}
-	if ctx.sigContext.SigType == 2 {
-		var pks []Pubkey
-		if len(data.Inputs) == 0 {
-			pks = make([]Pubkey, len(data.Outputs))
-			for i := 0; i < len(data.Outputs); i++ {
-				ctx.sigContext.unmarshelPublicKeysFromBytes(&pks[i], data.Outputs[i].Pk)
-			}
-
-		} else {
-			pks = make([]Pubkey, len(data.Inputs))
-			for i := 0; i < len(data.Inputs); i++ {
-				ctx.sigContext.unmarshelPublicKeysFromBytes(&pks[i], data.Inputs[i].u.Keys[:ctx.sigContext.PkSize])
-			}
+	aggregated := ctx.sigContext.aggregates()
+	pks := make([]Pubkey, len(signers))
+	for i := 0; i < len(signers); i++ {
+		if err := ctx.sigContext.unmarshelPublicKeysFromBytes(&pks[i], signers[i]); err != nil {
+			return malformedHeader(i, err)
 		}
-		if !ctx.sigContext.batchVerify(pks, buffer.Bytes(), txh.Kyber[0]) {
-			err = "invalid aggregate sig"
-			return false, &err
+		if collect {
+			ctx.blockSigs.add(&pks[i], msg)
+			continue
 		}
+		if !aggregated && !ctx.sigContext.verify(&pks[i], msg, txh.Kyber[i]) {
+			return invalidSig(i)
+		}
+	}
+	if !collect && aggregated && !ctx.sigContext.batchVerify(pks, msg, txh.Kyber[0]) {
+		return invalidSig(0)
 	}
-	return true, nil
+	return nil
 }
 
-func (ctx *ExeContext) utxoAccountableClassicTxHeader(txh *TxHeader, data *AppData) {
-	buffer := new(bytes.Buffer)
-	var keys SigKeyPair
-	var sig Signature
-
-	for i := 0; i < len(data.Inputs); i++ {
-		buffer.Write(data.Inputs[i].Header)
+// inputKeys returns the encoded keys of input owners, or output owners if there are no inputs
+func inputKeys(data *AppData) [][]byte {
+	if len(data.Inputs) == 0 {
+		return outputKeys(data, 0)
 	}
-	for i := 0; i < len(data.Outputs); i++ {
-		buffer.Write(data.Outputs[i].Pk)
-		buffer.WriteByte(data.Outputs[i].N)
-		buffer.Write(data.Outputs[i].Data)
+	signers := make([][]byte, len(data.Inputs))
+	for i := 0; i < len(data.Inputs); i++ {
+		signers[i] = data.Inputs[i].u.Keys
 	}
+	return signers
+}
 
-	var sigs []Signature
-	if ctx.sigContext.SigType == 1 {
-		txh.Kyber = make([]Signature, len(data.Inputs))
-	}
-	if ctx.sigContext.SigType == 2 {
-		sigs = make([]Signature, len(data.Inputs))
+// inputPublicKeys returns the public keys of input owners, or output owners if there are no inputs
+func (ctx *ExeContext) inputPublicKeys(data *AppData) [][]byte {
+	if len(data.Inputs) == 0 {
+		return outputPublicKeys(data, 0)
 	}
+	signers := make([][]byte, len(data.Inputs))
 	for i := 0; i < len(data.Inputs); i++ {
-		if ctx.sigContext.SigType == 1 || ctx.sigContext.SigType == 2 {
-			ctx.sigContext.unmarshelKeys(&keys, data.Inputs[i].u.Keys)
-			if ctx.sigContext.SigType == 1 {
-				txh.Kyber[i] = ctx.sigContext.sign(&keys, buffer.Bytes())
-			}
-			if ctx.sigContext.SigType == 2 {
-				sigs[i] = ctx.sigContext.sign(&keys, buffer.Bytes())
-			}
-		}
-	}
-	for i := 0; i < len(data.Outputs); i++ {
-		if ctx.sigContext.SigType == 1 || ctx.sigContext.SigType == 2 {
-			found := false
-			for j := 0; j < len(data.Inputs); j++ {
-				if bytes.Equal(data.Inputs[j].u.Keys[:ctx.sigContext.PkSize],
-					data.Outputs[i].Pk) == true {
-					found = true
-					break
-				}
-			}
-			if !found {
-				ctx.sigContext.unmarshelKeys(&keys, data.Outputs[i].u.Keys)
-				sig = ctx.sigContext.sign(&keys, buffer.Bytes())
-				if ctx.sigContext.SigType == 1 {
-					txh.Kyber = append(txh.Kyber, sig)
-				}
-				if ctx.sigContext.SigType == 2 {
-					sigs = append(sigs, sig)
-				}
-			}
-		}
-	}
-	if ctx.sigContext.SigType == 2 {
-		txh.Kyber = make([]Signature, 1)
-		txh.Kyber[0] = ctx.sigContext.aggregateSignatures(sigs)
+		signers[i] = data.Inputs[i].u.Keys[:ctx.sigContext.PkSize]
 	}
+	return signers
 }
 
-func (ctx *ExeContext) verifyUtxoAccountableClassicTxHeader(txh *TxHeader, data *AppData) (bool, *string) {
-	buffer := new(bytes.Buffer)
-	var pk Pubkey
-	var err string
-
-	for i := 0; i < len(data.Inputs); i++ {
-		buffer.Write(data.Inputs[i].Header)
-	}
-	for i := 0; i < len(data.Outputs); i++ {
-		buffer.Write(data.Outputs[i].Pk)
-		buffer.WriteByte(data.Outputs[i].N)
-		buffer.Write(data.Outputs[i].Data)
+// outputKeys returns the encoded keys of output owners starting from the output from
+func outputKeys(data *AppData, from int) [][]byte {
+	signers := make([][]byte, 0, len(data.Outputs))
+	for i := from; i < len(data.Outputs); i++ {
+		signers = append(signers, data.Outputs[i].u.Keys)
 	}
+	return signers
+}
 
-	var pks []Pubkey
-	if ctx.sigContext.SigType == 2 {
-		pks = make([]Pubkey, len(data.Inputs))
+// outputPublicKeys returns the public keys of output owners starting from the output from
+func outputPublicKeys(data *AppData, from int) [][]byte {
+	signers := make([][]byte, 0, len(data.Outputs))
+	for i := from; i < len(data.Outputs); i++ {
+		signers = append(signers, data.Outputs[i].Pk)
 	}
+	return signers
+}
 
-	for i := 0; i < len(data.Inputs); i++ {
-		if ctx.sigContext.SigType == 1 || ctx.sigContext.SigType == 2 {
-			if ctx.sigContext.SigType == 1 {
-				ctx.sigContext.unmarshelPublicKeysFromBytes(&pk, data.Inputs[i].u.Keys[:ctx.sigContext.PkSize])
-				if ctx.sigContext.verify(&pk, buffer.Bytes(), txh.Kyber[i]) == false {
-					err = "invalid sig"
-					return false, &err
-				}
-			}
-			if ctx.sigContext.SigType == 2 {
-				ctx.sigContext.unmarshelPublicKeysFromBytes(&pks[i], data.Inputs[i].u.Keys[:ctx.sigContext.PkSize])
-			}
-		}
-	}
-	j := 0
-	for i := 0; i < len(data.Outputs); i++ {
-		if ctx.sigContext.SigType == 1 || ctx.sigContext.SigType == 2 {
-			found := false
-			for j := 0; j < len(data.Inputs); j++ {
-				if bytes.Equal(data.Inputs[j].u.Keys[:ctx.sigContext.PkSize], data.Outputs[i].Pk) == true {
-					found = true
-					break
-				}
-			}
-			if !found {
-				if ctx.sigContext.SigType == 1 {
-					ctx.sigContext.unmarshelPublicKeysFromBytes(&pk, data.Outputs[i].Pk)
-					if ctx.sigContext.verify(&pk, buffer.Bytes(), txh.Kyber[j+len(data.Inputs)]) == false {
-						err = "invalid sig"
-						return false, &err
-					}
-				}
-				if ctx.sigContext.SigType == 2 {
-					ctx.sigContext.unmarshelPublicKeysFromBytes(&pk, data.Outputs[i].Pk)
-					pks = append(pks, pk)
-				}
-				j++
-			}
-		}
-	}
-	if ctx.sigContext.SigType == 2 {
-		if !ctx.sigContext.batchVerify(pks, buffer.Bytes(), txh.Kyber[0]) {
-			err = "invalid aggregate sig"
-			return false, &err
-		}
-	}
-	return true, nil
+func (ctx *ExeContext) utxoClassicTxHeader(txh *TxHeader, data *AppData) error {
+	// if there are no inputs, all output owners must sign. Otherwise, only input owners sign
+	return ctx.classicSign(txh, inputKeys(data), classicMessage(data))
 }
 
-func (ctx *ExeContext) accAccountableClassicTxHeader(txh *TxHeader, data *AppData) {
-	buffer := new(bytes.Buffer)
-	var keys SigKeyPair
+func (ctx *ExeContext) verifyUtxoClassicTxHeader(txh *TxHeader, data *AppData) error {
+	return ctx.classicVerify(txh, ctx.inputPublicKeys(data), classicMessage(data))
+}
 
-	for i := 0; i < len(data.Inputs); i++ {
-		buffer.Write(data.Inputs[i].Header)
-	}
-	for i := 0; i < len(data.Outputs); i++ {
-		buffer.Write(data.Outputs[i].Pk)
-		buffer.WriteByte(data.Outputs[i].N)
-		buffer.Write(data.Outputs[i].Data)
-	}
+func (ctx *ExeContext) accClassicTxHeader(txh *TxHeader, data *AppData) error {
+	// output owners must sign if there are no inputs. Otherwise, only input owners sign
+	return ctx.classicSign(txh, inputKeys(data), classicMessage(data))
+}
 
-	var sigs []Signature
-	if ctx.sigContext.SigType == 1 {
-		txh.Kyber = make([]Signature, len(data.Outputs))
-	}
-	if ctx.sigContext.SigType == 2 {
-		sigs = make([]Signature, len(data.Outputs))
-	}
+func (ctx *ExeContext) verifyAccClassicTxHeader(txh *TxHeader, data *AppData) error {
+	return ctx.classicVerify(txh, ctx.inputPublicKeys(data), classicMessage(data))
+}
 
+func (ctx *ExeContext) utxoAccountableClassicTxHeader(txh *TxHeader, data *AppData) error {
+	// owners of new public keys sign with the input owners
+	signers := make([][]byte, 0, len(data.Inputs)+len(data.Outputs))
 	for i := 0; i < len(data.Inputs); i++ {
-		if ctx.sigContext.SigType == 1 {
-			ctx.sigContext.unmarshelKeys(&keys, data.Inputs[i].u.Keys)
-			txh.Kyber[i] = ctx.sigContext.sign(&keys, buffer.Bytes())
-		}
-		if ctx.sigContext.SigType == 2 {
-			ctx.sigContext.unmarshelKeys(&keys, data.Inputs[i].u.Keys)
-			sigs[i] = ctx.sigContext.sign(&keys, buffer.Bytes())
-		}
+		signers = append(signers, data.Inputs[i].u.Keys)
 	}
-	for i := len(data.Inputs); i < len(data.Outputs); i++ {
-		if ctx.sigContext.SigType == 1 {
-			ctx.sigContext.unmarshelKeys(&keys, data.Outputs[i].u.Keys)
-			txh.Kyber[i] = ctx.sigContext.sign(&keys, buffer.Bytes())
-		}
-		if ctx.sigContext.SigType == 2 {
-			ctx.sigContext.unmarshelKeys(&keys, data.Outputs[i].u.Keys)
-			sigs[i] = ctx.sigContext.sign(&keys, buffer.Bytes())
+	for i := 0; i < len(data.Outputs); i++ {
+		if !ctx.hasInputPK(data, data.Outputs[i].Pk) {
+			signers = append(signers, data.Outputs[i].u.Keys)
 		}
 	}
+	return ctx.classicSign(txh, signers, classicMessage(data))
+}
 
-	if ctx.sigContext.SigType == 2 {
-		txh.Kyber = make([]Signature, 1)
-		txh.Kyber[0] = ctx.sigContext.aggregateSignatures(sigs)
+// hasInputPK checks whether an input owns the public key
+func (ctx *ExeContext) hasInputPK(data *AppData, pk []byte) bool {
+	for j := 0; j < len(data.Inputs); j++ {
+		if bytes.Equal(data.Inputs[j].u.Keys[:ctx.sigContext.PkSize], pk) {
+			return true
+		}
 	}
+	return false
 }
 
-func (ctx *ExeContext) verifyAccAccountableClassicTxHeader(txh *TxHeader, data *AppData) (bool, *string) {
-	buffer := new(bytes.Buffer)
-	var pk Pubkey
-	var err string
-
+func (ctx *ExeContext) verifyUtxoAccountableClassicTxHeader(txh *TxHeader, data *AppData) error {
+	// owners of new public keys sign with the input owners
+	signers := make([][]byte, 0, len(data.Inputs)+len(data.Outputs))
 	for i := 0; i < len(data.Inputs); i++ {
-		buffer.Write(data.Inputs[i].Header)
+		signers = append(signers, data.Inputs[i].u.Keys[:ctx.sigContext.PkSize])
 	}
 	for i := 0; i < len(data.Outputs); i++ {
-		buffer.Write(data.Outputs[i].Pk)
-		buffer.WriteByte(data.Outputs[i].N)
-		buffer.Write(data.Outputs[i].Data)
+		if !ctx.hasInputPK(data, data.Outputs[i].Pk) {
+			signers = append(signers, data.Outputs[i].Pk)
+		}
 	}
+	return ctx.classicVerify(txh, signers, classicMessage(data))
+}
 
-	var pks []Pubkey
-	if ctx.sigContext.SigType == 2 {
-		pks = make([]Pubkey, len(data.Outputs))
+func (ctx *ExeContext) accAccountableClassicTxHeader(txh *TxHeader, data *AppData) error {
+	// every account has an output, and owners of new accounts sign with the input owners
+	if len(data.Outputs) < len(data.Inputs) {
+		return signError(-1, fmt.Errorf("%w: fewer outputs than inputs", ErrMalformedEncoding))
 	}
-
+	signers := make([][]byte, 0, len(data.Outputs))
 	for i := 0; i < len(data.Inputs); i++ {
-		if ctx.sigContext.SigType == 1 {
-			ctx.sigContext.unmarshelPublicKeysFromBytes(&pk, data.Inputs[i].u.Keys[:ctx.sigContext.PkSize])
-			if ctx.sigContext.verify(&pk, buffer.Bytes(), txh.Kyber[i]) == false {
-				err = "invalid sig"
-				return false, &err
-			}
-		}
-		if ctx.sigContext.SigType == 2 {
-			ctx.sigContext.unmarshelPublicKeysFromBytes(&pks[i], data.Inputs[i].u.Keys[:ctx.sigContext.PkSize])
-		}
+		signers = append(signers, data.Inputs[i].u.Keys)
 	}
-	for i := len(data.Inputs); i < len(data.Outputs); i++ {
-		if ctx.sigContext.SigType == 1 {
-			ctx.sigContext.unmarshelPublicKeysFromBytes(&pk, data.Outputs[i].Pk)
-			if ctx.sigContext.verify(&pk, buffer.Bytes(), txh.Kyber[i]) == false {
-				err = "invalid sig"
-				return false, &err
-			}
-		}
-		if ctx.sigContext.SigType == 2 {
-			ctx.sigContext.unmarshelPublicKeysFromBytes(&pks[i], data.Outputs[i].Pk)
-		}
+	signers = append(signers, outputKeys(data, len(data.Inputs))...)
+	return ctx.classicSign(txh, signers, classicMessage(data))
+}
+
+func (ctx *ExeContext) verifyAccAccountableClassicTxHeader(txh *TxHeader, data *AppData) error {
+	// every account has an output
+	if len(data.Outputs) < len(data.Inputs) {
+		return malformedHeader(-1, fmt.Errorf("%w: fewer outputs than inputs", ErrMalformedEncoding))
 	}
-	if ctx.sigContext.SigType == 2 {
-		if !ctx.sigContext.batchVerify(pks, buffer.Bytes(), txh.Kyber[0]) {
-			err = "invalid aggregate sig"
-			return false, &err
-		}
+	signers := make([][]byte, 0, len(data.Outputs))
+	for i := 0; i < len(data.Inputs); i++ {
+		signers = append(signers, data.Inputs[i].u.Keys[:ctx.sigContext.PkSize])
 	}
-	return true, nil
+	signers = append(signers, outputPublicKeys(data, len(data.Inputs))...)
+	return ctx.classicVerify(txh, signers, classicMessage(data))
 }
 
-func (ctx *ExeContext) utxoOrigamiTxHeader(txh *TxHeader, data *AppData) {
+func (ctx *ExeContext) utxoOrigamiTxHeader(txh *TxHeader, data *AppData) error {
 	buffer := new(bytes.Buffer)
 	negkeyLen := 0
+	var err error
 
 	negkeysP := make([]*SigKeyPair, len(data.Inputs))
 	keysP := make([]*SigKeyPair, len(data.Outputs))
@@ -523,45 +292,61 @@ func (ctx *ExeContext) utxoOrigamiTxHeader(txh *TxHeader, data *AppData) {
 
 	// create keys
 	for i := 0; i < len(data.Outputs); i++ {
+		if err = ctx.sigContext.unmarshelKeys(&pluskeys[i], data.Outputs[i].u.Keys); err != nil {
+			return signError(i, err)
+		}
 		if len(data.Inputs) > i && bytes.Equal(data.Inputs[i].u.Keys, data.Outputs[i].u.Keys) == true {
-			ctx.sigContext.unmarshelKeys(&pluskeys[i], data.Outputs[i].u.Keys)
-			ctx.sigContext.selfMultiplyKeyPairsdiff(&pluskeys[i], data.Outputs[i].header, data.Inputs[i].Header)
-			keysP[i] = &pluskeys[i]
+			err = ctx.sigContext.selfMultiplyKeyPairsdiff(&pluskeys[i], data.Outputs[i].header, data.Inputs[i].Header)
 		} else {
-			ctx.sigContext.unmarshelKeys(&pluskeys[i], data.Outputs[i].u.Keys)
-			ctx.sigContext.selfMultiplyKeyPairs(&pluskeys[i], data.Outputs[i].header)
-			keysP[i] = &pluskeys[i]
+			err = ctx.sigContext.selfMultiplyKeyPairs(&pluskeys[i], data.Outputs[i].header)
 		}
+		if err != nil {
+			return signError(i, err)
+		}
+		keysP[i] = &pluskeys[i]
 	}
 	for i := 0; i < len(data.Inputs); i++ {
 		if !(len(data.Outputs) > i && bytes.Equal(data.Inputs[i].u.Keys, data.Outputs[i].u.Keys) == true) {
-			ctx.sigContext.unmarshelKeys(&negkeys[i], data.Inputs[i].u.Keys)
-			ctx.sigContext.selfMultiplyKeyPairs(&negkeys[i], data.Inputs[i].Header)
+			if err = ctx.sigContext.unmarshelKeys(&negkeys[i], data.Inputs[i].u.Keys); err != nil {
+				return signError(i, err)
+			}
+			if err = ctx.sigContext.selfMultiplyKeyPairs(&negkeys[i], data.Inputs[i].Header); err != nil {
+				return signError(i, err)
+			}
 			negkeysP[negkeyLen] = &negkeys[i]
 			negkeyLen++
 		}
 	}
 
 	txh.activityProof = ctx.computeAppActivity(data) // to compute header - must be after computeOutIdentifier
-	txh.excessPK = ctx.sigContext.diffPKFromPairs(keysP, negkeysP[:negkeyLen])
+	txh.excessPK, err = ctx.sigContext.diffPKFromPairs(keysP, negkeysP[:negkeyLen])
+	if err != nil {
+		return signError(-1, err)
+	}
 
 	txh.Kyber = make([]Signature, 1)
 
 	if len(txh.activityProof) != 33 {
-		log.Fatal("error in activities")
+		return signError(-1, fmt.Errorf("%w: invalid activity size", ErrInvalidActivity))
 	}
 
-	var pk Pubkey
-	ctx.sigContext.unmarshelPublicKeysFromBytes(&pk, txh.excessPK)
-
 	buffer.Write(txh.activityProof)
 	buffer.Write(txh.excessPK)
-	txh.Kyber[0] = ctx.sigContext.diffSign(keysP, negkeysP[:negkeyLen], &pk, buffer.Bytes())
+	txh.Kyber[0], err = ctx.sigContext.diffSign(keysP, negkeysP[:negkeyLen], buffer.Bytes())
+	if err != nil {
+		return signError(0, err)
+	}
+	return nil
 }
 
-func (ctx *ExeContext) verifyUtxoOrigamiTxHeader(txh *TxHeader, data *AppData) (bool, *string) {
+func (ctx *ExeContext) verifyUtxoOrigamiTxHeader(txh *TxHeader, data *AppData) error {
 	buffer := new(bytes.Buffer)
 	negkeyLen := 0
+	var err error
+
+	if err = checkSigCount(txh, 1); err != nil {
+		return err
+	}
 
 	negkeysP := make([]*Pubkey, len(data.Inputs))
 	keysP := make([]*Pubkey, len(data.Outputs))
@@ -579,20 +364,27 @@ func (ctx *ExeContext) verifyUtxoOrigamiTxHeader(txh *TxHeader, data *AppData) (
 	//start = time.Now()
 	// create keys
 	for i := 0; i < len(data.Outputs); i++ {
+		if err = ctx.sigContext.unmarshelPublicKeysFromBytes(&pluskeys[i], data.Outputs[i].Pk); err != nil {
+			return malformedHeader(i, err)
+		}
 		if len(data.Inputs) > i && bytes.Equal(data.Inputs[i].u.Keys[:ctx.sigContext.PkSize], data.Outputs[i].Pk) == true {
-			ctx.sigContext.unmarshelPublicKeysFromBytes(&pluskeys[i], data.Outputs[i].Pk)
-			ctx.sigContext.selfMultiplyPubKeydiff(&pluskeys[i], data.Outputs[i].header, data.Inputs[i].Header)
-			keysP[i] = &pluskeys[i]
+			err = ctx.sigContext.selfMultiplyPubKeydiff(&pluskeys[i], data.Outputs[i].header, data.Inputs[i].Header)
 		} else {
-			ctx.sigContext.unmarshelPublicKeysFromBytes(&pluskeys[i], data.Outputs[i].Pk)
-			ctx.sigContext.selfMultiplyPubKey(&pluskeys[i], data.Outputs[i].header)
-			keysP[i] = &pluskeys[i]
+			err = ctx.sigContext.selfMultiplyPubKey(&pluskeys[i], data.Outputs[i].header)
+		}
+		if err != nil {
+			return malformedHeader(i, err)
 		}
+		keysP[i] = &pluskeys[i]
 	}
 	for i := 0; i < len(data.Inputs); i++ {
 		if !(len(data.Outputs) > i && bytes.Equal(data.Inputs[i].u.Keys[:ctx.sigContext.PkSize], data.Outputs[i].Pk) == true) {
-			ctx.sigContext.unmarshelPublicKeysFromBytes(&negkeys[i], data.Inputs[i].u.Keys[:ctx.sigContext.PkSize])
-			ctx.sigContext.selfMultiplyPubKey(&negkeys[i], data.Inputs[i].Header)
+			if err = ctx.sigContext.unmarshelPublicKeysFromBytes(&negkeys[i], data.Inputs[i].u.Keys[:ctx.sigContext.PkSize]); err != nil {
+				return malformedHeader(i, err)
+			}
+			if err = ctx.sigContext.selfMultiplyPubKey(&negkeys[i], data.Inputs[i].Header); err != nil {
+				return malformedHeader(i, err)
+			}
 			negkeysP[negkeyLen] = &negkeys[i]
 			negkeyLen++
 		}
@@ -601,12 +393,18 @@ func (ctx *ExeContext) verifyUtxoOrigamiTxHeader(txh *TxHeader, data *AppData) (
 	//fmt.Print((end / time.Duration(1)).Microseconds(), " ")
 
 	//start = time.Now()
-	txh.activityProof = ctx.computeAppActivity(data) // to compute header - must be after computeOutIdentifier
+	activityProof := ctx.computeAppActivity(data) // to compute header - must be after computeOutIdentifier
 	//end = time.Since(start)
 	//fmt.Print((end / time.Duration(1)).Microseconds(), " ")
 
 	//start = time.Now()
-	txh.excessPK = ctx.sigContext.diffPK(keysP, negkeysP[:negkeyLen])
+	excessPK, err := ctx.sigContext.diffPK(keysP, negkeysP[:negkeyLen])
+	if err != nil {
+		return malformedHeader(-1, err)
+	}
+	if err = ctx.checkOrigamiHeader(txh, activityProof, excessPK); err != nil {
+		return err
+	}
 	//end = time.Since(start)
 	//fmt.Print((end / time.Duration(1)).Microseconds(), " ")
 
@@ -615,20 +413,22 @@ func (ctx *ExeContext) verifyUtxoOrigamiTxHeader(txh *TxHeader, data *AppData) (
 
 	//start = time.Now()
 	var pk Pubkey
-	ctx.sigContext.unmarshelPublicKeysFromBytes(&pk, txh.excessPK)
+	if err = ctx.sigContext.unmarshelPublicKeysFromBytes(&pk, txh.excessPK); err != nil {
+		return malformedHeader(-1, err)
+	}
 	if !ctx.sigContext.verify(&pk, buffer.Bytes(), txh.Kyber[0]) {
-		err := "invalid sig"
-		return false, &err
+		return invalidSig(0)
 	}
 	//end = time.Since(start)
 	//fmt.Println((end / time.Duration(1)).Microseconds())
 
-	return true, nil
+	return nil
 }
 
-func (ctx *ExeContext) accOrigamiTxHeader(txh *TxHeader, data *AppData) {
+func (ctx *ExeContext) accOrigamiTxHeader(txh *TxHeader, data *AppData) error {
 	buf := new(bytes.Buffer)
 	var keys SigKeyPair
+	var err error
 
 	// compute header
 	for i := 0; i < len(data.Outputs); i++ {
@@ -642,7 +442,7 @@ func (ctx *ExeContext) accOrigamiTxHeader(txh *TxHeader, data *AppData) {
 		data.Inputs[i].u.UDelta = append(data.Inputs[i].u.UDelta, txh.activityProof...)
 
 		if int(data.Outputs[i].N) != len(data.Inputs[i].u.UDelta)/33 {
-			log.Fatal("invalid delta size", data.Outputs[i].Pk[:5], int(data.Outputs[i].N), len(data.Inputs[i].u.UDelta)/33)
+			return signError(i, fmt.Errorf("%w: invalid delta size", ErrInvalidActivity))
 		}
 
 		buf.Write(data.Outputs[i].Pk)
@@ -651,9 +451,12 @@ func (ctx *ExeContext) accOrigamiTxHeader(txh *TxHeader, data *AppData) {
 		buf.Write(data.Inputs[i].u.UDelta)
 		//buf.Write(data.Inputs[i].u.Wmark)
 
-		if ctx.sigContext.SigType == 1 || ctx.sigContext.SigType == 2 {
-			ctx.sigContext.unmarshelKeys(&keys, data.Inputs[i].u.Keys)
-			txh.Kyber[i] = ctx.sigContext.sign(&keys, buf.Bytes())
+		if err = ctx.sigContext.unmarshelKeys(&keys, data.Inputs[i].u.Keys); err != nil {
+			return signError(i, err)
+		}
+		txh.Kyber[i], err = ctx.sigContext.sign(&keys, buf.Bytes())
+		if err != nil {
+			return signError(i, err)
 		}
 		buf.Reset()
 	}
@@ -667,36 +470,48 @@ func (ctx *ExeContext) accOrigamiTxHeader(txh *TxHeader, data *AppData) {
 		buf.Write(data.Outputs[i].u.UDelta)
 		//buf.Write(data.Outputs[i].u.Wmark)
 
-		if ctx.sigContext.SigType == 1 || ctx.sigContext.SigType == 2 {
-			ctx.sigContext.unmarshelKeys(&keys, data.Outputs[i].u.Keys)
-			txh.Kyber[i] = ctx.sigContext.sign(&keys, buf.Bytes())
+		if err = ctx.sigContext.unmarshelKeys(&keys, data.Outputs[i].u.Keys); err != nil {
+			return signError(i, err)
+		}
+		txh.Kyber[i], err = ctx.sigContext.sign(&keys, buf.Bytes())
+		if err != nil {
+			return signError(i, err)
 		}
 		buf.Reset()
 	}
+	return nil
 }
 
-func (ctx *ExeContext) verifyAccOrigamiTxHeader(txh *TxHeader, data *AppData) (bool, *string) {
+func (ctx *ExeContext) verifyAccOrigamiTxHeader(txh *TxHeader, data *AppData) error {
 	buf := new(bytes.Buffer)
-	var pk Pubkey
-	var err string
+	var err error
 
 	// compute header
 	for i := 0; i < len(data.Outputs); i++ {
 		data.Outputs[i].header = ctx.computeOutIdentifier(data.Outputs[i].Pk, data.Outputs[i].N, data.Outputs[i].Data)
 	}
 
-	txh.activityProof = ctx.computeAppActivity(data) // to compute header - must be after computeOutIdentifier
+	activityProof := ctx.computeAppActivity(data) // to compute header - must be after computeOutIdentifier
+	if err = ctx.checkOrigamiHeader(txh, activityProof, nil); err != nil {
+		return err
+	}
 
-	var bufs [][]byte
-	var pks []Pubkey
-	if ctx.sigContext.SigType == 2 {
-		bufs = make([][]byte, len(data.Outputs))
-		pks = make([]Pubkey, len(data.Outputs))
+	// every account has an output
+	if len(data.Outputs) < len(data.Inputs) {
+		return malformedHeader(-1, fmt.Errorf("%w: fewer outputs than inputs", ErrMalformedEncoding))
+	}
+	if err = checkSigCount(txh, len(data.Outputs)); err != nil {
+		return err
 	}
+
+	// signatures are verified together if the scheme can aggregate them
+	aggregated := ctx.sigContext.aggregates()
+	bufs := make([][]byte, len(data.Outputs))
+	pks := make([]Pubkey, len(data.Outputs))
 	for i := 0; i < len(data.Inputs); i++ {
 		data.Inputs[i].u.UDelta = append(data.Inputs[i].u.UDelta, txh.activityProof...)
 		if int(data.Outputs[i].N) != len(data.Inputs[i].u.UDelta)/33 {
-			log.Fatal("invalid delta size", data.Outputs[i].Pk[:5], int(data.Outputs[i].N), len(data.Inputs[i].u.UDelta)/33)
+			return malformedHeader(i, fmt.Errorf("%w: invalid delta size", ErrInvalidActivity))
 		}
 
 		buf.Write(data.Outputs[i].Pk)
@@ -704,18 +519,14 @@ func (ctx *ExeContext) verifyAccOrigamiTxHeader(txh *TxHeader, data *AppData) (b
 		buf.Write(data.Outputs[i].Data)
 		buf.Write(data.Inputs[i].u.UDelta)
 
-		if ctx.sigContext.SigType == 1 {
-			ctx.sigContext.unmarshelPublicKeysFromBytes(&pk, data.Inputs[i].u.Keys[:ctx.sigContext.PkSize])
-			if ctx.sigContext.verify(&pk, buf.Bytes(), txh.Kyber[i]) == false {
-				err = "invalid sig"
-				return false, &err
-			}
+		if err = ctx.sigContext.unmarshelPublicKeysFromBytes(&pks[i], data.Inputs[i].u.Keys[:ctx.sigContext.PkSize]); err != nil {
+			return malformedHeader(i, err)
 		}
-		if ctx.sigContext.SigType == 2 {
-			ctx.sigContext.unmarshelPublicKeysFromBytes(&pks[i], data.Inputs[i].u.Keys[:ctx.sigContext.PkSize])
-			bufs[i] = make([]byte, buf.Len())
-			copy(bufs[i], buf.Bytes())
+		if !aggregated && !ctx.sigContext.verify(&pks[i], buf.Bytes(), txh.Kyber[i]) {
+			return invalidSig(i)
 		}
+		bufs[i] = make([]byte, buf.Len())
+		copy(bufs[i], buf.Bytes())
 		buf.Reset()
 	}
 
@@ -728,26 +539,24 @@ func (ctx *ExeContext) verifyAccOrigamiTxHeader(txh *TxHeader, data *AppData) (b
 		buf.Write(data.Outputs[i].Data)
 		buf.Write(data.Outputs[i].u.UDelta)
 
-		if ctx.sigContext.SigType == 1 {
-			ctx.sigContext.unmarshelPublicKeysFromBytes(&pk, data.Outputs[i].Pk)
-			if ctx.sigContext.verify(&pk, buf.Bytes(), txh.Kyber[i]) == false {
-				err = "invalid sig"
-				return false, &err
-			}
+		if err = ctx.sigContext.unmarshelPublicKeysFromBytes(&pks[i], data.Outputs[i].Pk); err != nil {
+			return malformedHeader(i, err)
 		}
-		if ctx.sigContext.SigType == 2 {
-			ctx.sigContext.unmarshelPublicKeysFromBytes(&pks[i], data.Outputs[i].Pk)
-			bufs[i] = make([]byte, buf.Len())
-			copy(bufs[i], buf.Bytes())
+		if !aggregated && !ctx.sigContext.verify(&pks[i], buf.Bytes(), txh.Kyber[i]) {
+			return invalidSig(i)
 		}
+		bufs[i] = make([]byte, buf.Len())
+		copy(bufs[i], buf.Bytes())
 		buf.Reset()
 	}
-	if ctx.sigContext.SigType == 2 {
-		sig := ctx.sigContext.aggregateSignatures(txh.Kyber)
+	if aggregated {
+		sig, err := ctx.sigContext.aggregateSignaturesMultipleMsg(pks, bufs, txh.Kyber)
+		if err != nil {
+			return err
+		}
 		if !ctx.sigContext.batchVerifyMultipleMsg(pks, bufs, sig) {
-			err = "invalid aggregate sig"
-			return false, &err
+			return invalidSig(-1)
 		}
 	}
-	return true, nil
+	return nil
 }
@@ -0,0 +1,105 @@
+/**********************************************************************
+ * Copyright (c) 2017 Jayamine Alupotha                               *
+ * Distributed under the MIT software license, see the accompanying   *
+ * file COPYING or http://www.opensource.org/licenses/mit-license.php.*
+ **********************************************************************/
+
+package txhelper
+
+import (
+	"golang.org/x/crypto/sha3"
+)
+
+// Transaction identifiers are SHA3-256 hashes that start with a domain tag and a zero byte, and classic ones also
+// with the transaction model, so identifiers of different models and kinds never collide:
+//
+//	classic txid:       H("txhelper/classic/txid" || 0 || model || canonical encoding without signatures)
+//	classic wtxid:      H("txhelper/classic/wtxid" || 0 || model || canonical encoding)
+//	origami UTXO txid:  H("txhelper/origami-utxo/txid" || 0 || activity proof || excess pk)
+//	origami UTXO wtxid: H("txhelper/origami-utxo/wtxid" || 0 || activity proof || excess pk || signature)
+//	origami ACC txid:   H("txhelper/origami-acc/txid" || 0 || activity proof || account pks)
+//
+// Like segwit's txid, the txid doesn't cover signatures, so it doesn't change if signatures are aggregated or
+// replaced. The wtxid covers the whole transaction and is used in block roots. Origami account transactions don't
+// have a separate witness since peers keep the signatures in the accounts, so both identifiers are the same.
+//
+// The canonical encoding is the compact encoding with length-prefixed payloads. The compact encoding leaves out the
+// lengths if payloads are constant, so hashing it would give identifiers that depend on the payload distribution.
+const (
+	classicTxIdTag      = "txhelper/classic/txid"
+	classicWTxIdTag     = "txhelper/classic/wtxid"
+	origamiUtxoTxIdTag  = "txhelper/origami-utxo/txid"
+	origamiUtxoWTxIdTag = "txhelper/origami-utxo/wtxid"
+	origamiAccTxIdTag   = "txhelper/origami-acc/txid"
+)
+
+// taggedHash hashes the parts after the domain tag
+func taggedHash(tag string, parts ...[]byte) []byte {
+	hasher := sha3.New256()
+	hasher.Write([]byte(tag))
+	hasher.Write([]byte{0})
+	for _, part := range parts {
+		hasher.Write(part)
+	}
+	return hasher.Sum(nil)
+}
+
+// GetTxHeaderIdentifier outputs the wtxid of a transaction, which is included in block roots. txBytes can be the
+// compact encoding of classic transactions if it is already known; it is used only if payloads are length-prefixed.
+func (ctx *ExeContext) GetTxHeaderIdentifier(tx *Transaction, txBytes []byte) ([]byte, error) {
+	return ctx.model.TxIdentifier(ctx, tx, txBytes)
+}
+
+// WTxID outputs the identifier of the whole transaction including signatures
+func (ctx *ExeContext) WTxID(tx *Transaction) ([]byte, error) {
+	return ctx.model.TxIdentifier(ctx, tx, nil)
+}
+
+// TxID outputs the identifier of the transaction without signatures
+func (ctx *ExeContext) TxID(tx *Transaction) ([]byte, error) {
+	return ctx.model.TxID(ctx, tx)
+}
+
+// classicTxIdentifier hashes the canonical encoding of the transaction (models 1-4), so identifiers depend neither on
+// the wire format nor on the payload distribution
+func (ctx *ExeContext) classicTxIdentifier(tx *Transaction, txBytes []byte) ([]byte, error) {
+	if txBytes == nil || !ctx.variablePayloads() {
+		txBytes = ctx.encodeCompact(tx, true)
+	}
+	return taggedHash(classicWTxIdTag, []byte{uint8(ctx.txModel)}, txBytes), nil
+}
+
+// classicTxID hashes the canonical encoding of the transaction without signatures (models 1-4)
+func (ctx *ExeContext) classicTxID(tx *Transaction) ([]byte, error) {
+	unsigned := Transaction{Data: tx.Data}
+	return taggedHash(classicTxIdTag, []byte{uint8(ctx.txModel)}, ctx.encodeCompact(&unsigned, true)), nil
+}
+
+// origamiUtxoTxIdentifier hashes the activity proof, the excess public key and the signature (model 5)
+func (ctx *ExeContext) origamiUtxoTxIdentifier(tx *Transaction) ([]byte, error) {
+	if len(tx.Txh.activityProof) != 33 || len(tx.Txh.Kyber) != 1 {
+		return nil, ErrUnverified
+	}
+	return taggedHash(origamiUtxoWTxIdTag, tx.Txh.activityProof, tx.Txh.excessPK, tx.Txh.Kyber[0]), nil
+}
+
+// origamiUtxoTxID hashes the activity proof and the excess public key (model 5)
+func (ctx *ExeContext) origamiUtxoTxID(tx *Transaction) ([]byte, error) {
+	if len(tx.Txh.activityProof) != 33 {
+		return nil, ErrUnverified
+	}
+	return taggedHash(origamiUtxoTxIdTag, tx.Txh.activityProof, tx.Txh.excessPK), nil
+}
+
+// origamiAccTxIdentifier hashes the activity proof and all account public keys (model 6)
+func (ctx *ExeContext) origamiAccTxIdentifier(tx *Transaction) ([]byte, error) {
+	if len(tx.Txh.activityProof) != 33 {
+		return nil, ErrUnverified
+	}
+	parts := make([][]byte, 0, len(tx.Data.Outputs)+1)
+	parts = append(parts, tx.Txh.activityProof)
+	for i := 0; i < len(tx.Data.Outputs); i++ {
+		parts = append(parts, tx.Data.Outputs[i].Pk)
+	}
+	return taggedHash(origamiAccTxIdTag, parts...), nil
+}
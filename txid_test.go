@@ -0,0 +1,98 @@
+package txhelper
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+
+	"golang.org/x/crypto/sha3"
+)
+
+// vectorTx returns a transaction with fixed bytes for identifier test vectors
+func vectorTx(ctx *ExeContext) *Transaction {
+	tx := &Transaction{}
+	tx.Data.Inputs = []InputData{{Header: bytes.Repeat([]byte{1}, 32)}}
+	tx.Data.Outputs = []OutputData{{Pk: bytes.Repeat([]byte{2}, int(ctx.sigContext.PkSize)), N: 1, Data: []byte{0xaa, 0xbb, 0xcc, 0xdd}}}
+	tx.Txh.Kyber = []Signature{bytes.Repeat([]byte{3}, int(ctx.sigContext.SigSize))}
+	tx.Txh.activityProof = bytes.Repeat([]byte{4}, 33)
+	tx.Txh.excessPK = bytes.Repeat([]byte{5}, int(ctx.sigContext.PkSize))
+	return tx
+}
+
+func TestTxIDVectors(tester *testing.T) {
+	vectors := map[int][2]string{
+		1: {"9e5682be22101f1bf08dfd7aa9b6dfd120fb929e420a990af3e2be42ea9ad17e", "4a19c3325bdd21c189ae3adb83d4a82c09fddecefa888a3c677f3f47c1e49097"},
+		3: {"ce675992acdc969bf2e52e4752e889bd2f196003cf09fcc16df31826af9525ba", "a169802f891c90472216d9788af3f012897e725c22bc04a9471c761976406635"},
+		5: {"aaac56953e677f8ed0df1c0cead0bb5b6c71d7c124938b22002c3ed72f234b80", "e1588dd3c89dd1fdee71df483ba36342d5883ba3774a6d742df8bec642f81113"},
+		6: {"b1640bdcca9bc61ba4190d4ff738c5f2b5421f00f4792aee86e168ea21c8e70b", "b1640bdcca9bc61ba4190d4ff738c5f2b5421f00f4792aee86e168ea21c8e70b"},
+	}
+	for txType, vector := range vectors {
+		ctx := newTestContext(tester, 220, 1, txType, 1, 4, 10, 2, 3, 1, false, 2)
+		tx := vectorTx(&ctx)
+		txid, err := ctx.TxID(tx)
+		if err != nil {
+			tester.Fatal("couldn't compute txid:", err)
+		}
+		wtxid, err := ctx.WTxID(tx)
+		if err != nil {
+			tester.Fatal("couldn't compute wtxid:", err)
+		}
+		if hex.EncodeToString(txid) != vector[0] {
+			tester.Fatal("wrong txid of model", txType, hex.EncodeToString(txid))
+		}
+		if hex.EncodeToString(wtxid) != vector[1] {
+			tester.Fatal("wrong wtxid of model", txType, hex.EncodeToString(wtxid))
+		}
+	}
+}
+
+func TestClassicWTxID(tester *testing.T) {
+	ctx := newTestContext(tester, 221, 1, 1, 1, 4, 10, 2, 3, 1, false, 2)
+	tx := vectorTx(&ctx)
+	hasher := sha3.New256()
+	hasher.Write([]byte("txhelper/classic/wtxid"))
+	hasher.Write([]byte{0, 1})
+	hasher.Write(ctx.encodeCompact(tx, true))
+	wtxid, _ := ctx.WTxID(tx)
+	if !bytes.Equal(wtxid, hasher.Sum(nil)) {
+		tester.Fatal("wtxid is not the tagged hash of the canonical encoding")
+	}
+
+	// peers with other payload distributions compute the same identifiers
+	variable := newTestContext(tester, 221, 2, 1, 1, 4, 10, 2, 3, 1, false, 2, WithPayloadDistribution(UniformPayload{Min: 1, Max: 8}))
+	if other, _ := variable.WTxID(tx); !bytes.Equal(wtxid, other) {
+		tester.Fatal("wtxid depends on the payload distribution")
+	}
+	txid, _ := ctx.TxID(tx)
+	if other, _ := variable.TxID(tx); !bytes.Equal(txid, other) {
+		tester.Fatal("txid depends on the payload distribution")
+	}
+	header, _ := ctx.GetTxHeaderIdentifier(tx, nil)
+	if !bytes.Equal(wtxid, header) {
+		tester.Fatal("block roots don't use the wtxid")
+	}
+}
+
+func TestTxIDSignatures(tester *testing.T) {
+	for _, txType := range []int{1, 2, 3, 4, 5} {
+		ctx := newTestContext(tester, 222, 1, txType, 1, 4, 10, 2, 3, 1, false, 2)
+		tx := vectorTx(&ctx)
+		txid, _ := ctx.TxID(tx)
+		wtxid, _ := ctx.WTxID(tx)
+		if len(txid) != 32 || len(wtxid) != 32 {
+			tester.Fatal("identifiers must have 32 bytes")
+		}
+		if bytes.Equal(txid, wtxid) {
+			tester.Fatal("txid and wtxid must differ for model", txType)
+		}
+		tx.Txh.Kyber = []Signature{bytes.Repeat([]byte{6}, int(ctx.sigContext.SigSize))}
+		txid2, _ := ctx.TxID(tx)
+		wtxid2, _ := ctx.WTxID(tx)
+		if !bytes.Equal(txid, txid2) {
+			tester.Fatal("txid depends on signatures for model", txType)
+		}
+		if bytes.Equal(wtxid, wtxid2) {
+			tester.Fatal("wtxid doesn't depend on signatures for model", txType)
+		}
+	}
+}
@@ -0,0 +1,397 @@
+/**********************************************************************
+ * Copyright (c) 2017 Jayamine Alupotha                               *
+ * Distributed under the MIT software license, see the accompanying   *
+ * file COPYING or http://www.opensource.org/licenses/mit-license.php.*
+ **********************************************************************/
+
+package txhelper
+
+import (
+	"fmt"
+	"sync"
+)
+
+// TxModel defines how transactions of a model are created, verified, encoded and stored by peers.
+// NewContext selects the model registered for its txType. The built-in models are
+// 1 - ClassicUTXO, 2 - ClassicACC, 3 - ClassicAUTXO, 4 - ClassicAACC, 5 - OrigamiUTXO, 6 - OrigamiACC.
+// New models can embed a built-in model and override some of its methods. Models in other packages update the peer
+// state through ExeContext.Store with the ids and identifiers of prepared inputs and outputs.
+type TxModel interface {
+	// Name returns a short name of the model
+	Name() string
+	// IsAccount tells whether the first outputs update the accounts of inputs (account-based) or not (UTXO-based)
+	IsAccount() bool
+	// IsOrigami tells whether peers keep only the current state and the activity proofs instead of full transactions
+	IsOrigami() bool
+
+	// RandomAppData creates an application data change for randomly chosen users
+	RandomAppData(ctx *ExeContext, data *AppData, inSize uint8, outSize uint8, averageSize uint16) error
+	// CreateTxHeader signs the application data change
+	CreateTxHeader(ctx *ExeContext, txh *TxHeader, data *AppData) error
+	// VerifyTxHeader verifies the header of prepared application data
+	VerifyTxHeader(ctx *ExeContext, txh *TxHeader, data *AppData) error
+
+	// Encode outputs the transaction as bytes
+	Encode(ctx *ExeContext, tx *Transaction) []byte
+	// Decode parses a transaction created by Encode
+	Decode(ctx *ExeContext, arr []byte, tx *Transaction) error
+
+	// Schema returns sql statements that create the peer tables of the model
+	Schema(ctx *ExeContext) []string
+	// Apply updates the peer state with a verified transaction
+	Apply(ctx *ExeContext, txNum int, tx *Transaction) error
+	// ApplyTemp updates the temporary peer state with a verified transaction
+	ApplyTemp(ctx *ExeContext, txNum int, tx *Transaction) error
+	// Revert restores the peer state before an applied transaction using its undo record
+	Revert(ctx *ExeContext, txn int) error
+	// StoreTxHeader saves the header of an applied transaction
+	StoreTxHeader(ctx *ExeContext, txn int, tx *Transaction) error
+	// LoadTx returns enough data of a stored transaction to compute its identifier
+	LoadTx(ctx *ExeContext, txn int) (*Transaction, error)
+	// TxIdentifier outputs the identifier of a whole transaction (wtxid), which is used in block roots
+	TxIdentifier(ctx *ExeContext, tx *Transaction, txBytes []byte) ([]byte, error)
+	// TxID outputs the identifier of a transaction without signatures (txid)
+	TxID(ctx *ExeContext, tx *Transaction) ([]byte, error)
+	// AuditChain verifies the entire stored state of a peer
+	AuditChain(ctx *ExeContext) error
+}
+
+var (
+	txModelsLock sync.RWMutex
+	txModels     = map[int]TxModel{
+		1: ClassicUTXO{},
+		2: ClassicACC{},
+		3: ClassicAUTXO{},
+		4: ClassicAACC{},
+		5: OrigamiUTXO{},
+		6: OrigamiACC{},
+	}
+)
+
+// RegisterTxModel registers a transaction model for txType, so it can be used with NewContext
+func RegisterTxModel(txType int, model TxModel) error {
+	if model == nil {
+		return fmt.Errorf("%w: nil tx model", ErrInvalidConfig)
+	}
+	txModelsLock.Lock()
+	defer txModelsLock.Unlock()
+	if _, found := txModels[txType]; found {
+		return fmt.Errorf("%w: tx model %d is already registered", ErrInvalidConfig, txType)
+	}
+	txModels[txType] = model
+	return nil
+}
+
+// GetTxModel returns the transaction model registered for txType
+func GetTxModel(txType int) (TxModel, error) {
+	txModelsLock.RLock()
+	defer txModelsLock.RUnlock()
+	model, found := txModels[txType]
+	if !found {
+		return nil, fmt.Errorf("%w: %d", ErrUnknownTxModel, txType)
+	}
+	return model, nil
+}
+
+// origamiAccounts tells whether peers store accounts with their last signatures and transactions
+func (ctx *ExeContext) origamiAccounts() bool {
+	return ctx.model.IsOrigami() && ctx.model.IsAccount()
+}
+
+// txEncoding is the byte encoding of the built-in models
+type txEncoding struct{}
+
+func (txEncoding) Encode(ctx *ExeContext, tx *Transaction) []byte {
+	return ctx.encodeTx(tx)
+}
+
+func (txEncoding) Decode(ctx *ExeContext, arr []byte, tx *Transaction) error {
+	return ctx.decodeTx(arr, tx)
+}
+
+// classicModel keeps full transactions and marks spent outputs as used (models 1-4)
+type classicModel struct {
+	txEncoding
+}
+
+func (classicModel) IsOrigami() bool {
+	return false
+}
+
+func (classicModel) Schema(ctx *ExeContext) []string {
+	return ctx.classicSchema()
+}
+
+func (classicModel) Apply(ctx *ExeContext, txNum int, tx *Transaction) error {
+	return ctx.applyClassic(tx)
+}
+
+func (classicModel) ApplyTemp(ctx *ExeContext, txNum int, tx *Transaction) error {
+	return ctx.applyClassicTemp(txNum, tx)
+}
+
+func (classicModel) Revert(ctx *ExeContext, txn int) error {
+	return ctx.revertClassic(txn)
+}
+
+func (classicModel) StoreTxHeader(ctx *ExeContext, txn int, tx *Transaction) error {
+	return ctx.insertClassicTxHeader(txn, tx)
+}
+
+func (classicModel) LoadTx(ctx *ExeContext, txn int) (*Transaction, error) {
+	tx, ok, err := ctx.getStoredTx(txn)
+	if !ok {
+		return nil, err
+	}
+	return tx, nil
+}
+
+func (classicModel) TxIdentifier(ctx *ExeContext, tx *Transaction, txBytes []byte) ([]byte, error) {
+	return ctx.classicTxIdentifier(tx, txBytes)
+}
+
+func (classicModel) TxID(ctx *ExeContext, tx *Transaction) ([]byte, error) {
+	return ctx.classicTxID(tx)
+}
+
+func (classicModel) AuditChain(ctx *ExeContext) error {
+	return ctx.verifyStoredClassic()
+}
+
+// ClassicUTXO is the classic UTXO model (txType 1)
+type ClassicUTXO struct {
+	classicModel
+}
+
+func (ClassicUTXO) Name() string {
+	return "classicUTXO"
+}
+
+func (ClassicUTXO) IsAccount() bool {
+	return false
+}
+
+func (ClassicUTXO) RandomAppData(ctx *ExeContext, data *AppData, inSize uint8, outSize uint8, averageSize uint16) error {
+	return ctx.utxoAppData(data, inSize, outSize, averageSize)
+}
+
+func (ClassicUTXO) CreateTxHeader(ctx *ExeContext, txh *TxHeader, data *AppData) error {
+	return ctx.utxoClassicTxHeader(txh, data)
+}
+
+func (ClassicUTXO) VerifyTxHeader(ctx *ExeContext, txh *TxHeader, data *AppData) error {
+	return ctx.verifyUtxoClassicTxHeader(txh, data)
+}
+
+// ClassicACC is the classic account model (txType 2)
+type ClassicACC struct {
+	classicModel
+}
+
+func (ClassicACC) Name() string {
+	return "classicACC"
+}
+
+func (ClassicACC) IsAccount() bool {
+	return true
+}
+
+func (ClassicACC) RandomAppData(ctx *ExeContext, data *AppData, inSize uint8, outSize uint8, averageSize uint16) error {
+	return ctx.accAppData(data, inSize, outSize, averageSize)
+}
+
+func (ClassicACC) CreateTxHeader(ctx *ExeContext, txh *TxHeader, data *AppData) error {
+	return ctx.accClassicTxHeader(txh, data)
+}
+
+func (ClassicACC) VerifyTxHeader(ctx *ExeContext, txh *TxHeader, data *AppData) error {
+	return ctx.verifyAccClassicTxHeader(txh, data)
+}
+
+// ClassicAUTXO is the accountable classic UTXO model (txType 3)
+type ClassicAUTXO struct {
+	classicModel
+}
+
+func (ClassicAUTXO) Name() string {
+	return "classicAUTXO"
+}
+
+func (ClassicAUTXO) IsAccount() bool {
+	return false
+}
+
+func (ClassicAUTXO) RandomAppData(ctx *ExeContext, data *AppData, inSize uint8, outSize uint8, averageSize uint16) error {
+	return ctx.utxoAppData(data, inSize, outSize, averageSize)
+}
+
+func (ClassicAUTXO) CreateTxHeader(ctx *ExeContext, txh *TxHeader, data *AppData) error {
+	return ctx.utxoAccountableClassicTxHeader(txh, data)
+}
+
+func (ClassicAUTXO) VerifyTxHeader(ctx *ExeContext, txh *TxHeader, data *AppData) error {
+	return ctx.verifyUtxoAccountableClassicTxHeader(txh, data)
+}
+
+// ClassicAACC is the accountable classic account model (txType 4)
+type ClassicAACC struct {
+	classicModel
+}
+
+func (ClassicAACC) Name() string {
+	return "classicAACC"
+}
+
+func (ClassicAACC) IsAccount() bool {
+	return true
+}
+
+func (ClassicAACC) RandomAppData(ctx *ExeContext, data *AppData, inSize uint8, outSize uint8, averageSize uint16) error {
+	return ctx.accAppData(data, inSize, outSize, averageSize)
+}
+
+func (ClassicAACC) CreateTxHeader(ctx *ExeContext, txh *TxHeader, data *AppData) error {
+	return ctx.accAccountableClassicTxHeader(txh, data)
+}
+
+func (ClassicAACC) VerifyTxHeader(ctx *ExeContext, txh *TxHeader, data *AppData) error {
+	return ctx.verifyAccAccountableClassicTxHeader(txh, data)
+}
+
+// OrigamiUTXO is the origami UTXO model (txType 5). Peers delete spent outputs and keep only aggregated headers.
+type OrigamiUTXO struct {
+	txEncoding
+}
+
+func (OrigamiUTXO) Name() string {
+	return "origamiUTXO"
+}
+
+func (OrigamiUTXO) IsAccount() bool {
+	return false
+}
+
+func (OrigamiUTXO) IsOrigami() bool {
+	return true
+}
+
+func (OrigamiUTXO) RandomAppData(ctx *ExeContext, data *AppData, inSize uint8, outSize uint8, averageSize uint16) error {
+	return ctx.utxoAppData(data, inSize, outSize, averageSize)
+}
+
+func (OrigamiUTXO) CreateTxHeader(ctx *ExeContext, txh *TxHeader, data *AppData) error {
+	return ctx.utxoOrigamiTxHeader(txh, data)
+}
+
+func (OrigamiUTXO) VerifyTxHeader(ctx *ExeContext, txh *TxHeader, data *AppData) error {
+	return ctx.verifyUtxoOrigamiTxHeader(txh, data)
+}
+
+func (OrigamiUTXO) Schema(ctx *ExeContext) []string {
+	return ctx.origamiUtxoSchema()
+}
+
+func (OrigamiUTXO) Apply(ctx *ExeContext, txNum int, tx *Transaction) error {
+	return ctx.applyOrigamiUtxo(tx)
+}
+
+func (OrigamiUTXO) ApplyTemp(ctx *ExeContext, txNum int, tx *Transaction) error {
+	return ctx.applyOrigamiUtxoTemp(txNum, tx)
+}
+
+func (OrigamiUTXO) Revert(ctx *ExeContext, txn int) error {
+	return ctx.revertOrigamiUtxo(txn)
+}
+
+func (OrigamiUTXO) StoreTxHeader(ctx *ExeContext, txn int, tx *Transaction) error {
+	return ctx.insertOrigamiUtxoTxHeader(txn, tx)
+}
+
+func (OrigamiUTXO) LoadTx(ctx *ExeContext, txn int) (*Transaction, error) {
+	tx := new(Transaction)
+	if ok, err := ctx.getTxHeader(txn, &tx.Txh); !ok {
+		return nil, err
+	}
+	return tx, nil
+}
+
+func (OrigamiUTXO) TxIdentifier(ctx *ExeContext, tx *Transaction, txBytes []byte) ([]byte, error) {
+	return ctx.origamiUtxoTxIdentifier(tx)
+}
+
+func (OrigamiUTXO) TxID(ctx *ExeContext, tx *Transaction) ([]byte, error) {
+	return ctx.origamiUtxoTxID(tx)
+}
+
+func (OrigamiUTXO) AuditChain(ctx *ExeContext) error {
+	return ctx.verifyStoredOrigamiUtxo()
+}
+
+// OrigamiACC is the origami account model (txType 6). Peers keep only the current accounts and activity proofs.
+type OrigamiACC struct {
+	txEncoding
+}
+
+func (OrigamiACC) Name() string {
+	return "origamiACC"
+}
+
+func (OrigamiACC) IsAccount() bool {
+	return true
+}
+
+func (OrigamiACC) IsOrigami() bool {
+	return true
+}
+
+func (OrigamiACC) RandomAppData(ctx *ExeContext, data *AppData, inSize uint8, outSize uint8, averageSize uint16) error {
+	return ctx.accAppData(data, inSize, outSize, averageSize)
+}
+
+func (OrigamiACC) CreateTxHeader(ctx *ExeContext, txh *TxHeader, data *AppData) error {
+	return ctx.accOrigamiTxHeader(txh, data)
+}
+
+func (OrigamiACC) VerifyTxHeader(ctx *ExeContext, txh *TxHeader, data *AppData) error {
+	return ctx.verifyAccOrigamiTxHeader(txh, data)
+}
+
+func (OrigamiACC) Schema(ctx *ExeContext) []string {
+	return ctx.origamiAccSchema()
+}
+
+func (OrigamiACC) Apply(ctx *ExeContext, txNum int, tx *Transaction) error {
+	return ctx.applyOrigamiAcc(txNum, tx)
+}
+
+func (OrigamiACC) ApplyTemp(ctx *ExeContext, txNum int, tx *Transaction) error {
+	return ctx.applyOrigamiAccTemp(txNum, tx)
+}
+
+func (OrigamiACC) Revert(ctx *ExeContext, txn int) error {
+	return ctx.revertOrigamiAcc(txn)
+}
+
+func (OrigamiACC) StoreTxHeader(ctx *ExeContext, txn int, tx *Transaction) error {
+	return ctx.insertOrigamiAccTxHeader(txn, tx)
+}
+
+func (OrigamiACC) LoadTx(ctx *ExeContext, txn int) (*Transaction, error) {
+	tx := new(Transaction)
+	if ok, err := ctx.getStoredOrigamiAccTx(txn, tx); !ok {
+		return nil, err
+	}
+	return tx, nil
+}
+
+func (OrigamiACC) TxIdentifier(ctx *ExeContext, tx *Transaction, txBytes []byte) ([]byte, error) {
+	return ctx.origamiAccTxIdentifier(tx)
+}
+
+func (OrigamiACC) TxID(ctx *ExeContext, tx *Transaction) ([]byte, error) {
+	return ctx.origamiAccTxIdentifier(tx)
+}
+
+func (OrigamiACC) AuditChain(ctx *ExeContext) error {
+	return ctx.verifyStoredOrigamiAcc()
+}
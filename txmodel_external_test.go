@@ -0,0 +1,110 @@
+package txhelper_test
+
+import (
+	"testing"
+
+	"github.com/zero-history/txhelper"
+)
+
+// storeUTXO is a model of another package that applies classic UTXO transactions through the public peer store
+type storeUTXO struct {
+	txhelper.ClassicUTXO
+}
+
+func (storeUTXO) Name() string {
+	return "storeUTXO"
+}
+
+func (storeUTXO) Apply(ctx *txhelper.ExeContext, txNum int, tx *txhelper.Transaction) error {
+	for i := range tx.Data.Inputs {
+		if err := ctx.Store().AddUsed(tx.Data.Inputs[i].ID(), 1); err != nil {
+			return err
+		}
+	}
+	for i := range tx.Data.Outputs {
+		out := &tx.Data.Outputs[i]
+		if err := ctx.Store().PutOutput(&txhelper.StoredOutput{ID: out.ID(), H: out.Header(), Pk: out.Pk, N: out.N, Data: out.Data}); err != nil {
+			return err
+		}
+	}
+	ctx.CurrentOutputs += len(tx.Data.Outputs)
+	return nil
+}
+
+// storedOutputs lists the outputs of a peer store
+func storedOutputs(tester *testing.T, ctx *txhelper.ExeContext) []txhelper.StoredOutput {
+	var outputs []txhelper.StoredOutput
+	if err := ctx.Store().ForEachOutput(func(out *txhelper.StoredOutput) error {
+		outputs = append(outputs, *out)
+		return nil
+	}); err != nil {
+		tester.Fatal("couldn't read the outputs:", err)
+	}
+	return outputs
+}
+
+func TestExternalTxModel(tester *testing.T) {
+	if err := txhelper.RegisterTxModel(103, storeUTXO{}); err != nil {
+		tester.Fatal("couldn't register the model:", err)
+	}
+	newContext := func(uType int, txType int) txhelper.ExeContext {
+		ctx, err := txhelper.NewContext(341, uType, txType, 1, 32, 10, 3, 4, 1, false, 2, txhelper.WithInMemoryDB())
+		if err != nil {
+			tester.Fatal("couldn't create the context:", err)
+		}
+		return ctx
+	}
+	client := newContext(1, 103)
+	defer client.Close()
+	peers := []txhelper.ExeContext{newContext(2, 103), newContext(2, 1)}
+	for j := range peers {
+		defer peers[j].Close()
+	}
+
+	for i := 0; i < 3; i++ {
+		var encoded [][]byte
+		for k := 0; k < 4; k++ {
+			tx, err := client.RandomTransaction()
+			if err != nil {
+				tester.Fatal("couldn't create tx:", err)
+			}
+			if err = client.UpdateAppDataClient(&tx.Data); err != nil {
+				tester.Fatal("could not update the client:", err)
+			}
+			encoded = append(encoded, client.ToBytes(tx))
+		}
+		for j := range peers {
+			txs := make([]*txhelper.Transaction, len(encoded))
+			for k := range encoded {
+				txs[k] = new(txhelper.Transaction)
+				if err := peers[j].FromBytes(encoded[k], txs[k]); err != nil {
+					tester.Fatal("couldn't parse tx:", err, j)
+				}
+			}
+			block, err := peers[j].ProposeBlock(txs)
+			if err != nil {
+				tester.Fatal("could not propose the block:", err, j)
+			}
+			if err = peers[j].CommitBlock(block); err != nil {
+				tester.Fatal("could not commit the block:", err, j)
+			}
+		}
+	}
+
+	// the model of the other package keeps the same outputs as the built-in model
+	external, builtin := storedOutputs(tester, &peers[0]), storedOutputs(tester, &peers[1])
+	if len(external) != len(builtin) || len(external) == 0 {
+		tester.Fatal("models stored different outputs:", len(external), len(builtin))
+	}
+	for i := range external {
+		if external[i].ID != builtin[i].ID || string(external[i].H) != string(builtin[i].H) || external[i].Used != builtin[i].Used {
+			tester.Fatal("models stored different outputs:", i)
+		}
+	}
+	if err := peers[0].VerifyStoredAllBlocks(); err != nil {
+		tester.Fatal("invalid chain was stored:", err)
+	}
+	if err := peers[0].RevertBlock(); err != nil {
+		tester.Fatal("could not revert the block:", err)
+	}
+}
@@ -0,0 +1,59 @@
+package txhelper
+
+import (
+	"errors"
+	"testing"
+)
+
+// countingUTXO is an experimental model that reuses the classic UTXO model and counts applied transactions
+type countingUTXO struct {
+	ClassicUTXO
+	applied *int
+}
+
+func (countingUTXO) Name() string {
+	return "countingUTXO"
+}
+
+func (m countingUTXO) Apply(ctx *ExeContext, txNum int, tx *Transaction) error {
+	*m.applied++
+	return m.ClassicUTXO.Apply(ctx, txNum, tx)
+}
+
+func TestRegisterTxModel(tester *testing.T) {
+	applied := 0
+	if err := RegisterTxModel(101, countingUTXO{applied: &applied}); err != nil {
+		tester.Fatal("couldn't register the model:", err)
+	}
+	if err := RegisterTxModel(101, ClassicACC{}); !errors.Is(err, ErrInvalidConfig) {
+		tester.Fatal("a model was registered twice:", err)
+	}
+	if err := RegisterTxModel(1, ClassicACC{}); !errors.Is(err, ErrInvalidConfig) {
+		tester.Fatal("a built-in model was replaced:", err)
+	}
+	if _, err := GetTxModel(102); !errors.Is(err, ErrUnknownTxModel) {
+		tester.Fatal("unknown model was found:", err)
+	}
+
+	ctx := newTestContext(tester, 340, 1, 101, 1, 32, 10, 4, 5, 1, false, 2)
+	ctx.testPeerTransactions(10, tester)
+	if applied != 10 {
+		tester.Fatal("the registered model was not used:", applied)
+	}
+}
+
+func TestBuiltinTxModels(tester *testing.T) {
+	names := []string{"classicUTXO", "classicACC", "classicAUTXO", "classicAACC", "origamiUTXO", "origamiACC"}
+	for i, name := range names {
+		model, err := GetTxModel(i + 1)
+		if err != nil {
+			tester.Fatal("missing built-in model:", err)
+		}
+		if model.Name() != name {
+			tester.Fatal("invalid model:", i+1, model.Name())
+		}
+		if model.IsAccount() != (i%2 == 1) || model.IsOrigami() != (i >= 4) {
+			tester.Fatal("invalid model capabilities:", name)
+		}
+	}
+}
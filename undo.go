@@ -0,0 +1,228 @@
+/**********************************************************************
+ * Copyright (c) 2017 Jayamine Alupotha                               *
+ * Distributed under the MIT software license, see the accompanying   *
+ * file COPYING or http://www.opensource.org/licenses/mit-license.php.*
+ **********************************************************************/
+
+package txhelper
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// Peers save an undo record for every applied transaction, so forks can be switched by reverting transactions and
+// blocks from the tip. A record keeps the spent or updated inputs as they were before the transaction and the ids of
+// the outputs it created.
+
+// undoRecord restores the peer state before a transaction
+type undoRecord struct {
+	inputs []StoredOutput // inputs as they were stored before the transaction
+	outIds []int          // ids of created outputs
+}
+
+// undoSchema returns the undo table of sqlite stores
+func undoSchema() string {
+	return "DROP TABLE IF EXISTS undo; " +
+		"CREATE TABLE undo(txn INTEGER PRIMARY KEY, record BLOB);"
+}
+
+// encode writes the record
+func (u *undoRecord) encode() []byte {
+	b := binary.AppendUvarint(nil, uint64(len(u.inputs)))
+	for i := range u.inputs {
+		b = append(b, u.inputs[i].encode()...)
+	}
+	return appendRecordInts(b, u.outIds)
+}
+
+// decode parses a record written by encode
+func (u *undoRecord) decode(b []byte) error {
+	r := newRecordReader(b)
+	count, err := binary.ReadUvarint(r.r)
+	if err != nil || count > uint64(len(b)) {
+		return fmt.Errorf("%w: corrupted undo record", ErrInvalidChain)
+	}
+	u.inputs = make([]StoredOutput, count)
+	for i := range u.inputs {
+		u.inputs[i].read(r)
+	}
+	u.outIds = r.ints()
+	return r.done("undo record")
+}
+
+// insertUndo saves the state that a prepared transaction is going to change
+func (ctx *ExeContext) insertUndo(txNum int, tx *Transaction) error {
+	var undo undoRecord
+	undo.inputs = make([]StoredOutput, len(tx.Data.Inputs))
+	for i := 0; i < len(tx.Data.Inputs); i++ {
+		out, err := ctx.store.OutputByID(tx.Data.Inputs[i].u.id)
+		if err != nil {
+			return newTxError("save undo input", i, err)
+		}
+		undo.inputs[i] = *out
+	}
+	first := 0
+	if ctx.origamiAccounts() { // the first outputs update the accounts of inputs
+		first = len(tx.Data.Inputs)
+	}
+	for i := first; i < len(tx.Data.Outputs); i++ {
+		undo.outIds = append(undo.outIds, tx.Data.Outputs[i].u.id)
+	}
+	return ctx.store.PutUndo(txNum, undo.encode())
+}
+
+// getUndo returns the undo record of a transaction
+func (ctx *ExeContext) getUndo(txn int) (*undoRecord, error) {
+	record, err := ctx.store.Undo(txn)
+	if err != nil {
+		return nil, err
+	}
+	undo := new(undoRecord)
+	if err = undo.decode(record); err != nil {
+		return nil, err
+	}
+	return undo, nil
+}
+
+// deleteCreatedOutputs deletes the outputs of a reverted transaction
+func (ctx *ExeContext) deleteCreatedOutputs(undo *undoRecord) error {
+	for i := 0; i < len(undo.outIds); i++ {
+		if ok, err := ctx.deletePeerOut(undo.outIds[i]); !ok {
+			return newTxError("delete output", i, err)
+		}
+	}
+	return nil
+}
+
+// revertClassic deletes outputs and marks inputs as unused again (models 1-4)
+func (ctx *ExeContext) revertClassic(txn int) error {
+	undo, err := ctx.getUndo(txn)
+	if err != nil {
+		return err
+	}
+	if err = ctx.deleteCreatedOutputs(undo); err != nil {
+		return err
+	}
+	for i := 0; i < len(undo.inputs); i++ {
+		if err = ctx.store.UpdateOutput(&undo.inputs[i]); err != nil {
+			return newTxError("restore input", i, err)
+		}
+	}
+	if ctx.model.IsAccount() {
+		ctx.CurrentUsers -= len(undo.outIds) - len(undo.inputs)
+	}
+	ctx.CurrentOutputs -= len(undo.outIds)
+	return nil
+}
+
+// revertOrigamiUtxo deletes outputs and restores the deleted inputs (model 5)
+func (ctx *ExeContext) revertOrigamiUtxo(txn int) error {
+	undo, err := ctx.getUndo(txn)
+	if err != nil {
+		return err
+	}
+	if err = ctx.deleteCreatedOutputs(undo); err != nil {
+		return err
+	}
+	for i := 0; i < len(undo.inputs); i++ {
+		if err = ctx.store.PutOutput(&undo.inputs[i]); err != nil {
+			return newTxError("restore input", i, err)
+		}
+	}
+	ctx.CurrentOutputs -= len(undo.outIds) - len(undo.inputs)
+	ctx.DeletedOutputs -= len(undo.inputs)
+	return nil
+}
+
+// revertOrigamiAcc deletes new accounts and restores updated accounts including their histories (model 6)
+func (ctx *ExeContext) revertOrigamiAcc(txn int) error {
+	undo, err := ctx.getUndo(txn)
+	if err != nil {
+		return err
+	}
+	if err = ctx.deleteCreatedOutputs(undo); err != nil {
+		return err
+	}
+	for i := 0; i < len(undo.inputs); i++ {
+		if err = ctx.store.UpdateOutput(&undo.inputs[i]); err != nil {
+			return newTxError("restore input", i, err)
+		}
+	}
+	ctx.CurrentUsers -= len(undo.outIds)
+	ctx.CurrentOutputs -= len(undo.outIds)
+	ctx.DeletedOutputs -= len(undo.inputs)
+	return nil
+}
+
+// revertTx reverts the last stored transaction
+func (ctx *ExeContext) revertTx(txn int) error {
+	if err := ctx.model.Revert(ctx, txn); err != nil {
+		return newTxError("revert transaction", txn, err)
+	}
+	if err := ctx.store.DeleteTxHeader(txn); err != nil {
+		return newTxError("revert transaction", txn, err)
+	}
+	if err := ctx.store.DeleteUndo(txn); err != nil {
+		return newTxError("revert transaction", txn, err)
+	}
+	ctx.TotalTx -= 1
+	return nil
+}
+
+// RevertTransaction restores the peer state before the last transaction in one atomic update. Transactions of committed blocks must be
+// reverted with RevertBlock.
+func (ctx *ExeContext) RevertTransaction(txn int) error {
+	if ctx.uType != 2 {
+		return ErrNotPeer
+	}
+	if txn != ctx.TotalTx-1 {
+		return newTxError("revert transaction", txn, fmt.Errorf("%w: only the last transaction can be reverted", ErrInvalidChain))
+	}
+	if ctx.TotalBlock > 0 {
+		ok, _, _, _, _, firstTxn, txCount, err := ctx.getPeerBlock(ctx.TotalBlock - 1)
+		if !ok {
+			return newTxError("get block", ctx.TotalBlock-1, err)
+		}
+		if txn < firstTxn+txCount {
+			return newTxError("revert transaction", txn, fmt.Errorf("%w: transaction is in block %d", ErrInvalidChain, ctx.TotalBlock-1))
+		}
+	}
+	defer ctx.resetTemps()
+	return ctx.atomic(func() error {
+		return ctx.revertTx(txn)
+	})
+}
+
+// RevertBlock reverts all transactions of the last block and removes it in one atomic update, so the parent block
+// becomes the tip
+func (ctx *ExeContext) RevertBlock() error {
+	if ctx.uType != 2 {
+		return ErrNotPeer
+	}
+	height := ctx.TotalBlock - 1
+	if height < 0 {
+		return newTxError("revert block", height, fmt.Errorf("%w: no blocks", ErrInvalidChain))
+	}
+	ok, _, parent, _, _, firstTxn, txCount, err := ctx.getPeerBlock(height)
+	if !ok {
+		return newTxError("get block", height, err)
+	}
+	if firstTxn+txCount != ctx.TotalTx {
+		return newTxError("revert block", height, fmt.Errorf("%w: transactions were added after the block", ErrInvalidChain))
+	}
+	defer ctx.resetTemps()
+	return ctx.atomic(func() error {
+		for i := txCount - 1; i >= 0; i-- {
+			if err := ctx.revertTx(firstTxn + i); err != nil {
+				return newTxError("revert block", height, err)
+			}
+		}
+		if err := ctx.store.DeleteBlock(height); err != nil {
+			return newTxError("revert block", height, err)
+		}
+		ctx.TotalBlock -= 1
+		ctx.lastBlockHash = parent
+		return nil
+	})
+}
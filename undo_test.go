@@ -0,0 +1,159 @@
+package txhelper
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// peerState dumps the stored outputs and the counters of a peer. Origami UTXO outputs get new ids whenever they are
+// verified, so their ids are skipped.
+func (ctx *ExeContext) peerState(tester *testing.T) string {
+	var outputs []string
+	err := ctx.store.ForEachOutput(func(out *StoredOutput) error {
+		if ctx.model.IsOrigami() && !ctx.model.IsAccount() {
+			out.ID = 0
+		}
+		outputs = append(outputs, fmt.Sprintln(out.H, out.ID, out.Pk, out.N, out.Data, out.Sig, out.Txns, out.Used))
+		return nil
+	})
+	if err != nil {
+		tester.Fatal("couldn't read outputs:", err)
+	}
+	sort.Strings(outputs)
+	var state strings.Builder
+	for _, out := range outputs {
+		state.WriteString(out)
+	}
+	fmt.Fprintln(&state, ctx.TotalTx, ctx.TotalBlock, ctx.CurrentUsers, ctx.CurrentOutputs, ctx.DeletedOutputs, ctx.lastBlockHash)
+	return state.String()
+}
+
+// testBlockFromClient creates a block of new transactions of the client and verifies it in the peer
+func testBlockFromClient(tester *testing.T, client *ExeContext, peer *ExeContext, size int) *Block {
+	txs := make([]*Transaction, size)
+	for i := 0; i < size; i++ {
+		tx, err := client.RandomTransaction()
+		if err != nil {
+			tester.Fatal("couldn't create tx:", err)
+		}
+		if err = client.VerifyIncomingTransaction(tx); err != nil {
+			tester.Fatal("invalid transaction in the client:", err)
+		}
+		if err = client.UpdateAppDataClient(&tx.Data); err != nil {
+			tester.Fatal("could not update the client:", err)
+		}
+		txs[i] = new(Transaction)
+		if err = peer.FromBytes(client.ToBytes(tx), txs[i]); err != nil {
+			tester.Fatal("couldn't parse tx:", err)
+		}
+	}
+	block, err := peer.ProposeBlock(txs)
+	if err != nil {
+		tester.Fatal("could not propose the block:", err)
+	}
+	return block
+}
+
+func TestRevertBlock(tester *testing.T) {
+	for txType := 1; txType <= 6; txType++ {
+		client := newTestContext(tester, 250, 1, txType, 1, 32, 10, 3, 4, 1, false, 2)
+		peer := newTestContext(tester, 250, 2, txType, 1, 32, 10, 3, 4, 1, false, 2)
+
+		var blocks []*Block
+		var states []string
+		for i := 0; i < 3; i++ {
+			states = append(states, peer.peerState(tester))
+			block := testBlockFromClient(tester, &client, &peer, 4)
+			if err := peer.CommitBlock(block); err != nil {
+				tester.Fatal("could not commit the block:", err, txType)
+			}
+			blocks = append(blocks, block)
+		}
+		tip := peer.peerState(tester)
+
+		// switch back to the first block and then to the same branch again
+		for i := 2; i > 0; i-- {
+			if err := peer.RevertBlock(); err != nil {
+				tester.Fatal("could not revert the block:", err, txType, i)
+			}
+			if peer.peerState(tester) != states[i] {
+				tester.Fatal("reverted state is different:", txType, i)
+			}
+			if err := peer.VerifyStoredAllBlocks(); err != nil {
+				tester.Fatal("invalid chain after reverting:", err, txType, i)
+			}
+		}
+		for i := 1; i < 3; i++ {
+			if err := peer.VerifyBlock(blocks[i]); err != nil {
+				tester.Fatal("could not verify the reverted block:", err, txType, i)
+			}
+			if err := peer.CommitBlock(blocks[i]); err != nil {
+				tester.Fatal("could not commit the reverted block:", err, txType, i)
+			}
+		}
+		if peer.peerState(tester) != tip {
+			tester.Fatal("re-committed state is different:", txType)
+		}
+		if err := peer.VerifyStoredAllBlocks(); err != nil {
+			tester.Fatal("invalid chain after re-committing:", err, txType)
+		}
+		if err := peer.RevertTransaction(peer.TotalTx - 1); !errors.Is(err, ErrInvalidChain) {
+			tester.Fatal("transaction of a block was reverted:", txType)
+		}
+	}
+}
+
+func TestRevertTransaction(tester *testing.T) {
+	for txType := 1; txType <= 6; txType++ {
+		client := newTestContext(tester, 251, 1, txType, 1, 32, 10, 3, 4, 1, false, 2)
+		peer := newTestContext(tester, 251, 2, txType, 1, 32, 10, 3, 4, 1, false, 2)
+
+		var txs []*Transaction
+		var states []string
+		for i := 0; i < 6; i++ {
+			tx, err := client.RandomTransaction()
+			if err != nil {
+				tester.Fatal("couldn't create tx:", err)
+			}
+			if err = client.VerifyIncomingTransaction(tx); err != nil {
+				tester.Fatal("invalid transaction in the client:", err)
+			}
+			if err = client.UpdateAppDataClient(&tx.Data); err != nil {
+				tester.Fatal("could not update the client:", err)
+			}
+			var received Transaction
+			if err = peer.FromBytes(client.ToBytes(tx), &received); err != nil {
+				tester.Fatal("couldn't parse tx:", err)
+			}
+			states = append(states, peer.peerState(tester))
+			if err = peer.VerifyIncomingTransaction(&received); err != nil {
+				tester.Fatal("invalid transaction:", err, txType)
+			}
+			if err = peer.CommitTransaction(i, &received); err != nil {
+				tester.Fatal("could not commit the transaction:", err, txType)
+			}
+			txs = append(txs, &received)
+		}
+		if err := peer.RevertTransaction(0); !errors.Is(err, ErrInvalidChain) {
+			tester.Fatal("a transaction before the tip was reverted:", txType)
+		}
+		for i := 5; i >= 3; i-- {
+			if err := peer.RevertTransaction(i); err != nil {
+				tester.Fatal("could not revert the transaction:", err, txType, i)
+			}
+			if peer.peerState(tester) != states[i] {
+				tester.Fatal("reverted state is different:", txType, i)
+			}
+		}
+		if err := peer.VerifyStoredAllTransaction(); err != nil {
+			tester.Fatal("invalid chain after reverting:", err, txType)
+		}
+		// reverted outputs can be spent again
+		if err := peer.VerifyIncomingTransaction(txs[3]); err != nil {
+			tester.Fatal("reverted transaction is invalid:", err, txType)
+		}
+	}
+}